@@ -0,0 +1,353 @@
+package bench
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/driver/native"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/transformer"
+
+	"github.com/bblfsh/javascript-driver/driver/normalizer"
+	"github.com/bblfsh/javascript-driver/driver/parser"
+)
+
+const (
+	fixturesDir = "../../fixtures"
+	nativeBin   = "../../build/bin/native"
+)
+
+// EnvCorpus is a directory with additional sources to benchmark, like large
+// real-world files. All files with the .js extension are used, recursively.
+const EnvCorpus = "JS_DRIVER_BENCH_DIR"
+
+// file is a source with its native AST in all forms consumed by the stages.
+type file struct {
+	name  string
+	src   string
+	nodes int
+	// native is the AST returned by the parser, json is the reply of the
+	// native driver, and pre is the AST after the preprocessing. The json is
+	// only set if the native driver is built.
+	native nodes.Node
+	json   []byte
+	pre    nodes.Node
+}
+
+// loadFiles reads and parses all bench_* fixtures and the corpus set by
+// EnvCorpus. Files are parsed by the native driver if it is not nil, and by
+// the Go parser otherwise.
+func loadFiles(b *testing.B, d driver.Native) []*file {
+	paths, err := filepath.Glob(filepath.Join(fixturesDir, "bench_*.js"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if dir := os.Getenv(EnvCorpus); dir != "" {
+		err = filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+			if err == nil && !fi.IsDir() && strings.HasSuffix(path, ".js") {
+				paths = append(paths, path)
+			}
+			return err
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	if len(paths) == 0 {
+		b.Fatal("no files found")
+	}
+	sort.Strings(paths)
+	ctx := context.Background()
+	var r *rawNative
+	if d != nil {
+		var err error
+		if r, err = startRaw(nativeBin); err != nil {
+			b.Fatal(err)
+		}
+		defer r.Close()
+	} else {
+		d = parser.NewDriver(0)
+	}
+	files := make([]*file, 0, len(paths))
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		f := &file{name: filepath.Base(path), src: string(data)}
+		if f.native, err = d.Parse(ctx, f.src); err != nil {
+			b.Fatalf("%s: %v", path, err)
+		}
+		f.nodes = count(f.native)
+		if r != nil {
+			if f.json, err = r.Reply(f.src); err != nil {
+				b.Fatalf("%s: %v", path, err)
+			}
+		}
+		if f.pre, err = preprocess(f, clone(f.native)); err != nil {
+			b.Fatalf("%s: %v", path, err)
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// rawNative is a native driver process that is used to get the replies as
+// they are sent over the wire.
+type rawNative struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out *bufio.Reader
+}
+
+func startRaw(bin string) (*rawNative, error) {
+	cmd := exec.Command(bin)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &rawNative{cmd: cmd, in: in, out: bufio.NewReader(out)}, nil
+}
+
+// Reply sends a parse request and returns the reply line.
+func (r *rawNative) Reply(src string) ([]byte, error) {
+	req := map[string]interface{}{"content": src, "Encoding": native.UTF8}
+	if err := json.NewEncoder(r.in).Encode(req); err != nil {
+		return nil, err
+	}
+	line, err := r.out.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the reply: %v", err)
+	}
+	return line, nil
+}
+
+func (r *rawNative) Close() error {
+	r.in.Close()
+	return r.cmd.Wait()
+}
+
+// count returns the number of nodes with a type in the native AST.
+func count(ast nodes.Node) int {
+	n := 0
+	nodes.WalkPreOrder(ast, func(nd nodes.Node) bool {
+		if obj, ok := nd.(nodes.Object); ok {
+			if _, ok = obj["type"]; ok {
+				n++
+			}
+		}
+		return true
+	})
+	return n
+}
+
+// clone is the same as Node.Clone, but it accepts nil elements of arrays,
+// like holes of array literals.
+func clone(n nodes.Node) nodes.Node {
+	switch n := n.(type) {
+	case nodes.Object:
+		out := make(nodes.Object, len(n))
+		for k, v := range n {
+			out[k] = clone(v)
+		}
+		return out
+	case nodes.Array:
+		out := make(nodes.Array, len(n))
+		for i, v := range n {
+			out[i] = clone(v)
+		}
+		return out
+	}
+	return n
+}
+
+func run(ast nodes.Node, list []transformer.Transformer) (nodes.Node, error) {
+	var err error
+	for _, t := range list {
+		if ast, err = t.Do(ast); err != nil {
+			return nil, err
+		}
+	}
+	return ast, nil
+}
+
+// preprocess runs the Preprocess and PreprocessCode stages.
+func preprocess(f *file, ast nodes.Node) (nodes.Node, error) {
+	ast, err := run(ast, normalizer.Preprocess)
+	if err != nil {
+		return nil, err
+	}
+	for _, ct := range normalizer.PreprocessCode {
+		if ast, err = ct.OnCode(f.src).Do(ast); err != nil {
+			return nil, err
+		}
+	}
+	return ast, nil
+}
+
+// stage is a single step of the driver.
+type stage struct {
+	name string
+	// native stages are skipped if the native driver is not built
+	native bool
+	// input returns a fresh input of the stage for a file; it is not timed
+	input func(f *file) interface{}
+	run   func(f *file, in interface{}) error
+}
+
+// newStages returns all stages; d is the native driver used by the parse
+// stage, or nil if it is not built.
+func newStages(d driver.Native) []stage {
+	return []stage{
+		{
+			name:   "parse",
+			native: true,
+			input:  func(f *file) interface{} { return nil },
+			run: func(f *file, _ interface{}) error {
+				_, err := d.Parse(context.Background(), f.src)
+				return err
+			},
+		},
+		{
+			// decoding of the reply is a part of the parse stage as well
+			name:   "json",
+			native: true,
+			input:  func(f *file) interface{} { return nil },
+			run: func(f *file, _ interface{}) error {
+				var resp struct {
+					AST interface{} `json:"ast"`
+				}
+				if err := json.Unmarshal(f.json, &resp); err != nil {
+					return err
+				}
+				_, err := nodes.ToNode(resp.AST, nil)
+				return err
+			},
+		},
+		{
+			name:  "parse-go",
+			input: func(f *file) interface{} { return nil },
+			run: func(f *file, _ interface{}) error {
+				_, err := parser.NewDriver(0).Parse(context.Background(), f.src)
+				return err
+			},
+		},
+		{
+			name:  "preprocess",
+			input: func(f *file) interface{} { return clone(f.native) },
+			run: func(f *file, in interface{}) error {
+				_, err := preprocess(f, in.(nodes.Node))
+				return err
+			},
+		},
+		{
+			name:  "annotate",
+			input: func(f *file) interface{} { return clone(f.pre) },
+			run: func(f *file, in interface{}) error {
+				_, err := run(in.(nodes.Node), normalizer.Native)
+				return err
+			},
+		},
+		{
+			name:  "normalize",
+			input: func(f *file) interface{} { return clone(f.pre) },
+			run: func(f *file, in interface{}) error {
+				_, err := run(in.(nodes.Node), normalizer.Normalize)
+				return err
+			},
+		},
+	}
+}
+
+// BenchmarkStages measures each stage of the driver separately, for each file
+// and for all files together. Inputs of the stages are prepared in advance:
+// annotation and normalization start from the preprocessed AST, as they do in
+// the driver. Besides the throughput in bytes, the throughput in native nodes
+// is reported.
+//
+// The parse stage measures the requests to the native driver, including the
+// decoding of the replies, which is measured separately by the json stage.
+// Both are skipped if the native driver is not built; the other stages then
+// start from the AST of the Go parser. The parse-go stage measures the Go
+// parser, which builds the nodes directly.
+func BenchmarkStages(b *testing.B) {
+	var d driver.Native
+	if _, err := os.Stat(nativeBin); err == nil {
+		d = native.NewDriverAt(nativeBin, native.UTF8)
+		if err = d.Start(); err != nil {
+			b.Fatal(err)
+		}
+		defer d.Close()
+	}
+	files := loadFiles(b, d)
+	for _, s := range newStages(d) {
+		s := s
+		b.Run(s.name, func(b *testing.B) {
+			if s.native && d == nil {
+				b.Skip("native driver is not built")
+			}
+			for _, f := range files {
+				f := f
+				b.Run(f.name, func(b *testing.B) {
+					benchmark(b, s, []*file{f})
+				})
+			}
+			b.Run("all", func(b *testing.B) {
+				benchmark(b, s, files)
+			})
+		})
+	}
+}
+
+func benchmark(b *testing.B, s stage, files []*file) {
+	var size, n int
+	for _, f := range files {
+		size += len(f.src)
+		n += f.nodes
+	}
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	in := make([]interface{}, len(files))
+	// the time of the stage is measured separately, since B.Elapsed is not
+	// available in the Go versions supported by the module
+	var elapsed time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for j, f := range files {
+			in[j] = s.input(f)
+		}
+		b.StartTimer()
+		start := time.Now()
+		for j, f := range files {
+			if err := s.run(f, in[j]); err != nil {
+				b.Fatalf("%s: %v", f.name, err)
+			}
+		}
+		elapsed += time.Since(start)
+	}
+	b.StopTimer()
+	if sec := elapsed.Seconds(); sec > 0 {
+		b.ReportMetric(float64(n)*float64(b.N)/sec, "nodes/s")
+	}
+}
@@ -0,0 +1,10 @@
+// Package bench contains benchmarks of the driver stages: parsing, decoding of
+// the native AST, preprocessing, annotation and normalization. Parsing and
+// decoding require the native driver to be built; the Go parser is measured
+// as well.
+//
+// Benchmarks run over all bench_* fixtures, including a large real-world file,
+// and over the sources in the directory set by JS_DRIVER_BENCH_DIR:
+//
+//	go test -run - -bench . -benchmem ./driver/bench
+package bench
@@ -0,0 +1,597 @@
+// Package bundle splits bundles produced by webpack, browserify and rollup
+// into the original modules.
+//
+// Bundlers that keep modules apart wrap each of them into a factory function
+// of a module table. Tables are found in the native AST:
+//
+//	webpack:    (function(modules) {...})({"./src/a.js": function(module, exports, __webpack_require__) {...}})
+//	            var __webpack_modules__ = ({123: (module, exports, __webpack_require__) => {...}})
+//	            (self.webpackChunk = self.webpackChunk || []).push([[1], {...}])
+//	browserify: (function e(t, n, r) {...})({1: [function(require, module, exports) {...}, {"./b": 2}]}, {}, [1])
+//
+// Parcel 1 uses the same table as browserify and is reported as such.
+//
+// Rollup hoists all modules into a single scope, thus its output has no
+// module boundaries. Such bundles are only split if they have an inline
+// source map with more than one source: top-level statements, or statements
+// of an IIFE or UMD wrapper, are grouped by the original source.
+package bundle
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/sourcemap"
+)
+
+// Formats of bundles.
+const (
+	FormatWebpack    = "webpack"
+	FormatBrowserify = "browserify"
+	FormatRollup     = "rollup"
+)
+
+// Module is a single module of a bundle.
+type Module struct {
+	// ID is a key of the module in the module table. It is empty for rollup.
+	ID string
+	// Path is the original path of the module, if known.
+	Path string
+	// Requires lists module IDs or requests passed to require, in order.
+	Requires []string
+	// Factory is the native function node wrapping the module, if any.
+	Factory nodes.Object
+	// Body lists top-level statements of the module, if there is no factory.
+	Body nodes.Array
+}
+
+// Detect finds modules of a bundle in the native AST. The source map is
+// optional and is only used for rollup bundles. It returns an empty format if
+// the AST is not a bundle.
+//
+// Module tables are only searched in top-level statements and in statements
+// of a top-level IIFE, and only tables with a marker of the bundler runtime
+// are recognized, since arrays and objects of functions are common in other
+// code.
+func Detect(ast nodes.Node, m *sourcemap.Map) (string, []Module) {
+	format, mods, _ := detect(ast, m)
+	return format, mods
+}
+
+// detect is the same as Detect, but it also returns functions that remove
+// the module tables, or the module statements, from the AST.
+func detect(ast nodes.Node, m *sourcemap.Map) (string, []Module, []func()) {
+	var (
+		format string
+		mods   []Module
+		remove []func()
+	)
+	for _, s := range topLevel(ast) {
+		stmt, _ := s.(nodes.Object)
+		if f, list, rm := table(stmt); f != "" {
+			format = f
+			mods = append(mods, list...)
+			remove = append(remove, rm)
+		}
+	}
+	if format != "" {
+		return format, mods, remove
+	}
+	if m != nil && len(m.Sources) > 1 {
+		if mods, rm := hoisted(ast, m); len(mods) != 0 {
+			return FormatRollup, mods, []func(){rm}
+		}
+	}
+	return "", nil, nil
+}
+
+// topLevel returns top-level statements of a program and statements of all
+// top-level IIFEs.
+func topLevel(ast nodes.Node) nodes.Array {
+	file, _ := ast.(nodes.Object)
+	prog, _ := file["program"].(nodes.Object)
+	body, _ := prog["body"].(nodes.Array)
+	out := append(nodes.Array{}, body...)
+	for _, s := range body {
+		stmt, _ := s.(nodes.Object)
+		if block := iifeBody(stmt); block != nil {
+			stmts, _ := block["body"].(nodes.Array)
+			out = append(out, stmts...)
+		}
+	}
+	return out
+}
+
+// table checks if a statement is a call or a declaration with a module table.
+// It returns a function that removes the table from the statement.
+func table(stmt nodes.Object) (string, []Module, func()) {
+	switch typeOf(stmt) {
+	case "ExpressionStatement":
+		expr, _ := stmt["expression"].(nodes.Object)
+		if typeOf(expr) == "UnaryExpression" {
+			// !function(){...}()
+			expr, _ = expr["argument"].(nodes.Object)
+		}
+		if typeOf(expr) == "CallExpression" {
+			return callTable(expr)
+		}
+	case "VariableDeclaration":
+		decls, _ := stmt["declarations"].(nodes.Array)
+		for _, d := range decls {
+			d, _ := d.(nodes.Object)
+			id, _ := d["id"].(nodes.Object)
+			if typeOf(id) != "Identifier" || stringOf(id["name"]) != "__webpack_modules__" {
+				continue
+			}
+			init, _ := d["init"].(nodes.Object)
+			if mods := webpackTable(init, false); mods != nil {
+				return FormatWebpack, mods, func() { d["init"] = nil }
+			}
+		}
+	}
+	return "", nil, nil
+}
+
+// callTable checks if a call passes a module table to the bundler runtime.
+func callTable(n nodes.Object) (string, []Module, func()) {
+	args, _ := n["arguments"].(nodes.Array)
+	if len(args) == 0 {
+		return "", nil, nil
+	}
+	removeArg := func(i int) func() {
+		return func() { n["arguments"] = without(args, i) }
+	}
+	arg, _ := args[0].(nodes.Object)
+	callee, _ := n["callee"].(nodes.Object)
+	if len(args) >= 3 {
+		// (function e(t, n, r) {...})({...}, cache, entries)
+		cache, _ := args[1].(nodes.Object)
+		entries, _ := args[2].(nodes.Object)
+		if typeOf(cache) == "ObjectExpression" && typeOf(entries) == "ArrayExpression" {
+			if mods := browserifyTable(arg); mods != nil {
+				return FormatBrowserify, mods, removeArg(0)
+			}
+		}
+	}
+	switch {
+	case isFunction(callee):
+		// (function(modules) {...})({...})
+		mods := webpackTable(arg, true)
+		if mods != nil && (isWebpackRuntime(callee) || namedFactories(mods)) {
+			return FormatWebpack, mods, removeArg(0)
+		}
+	case isPush(callee) && isJSONP(callee["object"]) && typeOf(arg) == "ArrayExpression":
+		// JSONP chunk: push([chunkIds, modules, runtime?])
+		elems, _ := arg["elements"].(nodes.Array)
+		if len(elems) >= 2 {
+			t, _ := elems[1].(nodes.Object)
+			if mods := webpackTable(t, true); mods != nil {
+				return FormatWebpack, mods, func() { arg["elements"] = without(elems, 1) }
+			}
+		}
+	case typeOf(callee) == "Identifier" && stringOf(callee["name"]) == "webpackJsonp" && len(args) >= 2:
+		// webpackJsonp(chunkIds, modules)
+		t, _ := args[1].(nodes.Object)
+		if mods := webpackTable(t, true); mods != nil {
+			return FormatWebpack, mods, removeArg(1)
+		}
+	}
+	return "", nil, nil
+}
+
+// without returns a copy of an array without one element.
+func without(arr nodes.Array, i int) nodes.Array {
+	out := make(nodes.Array, 0, len(arr)-1)
+	out = append(out, arr[:i]...)
+	return append(out, arr[i+1:]...)
+}
+
+// isWebpackRuntime reports if a function is the runtime of webpack: it refers
+// to __webpack_require__, or calls factories from the module table passed as
+// the first parameter, as in modules[moduleId].call(module.exports, ...).
+func isWebpackRuntime(fnc nodes.Object) bool {
+	params, _ := fnc["params"].(nodes.Array)
+	var table string
+	if len(params) != 0 {
+		if p, _ := params[0].(nodes.Object); typeOf(p) == "Identifier" {
+			table = stringOf(p["name"])
+		}
+	}
+	found := false
+	nodes.WalkPreOrder(fnc["body"], func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok || found {
+			return !found
+		}
+		switch typeOf(obj) {
+		case "Identifier":
+			found = stringOf(obj["name"]) == "__webpack_require__"
+		case "MemberExpression":
+			prop, _ := obj["property"].(nodes.Object)
+			call, _ := obj["object"].(nodes.Object)
+			if table == "" || obj["computed"] == nodes.Bool(true) || stringOf(prop["name"]) != "call" ||
+				typeOf(call) != "MemberExpression" || call["computed"] != nodes.Bool(true) {
+				break
+			}
+			id, _ := call["object"].(nodes.Object)
+			found = typeOf(id) == "Identifier" && stringOf(id["name"]) == table
+		}
+		return !found
+	})
+	return found
+}
+
+// factoryParams are names of parameters of webpack module factories.
+var factoryParams = map[string]bool{
+	"module":                              true,
+	"exports":                             true,
+	"require":                             true,
+	"__webpack_module__":                  true,
+	"__webpack_exports__":                 true,
+	"__webpack_require__":                 true,
+	"__unused_webpack_module":             true,
+	"__unused_webpack_exports":            true,
+	"__unused_webpack___webpack_module__": true,
+}
+
+// namedFactories reports if all factories of a module table take parameters
+// named as webpack names them, like (module, exports, __webpack_require__).
+func namedFactories(mods []Module) bool {
+	for _, m := range mods {
+		params, _ := m.Factory["params"].(nodes.Array)
+		for _, p := range params {
+			p, _ := p.(nodes.Object)
+			if typeOf(p) != "Identifier" || !factoryParams[stringOf(p["name"])] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isJSONP reports if the node refers to the global array of webpack chunks,
+// like window.webpackJsonp or (self.webpackChunkapp = self.webpackChunkapp || []).
+func isJSONP(n nodes.Node) bool {
+	found := false
+	nodes.WalkPreOrder(n, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok || found {
+			return !found
+		}
+		var name string
+		switch typeOf(obj) {
+		case "Identifier":
+			name = stringOf(obj["name"])
+		case "StringLiteral":
+			name = stringOf(obj["value"])
+		}
+		found = strings.HasPrefix(name, "webpackJsonp") || strings.HasPrefix(name, "webpackChunk")
+		return !found
+	})
+	return found
+}
+
+// webpackTable returns modules of a webpack module table: an object with
+// literal keys, or an array, of factory functions. It returns nil if the node
+// is not a module table.
+//
+// Tables that are not declared by name are only recognized if all factories
+// take (module, exports, require) or a prefix of them, since arrays of
+// functions are common in other code.
+func webpackTable(n nodes.Object, strict bool) []Module {
+	var mods []Module
+	switch typeOf(n) {
+	case "ObjectExpression":
+		props, _ := n["properties"].(nodes.Array)
+		for _, p := range props {
+			id, val, ok := property(p)
+			if !ok || !isFactory(val, strict) {
+				return nil
+			}
+			mods = append(mods, webpackModule(id, p.(nodes.Object), val))
+		}
+	case "ArrayExpression":
+		elems, _ := n["elements"].(nodes.Array)
+		for i, e := range elems {
+			if e == nil {
+				// ids of other chunks
+				continue
+			}
+			val, _ := e.(nodes.Object)
+			if !isFactory(val, strict) {
+				return nil
+			}
+			mods = append(mods, webpackModule(strconv.Itoa(i), nil, val))
+		}
+	}
+	return mods
+}
+
+// reComment matches paths in comments emitted by webpack before each module,
+// if output.pathinfo is enabled.
+var reComment = regexp.MustCompile(`!\*{3} (.+?) \*{3}!`)
+
+func webpackModule(id string, prop, fnc nodes.Object) Module {
+	m := Module{ID: id, Factory: fnc}
+	if isPath(id) {
+		m.Path = id
+	}
+	for _, n := range []nodes.Object{fnc, prop} {
+		if m.Path != "" || n == nil {
+			continue
+		}
+		comments, _ := n["leadingComments"].(nodes.Array)
+		for _, c := range comments {
+			c, _ := c.(nodes.Object)
+			if sub := reComment.FindStringSubmatch(stringOf(c["value"])); sub != nil {
+				m.Path = sub[1]
+			}
+		}
+	}
+	// factory(module, exports, require)
+	params, _ := fnc["params"].(nodes.Array)
+	if len(params) >= 3 {
+		if p, _ := params[2].(nodes.Object); typeOf(p) == "Identifier" {
+			m.Requires = requires(fnc["body"], stringOf(p["name"]))
+		}
+	}
+	return m
+}
+
+// browserifyTable returns modules of a browserify module table: an object
+// with literal keys of [factory, dependencies] pairs. It returns nil if the
+// node is not a module table.
+func browserifyTable(n nodes.Object) []Module {
+	if typeOf(n) != "ObjectExpression" {
+		return nil
+	}
+	props, _ := n["properties"].(nodes.Array)
+	var mods []Module
+	for _, p := range props {
+		id, val, ok := property(p)
+		if !ok || typeOf(val) != "ArrayExpression" {
+			return nil
+		}
+		elems, _ := val["elements"].(nodes.Array)
+		if len(elems) != 2 {
+			return nil
+		}
+		fnc, _ := elems[0].(nodes.Object)
+		deps, _ := elems[1].(nodes.Object)
+		if !isFunction(fnc) || typeOf(deps) != "ObjectExpression" {
+			return nil
+		}
+		m := Module{ID: id, Factory: fnc}
+		if isPath(id) {
+			m.Path = id
+		}
+		// dependencies map requests to ids, in order of the requires
+		dprops, _ := deps["properties"].(nodes.Array)
+		for _, d := range dprops {
+			if req, _, ok := property(d); ok {
+				m.Requires = append(m.Requires, req)
+			}
+		}
+		mods = append(mods, m)
+	}
+	return mods
+}
+
+// requires returns literal arguments of all calls to a require function,
+// without duplicates.
+func requires(body nodes.Node, name string) []string {
+	var (
+		out  []string
+		seen = make(map[string]bool)
+	)
+	nodes.WalkPreOrder(body, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok || typeOf(obj) != "CallExpression" {
+			return true
+		}
+		callee, _ := obj["callee"].(nodes.Object)
+		args, _ := obj["arguments"].(nodes.Array)
+		if typeOf(callee) != "Identifier" || stringOf(callee["name"]) != name || len(args) == 0 {
+			return true
+		}
+		if arg, _ := args[0].(nodes.Object); arg != nil {
+			if s, ok := literal(arg); ok && !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+		return true
+	})
+	return out
+}
+
+// hoisted splits statements of a scope-hoisted bundle by the original source.
+// It returns a function that removes the statements from the AST.
+func hoisted(ast nodes.Node, m *sourcemap.Map) ([]Module, func()) {
+	var (
+		mods []Module
+		cur  *Module
+	)
+	block, stmts := statements(ast)
+	for _, s := range stmts {
+		stmt, _ := s.(nodes.Object)
+		var src string
+		if line, col, ok := start(stmt); ok {
+			if o, ok := m.Lookup(line-1, col); ok {
+				src = o.Source
+			}
+		}
+		switch {
+		case cur == nil || (src != "" && cur.Path != "" && src != cur.Path):
+			mods = append(mods, Module{Path: src})
+			cur = &mods[len(mods)-1]
+		case cur.Path == "":
+			// statements without a mapping precede the first mapped one
+			cur.Path = src
+		}
+		cur.Body = append(cur.Body, stmt)
+	}
+	return mods, func() { block["body"] = nodes.Array{} }
+}
+
+// statements returns top-level statements of a program, or of the function
+// wrapping the whole program: an IIFE or a UMD factory. It also returns the
+// node with the statements.
+func statements(ast nodes.Node) (nodes.Object, nodes.Array) {
+	file, _ := ast.(nodes.Object)
+	prog, _ := file["program"].(nodes.Object)
+	body, _ := prog["body"].(nodes.Array)
+	if len(body) != 1 {
+		return prog, body
+	}
+	stmt, _ := body[0].(nodes.Object)
+	block := iifeBody(stmt)
+	if block == nil {
+		return prog, body
+	}
+	stmts, _ := block["body"].(nodes.Array)
+	return block, stmts
+}
+
+// iifeBody returns the body of a function wrapping a statement: an IIFE or
+// a UMD factory. It returns nil if the statement is not such a call.
+func iifeBody(stmt nodes.Object) nodes.Object {
+	var expr nodes.Object
+	switch typeOf(stmt) {
+	case "ExpressionStatement":
+		expr, _ = stmt["expression"].(nodes.Object)
+		if typeOf(expr) == "UnaryExpression" {
+			// !function(){...}()
+			expr, _ = expr["argument"].(nodes.Object)
+		}
+	case "VariableDeclaration":
+		decls, _ := stmt["declarations"].(nodes.Array)
+		if len(decls) == 1 {
+			d, _ := decls[0].(nodes.Object)
+			expr, _ = d["init"].(nodes.Object)
+		}
+	}
+	if typeOf(expr) != "CallExpression" {
+		return nil
+	}
+	fnc, _ := expr["callee"].(nodes.Object)
+	if !isFunction(fnc) {
+		return nil
+	}
+	// (function(global, factory) {...})(this, function(exports) {...})
+	args, _ := expr["arguments"].(nodes.Array)
+	for _, a := range args {
+		if a, _ := a.(nodes.Object); isFunction(a) {
+			fnc = a
+		}
+	}
+	block, _ := fnc["body"].(nodes.Object)
+	if typeOf(block) != "BlockStatement" {
+		return nil
+	}
+	return block
+}
+
+// property returns a literal key and a value of an object property.
+func property(n nodes.Node) (string, nodes.Object, bool) {
+	p, _ := n.(nodes.Object)
+	if typeOf(p) != "ObjectProperty" || p["computed"] == nodes.Bool(true) {
+		return "", nil, false
+	}
+	key, _ := p["key"].(nodes.Object)
+	val, _ := p["value"].(nodes.Object)
+	id, ok := literal(key)
+	return id, val, ok
+}
+
+// literal returns the value of a string or numeric literal.
+func literal(n nodes.Object) (string, bool) {
+	switch typeOf(n) {
+	case "StringLiteral":
+		return stringOf(n["value"]), true
+	case "NumericLiteral":
+		switch v := n["value"].(type) {
+		case nodes.Int:
+			return strconv.FormatInt(int64(v), 10), true
+		case nodes.Uint:
+			return strconv.FormatUint(uint64(v), 10), true
+		case nodes.Float:
+			return strconv.FormatFloat(float64(v), 'f', -1, 64), true
+		}
+	}
+	return "", false
+}
+
+// isPath reports if a module id is a path rather than a number or a hash.
+func isPath(id string) bool {
+	for i := 0; i < len(id); i++ {
+		if id[i] == '/' || id[i] == '\\' {
+			return true
+		}
+	}
+	return false
+}
+
+func isFunction(n nodes.Object) bool {
+	switch typeOf(n) {
+	case "FunctionExpression", "ArrowFunctionExpression":
+		return true
+	}
+	return false
+}
+
+// isFactory reports if the node is a function that may wrap a webpack module.
+func isFactory(n nodes.Object, strict bool) bool {
+	if !isFunction(n) {
+		return false
+	} else if !strict {
+		return true
+	}
+	params, _ := n["params"].(nodes.Array)
+	return len(params) >= 1 && len(params) <= 3
+}
+
+// isPush reports if the node is a member expression of the push method.
+func isPush(n nodes.Object) bool {
+	if typeOf(n) != "MemberExpression" || n["computed"] == nodes.Bool(true) {
+		return false
+	}
+	prop, _ := n["property"].(nodes.Object)
+	return typeOf(prop) == "Identifier" && stringOf(prop["name"]) == "push"
+}
+
+func typeOf(n nodes.Object) string {
+	if n == nil {
+		return ""
+	}
+	return stringOf(n["type"])
+}
+
+func stringOf(n nodes.Node) string {
+	s, _ := n.(nodes.String)
+	return string(s)
+}
+
+// start returns the one-based line and zero-based UTF-16 column of a native node.
+func start(n nodes.Object) (int, int, bool) {
+	loc, _ := n["loc"].(nodes.Object)
+	s, _ := loc["start"].(nodes.Object)
+	line, ok1 := toInt(s["line"])
+	col, ok2 := toInt(s["column"])
+	return line, col, ok1 && ok2
+}
+
+func toInt(n nodes.Node) (int, bool) {
+	switch n := n.(type) {
+	case nodes.Int:
+		return int(n), true
+	case nodes.Uint:
+		return int(n), true
+	case nodes.Float:
+		return int(n), float64(int(n)) == float64(n)
+	}
+	return 0, false
+}
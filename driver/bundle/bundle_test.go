@@ -0,0 +1,263 @@
+package bundle
+
+import (
+	"context"
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/normalizer"
+	"github.com/bblfsh/javascript-driver/driver/parser"
+	"github.com/bblfsh/javascript-driver/driver/sourcemap"
+)
+
+const webpackDev = `(function(modules) {
+	var installedModules = {};
+	function __webpack_require__(moduleId) {
+		return modules[moduleId].call(module.exports, module, module.exports, __webpack_require__);
+	}
+	return __webpack_require__(__webpack_require__.s = "./src/index.js");
+})({
+"./src/index.js":
+/*!**********************!*\
+  !*** ./src/index.js ***!
+  \**********************/
+/*! no static exports found */
+/***/ (function(module, exports, __webpack_require__) {
+var a = __webpack_require__(/*! ./a */ "./src/a.js");
+var b = __webpack_require__("./node_modules/b/index.js");
+__webpack_require__("./src/a.js");
+/***/ }),
+"./src/a.js": (function(module, exports) {
+module.exports = 1;
+/***/ }),
+"./node_modules/b/index.js": (function(module) {
+module.exports = 2;
+})
+});
+`
+
+const webpackProd = `!function(e){var t={};function n(r){if(t[r])return t[r].exports;var o=t[r]={i:r,l:!1,exports:{}};return e[r].call(o.exports,o,o.exports,n),o.l=!0,o.exports}n(n.s=1)}([function(e,t){e.exports=1},function(e,t,n){var r=n(0);console.log(r)}]);`
+
+const webpack5 = `(() => {
+var __webpack_modules__ = ({
+/***/ 12:
+/*!******************!*\
+  !*** ./src/a.js ***!
+  \******************/
+/***/ ((module) => {
+module.exports = 1;
+/***/ }),
+/***/ 34:
+/***/ ((__unused_webpack_module, exports, __webpack_require__) => {
+const a = __webpack_require__(12);
+/***/ })
+});
+var __webpack_module_cache__ = {};
+})();
+`
+
+const webpackChunk = `(self["webpackChunkapp"] = self["webpackChunkapp"] || []).push([[179],{
+"./src/lazy.js": ((module, exports, __webpack_require__) => {
+__webpack_require__("./src/a.js");
+})
+}]);`
+
+const browserify = `(function(){function r(e,n,t){return e}return r})()({1:[function(require,module,exports){
+var b = require('./b');
+var c = require("c");
+},{"./b":2,"c":"c"}],2:[function(require,module,exports){
+module.exports = 2;
+},{}]},{},[1]);
+`
+
+const plain = `(function(handlers) {
+	handlers.forEach(function(h) { h(); });
+})([function() {}, function() {}]);
+var x = {"a": function() {}};
+run({1: function(a, b, c) {}}, {}, []);
+`
+
+// arrays of functions in other code, with one parameter as webpack factories
+const tasks = `(function (tasks) {
+	tasks.forEach(function (t) { t(function () {}); });
+})([function (cb) { cb(); }, function (cb) { setTimeout(cb); }]);
+routes.push(['/', [function (req) { return req.path; }]]);
+`
+
+// a webpack bundle nested into other code
+const nested = `function load() {
+	(function(modules) { return modules[0].call(null, {}); })([function(module, exports) {}]);
+}
+`
+
+const webpackJsonp = `webpackJsonp([0], {"./src/b.js": function(module, exports, __webpack_require__) {
+__webpack_require__("./src/a.js");
+}});
+`
+
+// rollup bundle of a.js, b.js and main.js, with a map of the declarations
+const rollup = "(function () {\n'use strict';\nconst a = 1;\nconst b = 2;\nconsole.log(a + b);\n})();\n"
+const rollupMap = `{"version":3,"sources":["a.js","b.js","main.js"],"names":[],"mappings":";;AAAA;ACAA;ACAA"}`
+
+func parse(t *testing.T, src string) nodes.Node {
+	ast, err := parser.NewDriver(0).Parse(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ast
+}
+
+type module struct {
+	ID, Path string
+	Requires []string
+	Body     int
+}
+
+func TestDetect(t *testing.T) {
+	m, err := sourcemap.Parse([]byte(rollupMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name   string
+		src    string
+		m      *sourcemap.Map
+		format string
+		exp    []module
+	}{
+		{
+			name: "webpack dev", src: webpackDev, format: FormatWebpack,
+			exp: []module{
+				{ID: "./src/index.js", Path: "./src/index.js", Requires: []string{"./src/a.js", "./node_modules/b/index.js"}},
+				{ID: "./src/a.js", Path: "./src/a.js"},
+				{ID: "./node_modules/b/index.js", Path: "./node_modules/b/index.js"},
+			},
+		},
+		{
+			name: "webpack prod", src: webpackProd, format: FormatWebpack,
+			exp: []module{
+				{ID: "0"},
+				{ID: "1", Requires: []string{"0"}},
+			},
+		},
+		{
+			name: "webpack 5", src: webpack5, format: FormatWebpack,
+			exp: []module{
+				{ID: "12", Path: "./src/a.js"},
+				{ID: "34", Requires: []string{"12"}},
+			},
+		},
+		{
+			name: "webpack chunk", src: webpackChunk, format: FormatWebpack,
+			exp: []module{
+				{ID: "./src/lazy.js", Path: "./src/lazy.js", Requires: []string{"./src/a.js"}},
+			},
+		},
+		{
+			name: "browserify", src: browserify, format: FormatBrowserify,
+			exp: []module{
+				{ID: "1", Requires: []string{"./b", "c"}},
+				{ID: "2"},
+			},
+		},
+		{
+			name: "rollup", src: rollup, m: m, format: FormatRollup,
+			exp: []module{
+				{Path: "a.js", Body: 1},
+				{Path: "b.js", Body: 1},
+				{Path: "main.js", Body: 1},
+			},
+		},
+		{
+			name: "webpack jsonp", src: webpackJsonp, format: FormatWebpack,
+			exp: []module{
+				{ID: "./src/b.js", Path: "./src/b.js", Requires: []string{"./src/a.js"}},
+			},
+		},
+		{name: "rollup without map", src: rollup},
+		{name: "plain", src: plain},
+		{name: "tasks", src: tasks},
+		{name: "nested", src: nested},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			format, mods := Detect(parse(t, c.src), c.m)
+			if format != c.format {
+				t.Fatalf("expected format %q, got %q", c.format, format)
+			}
+			var got []module
+			for _, m := range mods {
+				if c.format != FormatRollup && m.Factory == nil {
+					t.Errorf("no factory for module %q", m.ID)
+				}
+				got = append(got, module{ID: m.ID, Path: m.Path, Requires: m.Requires, Body: len(m.Body)})
+			}
+			if !reflect.DeepEqual(c.exp, got) {
+				t.Fatalf("unexpected modules:\n%+v\nvs\n%+v", c.exp, got)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	src := rollup + "//# sourceMappingURL=data:application/json;base64," +
+		base64.StdEncoding.EncodeToString([]byte(rollupMap))
+	for _, src := range []string{webpackDev, browserify, src} {
+		ast := Split(parse(t, src), src)
+		root, _ := ast.(nodes.Object)
+		if typ := root["type"]; typ != nodes.String("Bundle") {
+			t.Fatalf("unexpected root: %v", typ)
+		}
+		for _, m := range root["modules"].(nodes.Array) {
+			m := m.(nodes.Object)
+			n, _ := m["factory"].(nodes.Object)
+			if n == nil {
+				n = m["body"].(nodes.Array)[0].(nodes.Object)
+			}
+			if m["start"] != n["start"] {
+				t.Errorf("unexpected start of a module: %v vs %v", m["start"], n["start"])
+			}
+		}
+		for _, mode := range []driver.Mode{driver.ModeAnnotated, driver.ModeSemantic} {
+			if _, err := normalizer.Transforms.Do(context.Background(), mode, src, ast.Clone()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	ast := parse(t, plain)
+	if got := Split(ast, plain); !nodes.Equal(got, ast) {
+		t.Fatal("unexpected split of a plain file")
+	}
+}
+
+func TestSplitRuntime(t *testing.T) {
+	src := "/* header */\nvar version = 1;\n" + webpackDev
+	root := Split(parse(t, src), src).(nodes.Object)
+	if typ := root["type"]; typ != nodes.String("Bundle") {
+		t.Fatalf("unexpected root: %v", typ)
+	}
+	if comments, _ := root["comments"].(nodes.Array); len(comments) == 0 {
+		t.Fatal("comments are dropped")
+	}
+	runtime, _ := root["runtime"].(nodes.Object)
+	body, _ := runtime["body"].(nodes.Array)
+	if len(body) != 2 {
+		t.Fatalf("expected 2 statements in the runtime, got %d", len(body))
+	}
+	// the runtime is kept, the module table is not
+	call := body[1].(nodes.Object)["expression"].(nodes.Object)
+	if args := call["arguments"].(nodes.Array); len(args) != 0 {
+		t.Fatalf("module table is not removed: %v", args)
+	}
+	if fnc := call["callee"].(nodes.Object); !isWebpackRuntime(fnc) {
+		t.Fatal("runtime is dropped")
+	}
+	for _, mode := range []driver.Mode{driver.ModeAnnotated, driver.ModeSemantic} {
+		if _, err := normalizer.Transforms.Do(context.Background(), mode, src, root.Clone()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package bundle
+
+import (
+	"context"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/sourcemap"
+)
+
+// Split converts the native AST of a bundle to a Bundle node with a
+// BundleModule node per module. The program without the modules, that is the
+// runtime of the bundler and the code outside of the modules, is kept under
+// the runtime field, and all comments of the file are kept as well. Positions
+// of all nodes are kept relative to the bundle.
+//
+// The source is used to find an inline source map. The AST is returned as is
+// if it is not a bundle; otherwise, it is modified in place.
+func Split(ast nodes.Node, src string) nodes.Node {
+	m, err := sourcemap.ParseInline(src)
+	if err != nil {
+		m = nil
+	}
+	format, mods, remove := detect(ast, m)
+	if format == "" {
+		return ast
+	}
+	for _, rm := range remove {
+		rm()
+	}
+	file, _ := ast.(nodes.Object)
+	root := nodes.Object{"type": nodes.String("Bundle"), "format": nodes.String(format)}
+	copyPos(root, file, file)
+	root["runtime"] = file["program"]
+	for _, k := range []string{"comments", "tokens"} {
+		if v, ok := file[k]; ok {
+			root[k] = v
+		}
+	}
+	list := make(nodes.Array, 0, len(mods))
+	for _, m := range mods {
+		n := nodes.Object{"type": nodes.String("BundleModule")}
+		if m.ID != "" {
+			n["id"] = nodes.String(m.ID)
+		}
+		if m.Path != "" {
+			n["path"] = nodes.String(m.Path)
+		}
+		reqs := make(nodes.Array, 0, len(m.Requires))
+		for _, r := range m.Requires {
+			reqs = append(reqs, nodes.String(r))
+		}
+		n["requires"] = reqs
+		if m.Factory != nil {
+			n["factory"] = m.Factory
+			copyPos(n, m.Factory, m.Factory)
+		} else {
+			n["body"] = m.Body
+			first, _ := m.Body[0].(nodes.Object)
+			last, _ := m.Body[len(m.Body)-1].(nodes.Object)
+			copyPos(n, first, last)
+		}
+		list = append(list, n)
+	}
+	root["modules"] = list
+	return root
+}
+
+// copyPos sets positional fields of a native node to span from the start of
+// one node to the end of another.
+func copyPos(n, from, to nodes.Object) {
+	loc := nodes.Object{}
+	if l, _ := from["loc"].(nodes.Object); l != nil {
+		loc["start"] = l["start"].Clone()
+	}
+	if l, _ := to["loc"].(nodes.Object); l != nil {
+		loc["end"] = l["end"].Clone()
+	}
+	n["start"] = from["start"]
+	n["end"] = to["end"]
+	n["loc"] = loc
+}
+
+// NewNative wraps a native driver to split bundles into modules, as by Split.
+// Other sources are returned as parsed by the driver.
+func NewNative(d driver.Native) driver.Native {
+	return &bundleDriver{Native: d}
+}
+
+type bundleDriver struct {
+	driver.Native
+}
+
+// Parse implements driver.Native.
+func (d *bundleDriver) Parse(ctx context.Context, src string) (nodes.Node, error) {
+	ast, err := d.Native.Parse(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	return Split(ast, src), nil
+}
@@ -0,0 +1,217 @@
+// Package cache implements a content-addressed cache of parse results.
+//
+// Results are keyed by a hash of the source, parse options, the driver
+// version and its settings. Recently used results are kept in memory, and all results can
+// optionally be stored on disk to survive restarts and be shared between
+// driver instances.
+package cache
+
+import (
+	"bufio"
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/nodes/nodesproto"
+)
+
+// Key identifies a parse result.
+type Key [sha256.Size]byte
+
+// String returns a hex representation of the key.
+func (k Key) String() string {
+	return hex.EncodeToString(k[:])
+}
+
+// NewKey returns a key for parsing the source with given options by a given
+// version of the driver. Settings is a fingerprint of the driver configuration
+// that affects the result, like the parser backend or the limits. The file
+// name is a part of the key, since the driver chooses how to parse the source
+// by its extension, like for HTML documents and Vue components.
+func NewKey(vers driver.Version, settings string, opts driver.ParseOptions, src string) Key {
+	mode := opts.Mode
+	if mode == 0 {
+		mode = driver.ModeDefault
+	}
+	h := sha256.New()
+	// strings are prefixed with their length to avoid ambiguity
+	for _, s := range []string{vers.Version, vers.Build.UTC().Format(time.RFC3339Nano), settings, opts.Language, opts.Filename, src} {
+		var sz [8]byte
+		binary.LittleEndian.PutUint64(sz[:], uint64(len(s)))
+		h.Write(sz[:])
+		h.Write([]byte(s))
+	}
+	var m [8]byte
+	binary.LittleEndian.PutUint64(m[:], uint64(mode))
+	h.Write(m[:])
+	var k Key
+	h.Sum(k[:0])
+	return k
+}
+
+// Stats are the counters of a cache.
+type Stats struct {
+	Hits     uint64 // memory and disk hits
+	DiskHits uint64
+	Misses   uint64
+	Entries  int // in memory
+}
+
+// Cache of parse results. The trees returned by the cache are shared and must
+// not be modified.
+type Cache struct {
+	size int
+	dir  string
+
+	mu    sync.Mutex
+	ll    *list.List // of *entry, most recent first
+	items map[Key]*list.Element
+	stats Stats
+}
+
+type entry struct {
+	key Key
+	ast nodes.Node
+}
+
+// New creates a cache that keeps up to size results in memory. If dir is not
+// empty, all results are also stored in this directory.
+func New(size int, dir string) (*Cache, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &Cache{
+		size:  size,
+		dir:   dir,
+		ll:    list.New(),
+		items: make(map[Key]*list.Element),
+	}, nil
+}
+
+// Get returns a cached result. Disk errors are treated as cache misses.
+func (c *Cache) Get(k Key) (nodes.Node, bool) {
+	c.mu.Lock()
+	if e, ok := c.items[k]; ok {
+		c.ll.MoveToFront(e)
+		c.stats.Hits++
+		c.mu.Unlock()
+		return e.Value.(*entry).ast, true
+	}
+	c.mu.Unlock()
+
+	if c.dir == "" {
+		c.miss()
+		return nil, false
+	}
+	ast, err := c.readFile(k)
+	if err != nil {
+		c.miss()
+		return nil, false
+	}
+	c.mu.Lock()
+	c.stats.Hits++
+	c.stats.DiskHits++
+	c.add(k, ast)
+	c.mu.Unlock()
+	return ast, true
+}
+
+func (c *Cache) miss() {
+	c.mu.Lock()
+	c.stats.Misses++
+	c.mu.Unlock()
+}
+
+// Put stores a result in the cache.
+func (c *Cache) Put(k Key, ast nodes.Node) error {
+	c.mu.Lock()
+	c.add(k, ast)
+	c.mu.Unlock()
+	if c.dir == "" {
+		return nil
+	}
+	return c.writeFile(k, ast)
+}
+
+// add stores an entry in memory, evicting the least recently used one.
+// It must be called with the lock held.
+func (c *Cache) add(k Key, ast nodes.Node) {
+	if c.size <= 0 {
+		return
+	}
+	if e, ok := c.items[k]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*entry).ast = ast
+		return
+	}
+	c.items[k] = c.ll.PushFront(&entry{key: k, ast: ast})
+	for c.ll.Len() > c.size {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		delete(c.items, last.Value.(*entry).key)
+	}
+}
+
+// Stats returns the cache counters.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.stats
+	st.Entries = c.ll.Len()
+	return st
+}
+
+// path returns a file path for a key. Files are split into subdirectories
+// by the first byte of the key to keep directories small.
+func (c *Cache) path(k Key) string {
+	s := k.String()
+	return filepath.Join(c.dir, s[:2], s[2:])
+}
+
+func (c *Cache) readFile(k Key) (nodes.Node, error) {
+	f, err := os.Open(c.path(k))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return nodesproto.ReadTree(bufio.NewReader(f))
+}
+
+// writeFile stores the result to a temporary file and renames it, thus
+// concurrent readers never observe partial files.
+func (c *Cache) writeFile(k Key, ast nodes.Node) error {
+	path := c.path(k)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = nodesproto.WriteTo(w, ast)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/driver/manifest"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/html"
+)
+
+var testVersion = driver.Version{Version: "v1.0.0", Build: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+func key(src string) Key {
+	return NewKey(testVersion, "", driver.ParseOptions{}, src)
+}
+
+func ast(src string) nodes.Node {
+	return nodes.Object{
+		"@type": nodes.String("File"),
+		"src":   nodes.String(src),
+		"body": nodes.Array{
+			nodes.Object{"@type": nodes.String("Number"), "value": nodes.Int(-1), "pos": nodes.Uint(3)},
+			nodes.Object{"@type": nodes.String("Number"), "value": nodes.Float(1.5), "ok": nodes.Bool(true)},
+			nil,
+		},
+	}
+}
+
+func TestKey(t *testing.T) {
+	k := key("a")
+	if key("a") != k {
+		t.Fatal("key is not stable")
+	}
+	// the default mode is explicit
+	if NewKey(testVersion, "", driver.ParseOptions{Mode: driver.ModeDefault}, "a") != k {
+		t.Fatal("unexpected key difference")
+	}
+	other := []Key{
+		key("b"),
+		NewKey(testVersion, "", driver.ParseOptions{Mode: driver.ModeNative}, "a"),
+		NewKey(testVersion, "", driver.ParseOptions{Language: "typescript"}, "a"),
+		NewKey(testVersion, "", driver.ParseOptions{Filename: "a.js"}, "a"),
+		NewKey(driver.Version{Version: "v1.0.1", Build: testVersion.Build}, "", driver.ParseOptions{}, "a"),
+		NewKey(driver.Version{Version: "v1.0.0"}, "", driver.ParseOptions{}, "a"),
+		NewKey(testVersion, "tokens=true", driver.ParseOptions{}, "a"),
+		// no ambiguity between fields
+		NewKey(testVersion, "", driver.ParseOptions{Language: "a"}, ""),
+		NewKey(testVersion, "a", driver.ParseOptions{}, ""),
+		NewKey(testVersion, "", driver.ParseOptions{Filename: "a"}, ""),
+	}
+	for i, o := range other {
+		if o == k {
+			t.Errorf("key %d is the same", i)
+		}
+	}
+}
+
+func TestLRU(t *testing.T) {
+	c, err := New(2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"a", "b"} {
+		if err = c.Put(key(s), ast(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// "a" becomes the most recently used, "b" is evicted
+	if n, ok := c.Get(key("a")); !ok || !nodes.Equal(n, ast("a")) {
+		t.Fatalf("unexpected result: %v", n)
+	}
+	if err = c.Put(key("c"), ast("c")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(key("b")); ok {
+		t.Fatal("expected b to be evicted")
+	}
+	for _, s := range []string{"a", "c"} {
+		if _, ok := c.Get(key(s)); !ok {
+			t.Fatalf("expected %s to be cached", s)
+		}
+	}
+	if st := c.Stats(); st != (Stats{Hits: 3, Misses: 1, Entries: 2}) {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := New(0, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Put(key("a"), ast("a")); err != nil {
+		t.Fatal(err)
+	}
+	// a new cache reads results stored by the previous one
+	c, err = New(1, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, ok := c.Get(key("a"))
+	if !ok {
+		t.Fatal("expected a disk hit")
+	} else if !nodes.Equal(n, ast("a")) {
+		t.Fatalf("unexpected result: %v", n)
+	}
+	if _, ok = c.Get(key("a")); !ok {
+		t.Fatal("expected a memory hit")
+	}
+	if _, ok = c.Get(key("b")); ok {
+		t.Fatal("unexpected hit")
+	}
+	if st := c.Stats(); st != (Stats{Hits: 2, DiskHits: 1, Misses: 1, Entries: 1}) {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+// countingDriver returns the source as an AST and counts requests.
+type countingDriver struct {
+	calls int
+}
+
+func (d *countingDriver) Start() error { return nil }
+func (d *countingDriver) Close() error { return nil }
+
+func (d *countingDriver) Parse(ctx context.Context, src string, opts *driver.ParseOptions) (nodes.Node, error) {
+	d.calls++
+	if src == "syntax" {
+		return nil, driver.ErrSyntax.Wrap(errors.New("unexpected token"))
+	}
+	return ast(src), nil
+}
+
+func (d *countingDriver) Version(ctx context.Context) (driver.Version, error) {
+	return testVersion, nil
+}
+
+func (d *countingDriver) Languages(ctx context.Context) ([]manifest.Manifest, error) {
+	return nil, nil
+}
+
+func TestDriver(t *testing.T) {
+	c, err := New(10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd := &countingDriver{}
+	d := NewDriver(cd, c, "")
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		n, err := d.Parse(ctx, "a", &driver.ParseOptions{Filename: "a.js"})
+		if err != nil {
+			t.Fatal(err)
+		} else if !nodes.Equal(n, ast("a")) {
+			t.Fatalf("unexpected result: %v", n)
+		}
+	}
+	if _, err = d.Parse(ctx, "a", &driver.ParseOptions{Mode: driver.ModeNative}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err = d.Parse(ctx, "syntax", nil); !driver.ErrSyntax.Is(err) {
+			t.Fatalf("expected syntax error, got %v", err)
+		}
+	}
+	if cd.calls != 4 {
+		t.Fatalf("unexpected number of requests: %d", cd.calls)
+	}
+}
+
+// componentDriver parses sources differently by the file extension, like
+// html.NewDriver does.
+type componentDriver struct {
+	countingDriver
+}
+
+func (d *componentDriver) Parse(ctx context.Context, src string, opts *driver.ParseOptions) (nodes.Node, error) {
+	if opts != nil && html.IsComponent(opts.Filename) {
+		src = "component:" + src
+	}
+	return d.countingDriver.Parse(ctx, src, opts)
+}
+
+func TestDriverFilename(t *testing.T) {
+	c, err := New(10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDriver(&componentDriver{}, c, "")
+	ctx := context.Background()
+	const src = "<script>a</script>"
+	for _, name := range []string{"a.js", "a.vue", "a.js"} {
+		n, err := d.Parse(ctx, src, &driver.ParseOptions{Filename: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := src
+		if name == "a.vue" {
+			exp = "component:" + src
+		}
+		if !nodes.Equal(n, ast(exp)) {
+			t.Fatalf("unexpected result for %s: %v", name, n)
+		}
+	}
+}
+
+func TestDriverSettings(t *testing.T) {
+	c, err := New(10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd := &countingDriver{}
+	ctx := context.Background()
+	// drivers with different settings share the cache, but not the results
+	for _, settings := range []string{"backend=go", "backend=native", "backend=go"} {
+		d := NewDriver(cd, c, settings)
+		if _, err = d.Parse(ctx, "a", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cd.calls != 2 {
+		t.Fatalf("unexpected number of requests: %d", cd.calls)
+	}
+}
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+// NewDriver wraps a driver to serve repeated requests from the cache without
+// running the native parser and the transformations.
+//
+// Only successful results are cached: syntax errors are cheap to reproduce,
+// and driver failures and timeouts may not happen on the next attempt.
+// Settings is a part of all keys, see NewKey.
+func NewDriver(d driver.DriverModule, c *Cache, settings string) driver.DriverModule {
+	return &cachedDriver{DriverModule: d, c: c, settings: settings}
+}
+
+type cachedDriver struct {
+	driver.DriverModule
+	c        *Cache
+	settings string
+}
+
+// Parse implements driver.Driver.
+func (d *cachedDriver) Parse(ctx context.Context, src string, opts *driver.ParseOptions) (nodes.Node, error) {
+	vers, err := d.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var o driver.ParseOptions
+	if opts != nil {
+		o = *opts
+	}
+	k := NewKey(vers, d.settings, o, src)
+	if ast, ok := d.c.Get(k); ok {
+		return ast, nil
+	}
+	ast, err := d.DriverModule.Parse(ctx, src, opts)
+	if err != nil {
+		return ast, err
+	}
+	// the result is already computed, a failure to store it is not an error
+	_ = d.c.Put(k, ast)
+	return ast, nil
+}
@@ -0,0 +1,566 @@
+// Package callgraph extracts an intra-file call graph from JavaScript UAST.
+//
+// It relies on the annotated UAST produced by this driver and on the scope
+// analysis from the scope package to resolve callee names.
+package callgraph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/scope"
+)
+
+// ModuleID is the ID of the node that represents the top-level code of a file.
+const ModuleID = "<module>"
+
+// Kind is a kind of the call graph node.
+type Kind string
+
+const (
+	// KindModule is the top-level code of the file.
+	KindModule = Kind("module")
+	// KindFunction is a function declaration or a function expression.
+	KindFunction = Kind("function")
+	// KindMethod is a class or object method.
+	KindMethod = Kind("method")
+	// KindConstructor is a class constructor, explicit or implicit.
+	KindConstructor = Kind("constructor")
+	// KindImport is a symbol imported from another module.
+	KindImport = Kind("import")
+	// KindGlobal is a global name that is not declared in the file.
+	KindGlobal = Kind("global")
+)
+
+// Node is a single caller or callee.
+type Node struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Kind Kind   `json:"kind"`
+	// Module is the import path of the module for KindImport nodes.
+	Module string `json:"module,omitempty"`
+	// Symbol is the imported name for KindImport nodes: "default" for default
+	// imports and "*" for namespace imports.
+	Symbol string         `json:"symbol,omitempty"`
+	Start  *uast.Position `json:"start,omitempty"`
+	End    *uast.Position `json:"end,omitempty"`
+}
+
+// Edge is a single call site.
+type Edge struct {
+	Caller string `json:"caller"`
+	Callee string `json:"callee"`
+	// New is set for calls done with the new operator.
+	New bool `json:"new,omitempty"`
+	// Pos is the position of the call expression.
+	Pos *uast.Position `json:"pos,omitempty"`
+}
+
+// Graph is a call graph of a single file.
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []Edge  `json:"edges"`
+}
+
+// Node returns a graph node by ID.
+func (g *Graph) Node(id string) *Node {
+	for _, n := range g.Nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// Build extracts a call graph from an annotated UAST of a single file.
+func Build(root nodes.Node) (*Graph, error) {
+	info, err := scope.Analyze(root)
+	if err != nil {
+		return nil, err
+	}
+	b := &builder{
+		info:    info,
+		g:       &Graph{Edges: []Edge{}},
+		byNode:  make(map[nodes.Comparable]*Node),
+		byID:    make(map[string]*Node),
+		imports: make(map[nodes.Comparable]string),
+		classes: make(map[nodes.Comparable]*class),
+	}
+	b.add(&Node{ID: ModuleID, Name: ModuleID, Kind: KindModule})
+	b.collect(root, "")
+	b.walk(root, frame{caller: ModuleID})
+	return b.g, nil
+}
+
+// class is a class declaration or expression found in the file.
+type class struct {
+	node  nodes.Object
+	name  string
+	ctor  *Node
+	super nodes.Object // superclass identifier, if any
+	// methods maps method names to graph nodes; static methods are stored
+	// with "static " prefix.
+	methods map[string]*Node
+}
+
+type builder struct {
+	info *scope.Info
+	g    *Graph
+
+	byNode  map[nodes.Comparable]*Node
+	byID    map[string]*Node
+	imports map[nodes.Comparable]string // import specifier -> module path
+	classes map[nodes.Comparable]*class
+}
+
+// frame is the context of the walk.
+type frame struct {
+	caller string
+	class  *class
+}
+
+func (b *builder) add(n *Node) *Node {
+	if old := b.byID[n.ID]; old != nil {
+		return old
+	}
+	b.byID[n.ID] = n
+	b.g.Nodes = append(b.g.Nodes, n)
+	return n
+}
+
+func (b *builder) addFor(obj nodes.Object, name string, kind Kind) *Node {
+	if n := b.byNode[nodes.UniqueKey(obj)]; n != nil {
+		return n
+	}
+	n := &Node{Name: name, Kind: kind}
+	if ps := uast.PositionsOf(obj); ps != nil {
+		n.Start, n.End = ps.Start(), ps.End()
+	}
+	n.ID = name
+	if n.Start != nil {
+		n.ID = fmt.Sprintf("%s@%d:%d", name, n.Start.Line, n.Start.Col)
+	}
+	// make sure the ID is unique even if there are no positions
+	for i := 2; b.byID[n.ID] != nil; i++ {
+		n.ID = fmt.Sprintf("%s#%d", name, i)
+	}
+	n = b.add(n)
+	b.byNode[nodes.UniqueKey(obj)] = n
+	return n
+}
+
+func typeOf(n nodes.Node) string {
+	return scope.TypeOf(n)
+}
+
+func field(obj nodes.Object, k string) nodes.Object {
+	v, _ := obj[k].(nodes.Object)
+	return v
+}
+
+func list(obj nodes.Object, k string) nodes.Array {
+	v, _ := obj[k].(nodes.Array)
+	return v
+}
+
+func isTrue(obj nodes.Object, k string) bool {
+	v, _ := obj[k].(nodes.Bool)
+	return bool(v)
+}
+
+func strField(obj nodes.Object, k string) string {
+	v, _ := obj[k].(nodes.String)
+	return string(v)
+}
+
+// keyName returns a static name of a property key.
+func keyName(key nodes.Object) string {
+	switch typeOf(key) {
+	case "Identifier", "uast:Identifier":
+		return scope.NameOf(key)
+	case "PrivateName":
+		return "#" + scope.NameOf(field(key, "id"))
+	case "StringLiteral", "uast:String", "NumericLiteral":
+		if v, ok := key["value"].(nodes.Value); ok && v != nil {
+			return nodes.ToString(v)
+		}
+		if v, ok := key["Value"].(nodes.String); ok {
+			return string(v)
+		}
+	}
+	return ""
+}
+
+// exprName returns a dotted name of an identifier or a member expression.
+func exprName(n nodes.Object) string {
+	switch typeOf(n) {
+	case "Identifier", "uast:Identifier":
+		return scope.NameOf(n)
+	case "ThisExpression":
+		return "this"
+	case "MemberExpression":
+		if isTrue(n, "computed") {
+			return ""
+		}
+		obj := exprName(field(n, "object"))
+		prop := keyName(field(n, "property"))
+		if obj == "" || prop == "" {
+			return ""
+		}
+		return obj + "." + prop
+	}
+	return ""
+}
+
+func isFunction(typ string) bool {
+	switch typ {
+	case "FunctionDeclaration", "FunctionExpression", "ArrowFunctionExpression",
+		"ObjectMethod", "ClassMethod", "ClassPrivateMethod":
+		return true
+	}
+	return false
+}
+
+// collect registers all functions, classes and imports before the call sites
+// are resolved, so that calls can refer to functions declared later in the file.
+// The name argument is a name suggested by the parent node, if any.
+func (b *builder) collect(n nodes.Node, name string) {
+	switch n := n.(type) {
+	case nodes.Array:
+		for _, v := range n {
+			b.collect(v, "")
+		}
+		return
+	case nodes.Object:
+		b.collectObject(n, name)
+	}
+}
+
+func (b *builder) collectObject(n nodes.Object, name string) {
+	typ := typeOf(n)
+	switch typ {
+	case "ImportDeclaration":
+		path := scope.NameOf(field(n, "source"))
+		if v, ok := field(n, "source")["value"].(nodes.String); ok {
+			path = string(v)
+		}
+		for _, sp := range list(n, "specifiers") {
+			b.imports[nodes.UniqueKey(sp)] = path
+		}
+		return
+	case "VariableDeclarator":
+		if id := field(n, "id"); id != nil {
+			name = scope.NameOf(id)
+		}
+		b.collect(n["init"], name)
+		return
+	case "AssignmentExpression":
+		b.collect(n["left"], "")
+		b.collect(n["right"], exprName(field(n, "left")))
+		return
+	case "ObjectProperty", "ClassProperty":
+		if !isTrue(n, "computed") {
+			name = keyName(field(n, "key"))
+		} else {
+			b.collect(n["key"], "")
+		}
+		b.collect(n["value"], name)
+		return
+	case "ClassDeclaration", "ClassExpression":
+		b.collectClass(n, name)
+		return
+	case "FunctionDeclaration", "FunctionExpression", "ArrowFunctionExpression", "ObjectMethod":
+		if id := field(n, "id"); id != nil {
+			name = scope.NameOf(id)
+		}
+		kind := KindFunction
+		if typ == "ObjectMethod" {
+			kind = KindMethod
+			if !isTrue(n, "computed") {
+				name = keyName(field(n, "key"))
+			}
+		}
+		if name == "" {
+			name = "<anonymous>"
+		}
+		b.addFor(n, name, kind)
+	}
+	for _, k := range n.Keys() {
+		switch v := n[k].(type) {
+		case nodes.Object, nodes.Array:
+			if k == uast.KeyPos {
+				continue
+			}
+			b.collect(v, "")
+		}
+	}
+}
+
+func (b *builder) collectClass(n nodes.Object, name string) {
+	if id := field(n, "id"); id != nil {
+		name = scope.NameOf(id)
+	}
+	if name == "" {
+		name = "<anonymous>"
+	}
+	c := &class{
+		node:    n,
+		name:    name,
+		super:   field(n, "superClass"),
+		methods: make(map[string]*Node),
+	}
+	b.classes[nodes.UniqueKey(n)] = c
+	b.collect(n["superClass"], "")
+	for _, m := range list(field(n, "body"), "body") {
+		m, _ := m.(nodes.Object)
+		switch typeOf(m) {
+		case "ClassMethod", "ClassPrivateMethod":
+			mname := ""
+			if !isTrue(m, "computed") {
+				mname = keyName(field(m, "key"))
+			}
+			if strField(m, "kind") == "constructor" {
+				c.ctor = b.addFor(m, name, KindConstructor)
+			} else if mname != "" {
+				gn := b.addFor(m, name+"."+mname, KindMethod)
+				if isTrue(m, "static") {
+					mname = "static " + mname
+				}
+				c.methods[mname] = gn
+			} else {
+				b.addFor(m, name+".<computed>", KindMethod)
+			}
+			b.collect(m["body"], "")
+			b.collect(m["params"], "")
+			continue
+		}
+		b.collect(m, "")
+	}
+	if c.ctor == nil {
+		// implicit constructor is represented by the class node itself
+		c.ctor = b.addFor(n, name, KindConstructor)
+	}
+}
+
+// walk finds all call sites and records edges.
+func (b *builder) walk(n nodes.Node, f frame) {
+	switch n := n.(type) {
+	case nodes.Array:
+		for _, v := range n {
+			b.walk(v, f)
+		}
+	case nodes.Object:
+		typ := typeOf(n)
+		if c := b.classes[nodes.UniqueKey(n)]; c != nil {
+			f.class = c
+		}
+		if gn := b.byNode[nodes.UniqueKey(n)]; gn != nil && isFunction(typ) {
+			f.caller = gn.ID
+		}
+		switch typ {
+		case "CallExpression", "NewExpression", "OptionalCallExpression":
+			b.call(n, f, typ == "NewExpression")
+		}
+		for _, k := range n.Keys() {
+			switch v := n[k].(type) {
+			case nodes.Object, nodes.Array:
+				if k == uast.KeyPos {
+					continue
+				}
+				b.walk(v, f)
+			}
+		}
+	}
+}
+
+func (b *builder) call(n nodes.Object, f frame, isNew bool) {
+	callee := b.resolve(field(n, "callee"), f, isNew)
+	if callee == nil {
+		return
+	}
+	e := Edge{Caller: f.caller, Callee: callee.ID, New: isNew}
+	if ps := uast.PositionsOf(n); ps != nil {
+		e.Pos = ps.Start()
+	}
+	b.g.Edges = append(b.g.Edges, e)
+}
+
+// resolve finds a graph node for a callee expression.
+func (b *builder) resolve(callee nodes.Object, f frame, isNew bool) *Node {
+	switch typeOf(callee) {
+	case "Identifier", "uast:Identifier":
+		return b.resolveIdent(callee)
+	case "Super":
+		if f.class == nil {
+			return nil
+		}
+		if sc := b.classOf(f.class.super); sc != nil {
+			return sc.ctor
+		}
+		return nil
+	case "MemberExpression", "OptionalMemberExpression":
+		if isTrue(callee, "computed") {
+			return nil
+		}
+		prop := keyName(field(callee, "property"))
+		if prop == "" {
+			return nil
+		}
+		obj := field(callee, "object")
+		switch typeOf(obj) {
+		case "ThisExpression":
+			if f.class == nil {
+				return nil
+			}
+			return b.method(f.class, prop, false)
+		case "Super":
+			if f.class == nil {
+				return nil
+			}
+			if sc := b.classOf(f.class.super); sc != nil {
+				return b.method(sc, prop, false)
+			}
+			return nil
+		case "Identifier", "uast:Identifier":
+			bnd := b.info.BindingOf(obj)
+			if bnd == nil {
+				return nil
+			}
+			if c := b.classOf(obj); c != nil {
+				return b.method(c, prop, true)
+			}
+			if path, sym, ok := b.importOf(bnd); ok {
+				switch sym {
+				case "*":
+					sym = prop
+				default:
+					sym = sym + "." + prop
+				}
+				return b.external(path, sym)
+			}
+		}
+	}
+	return nil
+}
+
+func (b *builder) resolveIdent(id nodes.Object) *Node {
+	bnd := b.info.BindingOf(id)
+	if bnd == nil {
+		name := scope.NameOf(id)
+		return b.add(&Node{ID: "global:" + name, Name: name, Kind: KindGlobal})
+	}
+	if path, sym, ok := b.importOf(bnd); ok {
+		return b.external(path, sym)
+	}
+	if c := b.classes[nodes.UniqueKey(bnd.Node)]; c != nil {
+		return c.ctor
+	}
+	if gn := b.byNode[nodes.UniqueKey(bnd.Node)]; gn != nil {
+		return gn
+	}
+	if typeOf(bnd.Node) == "VariableDeclarator" {
+		init := field(bnd.Node, "init")
+		if c := b.classes[nodes.UniqueKey(init)]; c != nil {
+			return c.ctor
+		}
+		if gn := b.byNode[nodes.UniqueKey(init)]; gn != nil {
+			return gn
+		}
+	}
+	return nil
+}
+
+// classOf returns a local class referenced by an identifier.
+func (b *builder) classOf(id nodes.Object) *class {
+	if id == nil {
+		return nil
+	}
+	bnd := b.info.BindingOf(id)
+	if bnd == nil {
+		return nil
+	}
+	if c := b.classes[nodes.UniqueKey(bnd.Node)]; c != nil {
+		return c
+	}
+	if typeOf(bnd.Node) == "VariableDeclarator" {
+		return b.classes[nodes.UniqueKey(field(bnd.Node, "init"))]
+	}
+	return nil
+}
+
+// method finds a method of a class, following the local superclass chain.
+func (b *builder) method(c *class, name string, static bool) *Node {
+	if static {
+		name = "static " + name
+	}
+	seen := make(map[*class]bool)
+	for c != nil && !seen[c] {
+		seen[c] = true
+		if m := c.methods[name]; m != nil {
+			return m
+		}
+		c = b.classOf(c.super)
+	}
+	return nil
+}
+
+// importOf returns the module path and the imported symbol for a binding
+// introduced by an import declaration or a CommonJS require call.
+func (b *builder) importOf(bnd *scope.Binding) (path, sym string, ok bool) {
+	switch bnd.Kind {
+	case scope.Import:
+		path, ok = b.imports[nodes.UniqueKey(bnd.Node)]
+		if !ok {
+			return "", "", false
+		}
+		switch typeOf(bnd.Node) {
+		case "ImportDefaultSpecifier":
+			sym = "default"
+		case "ImportNamespaceSpecifier":
+			sym = "*"
+		default:
+			sym = keyName(field(bnd.Node, "imported"))
+		}
+		return path, sym, true
+	case scope.Var, scope.Let, scope.Const:
+		// const x = require("path")
+		init := field(bnd.Node, "init")
+		if typeOf(init) != "CallExpression" || scope.NameOf(field(init, "callee")) != "require" {
+			return "", "", false
+		}
+		args := list(init, "arguments")
+		if len(args) != 1 {
+			return "", "", false
+		}
+		arg, _ := args[0].(nodes.Object)
+		if typeOf(arg) != "StringLiteral" {
+			return "", "", false
+		}
+		v, _ := arg["value"].(nodes.String)
+		if typeOf(field(bnd.Node, "id")) != "Identifier" {
+			return "", "", false
+		}
+		return string(v), "*", true
+	}
+	return "", "", false
+}
+
+func (b *builder) external(path, sym string) *Node {
+	name := path
+	if sym != "" {
+		name = path + "#" + sym
+	}
+	if strings.HasPrefix(sym, "*") {
+		name = path
+	}
+	return b.add(&Node{
+		ID:     "import:" + name,
+		Name:   name,
+		Kind:   KindImport,
+		Module: path,
+		Symbol: sym,
+	})
+}
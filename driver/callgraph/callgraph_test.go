@@ -0,0 +1,85 @@
+package callgraph
+
+import (
+	"testing"
+
+	"github.com/bblfsh/javascript-driver/driver/internal/fixturetest"
+)
+
+var casesBuild = []struct {
+	name  string
+	edges []string
+}{
+	{
+		name: "bench_fibonacci.js",
+		edges: []string{
+			"fib@1:1 -> fib@1:1",
+			"fib@1:1 -> fib@1:1",
+		},
+	},
+	{
+		name: "bench_mutual_recursion.js",
+		edges: []string{
+			"f@1:1 -> m@5:1",
+			"f@1:1 -> f@1:1",
+			"m@5:1 -> f@1:1",
+			"m@5:1 -> m@5:1",
+			"range@9:1 -> global:Array",
+		},
+	},
+	{
+		name: "call-expression.js",
+		edges: []string{
+			"<module> -> global:a",
+			"<module> -> global:a",
+			"<module> -> global:a",
+		},
+	},
+	{
+		name: "issue69-70.js",
+		edges: []string{
+			"<module> -> import:react#createRef",
+			"LocationPicker.render@77:3 -> import:./services/placeholder#default",
+		},
+	},
+}
+
+func TestBuild(t *testing.T) {
+	for _, c := range casesBuild {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			g, err := Build(fixturetest.LoadUAST(t, c.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range g.Edges {
+				got = append(got, e.Caller+" -> "+e.Callee)
+				if g.Node(e.Caller) == nil || g.Node(e.Callee) == nil {
+					t.Errorf("edge refers to a missing node: %+v", e)
+				}
+			}
+			if !fixturetest.Equal(got, c.edges) {
+				t.Fatalf("unexpected edges:\n%q\nexpected:\n%q", got, c.edges)
+			}
+		})
+	}
+}
+
+func TestBuildImports(t *testing.T) {
+	g, err := Build(fixturetest.LoadUAST(t, "issue69-70.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := g.Node("import:react#createRef")
+	if n == nil {
+		t.Fatal("expected a node for React.createRef")
+	}
+	if n.Kind != KindImport || n.Module != "react" || n.Symbol != "createRef" {
+		t.Errorf("unexpected node: %+v", n)
+	}
+	m := g.Node("LocationPicker.render@77:3")
+	if m == nil || m.Kind != KindMethod {
+		t.Errorf("expected render to be a method, got: %+v", m)
+	}
+}
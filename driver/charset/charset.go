@@ -0,0 +1,234 @@
+// Package charset detects encodings of source files and transcodes them to
+// UTF-8, keeping track of byte offsets in the original input.
+//
+// Byte order marks of UTF-8, UTF-16 and UTF-32 are recognized. Files without
+// a BOM are detected as UTF-16 if most of the code units are ASCII, as UTF-8
+// if they are valid UTF-8, and as Windows-1252 otherwise. Windows-1252 is a
+// superset of the printable characters of Latin-1 (ISO-8859-1), thus Latin-1
+// files are decoded correctly as well.
+package charset
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is a character encoding of a source file.
+type Encoding string
+
+// Supported encodings.
+const (
+	UTF8        Encoding = "utf-8"
+	UTF16LE     Encoding = "utf-16le"
+	UTF16BE     Encoding = "utf-16be"
+	UTF32LE     Encoding = "utf-32le"
+	UTF32BE     Encoding = "utf-32be"
+	Windows1252 Encoding = "windows-1252"
+)
+
+// boms lists byte order marks. UTF-32LE must precede UTF-16LE.
+var boms = []struct {
+	bom string
+	enc Encoding
+}{
+	{"\x00\x00\xFE\xFF", UTF32BE},
+	{"\xFF\xFE\x00\x00", UTF32LE},
+	{"\xEF\xBB\xBF", UTF8},
+	{"\xFE\xFF", UTF16BE},
+	{"\xFF\xFE", UTF16LE},
+}
+
+// Detect returns the encoding of the source and the length of its byte order
+// mark, if any.
+func Detect(src string) (Encoding, int) {
+	for _, b := range boms {
+		if strings.HasPrefix(src, b.bom) {
+			return b.enc, len(b.bom)
+		}
+	}
+	// UTF-16 of ASCII is valid UTF-8, thus it is checked first
+	if enc := guessUTF16(src); enc != "" {
+		return enc, 0
+	}
+	if utf8.ValidString(src) {
+		return UTF8, 0
+	}
+	return Windows1252, 0
+}
+
+// guessSize is the number of bytes used to guess an encoding without a BOM.
+const guessSize = 4096
+
+// guessUTF16 detects UTF-16 by zero bytes: source code consists mostly of
+// ASCII characters, which have a zero high byte. It returns an empty string if
+// the source is not likely to be UTF-16.
+func guessUTF16(src string) Encoding {
+	if len(src) > guessSize {
+		src = src[:guessSize]
+	}
+	units := len(src) / 2
+	if units == 0 {
+		return ""
+	}
+	var even, odd int
+	for i := 0; i+1 < len(src); i += 2 {
+		if src[i] == 0 {
+			even++
+		}
+		if src[i+1] == 0 {
+			odd++
+		}
+	}
+	switch {
+	case odd*2 >= units && even*8 < odd:
+		return UTF16LE
+	case even*2 >= units && odd*8 < even:
+		return UTF16BE
+	}
+	return ""
+}
+
+// Text is a source transcoded to UTF-8.
+type Text struct {
+	// Encoding is the encoding of the original source.
+	Encoding Encoding
+	// BOM is the length of the byte order mark of the original source.
+	BOM int
+
+	text string
+	// spans map offsets of the text to the original; nil if they are the same
+	spans []span
+}
+
+// span is a run of characters that take the same number of bytes in the text
+// and in the original source.
+type span struct {
+	text, orig int // offsets of the first character
+	tw, ow     int // widths of each character
+}
+
+// Decode detects the encoding of the source and transcodes it to UTF-8.
+// Invalid sequences are replaced with U+FFFD. The byte order mark is removed.
+func Decode(src string) *Text {
+	enc, bom := Detect(src)
+	t := &Text{Encoding: enc, BOM: bom}
+	if enc == UTF8 {
+		t.text = src[bom:]
+		if bom != 0 {
+			t.spans = []span{{text: 0, orig: bom, tw: 1, ow: 1}}
+		}
+		return t
+	}
+	var (
+		buf strings.Builder
+		tmp [utf8.UTFMax]byte
+	)
+	buf.Grow(len(src))
+	for i := bom; i < len(src); {
+		r, n := decodeRune(enc, src[i:])
+		tw := utf8.EncodeRune(tmp[:], r)
+		t.add(buf.Len(), i, tw, n)
+		buf.Write(tmp[:tw])
+		i += n
+	}
+	t.text = buf.String()
+	if t.spans == nil {
+		// empty source with a BOM
+		t.spans = []span{{text: 0, orig: bom, tw: 1, ow: 1}}
+	}
+	return t
+}
+
+// add records a character of the text.
+func (t *Text) add(text, orig, tw, ow int) {
+	if n := len(t.spans); n != 0 {
+		if s := t.spans[n-1]; s.tw == tw && s.ow == ow {
+			return
+		}
+	}
+	t.spans = append(t.spans, span{text: text, orig: orig, tw: tw, ow: ow})
+}
+
+// decodeRune decodes the first character of the source in a given encoding.
+// It returns the character and the number of bytes it takes.
+func decodeRune(enc Encoding, s string) (rune, int) {
+	switch enc {
+	case UTF16LE, UTF16BE:
+		if len(s) < 2 {
+			return utf8.RuneError, len(s)
+		}
+		u := unit16(enc, s)
+		if !utf16.IsSurrogate(rune(u)) {
+			return rune(u), 2
+		}
+		if len(s) >= 4 {
+			if r := utf16.DecodeRune(rune(u), rune(unit16(enc, s[2:]))); r != utf8.RuneError {
+				return r, 4
+			}
+		}
+		return utf8.RuneError, 2
+	case UTF32LE, UTF32BE:
+		if len(s) < 4 {
+			return utf8.RuneError, len(s)
+		}
+		var u uint32
+		if enc == UTF32LE {
+			u = uint32(s[0]) | uint32(s[1])<<8 | uint32(s[2])<<16 | uint32(s[3])<<24
+		} else {
+			u = uint32(s[3]) | uint32(s[2])<<8 | uint32(s[1])<<16 | uint32(s[0])<<24
+		}
+		if r := rune(u); u <= utf8.MaxRune && utf8.ValidRune(r) {
+			return r, 4
+		}
+		return utf8.RuneError, 4
+	}
+	c := s[0]
+	if c >= 0x80 && c < 0xA0 {
+		return windows1252[c-0x80], 1
+	}
+	return rune(c), 1
+}
+
+func unit16(enc Encoding, s string) uint16 {
+	if enc == UTF16LE {
+		return uint16(s[0]) | uint16(s[1])<<8
+	}
+	return uint16(s[1]) | uint16(s[0])<<8
+}
+
+// windows1252 maps bytes 0x80-0x9F of Windows-1252. Undefined bytes are
+// mapped to C1 controls, as in Latin-1.
+var windows1252 = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+// String returns the text in UTF-8.
+func (t *Text) String() string {
+	return t.text
+}
+
+// Transcoded reports if offsets of the text differ from the original source.
+func (t *Text) Transcoded() bool {
+	return t.spans != nil
+}
+
+// Offset converts a byte offset in the text to a byte offset in the original
+// source. Offsets inside a character are rounded down to its start.
+func (t *Text) Offset(off int) int {
+	if t.spans == nil {
+		return off
+	}
+	i := sort.Search(len(t.spans), func(i int) bool {
+		return t.spans[i].text > off
+	}) - 1
+	if i < 0 {
+		return off
+	}
+	s := t.spans[i]
+	return s.orig + (off-s.text)/s.tw*s.ow
+}
@@ -0,0 +1,238 @@
+package charset_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/charset"
+	"github.com/bblfsh/javascript-driver/driver/normalizer"
+	"github.com/bblfsh/javascript-driver/driver/parser"
+)
+
+const fixturesDir = "../../fixtures"
+
+func TestDetect(t *testing.T) {
+	for _, c := range []struct {
+		src string
+		enc charset.Encoding
+		bom int
+	}{
+		{src: "", enc: charset.UTF8},
+		{src: "var a;", enc: charset.UTF8},
+		{src: "var ü;", enc: charset.UTF8},
+		{src: "\xEF\xBB\xBFvar a;", enc: charset.UTF8, bom: 3},
+		{src: "\xFF\xFEv\x00a\x00r\x00", enc: charset.UTF16LE, bom: 2},
+		{src: "\xFE\xFF\x00v\x00a\x00r", enc: charset.UTF16BE, bom: 2},
+		{src: "\xFF\xFE\x00\x00v\x00\x00\x00", enc: charset.UTF32LE, bom: 4},
+		{src: "\x00\x00\xFE\xFF\x00\x00\x00v", enc: charset.UTF32BE, bom: 4},
+		{src: "v\x00a\x00r\x00 \x00a\x00;\x00", enc: charset.UTF16LE},
+		{src: "\x00v\x00a\x00r\x00 \x00a\x00;", enc: charset.UTF16BE},
+		{src: "var caf\xE9;", enc: charset.Windows1252},
+		{src: "// \x93quoted\x94", enc: charset.Windows1252},
+	} {
+		enc, bom := charset.Detect(c.src)
+		if enc != c.enc || bom != c.bom {
+			t.Errorf("%q: expected %s (%d), got %s (%d)", c.src, c.enc, c.bom, enc, bom)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, c := range []struct {
+		src  string
+		text string
+		offs map[int]int
+	}{
+		{src: "a\xC3\xBCb", text: "aüb", offs: map[int]int{0: 0, 1: 1, 3: 3, 4: 4}},
+		{src: "\xEF\xBB\xBFab", text: "ab", offs: map[int]int{0: 3, 2: 5}},
+		{src: "\xEF\xBB\xBF", text: "", offs: map[int]int{0: 3}},
+		{src: "\xFF\xFE", text: "", offs: map[int]int{0: 2}},
+		{src: "caf\xE9 \x80", text: "café €", offs: map[int]int{3: 3, 5: 4, 6: 5, 9: 6}},
+		{src: "\xFF\xFEa\x00\xFC\x00\x3D\xD8\x00\xDEb\x00", text: "aü😀b", offs: map[int]int{0: 2, 1: 4, 3: 6, 7: 10, 8: 12}},
+		// unpaired surrogate and a truncated unit
+		{src: "\xFE\xFF\xD8\x3D\x00a\x00", text: "�a�", offs: map[int]int{3: 4, 4: 6, 7: 7}},
+		{src: "\xFF\xFE\x00\x00a\x00\x00\x00\x00\xD8\x00\x00", text: "a�", offs: map[int]int{1: 8, 4: 12}},
+	} {
+		text := charset.Decode(c.src)
+		if got := text.String(); got != c.text {
+			t.Errorf("%q: expected %q, got %q", c.src, c.text, got)
+			continue
+		}
+		for off, exp := range c.offs {
+			if got := text.Offset(off); got != exp {
+				t.Errorf("%q: expected offset %d for %d, got %d", c.src, exp, off, got)
+			}
+		}
+	}
+}
+
+// TestFixtures checks that positions of identifiers in fixtures in other
+// encodings point to the identifiers in the original source.
+func TestFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(fixturesDir, "encoding", "*.js"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(files) == 0 {
+		t.Fatal("no fixtures found")
+	}
+	ctx := context.Background()
+	d := charset.NewNative(parser.NewDriver(0))
+	for _, path := range files {
+		path := path
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src := string(data)
+			ast, err := d.Parse(ctx, src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err = normalizer.Transforms.Do(ctx, driver.ModeSemantic, src, ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// original offsets of all characters of the text
+			text := charset.Decode(src)
+			inv := make(map[uint32]int)
+			for i := range text.String() {
+				inv[uint32(text.Offset(i))] = i
+			}
+			inv[uint32(len(src))] = len(text.String())
+			var n int
+			nodes.WalkPreOrder(ast, func(nd nodes.Node) bool {
+				var id uast.Identifier
+				if uast.TypeOf(nd) != uast.TypeOf(id) {
+					return true
+				} else if err := uast.NodeAs(nd, &id); err != nil {
+					t.Fatal(err)
+				}
+				start, end := id.Positions.Start(), id.Positions.End()
+				if start == nil || end == nil {
+					return true
+				}
+				n++
+				ds, ok1 := inv[start.Offset]
+				de, ok2 := inv[end.Offset]
+				if !ok1 || !ok2 {
+					t.Errorf("position of %q is not at a character: %d-%d", id.Name, start.Offset, end.Offset)
+					return true
+				} else if got := text.String()[ds:de]; got != id.Name {
+					t.Errorf("wrong position of %q: %q", id.Name, got)
+				}
+				if line := strings.Count(text.String()[:ds], "\n") + 1; uint32(line) != start.Line {
+					t.Errorf("wrong line of %q: %d vs %d", id.Name, start.Line, line)
+				}
+				// columns are in bytes of the original source
+				if ls := start.Offset - start.Col + 1; ls != 0 {
+					if i, ok := inv[ls]; !ok || text.String()[i-1] != '\n' {
+						t.Errorf("wrong column of %q: %d", id.Name, start.Col)
+					}
+				} else if start.Line != 1 {
+					t.Errorf("wrong column of %q: %d", id.Name, start.Col)
+				}
+				return true
+			})
+			if n == 0 {
+				t.Fatal("no identifiers found")
+			}
+		})
+	}
+}
+
+func TestLines(t *testing.T) {
+	// lines are terminated by CRLF, CR, U+2028, U+2029 and LF; 😀 is two
+	// UTF-16 code units and four bytes
+	src := "a\r\nb\rc\u2028d\u2029😀e\nf"
+	ast := nodes.Array{}
+	for _, off := range []int{0, 3, 5, 7, 9, 11, 13} {
+		ast = append(ast, uast.Position{Offset: uint32(off)}.ToObject())
+	}
+	out, err := charset.FromUTF16Offset().OnCode(src).Do(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []uast.Position{
+		{Offset: 0, Line: 1, Col: 1},
+		{Offset: 3, Line: 2, Col: 1},
+		{Offset: 5, Line: 3, Col: 1},
+		{Offset: 9, Line: 4, Col: 1},
+		{Offset: 13, Line: 5, Col: 1},
+		{Offset: 17, Line: 5, Col: 5},
+		{Offset: 19, Line: 6, Col: 1},
+	}
+	for i, n := range out.(nodes.Array) {
+		if got := *uast.AsPosition(n.(nodes.Object)); got != exp[i] {
+			t.Errorf("expected %v, got %v", exp[i], got)
+		}
+	}
+}
+
+// TestVerifyLoc checks that native locations of all fixtures match their
+// offsets.
+func TestVerifyLoc(t *testing.T) {
+	var files []string
+	for _, pattern := range []string{"*.js", filepath.Join("encoding", "*.js")} {
+		list, err := filepath.Glob(filepath.Join(fixturesDir, pattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, list...)
+	}
+	if len(files) == 0 {
+		t.Fatal("no fixtures found")
+	}
+	ctx := context.Background()
+	d := charset.NewNative(charset.NewVerifier(parser.NewDriver(0)))
+	for _, path := range files {
+		path := path
+		if strings.HasPrefix(filepath.Base(path), "_") {
+			// syntax errors
+			continue
+		}
+		t.Run(strings.TrimPrefix(path, fixturesDir+"/"), func(t *testing.T) {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = d.Parse(ctx, string(data)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestVerifyLocMismatch(t *testing.T) {
+	const src = "var a;\u2028a = 1;"
+	ast, err := parser.NewDriver(0).Parse(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	} else if err = charset.VerifyLoc(src, ast); err != nil {
+		t.Fatal(err)
+	}
+	// the location of the second statement as if only LF ended lines
+	prog := ast.(nodes.Object)["program"].(nodes.Object)
+	stmt := prog["body"].(nodes.Array)[1].(nodes.Object)
+	stmt["loc"].(nodes.Object)["start"] = nodes.Object{"line": nodes.Int(1), "column": nodes.Int(7)}
+	err = charset.VerifyLoc(src, ast)
+	lerr, ok := err.(*charset.LocError)
+	if !ok {
+		t.Fatalf("expected a location error, got %v", err)
+	}
+	exp := []charset.LocMismatch{{
+		Type: "ExpressionStatement", Offset: 7,
+		Line: 1, Col: 7, ExpLine: 2, ExpCol: 0,
+	}}
+	if !reflect.DeepEqual(exp, lerr.Mismatches) {
+		t.Fatalf("unexpected mismatches: %v", lerr.Mismatches)
+	}
+}
@@ -0,0 +1,58 @@
+package charset
+
+import (
+	"context"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/transformer"
+)
+
+// NewNative wraps a native driver to transcode sources to UTF-8, as by Decode.
+// Native positions are relative to the transcoded text; FromUTF16Offset maps
+// them back to the original source.
+func NewNative(d driver.Native) driver.Native {
+	return &charsetDriver{Native: d}
+}
+
+type charsetDriver struct {
+	driver.Native
+}
+
+// Parse implements driver.Native.
+func (d *charsetDriver) Parse(ctx context.Context, src string) (nodes.Node, error) {
+	return d.Native.Parse(ctx, Decode(src).String())
+}
+
+// FromUTF16Offset is the same as positioner.FromUTF16Offset, but the source
+// is transcoded as by Decode. Offsets of the native AST are interpreted as
+// UTF-16 offsets in the transcoded text, while the resulting offsets and
+// columns are bytes of the original source. Lines are terminated as in
+// JavaScript, including CR, U+2028 and U+2029.
+func FromUTF16Offset() transformer.CodeTransformer {
+	return transcoded{}
+}
+
+type transcoded struct{}
+
+// OnCode implements transformer.CodeTransformer.
+func (transcoded) OnCode(code string) transformer.Transformer {
+	idx := newIndex(Decode(code))
+	return transformer.TransformObjFunc(func(o nodes.Object) (nodes.Object, bool, error) {
+		pos := uast.AsPosition(o)
+		if pos == nil {
+			return o, false, nil
+		}
+		p, err := idx.position(int(pos.Offset))
+		if err != nil {
+			return o, false, err
+		}
+		pos.Offset, pos.Line, pos.Col = p.Offset, p.Line, p.Col
+		o = o.CloneObject()
+		for k, v := range pos.ToObject() {
+			o[k] = v
+		}
+		return o, true, nil
+	})
+}
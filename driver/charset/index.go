@@ -0,0 +1,96 @@
+package charset
+
+import (
+	"sort"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/transformer/positioner"
+)
+
+// index maps UTF-16 offsets of a transcoded text to positions in the original
+// source. Lines are terminated as in JavaScript: by LF, CRLF, CR, U+2028 and
+// U+2029, thus they are the same as lines of the native locations.
+type index struct {
+	text  *Text
+	units *positioner.Index
+	// lines are byte offsets of the line starts in the text
+	lines []int
+}
+
+func newIndex(t *Text) *index {
+	s := t.String()
+	return &index{
+		text:  t,
+		units: positioner.NewIndex([]byte(s), &positioner.IndexOptions{Unicode: true}),
+		lines: lineStarts(s),
+	}
+}
+
+// lineStarts returns byte offsets of the lines of a text.
+func lineStarts(s string) []int {
+	lines := []int{0}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\n':
+			lines = append(lines, i+1)
+		case '\r':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+			lines = append(lines, i+1)
+		case 0xE2:
+			// U+2028 and U+2029 are encoded as E2 80 A8 and E2 80 A9
+			if i+2 < len(s) && s[i+1] == 0x80 && (s[i+2] == 0xA8 || s[i+2] == 0xA9) {
+				i += 2
+				lines = append(lines, i+1)
+			}
+		}
+	}
+	return lines
+}
+
+// line returns a one-based line of a byte offset in the text and the offset
+// of the line start.
+func (idx *index) line(off int) (int, int) {
+	line := sort.Search(len(idx.lines), func(i int) bool {
+		return idx.lines[i] > off
+	})
+	return line, idx.lines[line-1]
+}
+
+// position converts a UTF-16 offset in the text to a position in the original
+// source. Columns are in bytes of the original source; the first line starts
+// with the BOM.
+func (idx *index) position(off16 int) (uast.Position, error) {
+	off, err := idx.units.FromUTF16Offset(off16)
+	if err != nil {
+		return uast.Position{}, err
+	}
+	line, start := idx.line(off)
+	orig := idx.text.Offset(off)
+	if line == 1 {
+		start = 0
+	} else {
+		start = idx.text.Offset(start)
+	}
+	return uast.Position{
+		Offset: uint32(orig),
+		Line:   uint32(line),
+		Col:    uint32(orig - start + 1),
+	}, nil
+}
+
+// loc converts a UTF-16 offset in the text to a one-based line and a zero-based
+// column in UTF-16 code units, as in native locations.
+func (idx *index) loc(off16 int) (int, int, error) {
+	off, err := idx.units.FromUTF16Offset(off16)
+	if err != nil {
+		return 0, 0, err
+	}
+	line, start := idx.line(off)
+	start16, err := idx.units.ToUTF16Offset(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	return line, off16 - start16, nil
+}
@@ -0,0 +1,142 @@
+package charset
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+// EnvVerifyLoc enables the comparison of the native locations with the
+// positions computed from the offsets, if "1" or "true".
+const EnvVerifyLoc = "JS_DRIVER_VERIFY_LOC"
+
+// VerifyEnabled reports if the verification is enabled by EnvVerifyLoc.
+func VerifyEnabled() bool {
+	v, _ := strconv.ParseBool(os.Getenv(EnvVerifyLoc))
+	return v
+}
+
+// LocMismatch is a native location that differs from the one computed from
+// the offset of the node.
+type LocMismatch struct {
+	Type   string // type of the native node
+	Offset int    // UTF-16 offset in the transcoded text
+	// Line and Col are the native location, ExpLine and ExpCol are computed.
+	// Columns are zero-based UTF-16 code units, as in the native AST. Both
+	// computed values are zero if the offset is out of the text.
+	Line, Col       int
+	ExpLine, ExpCol int
+}
+
+func (m LocMismatch) String() string {
+	return fmt.Sprintf("%s at %d: %d:%d, expected %d:%d", m.Type, m.Offset, m.Line, m.Col, m.ExpLine, m.ExpCol)
+}
+
+// LocError lists all mismatching locations of a native AST.
+type LocError struct {
+	Mismatches []LocMismatch
+}
+
+// maxListed is the number of mismatches listed in the error message.
+const maxListed = 5
+
+func (e *LocError) Error() string {
+	list := e.Mismatches
+	if len(list) > maxListed {
+		list = list[:maxListed]
+	}
+	s := make([]string, 0, len(list))
+	for _, m := range list {
+		s = append(s, m.String())
+	}
+	msg := fmt.Sprintf("%d native locations differ from offsets: %s", len(e.Mismatches), strings.Join(s, "; "))
+	if len(e.Mismatches) > maxListed {
+		msg += "; ..."
+	}
+	return msg
+}
+
+// VerifyLoc checks that the line and column of each native node match its
+// offsets, with lines terminated as in JavaScript. The source is transcoded
+// as by Decode, thus both the original and the transcoded source are accepted.
+// It returns a LocError listing all mismatches, if any.
+func VerifyLoc(src string, ast nodes.Node) error {
+	idx := newIndex(Decode(src))
+	var list []LocMismatch
+	nodes.WalkPreOrder(ast, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok {
+			return true
+		}
+		loc, ok := obj["loc"].(nodes.Object)
+		if !ok {
+			return true
+		}
+		typ, _ := obj["type"].(nodes.String)
+		for _, k := range []string{"start", "end"} {
+			off, ok1 := toInt(obj[k])
+			pos, _ := loc[k].(nodes.Object)
+			line, ok2 := toInt(pos["line"])
+			col, ok3 := toInt(pos["column"])
+			if !ok1 || !ok2 || !ok3 {
+				continue
+			}
+			eline, ecol, err := idx.loc(off)
+			if err != nil {
+				eline, ecol = 0, 0
+			} else if line == eline && col == ecol {
+				continue
+			}
+			list = append(list, LocMismatch{
+				Type: string(typ), Offset: off,
+				Line: line, Col: col,
+				ExpLine: eline, ExpCol: ecol,
+			})
+		}
+		return true
+	})
+	if len(list) != 0 {
+		return &LocError{Mismatches: list}
+	}
+	return nil
+}
+
+// toInt converts a numeric native value to an int.
+func toInt(n nodes.Node) (int, bool) {
+	switch n := n.(type) {
+	case nodes.Int:
+		return int(n), true
+	case nodes.Uint:
+		return int(n), true
+	case nodes.Float:
+		return int(n), float64(int(n)) == float64(n)
+	}
+	return 0, false
+}
+
+// NewVerifier wraps a native driver to check the native locations of every
+// parsed AST, as by VerifyLoc. Mismatches are reported as transform failures.
+func NewVerifier(d driver.Native) driver.Native {
+	return &verifier{Native: d}
+}
+
+type verifier struct {
+	driver.Native
+}
+
+// Parse implements driver.Native.
+func (d *verifier) Parse(ctx context.Context, src string) (nodes.Node, error) {
+	ast, err := d.Native.Parse(ctx, src)
+	if err != nil {
+		return ast, err
+	}
+	if err = VerifyLoc(src, ast); err != nil {
+		return nil, driver.ErrTransformFailure.Wrap(err)
+	}
+	return ast, nil
+}
@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/bblfsh/sdk/v3/driver"
+
+	"github.com/bblfsh/javascript-driver/driver/pool"
+	"github.com/bblfsh/javascript-driver/driver/walk"
+)
+
+func init() {
+	register("batch", "parse all files in a directory and print one JSON record per file", runBatch)
+}
+
+// Statuses of batch records, as in the bblfsh protocol.
+const (
+	statusOK      = "ok"
+	statusError   = "error"   // the file cannot be parsed or transformed
+	statusFatal   = "fatal"   // the file cannot be read or the native parser failed
+	statusTimeout = "timeout" // the native parser did not respond in time and was restarted
+)
+
+// batchRecord is a result of parsing a single file.
+type batchRecord struct {
+	Path   string      `json:"path"`
+	Status string      `json:"status"`
+	Errors []string    `json:"errors,omitempty"`
+	UAST   interface{} `json:"uast,omitempty"`
+}
+
+// walkFlags adds flags for the files listed by walk.Walk.
+func walkFlags(fs *flag.FlagSet) *walk.Options {
+	var opt walk.Options
+	fs.Var((*stringList)(&opt.Include), "include", "glob of files to parse; can be repeated (default "+strings.Join(walk.DefaultInclude, ",")+")")
+	fs.Var((*stringList)(&opt.Exclude), "exclude", "glob of files or directories to skip; can be repeated")
+	fs.BoolVar(&opt.NoGitIgnore, "no-gitignore", false, "do not skip files ignored by .gitignore")
+	return &opt
+}
+
+// stringList is a flag that can be set multiple times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func runBatch(args []string) error {
+	fs, bin := newFlagSet("batch")
+	opt := walkFlags(fs)
+	modeName := fs.String("mode", "semantic", "transformation mode: native, annotated or semantic")
+	sourceMaps := fs.Bool("sourcemaps", false, "attach original positions from inline or adjacent source maps")
+	bundles := fs.Bool("bundles", false, "split webpack, browserify and rollup bundles into modules")
+	toks := tokensFlag(fs)
+	workers := fs.Int("workers", runtime.NumCPU(), "number of native parser processes")
+	timeout := timeoutFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: batch [flags] [dir]")
+	}
+	root := fs.Arg(0)
+	if root == "" {
+		root = "."
+	}
+	mode, err := driver.ParseMode(*modeName)
+	if err != nil {
+		return err
+	}
+	if *workers < 1 {
+		return fmt.Errorf("at least one worker is required")
+	}
+
+	if err := enableTokens(*toks); err != nil {
+		return err
+	}
+	d, err := startDriver(*bin, pool.Config{Size: *workers, Timeout: *timeout}, *bundles)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	d.sourceMaps = *sourceMaps
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	paths := make(chan string, *workers)
+	var walkErr error
+	go func() {
+		defer close(paths)
+		walkErr = walk.Walk(root, *opt, func(path string) error {
+			select {
+			case paths <- path:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	records := make(chan batchRecord, *workers)
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				records <- parseRecord(ctx, d, root, path, mode)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(records)
+	}()
+
+	var total, failed int
+	enc := json.NewEncoder(Stdout)
+	for rec := range records {
+		total++
+		if rec.Status != statusOK {
+			failed++
+		}
+		if err = enc.Encode(rec); err != nil {
+			// stop the walk and drain the workers
+			cancel()
+			for range records {
+			}
+			return err
+		}
+	}
+	if walkErr != nil {
+		return walkErr
+	}
+	fmt.Fprintf(Stderr, "parsed %d files, %d failed\n", total, failed)
+	return nil
+}
+
+// parseRecord parses a single file.
+func parseRecord(ctx context.Context, d *localDriver, root, path string, mode driver.Mode) batchRecord {
+	rec := batchRecord{Path: path, Status: statusOK}
+	ast, err := d.ParseFile(ctx, filepath.Join(root, filepath.FromSlash(path)), mode)
+	switch {
+	case err == nil:
+		rec.UAST = ast.Native()
+		return rec
+	case pool.ErrTimeout.Is(err):
+		rec.Status = statusTimeout
+	case driver.ErrDriverFailure.Is(err):
+		rec.Status = statusFatal
+	case driver.ErrSyntax.Is(err), driver.ErrTransformFailure.Is(err):
+		rec.Status = statusError
+	default:
+		rec.Status = statusFatal
+	}
+	rec.Errors = []string{err.Error()}
+	return rec
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/pool"
+)
+
+// readRecords decodes NDJSON batch records and sorts them by path.
+func readRecords(t *testing.T, out string) []batchRecord {
+	var recs []batchRecord
+	dec := json.NewDecoder(strings.NewReader(out))
+	for dec.More() {
+		var rec batchRecord
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].Path < recs[j].Path
+	})
+	return recs
+}
+
+func TestBatch(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		".gitignore":     "dist/\n",
+		"a.js":           validJS,
+		"bad.js":         invalidJS,
+		"src/view.jsx":   "x = <a/>;\n",
+		"src/page.html":  "<script>var x = 1;</script>",
+		"dist/bundle.js": validJS,
+		"vendor/lib.js":  validJS,
+		"readme.md":      "# readme\n",
+	})
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name  string
+		args  []string
+		paths []string
+	}{
+		{name: "default", paths: []string{"a.js", "bad.js", "src/page.html", "src/view.jsx", "vendor/lib.js"}},
+		{name: "exclude", args: []string{"-exclude", "vendor", "-exclude", "*.html"}, paths: []string{"a.js", "bad.js", "src/view.jsx"}},
+		{name: "include", args: []string{"-include", "*.jsx"}, paths: []string{"src/view.jsx"}},
+		{name: "gitignore", args: []string{"-no-gitignore", "-include", "bundle.js"}, paths: []string{"dist/bundle.js"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			args := append([]string{"batch", "-workers", "3"}, c.args...)
+			out, _, err := run(t, append(args, dir)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var paths []string
+			for _, rec := range readRecords(t, out) {
+				paths = append(paths, rec.Path)
+			}
+			if !reflect.DeepEqual(paths, c.paths) {
+				t.Fatalf("unexpected files: %q, expected: %q", paths, c.paths)
+			}
+		})
+	}
+
+	out, errs, err := run(t, "batch", "-mode", "native", "-exclude", "vendor", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(errs, "parsed 4 files, 1 failed") {
+		t.Fatalf("unexpected summary: %q", errs)
+	}
+	for _, rec := range readRecords(t, out) {
+		if rec.Path == "bad.js" {
+			if rec.Status != statusError || len(rec.Errors) != 1 || rec.UAST != nil {
+				t.Errorf("unexpected record of an invalid file: %+v", rec)
+			}
+			continue
+		}
+		if rec.Status != statusOK || len(rec.Errors) != 0 {
+			t.Errorf("unexpected record of %s: %+v", rec.Path, rec)
+		} else if root, _ := rec.UAST.(map[string]interface{}); root["type"] != "File" && root["type"] != "HTMLDocument" {
+			t.Errorf("unexpected native AST of %s: %v", rec.Path, rec.UAST)
+		}
+	}
+}
+
+func TestBatchArgs(t *testing.T) {
+	for _, args := range [][]string{
+		{"-mode", "none"},
+		{"-workers", "0"},
+		{"a", "b"},
+		{"-unknown"},
+	} {
+		if _, _, err := run(t, append([]string{"batch"}, args...)...); err == nil {
+			t.Errorf("expected an error for %q", args)
+		}
+	}
+	if _, _, err := run(t, "batch", filepath.Join(os.TempDir(), "no-such-dir")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+// errDriver fails to parse all files with the same error.
+type errDriver struct {
+	driver.Native
+	err error
+}
+
+func (d errDriver) Parse(ctx context.Context, src string) (nodes.Node, error) {
+	return nil, d.err
+}
+
+func TestParseRecord(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.js": validJS})
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name   string
+		path   string
+		err    error
+		status string
+	}{
+		{name: "syntax", path: "a.js", err: errors.New("unexpected token"), status: statusError},
+		{name: "timeout", path: "a.js", err: pool.ErrTimeout.New(), status: statusTimeout},
+		{name: "driver failure", path: "a.js", err: driver.ErrDriverFailure.New(), status: statusFatal},
+		{name: "missing file", path: "none.js", status: statusFatal},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := &localDriver{d: errDriver{err: c.err}}
+			rec := parseRecord(context.Background(), d, dir, c.path, driver.ModeSemantic)
+			if rec.Path != c.path || rec.Status != c.status || len(rec.Errors) != 1 || rec.UAST != nil {
+				t.Fatalf("unexpected record: %+v", rec)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bblfsh/sdk/v3/driver"
+
+	"github.com/bblfsh/javascript-driver/driver/callgraph"
+	"github.com/bblfsh/javascript-driver/driver/pool"
+)
+
+func init() {
+	register("callgraph", "print an intra-file call graph as JSON", runCallgraph)
+}
+
+func runCallgraph(args []string) error {
+	fs, bin := newFlagSet("callgraph")
+	timeout := timeoutFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: callgraph [flags] <file.js>")
+	}
+	d, err := startDriver(*bin, pool.Config{Size: 1, Timeout: *timeout}, false)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ast, err := d.ParseFile(context.Background(), fs.Arg(0), driver.ModeAnnotated)
+	if err != nil {
+		return err
+	}
+	g, err := callgraph.Build(ast)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
@@ -0,0 +1,250 @@
+// Package cli implements driver subcommands that work without bblfshd.
+//
+// Each command starts the native parser locally and runs the driver
+// transformation pipeline in-process.
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/driver/native"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/bundle"
+	"github.com/bblfsh/javascript-driver/driver/charset"
+	"github.com/bblfsh/javascript-driver/driver/html"
+	"github.com/bblfsh/javascript-driver/driver/limits"
+	"github.com/bblfsh/javascript-driver/driver/normalizer"
+	"github.com/bblfsh/javascript-driver/driver/parser"
+	"github.com/bblfsh/javascript-driver/driver/pool"
+	"github.com/bblfsh/javascript-driver/driver/sourcemap"
+	"github.com/bblfsh/javascript-driver/driver/tokens"
+)
+
+// EnvBackend selects the parser backend: "native" (default) runs the Babel
+// parser with Node.js, "go" runs an equivalent parser in-process.
+const EnvBackend = "JS_DRIVER_BACKEND"
+
+// NewNative creates a native driver for the backend selected by EnvBackend.
+// The native parser binary located at bin is not used by the "go" backend.
+// Both backends list tokens if tokens.EnvTokens is set.
+func NewNative(bin string) driver.Native {
+	switch b := os.Getenv(EnvBackend); b {
+	case "go":
+		d := parser.NewDriver(limits.FromEnv().MaxDepth)
+		if tokens.Enabled() {
+			d = d.WithTokens()
+		}
+		return d
+	case "", "native":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown value of %s: %q\n", EnvBackend, b)
+	}
+	return native.NewDriverAt(bin, native.UTF8)
+}
+
+// command is a single driver subcommand.
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{}
+
+func register(name, usage string, run func(args []string) error) {
+	commands[name] = command{usage: usage, run: run}
+}
+
+// Stdout and Stderr are the outputs used by all commands.
+var (
+	Stdout io.Writer = os.Stdout
+	Stderr io.Writer = os.Stderr
+)
+
+// Main runs a subcommand named by the first argument. It returns false if
+// the argument is not a known command; in this case the caller should start
+// the driver server instead.
+func Main(args []string) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	name := args[0]
+	if name == "help" {
+		printUsage()
+		return 0, true
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		return 0, false
+	}
+	if err := cmd.run(args[1:]); err != nil {
+		fmt.Fprintln(Stderr, err)
+		return 1, true
+	}
+	return 0, true
+}
+
+func printUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(Stderr, "usage: driver <command> [flags] [args]")
+	fmt.Fprintln(Stderr, "\nWithout a command the driver starts a gRPC server.\n\ncommands:")
+	for _, name := range names {
+		fmt.Fprintf(Stderr, "  %-12s %s\n", name, commands[name].usage)
+	}
+}
+
+// newFlagSet creates a flag set for a command with common flags.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(Stderr)
+	bin := fs.String("native", native.Binary, "path to the native parser binary")
+	return fs, bin
+}
+
+// timeoutFlag adds a flag for the per-file parse timeout.
+func timeoutFlag(fs *flag.FlagSet) *time.Duration {
+	return fs.Duration("timeout", time.Minute, "parse timeout per file; the native parser is restarted when it expires (0 to disable)")
+}
+
+// tokensFlag adds a flag to list tokens in the nodes that contain them.
+// The native parser reads the setting from the environment, thus it must be
+// applied by enableTokens before the driver starts.
+func tokensFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("tokens", false, "list tokens and comments in the nodes that contain them")
+}
+
+func enableTokens(enable bool) error {
+	if !enable {
+		return nil
+	}
+	return os.Setenv(tokens.EnvTokens, "true")
+}
+
+// localDriver runs the native parser and the driver transforms in-process.
+type localDriver struct {
+	d driver.Native
+
+	// sourceMaps enables attaching original positions to files with
+	// source maps, as found by sourcemap.Load.
+	sourceMaps bool
+	// tokens links the tokens listed by the parser to the nodes.
+	tokens bool
+}
+
+// NewPool creates a pool of native parsers located at bin, or of in-process
+// parsers if selected by EnvBackend. It is the native driver of the server
+// and of all commands. Processes that crash or exceed the timeout are
+// restarted by the pool. Inputs are checked against the limits set in the
+// environment. HTML documents and encodings other than UTF-8 are recognized.
+// If bundles is set, bundles are split into modules. Native locations are
+// verified if enabled in the environment.
+func NewPool(bin string, conf pool.Config, bundles bool) driver.Native {
+	var d driver.Native = limits.NewDriver(pool.New(conf, func() driver.Native {
+		return NewNative(bin)
+	}), limits.FromEnv())
+	if charset.VerifyEnabled() {
+		d = charset.NewVerifier(d)
+	}
+	if bundles {
+		d = bundle.NewNative(d)
+	}
+	return charset.NewNative(html.NewNative(d))
+}
+
+// startDriver starts the native parsers created by NewPool. Tokens are listed
+// if enabled in the environment.
+func startDriver(bin string, conf pool.Config, bundles bool) (*localDriver, error) {
+	d := NewPool(bin, conf, bundles)
+	if err := d.Start(); err != nil {
+		return nil, fmt.Errorf("cannot start native parser %q: %v", bin, err)
+	}
+	return &localDriver{d: d, tokens: tokens.Enabled()}, nil
+}
+
+// Parse parses the source and transforms it to a given mode.
+func (d *localDriver) Parse(ctx context.Context, src string, mode driver.Mode) (nodes.Node, error) {
+	ast, err := d.d.Parse(ctx, src)
+	return d.transform(ctx, src, mode, ast, err)
+}
+
+// ParseHTML parses all scripts of an HTML document and transforms them to a given mode.
+func (d *localDriver) ParseHTML(ctx context.Context, src string, mode driver.Mode) (nodes.Node, error) {
+	ast, err := html.Parse(ctx, d.d, charset.Decode(src).String())
+	return d.transform(ctx, src, mode, ast, err)
+}
+
+// ParseComponent parses all scripts of a single-file component and transforms them to a given mode.
+func (d *localDriver) ParseComponent(ctx context.Context, src string, mode driver.Mode) (nodes.Node, error) {
+	ast, err := html.ParseComponent(ctx, d.d, charset.Decode(src).String())
+	return d.transform(ctx, src, mode, ast, err)
+}
+
+// transform converts the native AST to a given mode, or wraps the parse error.
+func (d *localDriver) transform(ctx context.Context, src string, mode driver.Mode, ast nodes.Node, err error) (nodes.Node, error) {
+	if err != nil {
+		if !driver.ErrDriverFailure.Is(err) {
+			err = driver.ErrSyntax.Wrap(err)
+		}
+		return nil, err
+	}
+	ast, err = normalizer.Transforms.Do(ctx, mode, src, ast)
+	if err != nil {
+		return nil, driver.ErrTransformFailure.Wrap(err)
+	}
+	if d.tokens && mode != driver.ModeNative {
+		tokens.Link(ast)
+	}
+	return ast, nil
+}
+
+// ParseFile reads and parses a file. Files with HTML and component extensions
+// are parsed as HTML documents and single-file components. If source maps are
+// enabled, original positions are attached to the nodes.
+func (d *localDriver) ParseFile(ctx context.Context, path string, mode driver.Mode) (nodes.Node, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	src := string(data)
+	ast, err := d.parseNamed(ctx, path, src, mode)
+	if err != nil || !d.sourceMaps {
+		return ast, err
+	}
+	// the file was parsed successfully, thus invalid maps are ignored
+	m, merr := sourcemap.Load(path, src)
+	if merr != nil || m == nil {
+		return ast, nil
+	}
+	if err = sourcemap.Attach(ast, src, mode, m); err != nil {
+		return nil, driver.ErrTransformFailure.Wrap(err)
+	}
+	return ast, nil
+}
+
+// parseNamed parses the source as JavaScript, HTML or a component, depending
+// on the file name.
+func (d *localDriver) parseNamed(ctx context.Context, name, src string, mode driver.Mode) (nodes.Node, error) {
+	switch {
+	case html.IsHTML(name):
+		return d.ParseHTML(ctx, src, mode)
+	case html.IsComponent(name):
+		return d.ParseComponent(ctx, src, mode)
+	}
+	return d.Parse(ctx, src, mode)
+}
+
+func (d *localDriver) Close() error {
+	return d.d.Close()
+}
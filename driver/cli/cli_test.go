@@ -0,0 +1,330 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+
+	"github.com/bblfsh/javascript-driver/driver/coverage"
+)
+
+// writeTree creates files in a temporary directory.
+func writeTree(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// run runs a command with the in-process parser and returns its output.
+func run(t *testing.T, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+	cmd, ok := commands[args[0]]
+	if !ok {
+		t.Fatalf("unknown command: %q", args[0])
+	}
+	defer os.Unsetenv(EnvBackend)
+	os.Setenv(EnvBackend, "go")
+
+	var out, errs bytes.Buffer
+	Stdout, Stderr = &out, &errs
+	defer func() {
+		Stdout, Stderr = os.Stdout, os.Stderr
+	}()
+	err = cmd.run(args[1:])
+	return out.String(), errs.String(), err
+}
+
+func TestDispatch(t *testing.T) {
+	defer func() {
+		Stderr = os.Stderr
+	}()
+	var errs bytes.Buffer
+	Stderr = &errs
+	if _, ok := Main(nil); ok {
+		t.Fatal("no command should start the server")
+	}
+	if _, ok := Main([]string{"--unknown"}); ok {
+		t.Fatal("unknown command should start the server")
+	}
+	if code, ok := Main([]string{"help"}); !ok || code != 0 {
+		t.Fatalf("unexpected result of help: %d, %v", code, ok)
+	}
+	for name := range commands {
+		if !strings.Contains(errs.String(), name) {
+			t.Errorf("command %q is not listed by help", name)
+		}
+	}
+	errs.Reset()
+	if code, ok := Main([]string{"parse", "-mode", "none"}); !ok || code != 1 {
+		t.Fatalf("unexpected result of a failed command: %d, %v", code, ok)
+	} else if errs.Len() == 0 {
+		t.Fatal("error is not printed")
+	}
+}
+
+const (
+	validJS   = "function f(a) { return a + 'x'; }\n"
+	invalidJS = "function (\n"
+)
+
+func TestParse(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"a.js":       validJS,
+		"bad.js":     invalidJS,
+		"page.html":  "<html><body><script>var x = 1;</script></body></html>",
+		"comp.vue":   "<template><div/></template>\n<script>export default {};</script>\n",
+		"bundle.txt": "not parsed as a file name\n",
+	})
+	defer os.RemoveAll(dir)
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+	cases := []struct {
+		name string
+		args []string
+		// exp is a substring of the output
+		exp string
+		// err is a substring of the error
+		err string
+	}{
+		{name: "semantic", args: []string{path("a.js")}, exp: "uast:FunctionGroup"},
+		{name: "annotated", args: []string{"-mode", "annotated", path("a.js")}, exp: "'@role'"},
+		{name: "native json", args: []string{"-mode", "native", "-format", "json", path("a.js")}, exp: `"type": "FunctionDeclaration"`},
+		{name: "tokens", args: []string{"-mode", "annotated", "-tokens", path("a.js")}, exp: "tokens"},
+		{name: "html", args: []string{"-mode", "annotated", path("page.html")}, exp: "VariableDeclaration"},
+		{name: "component", args: []string{"-mode", "annotated", path("comp.vue")}, exp: "ExportDefaultDeclaration"},
+		{name: "unknown flag", args: []string{"-unknown"}, err: "flag provided but not defined"},
+		{name: "mode", args: []string{"-mode", "none", path("a.js")}, err: "mode"},
+		{name: "format", args: []string{"-format", "xml", path("a.js")}, err: `unsupported format: "xml"`},
+		{name: "arguments", args: []string{path("a.js"), path("a.js")}, err: "usage: parse"},
+		{name: "missing file", args: []string{path("none.js")}, err: "no such file"},
+		{name: "syntax error", args: []string{path("bad.js")}, err: "syntax error"},
+		{name: "source map", args: []string{"-sourcemap", path("none.map"), path("a.js")}, err: "no such file"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer os.Unsetenv("JS_DRIVER_TOKENS")
+			out, _, err := run(t, append([]string{"parse"}, c.args...)...)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expected error with %q, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out, c.exp) {
+				t.Fatalf("%q is not in the output:\n%s", c.exp, out)
+			}
+		})
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.js": validJS})
+	defer os.RemoveAll(dir)
+	out, _, err := run(t, "parse", "-mode", "native", "-format", "json", filepath.Join(dir, "a.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file map[string]interface{}
+	if err = json.Unmarshal([]byte(out), &file); err != nil {
+		t.Fatal(err)
+	} else if file["type"] != "File" {
+		t.Fatalf("unexpected root node: %v", file["type"])
+	}
+	if _, err = encoder("xml"); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
+
+func TestWalkFlags(t *testing.T) {
+	fs, _ := newFlagSet("test")
+	opt := walkFlags(fs)
+	err := fs.Parse([]string{"-include", "*.js", "-include", "*.ts", "-exclude", "vendor", "-no-gitignore", "dir"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(opt.Include, ",") != "*.js,*.ts" || strings.Join(opt.Exclude, ",") != "vendor" || !opt.NoGitIgnore {
+		t.Fatalf("unexpected options: %+v", *opt)
+	}
+	if fs.Arg(0) != "dir" {
+		t.Fatalf("unexpected arguments: %v", fs.Args())
+	}
+}
+
+// reportTree has a file with unannotated JSX nodes, a file that cannot be
+// parsed and a file that is not listed.
+var reportTree = map[string]string{
+	"a.js":      validJS,
+	"jsx.js":    "x = <a>text</a>;\n",
+	"bad.js":    invalidJS,
+	"readme.md": "# readme\n",
+}
+
+func TestCoverage(t *testing.T) {
+	dir := writeTree(t, reportTree)
+	defer os.RemoveAll(dir)
+
+	out, errs, err := run(t, "coverage", "-workers", "2", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(errs, "parsed 3 files, 1 failed") || !strings.Contains(errs, "bad.js") {
+		t.Fatalf("unexpected errors:\n%s", errs)
+	}
+	keys, err := coverage.ReadBaseline(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(keys, ",") != "JSXClosingElement,JSXElement.children,JSXElement.closingElement,JSXText" {
+		t.Fatalf("unexpected unannotated keys: %v", keys)
+	}
+
+	all, _, err := run(t, "coverage", "-all", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(all, "ReturnStatement\t0/1\n") {
+		t.Fatalf("annotated types are not listed:\n%s", all)
+	}
+
+	baseline := filepath.Join(dir, "baseline.txt")
+	if err = ioutil.WriteFile(baseline, []byte(out+"Program\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, errs, err = run(t, "coverage", "-baseline", baseline, dir); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(errs, "Program is annotated now") {
+		t.Fatalf("fixed keys are not reported:\n%s", errs)
+	}
+	if err = ioutil.WriteFile(baseline, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = run(t, "coverage", "-baseline", baseline, dir); err == nil || !strings.Contains(err.Error(), "JSXText]") {
+		t.Fatalf("expected a regression, got %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"-workers", "0", dir},
+		{dir, dir},
+		{"-baseline", filepath.Join(dir, "none.txt"), dir},
+	} {
+		if _, _, err = run(t, append([]string{"coverage"}, args...)...); err == nil {
+			t.Errorf("expected an error for %q", args)
+		}
+	}
+}
+
+func TestNormalized(t *testing.T) {
+	dir := writeTree(t, reportTree)
+	defer os.RemoveAll(dir)
+
+	out, errs, err := run(t, "normalized", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(errs, "parsed 3 files, 1 failed") || !strings.Contains(errs, "bad.js") {
+		t.Fatalf("unexpected errors:\n%s", errs)
+	}
+	report, err := coverage.ReadNormalization(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := report["FunctionDeclaration"]; c.Native != 1 || c.Normalized() != 1 {
+		t.Fatalf("unexpected counts of functions: %+v", c)
+	}
+
+	out, errs, err = run(t, "normalized", "-files", dir)
+	if err != nil {
+		t.Fatal(err)
+	} else if strings.Contains(errs, "bad.js") {
+		t.Fatalf("errors are printed with records:\n%s", errs)
+	}
+	records := make(map[string]normalizedRecord)
+	dec := json.NewDecoder(strings.NewReader(out))
+	for dec.More() {
+		var rec normalizedRecord
+		if err = dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		records[rec.Path] = rec
+	}
+	if len(records) != 3 {
+		t.Fatalf("unexpected records: %v", records)
+	}
+	if rec := records["bad.js"]; rec.Error == "" || rec.Total != nil {
+		t.Fatalf("unexpected record of an invalid file: %+v", rec)
+	}
+	if rec := records["a.js"]; rec.Error != "" || rec.Total == nil || rec.Total.Native == 0 {
+		t.Fatalf("unexpected record of a valid file: %+v", rec)
+	}
+
+	// the report is its own baseline
+	baseline := filepath.Join(dir, "baseline.txt")
+	if out, _, err = run(t, "normalized", dir); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(baseline, []byte(out), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = run(t, "normalized", "-baseline", baseline, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"-workers", "0", dir},
+		{dir, dir},
+		{"-baseline", filepath.Join(dir, "none.txt"), dir},
+	} {
+		if _, _, err = run(t, append([]string{"normalized"}, args...)...); err == nil {
+			t.Errorf("expected an error for %q", args)
+		}
+	}
+}
+
+func TestCallgraph(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"a.js": "function f() { g(); }\nfunction g() {}\n",
+	})
+	defer os.RemoveAll(dir)
+	out, _, err := run(t, "callgraph", filepath.Join(dir, "a.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"g"`) {
+		t.Fatalf("callee is not in the output:\n%s", out)
+	}
+	if _, _, err = run(t, "callgraph"); err == nil {
+		t.Fatal("expected an error without a file")
+	}
+}
+
+func TestTransformErrors(t *testing.T) {
+	d := &localDriver{}
+	if _, err := d.transform(context.Background(), "", driver.ModeSemantic, nil, driver.ErrDriverFailure.New()); !driver.ErrDriverFailure.Is(err) {
+		t.Fatalf("driver failures must be kept: %v", err)
+	}
+	if _, err := d.transform(context.Background(), "", driver.ModeSemantic, nil, os.ErrNotExist); !driver.ErrSyntax.Is(err) {
+		t.Fatalf("parse errors must be syntax errors: %v", err)
+	}
+}
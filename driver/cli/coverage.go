@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/bblfsh/sdk/v3/driver"
+
+	"github.com/bblfsh/javascript-driver/driver/coverage"
+	"github.com/bblfsh/javascript-driver/driver/pool"
+	"github.com/bblfsh/javascript-driver/driver/walk"
+)
+
+func init() {
+	register("coverage", "list native node types and fields without annotations in a directory", runCoverage)
+}
+
+func runCoverage(args []string) error {
+	fs, bin := newFlagSet("coverage")
+	opt := walkFlags(fs)
+	baselineFile := fs.String("baseline", "", "file with known unannotated keys; fail if other keys are unannotated")
+	all := fs.Bool("all", false, "list annotated types and fields as well")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of native parser processes")
+	timeout := timeoutFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: coverage [flags] [dir]")
+	}
+	root := fs.Arg(0)
+	if root == "" {
+		root = "."
+	}
+	if *workers < 1 {
+		return fmt.Errorf("at least one worker is required")
+	}
+	var baseline []string
+	if *baselineFile != "" {
+		f, err := os.Open(*baselineFile)
+		if err != nil {
+			return err
+		}
+		baseline, err = coverage.ReadBaseline(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	d, err := startDriver(*bin, pool.Config{Size: *workers, Timeout: *timeout}, false)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ctx := context.Background()
+	var (
+		mu     sync.Mutex
+		report = coverage.Report{}
+		total  int
+		failed int
+	)
+	err = parallelWalk(root, *opt, *workers, func(path string) {
+		ast, err := d.ParseFile(ctx, filepath.Join(root, filepath.FromSlash(path)), driver.ModeAnnotated)
+		mu.Lock()
+		defer mu.Unlock()
+		total++
+		if err != nil {
+			failed++
+			fmt.Fprintf(Stderr, "%s: %v\n", path, err)
+			return
+		}
+		report.Add(ast)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(Stderr, "parsed %d files, %d failed\n", total, failed)
+	if err = report.Write(Stdout, *all); err != nil {
+		return err
+	}
+	if *baselineFile == "" {
+		return nil
+	}
+	regressed, fixed := report.Compare(baseline)
+	for _, k := range fixed {
+		fmt.Fprintf(Stderr, "%s is annotated now\n", k)
+	}
+	if len(regressed) != 0 {
+		return fmt.Errorf("%d types and fields are not annotated: %v", len(regressed), regressed)
+	}
+	return nil
+}
+
+// parallelWalk calls fn for each file of a directory, as listed by walk.Walk,
+// from a given number of goroutines.
+func parallelWalk(root string, opt walk.Options, workers int, fn func(path string)) error {
+	paths := make(chan string, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				fn(path)
+			}
+		}()
+	}
+	err := walk.Walk(root, opt, func(path string) error {
+		paths <- path
+		return nil
+	})
+	close(paths)
+	wg.Wait()
+	return err
+}
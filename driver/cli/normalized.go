@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/coverage"
+	"github.com/bblfsh/javascript-driver/driver/normalizer"
+	"github.com/bblfsh/javascript-driver/driver/pool"
+)
+
+func init() {
+	register("normalized", "report the fraction of native nodes converted to semantic nodes in a directory", runNormalized)
+}
+
+// normalizedRecord is the semantic coverage of a single file.
+type normalizedRecord struct {
+	Path  string                 `json:"path"`
+	Error string                 `json:"error,omitempty"`
+	Total *coverage.Counts       `json:"total,omitempty"`
+	Types coverage.Normalization `json:"types,omitempty"`
+}
+
+func runNormalized(args []string) error {
+	fs, bin := newFlagSet("normalized")
+	opt := walkFlags(fs)
+	baselineFile := fs.String("baseline", "", "file written by this command before; fail if fewer nodes of any type are normalized")
+	files := fs.Bool("files", false, "print one JSON record per file instead of the totals by type")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of native parser processes")
+	timeout := timeoutFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: normalized [flags] [dir]")
+	}
+	root := fs.Arg(0)
+	if root == "" {
+		root = "."
+	}
+	if *workers < 1 {
+		return fmt.Errorf("at least one worker is required")
+	}
+	var baseline coverage.Normalization
+	if *baselineFile != "" {
+		f, err := os.Open(*baselineFile)
+		if err != nil {
+			return err
+		}
+		baseline, err = coverage.ReadNormalization(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	d, err := startDriver(*bin, pool.Config{Size: *workers, Timeout: *timeout}, false)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ctx := context.Background()
+	var (
+		mu     sync.Mutex
+		report = coverage.Normalization{}
+		enc    = json.NewEncoder(Stdout)
+		total  int
+		failed int
+		encErr error
+	)
+	err = parallelWalk(root, *opt, *workers, func(path string) {
+		rec := normalizedRecord{Path: path, Types: coverage.Normalization{}}
+		native, sem, err := d.parseSemantic(ctx, filepath.Join(root, filepath.FromSlash(path)))
+		if err != nil {
+			rec.Error = err.Error()
+		} else {
+			rec.Types.Add(native, sem)
+			t := rec.Types.Total()
+			rec.Total = &t
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		total++
+		if err != nil {
+			failed++
+			if !*files {
+				fmt.Fprintf(Stderr, "%s: %v\n", path, err)
+			}
+		} else {
+			report.Add(native, sem)
+		}
+		if *files && encErr == nil {
+			encErr = enc.Encode(rec)
+		}
+	})
+	if err != nil {
+		return err
+	} else if encErr != nil {
+		return encErr
+	}
+	fmt.Fprintf(Stderr, "parsed %d files, %d failed\n", total, failed)
+	if !*files {
+		if err = report.Write(Stdout); err != nil {
+			return err
+		}
+	}
+	if baseline == nil {
+		return nil
+	}
+	regressed, improved := report.Compare(baseline)
+	for _, typ := range improved {
+		fmt.Fprintf(Stderr, "%s: %.1f%% normalized, was %.1f%%\n", typ, report[typ].Percent(), baseline[typ].Percent())
+	}
+	if len(regressed) != 0 {
+		return fmt.Errorf("fewer nodes are normalized for %d types: %v", len(regressed), regressed)
+	}
+	return nil
+}
+
+// parseSemantic parses a file and returns its native AST and semantic UAST.
+func (d *localDriver) parseSemantic(ctx context.Context, path string) (nodes.Node, nodes.Node, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	src := string(data)
+	native, err := d.parseNamed(ctx, path, src, driver.ModeNative)
+	if err != nil {
+		return nil, nil, err
+	}
+	sem, err := normalizer.Transforms.Do(ctx, driver.ModeSemantic, src, native.Clone())
+	if err != nil {
+		return nil, nil, driver.ErrTransformFailure.Wrap(err)
+	}
+	return native, sem, nil
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/uastyaml"
+
+	"github.com/bblfsh/javascript-driver/driver/pool"
+	"github.com/bblfsh/javascript-driver/driver/sourcemap"
+)
+
+func init() {
+	register("parse", "parse a file and print its AST in a given mode", runParse)
+}
+
+func runParse(args []string) error {
+	fs, bin := newFlagSet("parse")
+	modeName := fs.String("mode", "semantic", "transformation mode: native, annotated or semantic")
+	format := fs.String("format", "yaml", "output format: json or yaml")
+	mapPath := fs.String("sourcemap", "", `source map of the file, or "auto" to use an inline or adjacent one`)
+	bundles := fs.Bool("bundles", false, "split webpack, browserify and rollup bundles into modules")
+	toks := tokensFlag(fs)
+	timeout := timeoutFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: parse [flags] [file.js|file.html|file.vue|file.svelte]")
+	}
+	mode, err := driver.ParseMode(*modeName)
+	if err != nil {
+		return err
+	}
+	encode, err := encoder(*format)
+	if err != nil {
+		return err
+	}
+	src, err := readSource(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := enableTokens(*toks); err != nil {
+		return err
+	}
+	d, err := startDriver(*bin, pool.Config{Size: 1, Timeout: *timeout}, *bundles)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ast, err := d.parseNamed(context.Background(), fs.Arg(0), src, mode)
+	if err != nil {
+		return err
+	}
+	if *mapPath != "" {
+		m, err := loadSourceMap(*mapPath, fs.Arg(0), src)
+		if err != nil {
+			return err
+		} else if m != nil {
+			if err = sourcemap.Attach(ast, src, mode, m); err != nil {
+				return err
+			}
+		}
+	}
+	return encode(Stdout, ast)
+}
+
+// loadSourceMap reads a source map from a given path. If the path is "auto",
+// the map is found as by sourcemap.Load, and may be nil.
+func loadSourceMap(path, name, src string) (*sourcemap.Map, error) {
+	if path != "auto" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return sourcemap.Parse(data)
+	}
+	if name == "" || name == "-" {
+		// the standard input may only have an inline map
+		m, err := sourcemap.ParseInline(src)
+		if err == sourcemap.ErrNotInline {
+			return nil, nil
+		}
+		return m, err
+	}
+	return sourcemap.Load(name, src)
+}
+
+// readSource reads a file, or the standard input if the path is empty or "-".
+func readSource(path string) (string, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "" || path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	return string(data), err
+}
+
+// encoder returns a function that writes the AST in a given format.
+func encoder(format string) (func(w io.Writer, n nodes.Node) error, error) {
+	switch format {
+	case "json":
+		return func(w io.Writer, n nodes.Node) error {
+			var v interface{}
+			if n != nil {
+				v = n.Native()
+			}
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(v)
+		}, nil
+	case "yaml":
+		return func(w io.Writer, n nodes.Node) error {
+			data, err := uastyaml.Marshal(n)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported format: %q", format)
+}
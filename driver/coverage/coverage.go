@@ -0,0 +1,166 @@
+// Package coverage reports native node types and fields that are not covered
+// by the annotation rules of the driver, and the fraction of native nodes of
+// each type that are converted to semantic nodes by the normalizer.
+//
+// The SDK assigns the Unannotated role to nodes that match no rule, thus the
+// coverage is computed from annotated UASTs. Nodes without roles are counted
+// as unannotated as well. Keys of a report are native types, like "JSXText",
+// and fields of native types, like "WhileStatement.test". Counts of a field
+// are the counts of the nodes it contains.
+//
+// Semantic coverage compares the native AST with the semantic UAST: native
+// nodes that are left in the UAST keep their type in the driver namespace,
+// while the normalized nodes have types of the uast namespace.
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/role"
+)
+
+// Stats counts the nodes of a native type or a field.
+type Stats struct {
+	Nodes       int `json:"nodes"`
+	Unannotated int `json:"unannotated"`
+}
+
+// Report is the annotation coverage of a set of annotated UASTs.
+type Report map[string]*Stats
+
+// Add counts all nodes of an annotated UAST.
+func (r Report) Add(ast nodes.Node) {
+	r.visit("", ast)
+}
+
+// visit counts the nodes of a subtree. The key of the field that contains the
+// subtree is empty for the root and for nodes without a native type.
+func (r Report) visit(field string, n nodes.Node) {
+	switch n := n.(type) {
+	case nodes.Array:
+		for _, v := range n {
+			r.visit(field, v)
+		}
+	case nodes.Object:
+		typ := uast.TypeOf(n)
+		if strings.HasPrefix(typ, uast.NS+":") {
+			// positions and other SDK nodes
+			return
+		}
+		if typ != "" {
+			un := unannotated(n)
+			r.count(typ, un)
+			if field != "" {
+				r.count(field, un)
+			}
+		}
+		for k, v := range n {
+			if strings.HasPrefix(k, "@") {
+				continue
+			}
+			key := ""
+			if typ != "" {
+				key = typ + "." + k
+			}
+			r.visit(key, v)
+		}
+	}
+}
+
+func (r Report) count(key string, unannotated bool) {
+	s := r[key]
+	if s == nil {
+		s = &Stats{}
+		r[key] = s
+	}
+	s.Nodes++
+	if unannotated {
+		s.Unannotated++
+	}
+}
+
+// unannotated reports if a node was not matched by any annotation rule, or
+// has no roles.
+func unannotated(n nodes.Object) bool {
+	for _, r := range uast.RolesOf(n) {
+		if r == role.Unannotated {
+			return true
+		}
+	}
+	return false
+}
+
+// Unannotated returns the sorted keys with at least one unannotated node.
+func (r Report) Unannotated() []string {
+	var keys []string
+	for k, s := range r {
+		if s.Unannotated != 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Compare returns unannotated keys that are not listed in a baseline, and the
+// keys of the baseline that are covered now. Keys of the baseline that are
+// not in the report are neither regressions nor improvements.
+func (r Report) Compare(baseline []string) (regressed, fixed []string) {
+	known := make(map[string]bool, len(baseline))
+	for _, k := range baseline {
+		known[k] = true
+		if s := r[k]; s != nil && s.Unannotated == 0 {
+			fixed = append(fixed, k)
+		}
+	}
+	for _, k := range r.Unannotated() {
+		if !known[k] {
+			regressed = append(regressed, k)
+		}
+	}
+	sort.Strings(fixed)
+	return regressed, fixed
+}
+
+// Write prints the unannotated keys with their counts, one per line. If all is
+// set, covered keys are printed as well. The output can be read as a baseline
+// by ReadBaseline.
+func (r Report) Write(w io.Writer, all bool) error {
+	keys := r.Unannotated()
+	if all {
+		keys = keys[:0]
+		for k := range r {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	}
+	for _, k := range keys {
+		s := r[k]
+		if _, err := fmt.Fprintf(w, "%s\t%d/%d\n", k, s.Unannotated, s.Nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadBaseline reads a list of known unannotated keys. Each line starts with
+// a key, the rest of the line is ignored. Empty lines and lines starting with
+// '#' are skipped.
+func ReadBaseline(r io.Reader) ([]string, error) {
+	var keys []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		keys = append(keys, f[0])
+	}
+	return keys, sc.Err()
+}
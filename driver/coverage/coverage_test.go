@@ -0,0 +1,107 @@
+package coverage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/role"
+
+	"github.com/bblfsh/javascript-driver/driver/internal/fixturetest"
+)
+
+const baselineFile = "testdata/baseline.txt"
+
+// fixtures returns the paths of all fixtures, including the encoding fixtures,
+// with a given extension added.
+func fixtures(t testing.TB, ext string) []string {
+	return fixturetest.Glob(t, "*.js"+ext, filepath.Join("encoding", "*.js"+ext))
+}
+
+func node(typ string, roles ...role.Role) nodes.Object {
+	obj := nodes.Object{uast.KeyType: nodes.String(typ)}
+	if len(roles) != 0 {
+		obj[uast.KeyRoles] = uast.RoleList(roles...)
+	}
+	return obj
+}
+
+func TestReport(t *testing.T) {
+	closing := node("JSXClosingElement", role.Unannotated)
+	closing[uast.KeyPos] = uast.Positions{uast.KeyStart: {Offset: 1}}.ToObject()
+	text := node("JSXText", role.Unannotated)
+	elem := node("JSXElement", role.Incomplete)
+	elem["closingElement"] = closing
+	elem["children"] = nodes.Array{text, node("JSXElement", role.Incomplete)}
+	// nodes without a type are transparent, but fields of their parents are
+	// lost; nodes without roles are unannotated
+	elem["extra"] = nodes.Object{"value": node("JSXText")}
+
+	r := Report{}
+	r.Add(elem)
+	exp := Report{
+		"JSXElement":                {Nodes: 2},
+		"JSXElement.children":       {Nodes: 2, Unannotated: 1},
+		"JSXElement.closingElement": {Nodes: 1, Unannotated: 1},
+		"JSXClosingElement":         {Nodes: 1, Unannotated: 1},
+		"JSXText":                   {Nodes: 2, Unannotated: 2},
+	}
+	if !reflect.DeepEqual(exp, r) {
+		t.Fatalf("unexpected report:\n%s", dump(r))
+	}
+	regressed, fixed := r.Compare([]string{"JSXText", "JSXElement", "JSXFragment"})
+	if exp := []string{"JSXClosingElement", "JSXElement.children", "JSXElement.closingElement"}; !reflect.DeepEqual(exp, regressed) {
+		t.Errorf("unexpected regressions: %q", regressed)
+	}
+	if exp := []string{"JSXElement"}; !reflect.DeepEqual(exp, fixed) {
+		t.Errorf("unexpected fixes: %q", fixed)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := r.Write(buf, false); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ReadBaseline(strings.NewReader("# comment\n\n" + buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(r.Unannotated(), keys) {
+		t.Fatalf("unexpected baseline: %q", keys)
+	}
+}
+
+// TestFixtures checks that the annotation coverage of the fixtures does not
+// regress.
+func TestFixtures(t *testing.T) {
+	r := Report{}
+	for _, path := range fixtures(t, ".uast") {
+		r.Add(fixturetest.Load(t, path))
+	}
+	f, err := os.Open(baselineFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	baseline, err := ReadBaseline(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	regressed, fixed := r.Compare(baseline)
+	for _, k := range regressed {
+		s := r[k]
+		t.Errorf("%s: %d of %d nodes are not annotated", k, s.Unannotated, s.Nodes)
+	}
+	for _, k := range fixed {
+		t.Errorf("%s is annotated now, remove it from %s", k, baselineFile)
+	}
+}
+
+func dump(r Report) string {
+	buf := bytes.NewBuffer(nil)
+	r.Write(buf, true)
+	return buf.String()
+}
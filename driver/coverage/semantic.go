@@ -0,0 +1,151 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+// Counts are the numbers of native nodes of a type, and of the nodes of the
+// same type left as is in the semantic UAST.
+type Counts struct {
+	Native int `json:"native"`
+	Raw    int `json:"raw"`
+}
+
+// Normalized returns the number of nodes converted to semantic nodes.
+func (c Counts) Normalized() int {
+	if c.Raw > c.Native {
+		return 0
+	}
+	return c.Native - c.Raw
+}
+
+// Percent returns the percentage of normalized nodes.
+func (c Counts) Percent() float64 {
+	if c.Native == 0 {
+		return 100
+	}
+	return 100 * float64(c.Normalized()) / float64(c.Native)
+}
+
+// less reports if a smaller fraction of nodes is normalized than in d.
+func (c Counts) less(d Counts) bool {
+	if c.Native == 0 || d.Native == 0 {
+		return false
+	}
+	return c.Normalized()*d.Native < d.Normalized()*c.Native
+}
+
+// Normalization is the semantic coverage of a set of files, by native type.
+type Normalization map[string]*Counts
+
+// Add counts nodes of a native AST and of the semantic UAST produced from it.
+// Native nodes are all nodes with a type, while the nodes left as is in the
+// semantic UAST are the nodes of the driver namespace.
+func (n Normalization) Add(native, semantic nodes.Node) {
+	nodes.WalkPreOrder(native, func(nd nodes.Node) bool {
+		if obj, ok := nd.(nodes.Object); ok {
+			if typ, ok := obj["type"].(nodes.String); ok {
+				n.counts(string(typ)).Native++
+			}
+		}
+		return true
+	})
+	nodes.WalkPreOrder(semantic, func(nd nodes.Node) bool {
+		if obj, ok := nd.(nodes.Object); ok {
+			typ := uast.TypeOf(obj)
+			if typ == "" || strings.HasPrefix(typ, uast.NS+":") {
+				return true
+			}
+			// types of the driver namespace are the native types
+			if i := strings.IndexByte(typ, ':'); i >= 0 {
+				typ = typ[i+1:]
+			}
+			n.counts(typ).Raw++
+		}
+		return true
+	})
+}
+
+func (n Normalization) counts(typ string) *Counts {
+	c := n[typ]
+	if c == nil {
+		c = &Counts{}
+		n[typ] = c
+	}
+	return c
+}
+
+// Total sums the counts of all types.
+func (n Normalization) Total() Counts {
+	var t Counts
+	for _, c := range n {
+		t.Native += c.Native
+		t.Raw += c.Native - c.Normalized()
+	}
+	return t
+}
+
+// Compare returns the types with a smaller fraction of normalized nodes than
+// in a baseline, and the types with a larger one. Types that are not in both
+// reports are skipped.
+func (n Normalization) Compare(baseline Normalization) (regressed, improved []string) {
+	for typ, c := range n {
+		b := baseline[typ]
+		switch {
+		case b == nil:
+		case c.less(*b):
+			regressed = append(regressed, typ)
+		case b.less(*c):
+			improved = append(improved, typ)
+		}
+	}
+	sort.Strings(regressed)
+	sort.Strings(improved)
+	return regressed, improved
+}
+
+// Write prints the number of normalized and native nodes of each type, and the
+// total percentage. The output can be read as a baseline by ReadNormalization.
+func (n Normalization) Write(w io.Writer) error {
+	types := make([]string, 0, len(n))
+	for typ := range n {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		c := n[typ]
+		if _, err := fmt.Fprintf(w, "%s\t%d/%d\t%.1f%%\n", typ, c.Normalized(), c.Native, c.Percent()); err != nil {
+			return err
+		}
+	}
+	t := n.Total()
+	_, err := fmt.Fprintf(w, "# total\t%d/%d\t%.1f%%\n", t.Normalized(), t.Native, t.Percent())
+	return err
+}
+
+// ReadNormalization reads a baseline written by Normalization.Write.
+func ReadNormalization(r io.Reader) (Normalization, error) {
+	n := Normalization{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		} else if len(f) < 2 {
+			return nil, fmt.Errorf("no counts of %q", f[0])
+		}
+		var norm, native int
+		if _, err := fmt.Sscanf(f[1], "%d/%d", &norm, &native); err != nil {
+			return nil, fmt.Errorf("wrong counts of %q: %v", f[0], err)
+		}
+		n[f[0]] = &Counts{Native: native, Raw: native - norm}
+	}
+	return n, sc.Err()
+}
@@ -0,0 +1,115 @@
+package coverage
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/internal/fixturetest"
+)
+
+const normalizedFile = "testdata/normalized.txt"
+
+func TestNormalization(t *testing.T) {
+	id := func(name string) nodes.Object {
+		return nodes.Object{"type": nodes.String("Identifier"), "name": nodes.String(name)}
+	}
+	sid := func(name string) nodes.Object {
+		return nodes.Object{uast.KeyType: nodes.String("uast:Identifier"), "Name": nodes.String(name)}
+	}
+	native := nodes.Object{
+		"type":   nodes.String("CallExpression"),
+		"callee": id("f"),
+		"arguments": nodes.Array{
+			id("a"),
+			nodes.Object{"type": nodes.String("SpreadElement"), "argument": id("b")},
+		},
+	}
+	semantic := nodes.Object{
+		uast.KeyType: nodes.String("javascript:CallExpression"),
+		"callee":     sid("f"),
+		"arguments": nodes.Array{
+			sid("a"),
+			nodes.Object{
+				uast.KeyType: nodes.String("javascript:SpreadElement"),
+				"argument":   sid("b"),
+			},
+		},
+	}
+	n := Normalization{}
+	n.Add(native, semantic)
+	exp := Normalization{
+		"CallExpression": {Native: 1, Raw: 1},
+		"SpreadElement":  {Native: 1, Raw: 1},
+		"Identifier":     {Native: 3},
+	}
+	if !reflect.DeepEqual(exp, n) {
+		t.Fatalf("unexpected counts: %v", n)
+	}
+	if tot := n.Total(); tot != (Counts{Native: 5, Raw: 2}) || tot.Percent() != 60 {
+		t.Fatalf("unexpected total: %v", tot)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := n.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadNormalization(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(n, got) {
+		t.Fatalf("unexpected baseline:\n%s", buf.String())
+	}
+
+	base := Normalization{
+		"CallExpression": {Native: 2, Raw: 1},
+		"Identifier":     {Native: 10, Raw: 1},
+		"SpreadElement":  {Native: 4, Raw: 4},
+		"Literal":        {Native: 1},
+	}
+	regressed, improved := n.Compare(base)
+	if exp := []string{"CallExpression"}; !reflect.DeepEqual(exp, regressed) {
+		t.Errorf("unexpected regressions: %q", regressed)
+	}
+	if exp := []string{"Identifier"}; !reflect.DeepEqual(exp, improved) {
+		t.Errorf("unexpected improvements: %q", improved)
+	}
+}
+
+// TestNormalizedFixtures checks that the fraction of normalized nodes of each
+// type in the fixtures is not smaller than in the baseline. The baseline is
+// written by the "normalized" command of the driver for the fixtures with
+// golden files:
+//
+//	driver normalized -exclude bench_nodes.js fixtures > driver/coverage/testdata/normalized.txt
+func TestNormalizedFixtures(t *testing.T) {
+	n := Normalization{}
+	for _, path := range fixtures(t, ".native") {
+		path = strings.TrimSuffix(path, ".native")
+		n.Add(fixturetest.Load(t, path+".native"), fixturetest.Load(t, path+".sem.uast"))
+	}
+	f, err := os.Open(normalizedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	baseline, err := ReadNormalization(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	regressed, improved := n.Compare(baseline)
+	for _, typ := range regressed {
+		t.Errorf("%s: %.1f%% normalized, was %.1f%%", typ, n[typ].Percent(), baseline[typ].Percent())
+	}
+	for _, typ := range improved {
+		t.Logf("%s: %.1f%% normalized, was %.1f%%; update %s", typ, n[typ].Percent(), baseline[typ].Percent(), normalizedFile)
+	}
+	if !reflect.DeepEqual(baseline, n) {
+		t.Logf("the fixtures changed; update %s", normalizedFile)
+	}
+}
@@ -1,67 +1,152 @@
 package fixtures
 
 import (
+	"io/ioutil"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/bblfsh/javascript-driver/driver/charset"
 	"github.com/bblfsh/javascript-driver/driver/normalizer"
 	"github.com/bblfsh/sdk/v3/driver"
 	"github.com/bblfsh/sdk/v3/driver/fixtures"
 	"github.com/bblfsh/sdk/v3/driver/native"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/transformer"
 	"github.com/bblfsh/sdk/v3/uast/transformer/positioner"
+	"github.com/bblfsh/sdk/v3/uast/uastyaml"
 )
 
 const projectRoot = "../../"
 
+// newDriver starts the native parser. Native locations of all fixtures are
+// checked against the positions computed from the offsets.
+func newDriver() driver.Native {
+	d := native.NewDriverAt(filepath.Join(projectRoot, "build/bin/native"), native.UTF8)
+	return charset.NewNative(charset.NewVerifier(d))
+}
+
+var semanticConfig = fixtures.SemanticConfig{
+	BlacklistTypes: []string{
+		"Identifier",
+		"StringLiteral",
+		"CommentLine",
+		"CommentBlock",
+		"BlockStatement",
+		"ImportDeclaration",
+		"ImportSpecifier",
+		"ImportDefaultSpecifier",
+		"ImportNamespaceSpecifier",
+		"FunctionDeclaration",
+	},
+}
+
+// literals are node types with tokens that are written in the source as is.
+var literals = []string{
+	"StringLiteral",
+	"RegExpLiteral",
+	"StringLiteral",
+	"BooleanLiteral",
+	"NumericLiteral",
+	"DirectiveLiteral",
+}
+
 var Suite = &fixtures.Suite{
-	Lang: "javascript",
-	Ext:  ".js",
-	Path: filepath.Join(projectRoot, fixtures.Dir),
-	NewDriver: func() driver.Native {
-		return native.NewDriverAt(filepath.Join(projectRoot, "build/bin/native"), native.UTF8)
+	Lang:       "javascript",
+	Ext:        ".js",
+	Path:       filepath.Join(projectRoot, fixtures.Dir),
+	NewDriver:  newDriver,
+	Transforms: normalizer.Transforms,
+	BenchName:  "issue69-70", // the largest fixture with goldens; bench_nodes has none, see driver/bench
+	Semantic:   semanticConfig,
+	VerifyTokens: []positioner.VerifyToken{
+		{Types: append([]string{"Identifier", "CommentLine", "CommentBlock"}, literals...)},
 	},
+}
+
+// EncodingSuite checks sources in encodings other than UTF-8. Tokens are
+// transcoded to UTF-8, thus identifiers and comments cannot match the original
+// source. Literals of these fixtures are ASCII.
+var EncodingSuite = &fixtures.Suite{
+	Lang:       "javascript",
+	Ext:        ".js",
+	Path:       filepath.Join(projectRoot, fixtures.Dir, "encoding"),
+	NewDriver:  newDriver,
 	Transforms: normalizer.Transforms,
-	BenchName:  "u2_class_method", // TODO: specify a largest file
-	Semantic: fixtures.SemanticConfig{
-		BlacklistTypes: []string{
-			"Identifier",
-			"StringLiteral",
-			"CommentLine",
-			"CommentBlock",
-			"BlockStatement",
-			"ImportDeclaration",
-			"ImportSpecifier",
-			"ImportDefaultSpecifier",
-			"ImportNamespaceSpecifier",
-			"FunctionDeclaration",
-		},
+	Semantic:   semanticConfig,
+	VerifyTokens: []positioner.VerifyToken{
+		{Types: literals},
 	},
+}
+
+// EscapedSuite checks identifiers written with unicode escapes. Tokens of such
+// identifiers are the escaped source, thus only literals and comments match
+// the source as is.
+var EscapedSuite = &fixtures.Suite{
+	Lang:       "javascript",
+	Ext:        ".js",
+	Path:       filepath.Join(projectRoot, fixtures.Dir, "escaped"),
+	NewDriver:  newDriver,
+	Transforms: normalizer.Transforms,
+	Semantic:   semanticConfig,
 	VerifyTokens: []positioner.VerifyToken{
-		{Types: []string{
-			// TODO(dennwc): issues with positions in native AST
-			//               in some cases a positional info of an
-			//               identifier covers the whole parameter
-			//               declaration
+		{Types: append([]string{"CommentLine", "CommentBlock"}, literals...)},
+	},
+}
 
-			//"Identifier",
+func TestJavascriptDriver(t *testing.T) {
+	Suite.RunTests(t)
+}
 
-			// TODO(dennwc): positions doesn't cover the "//" and "/*" tokens
+func TestJavascriptEncodings(t *testing.T) {
+	EncodingSuite.RunTests(t)
+}
 
-			// "CommentLine",
-			// "CommentBlock",
+func TestJavascriptEscaped(t *testing.T) {
+	EscapedSuite.RunTests(t)
+}
 
-			"StringLiteral",
-			"RegExpLiteral",
-			"StringLiteral",
-			"BooleanLiteral",
-			"NumericLiteral",
-			"DirectiveLiteral",
-		}},
-	},
+// TestJavascriptReversible checks that the native AST of every fixture can be
+// restored from its semantic UAST.
+func TestJavascriptReversible(t *testing.T) {
+	var files []string
+	for _, s := range []*fixtures.Suite{Suite, EncodingSuite, EscapedSuite} {
+		list, err := filepath.Glob(filepath.Join(s.Path, "*"+s.Ext+".native"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, list...)
+	}
+	for _, path := range files {
+		name := strings.TrimSuffix(filepath.Base(path), ".native")
+		t.Run(name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err := uastyaml.Unmarshal(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast = transform(t, ast, normalizer.Preprocess...)
+			sem := transform(t, ast.Clone(), normalizer.Normalize...)
+			got := transform(t, sem, normalizer.Denormalize)
+			if !nodes.Equal(ast, got) {
+				t.Fatal("native AST changed after the round trip")
+			}
+		})
+	}
 }
 
-func TestJavascriptDriver(t *testing.T) {
-	Suite.RunTests(t)
+func transform(t testing.TB, ast nodes.Node, tr ...transformer.Transformer) nodes.Node {
+	var err error
+	for _, tr := range tr {
+		ast, err = tr.Do(ast)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ast
 }
 
 func BenchmarkJavascriptDriver(b *testing.B) {
@@ -0,0 +1,61 @@
+//go:build go1.18
+// +build go1.18
+
+package fuzz
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/driver/native"
+
+	"github.com/bblfsh/javascript-driver/driver/parser"
+)
+
+const nativeBin = "../../build/bin/native"
+
+// maxInput limits the size of the generated programs, thus the time spent on
+// a single input.
+const maxInput = 1 << 10
+
+// FuzzTransforms runs the parsers and all transforms on generated programs.
+// Each program is parsed by the native driver, if it is built, and by the Go
+// parser. Programs that fail are saved as new fixtures, see save.
+//
+// Fuzzing requires Go 1.18, while the module supports older versions.
+func FuzzTransforms(f *testing.F) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		seed := make([]byte, 16+r.Intn(512))
+		r.Read(seed)
+		f.Add(seed)
+	}
+	drivers := map[string]driver.Native{"go": parser.NewDriver(0)}
+	if _, err := os.Stat(nativeBin); err == nil {
+		d := native.NewDriverAt(nativeBin, native.UTF8)
+		if err = d.Start(); err != nil {
+			f.Fatal(err)
+		}
+		defer d.Close()
+		drivers["native"] = d
+	} else {
+		f.Log("native driver is not built, only the Go parser is checked")
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) > maxInput {
+			data = data[:maxInput]
+		}
+		src := Generate(data)
+		for name, d := range drivers {
+			if err := check(d, src); err != nil {
+				path, serr := save(d, src)
+				if serr != nil {
+					t.Fatalf("%s: %v\ncannot save the program: %v\n%s", name, err, serr, src)
+				}
+				t.Fatalf("%s: %v\nthe program is saved to %s", name, err, path)
+			}
+		}
+	})
+}
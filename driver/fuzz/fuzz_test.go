@@ -0,0 +1,122 @@
+package fuzz
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/uastyaml"
+
+	"github.com/bblfsh/javascript-driver/driver/charset"
+	"github.com/bblfsh/javascript-driver/driver/internal/fixturetest"
+	"github.com/bblfsh/javascript-driver/driver/normalizer"
+)
+
+func TestGenerate(t *testing.T) {
+	data := []byte("some bytes to choose alternatives")
+	if a, b := Generate(data), Generate(data); a != b {
+		t.Fatalf("programs differ:\n%s\nvs\n%s", a, b)
+	}
+	if src := Generate(nil); src == "" {
+		t.Fatal("empty program")
+	}
+}
+
+// parse parses a program. Panics are returned as errors.
+func parse(d driver.Native, src string) (ast nodes.Node, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return d.Parse(context.Background(), src)
+}
+
+// check parses a program and runs the transforms in all modes. Panics are
+// returned as errors.
+func check(d driver.Native, src string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	ctx := context.Background()
+	// the AST is parsed for each mode: transforms change it in place
+	for _, mode := range []driver.Mode{driver.ModeAnnotated, driver.ModeSemantic} {
+		ast, err := parse(d, src)
+		if err != nil {
+			return fmt.Errorf("parse: %v", err)
+		}
+		if err = charset.VerifyLoc(src, ast); err != nil {
+			return err
+		}
+		out, err := normalizer.Transforms.Do(ctx, mode, src, ast)
+		if err != nil {
+			return fmt.Errorf("transform: %v", err)
+		}
+		if err = checkPositions(src, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPositions checks that all positions of a UAST are in the source and
+// that nodes do not end before they start.
+func checkPositions(src string, ast nodes.Node) error {
+	var err error
+	nodes.WalkPreOrder(ast, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok || err != nil {
+			return err == nil
+		} else if _, ok = obj[uast.KeyPos]; !ok {
+			return true
+		}
+		typ := uast.TypeOf(obj)
+		pos := uast.PositionsOf(obj)
+		for k, p := range pos {
+			if !p.HasLineCol() || p.Offset > uint32(len(src)) {
+				err = fmt.Errorf("invalid %s position of %s: %+v", k, typ, p)
+				return false
+			}
+		}
+		if start, end := pos.Start(), pos.End(); start != nil && end != nil && start.Offset > end.Offset {
+			err = fmt.Errorf("%s ends before it starts: %d > %d", typ, start.Offset, end.Offset)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// save writes a failing program to the fixtures directory, together with its
+// native AST, thus it fails the fixture tests until it is fixed and the
+// goldens are generated. Programs that cannot be parsed by the driver are
+// saved without the native AST, since there is none. It returns the path of
+// the program.
+func save(d driver.Native, src string) (string, error) {
+	sum := sha1.Sum([]byte(src))
+	path := filepath.Join(fixturetest.Dir, fmt.Sprintf("fuzz-%x.js", sum[:4]))
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		return path, err
+	}
+	ast, err := parse(d, src)
+	if err != nil {
+		return path, nil
+	}
+	data, err := uastyaml.Marshal(ast)
+	if err != nil {
+		return path, err
+	}
+	return path, ioutil.WriteFile(path+".native", data, 0644)
+}
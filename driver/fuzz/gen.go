@@ -0,0 +1,600 @@
+// Package fuzz generates syntactically valid JavaScript programs for fuzz
+// testing of the driver.
+//
+// Programs are derived from a grammar that covers statements, expressions,
+// classes, modules, JSX and Flow annotations, together with the syntax of the
+// Babel plugins enabled in the native parser. The generator is driven by a
+// byte string: each byte selects an alternative of a grammar rule, thus a
+// fuzzer mutating the bytes explores the grammar instead of random text.
+package fuzz
+
+import (
+	"strconv"
+	"strings"
+)
+
+// maxDepth limits the nesting of the generated programs.
+const maxDepth = 8
+
+// Generate returns a program derived from data. The same data always gives
+// the same program. When data is exhausted or the nesting is too deep, the
+// first alternative of each rule is chosen, which never nests further.
+func Generate(data []byte) string {
+	g := &gen{data: data}
+	var b strings.Builder
+	n := 1 + g.choose(8)
+	for i := 0; i < n; i++ {
+		b.WriteString(g.topLevel())
+		b.WriteString(g.newline())
+	}
+	return b.String()
+}
+
+// scope is the syntactic context of a statement or an expression.
+type scope struct {
+	fn    bool // return is allowed
+	async bool // await is allowed
+	gen   bool // yield is allowed
+	loop  bool // break and continue are allowed
+	sw    bool // break is allowed
+}
+
+type gen struct {
+	data  []byte
+	depth int
+	names int
+	// privates are the private names of the enclosing classes
+	privates []string
+	// exported is set after the default export
+	exported bool
+}
+
+// choose returns the next alternative of n.
+func (g *gen) choose(n int) int {
+	if len(g.data) == 0 || g.depth > maxDepth {
+		return 0
+	}
+	b := g.data[0]
+	g.data = g.data[1:]
+	return int(b) % n
+}
+
+// enter increments the nesting depth until the returned function is called.
+func (g *gen) enter() func() {
+	g.depth++
+	return func() { g.depth-- }
+}
+
+// name returns a new unique binding name.
+func (g *gen) name() string {
+	g.names++
+	return "v" + strconv.Itoa(g.names)
+}
+
+// freeNames are references to undeclared variables, including non-ASCII and
+// astral identifiers.
+var freeNames = []string{"a", "b", "c", "é", "𝒳", "$", "_x"}
+
+func (g *gen) ref() string {
+	if g.names != 0 && g.choose(2) == 0 {
+		return "v" + strconv.Itoa(1+g.choose(g.names))
+	}
+	return freeNames[g.choose(len(freeNames))]
+}
+
+// newline returns a line terminator; all JavaScript terminators are used.
+func (g *gen) newline() string {
+	switch g.choose(8) {
+	case 1:
+		return "\r\n"
+	case 2:
+		return "\r"
+	case 3:
+		return "\u2028"
+	case 4:
+		return "\u2029"
+	}
+	return "\n"
+}
+
+// list joins n items generated by fn.
+func (g *gen) list(n int, sep string, fn func() string) string {
+	items := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		items = append(items, fn())
+	}
+	return strings.Join(items, sep)
+}
+
+func (g *gen) topLevel() string {
+	switch g.choose(12) {
+	case 1:
+		return g.importDecl()
+	case 2:
+		return "export " + g.declaration(scope{})
+	case 3:
+		if !g.exported {
+			g.exported = true
+			return "export default (" + g.expr(scope{}) + ");"
+		}
+	}
+	return g.stmt(scope{})
+}
+
+func (g *gen) importDecl() string {
+	src := strconv.Quote("m" + strconv.Itoa(g.choose(3)))
+	switch g.choose(6) {
+	case 1:
+		return "import " + g.name() + " from " + src + ";"
+	case 2:
+		return "import { " + g.ref() + " as " + g.name() + " } from " + src + ";"
+	case 3:
+		return "import * as " + g.name() + " from " + src + ";"
+	case 4:
+		return "import type { T as " + g.name() + " } from " + src + ";"
+	case 5:
+		return "import " + g.name() + ", { " + g.name() + " } from " + src + ";"
+	}
+	return "import " + src + ";"
+}
+
+// declaration is a statement that can be exported.
+func (g *gen) declaration(c scope) string {
+	switch g.choose(5) {
+	case 1:
+		return g.function(c, "function "+g.name())
+	case 2:
+		return g.class(c, g.name())
+	case 3:
+		return "type " + g.name() + " = " + g.typ() + ";"
+	case 4:
+		return g.varDecl(c) + ";"
+	}
+	return "const " + g.name() + " = " + g.expr(c) + ";"
+}
+
+func (g *gen) block(c scope) string {
+	defer g.enter()()
+	return "{" + g.newline() + g.list(g.choose(4), g.newline(), func() string {
+		return g.stmt(c)
+	}) + g.newline() + "}"
+}
+
+func (g *gen) stmt(c scope) string {
+	defer g.enter()()
+	switch g.choose(24) {
+	case 1:
+		return g.varDecl(c) + ";"
+	case 2:
+		s := "if (" + g.expr(c) + ") " + g.block(c)
+		if g.choose(2) == 1 {
+			s += " else " + g.block(c)
+		}
+		return s
+	case 3:
+		loop := c
+		loop.loop = true
+		i := g.name()
+		return "for (let " + i + " = 0; " + i + " < " + g.expr(c) + "; " + i + "++) " + g.block(loop)
+	case 4:
+		loop := c
+		loop.loop = true
+		kw := []string{" in ", " of "}[g.choose(2)]
+		return "for (const " + g.name() + kw + g.expr(c) + ") " + g.block(loop)
+	case 5:
+		loop := c
+		loop.loop = true
+		if g.choose(2) == 1 {
+			return "do " + g.block(loop) + " while (" + g.expr(c) + ");"
+		}
+		return "while (" + g.expr(c) + ") " + g.block(loop)
+	case 6:
+		return g.declaration(c)
+	case 7:
+		if c.fn {
+			return "return " + g.expr(c) + ";"
+		}
+	case 8:
+		if c.loop {
+			return []string{"break;", "continue;"}[g.choose(2)]
+		} else if c.sw {
+			return "break;"
+		}
+	case 9:
+		return "throw " + g.expr(c) + ";"
+	case 10:
+		s := "try " + g.block(c)
+		switch g.choose(3) {
+		case 0:
+			s += " catch (" + g.name() + ") " + g.block(c)
+		case 1:
+			s += " catch " + g.block(c)
+		case 2:
+			s += " finally " + g.block(c)
+		}
+		return s
+	case 11:
+		sw := c
+		sw.sw = true
+		cases := g.list(g.choose(4), g.newline(), func() string {
+			return "case " + g.expr(c) + ":" + g.newline() + g.stmt(sw)
+		})
+		if g.choose(2) == 1 {
+			cases += g.newline() + "default:" + g.newline() + g.stmt(sw)
+		}
+		return "switch (" + g.expr(c) + ") {" + g.newline() + cases + g.newline() + "}"
+	case 12:
+		return "interface " + g.name() + " { " + g.name() + ": " + g.typ() + "; " + g.name() + "(" + g.name() + ": " + g.typ() + "): " + g.typ() + " }"
+	case 13:
+		return "@" + g.ref() + g.newline() + g.class(c, g.name())
+	case 14:
+		return g.block(c)
+	case 15:
+		return ";"
+	case 16:
+		return "/* " + g.text() + " */ " + g.stmt(c)
+	case 17:
+		return "// " + g.text() + g.newline() + g.stmt(c)
+	case 18:
+		return g.function(c, "async function "+g.name())
+	case 19:
+		return g.function(c, "function* "+g.name())
+	case 20:
+		return "type " + g.name() + "<T> = " + g.typ() + " | T;"
+	}
+	return g.exprStmt(c)
+}
+
+// exprStmt is an expression statement. Expressions that would be parsed as
+// declarations or blocks are parenthesized.
+func (g *gen) exprStmt(c scope) string {
+	e := g.expr(c)
+	for _, p := range []string{"{", "function", "async", "class", "let"} {
+		if strings.HasPrefix(e, p) {
+			return "(" + e + ");"
+		}
+	}
+	return e + ";"
+}
+
+func (g *gen) varDecl(c scope) string {
+	kw := []string{"var", "let", "const"}[g.choose(3)]
+	s := kw + " " + g.name()
+	if g.choose(2) == 1 {
+		s += ": " + g.typ()
+	}
+	if kw == "const" || g.choose(2) == 1 {
+		s += " = " + g.expr(c)
+	}
+	return s
+}
+
+// params returns a parameter list with unique names. Default values cannot
+// contain await and yield, thus they are generated in an empty context.
+func (g *gen) params() string {
+	c := scope{}
+	return "(" + g.list(g.choose(4), ", ", func() string {
+		p := g.name()
+		switch g.choose(4) {
+		case 1:
+			p += ": " + g.typ()
+		case 2:
+			p += " = " + g.expr(c)
+		case 3:
+			p += "?: " + g.typ()
+		}
+		return p
+	}) + ")"
+}
+
+// function returns a function with a given head, like "function f". Functions
+// named with "async" are async, functions named with "*" are generators.
+func (g *gen) function(c scope, head string) string {
+	body := scope{
+		fn:    true,
+		async: strings.Contains(head, "async"),
+		gen:   strings.Contains(head, "*"),
+	}
+	s := head
+	// "<" after "*" starts a JSX element
+	if g.choose(3) == 1 && !strings.HasSuffix(head, "*") {
+		s += "<T>"
+	}
+	s += g.params()
+	if g.choose(2) == 1 {
+		s += ": " + g.typ()
+	}
+	return s + " " + g.block(body)
+}
+
+func (g *gen) class(c scope, name string) string {
+	defer g.enter()()
+	s := "class " + name
+	if g.choose(3) == 1 {
+		s += " extends (" + g.expr(c) + ")"
+	}
+	priv := "#" + g.name()
+	g.privates = append(g.privates, priv)
+	defer func() { g.privates = g.privates[:len(g.privates)-1] }()
+	members := []string{priv + " = " + g.expr(scope{}) + ";"}
+	members = append(members, g.list(g.choose(5), g.newline(), g.member))
+	return s + " {" + g.newline() + strings.Join(members, g.newline()) + g.newline() + "}"
+}
+
+// member returns a class member. Initializers of properties cannot contain
+// await and yield, thus members are generated in an empty context.
+func (g *gen) member() string {
+	c := scope{}
+	switch g.choose(8) {
+	case 1:
+		return "static " + g.function(c, g.name())
+	case 2:
+		return "get " + g.name() + "() " + g.block(scope{fn: true})
+	case 3:
+		return "set " + g.name() + "(" + g.name() + ") " + g.block(scope{fn: true})
+	case 4:
+		return g.name() + ": " + g.typ() + " = " + g.expr(c) + ";"
+	case 5:
+		return "@" + g.ref() + " " + g.function(c, g.name())
+	case 6:
+		return g.function(c, "async "+g.name())
+	case 7:
+		return g.function(c, "*"+g.name())
+	}
+	return g.function(c, g.name())
+}
+
+// text is a comment or a JSX text, with non-ASCII characters.
+func (g *gen) text() string {
+	return []string{"x", "é", "😀 a", "b c"}[g.choose(4)]
+}
+
+// operand returns an expression that can be used as an object of a member
+// expression or a callee.
+func (g *gen) operand(c scope) string {
+	if g.choose(2) == 0 {
+		return g.ref()
+	}
+	return "(" + g.expr(c) + ")"
+}
+
+var binaryOps = []string{
+	"+", "-", "*", "/", "%", "**", "==", "!=", "===", "!==", "<", "<=", ">", ">=",
+	"<<", ">>", ">>>", "&", "|", "^", "&&", "||", "??", "in", "instanceof", "|>",
+}
+
+var assignOps = []string{"=", "+=", "-=", "*=", "**=", ">>>=", "|="}
+
+func (g *gen) expr(c scope) string {
+	defer g.enter()()
+	switch g.choose(32) {
+	case 1:
+		return g.number()
+	case 2:
+		return g.str()
+	case 3:
+		return "(" + g.expr(c) + " " + binaryOps[g.choose(len(binaryOps))] + " " + g.expr(c) + ")"
+	case 4:
+		op := []string{"!", "-", "+", "~", "typeof ", "void "}[g.choose(6)]
+		return "(" + op + g.expr(c) + ")"
+	case 5:
+		return g.operand(c) + "(" + g.args(c) + ")"
+	case 6:
+		switch g.choose(4) {
+		case 1:
+			return g.operand(c) + "[" + g.expr(c) + "]"
+		case 2:
+			return g.operand(c) + "?." + g.name()
+		case 3:
+			return g.operand(c) + "?.[" + g.expr(c) + "]"
+		}
+		return g.operand(c) + "." + g.name()
+	case 7:
+		return g.arrow(c)
+	case 8:
+		return "(" + g.function(c, []string{"function", "async function", "function*"}[g.choose(3)]) + ")"
+	case 9:
+		return g.object(c)
+	case 10:
+		return "[" + g.list(g.choose(4), ", ", func() string {
+			switch g.choose(4) {
+			case 1:
+				return "..." + g.expr(c)
+			case 2:
+				return ""
+			}
+			return g.expr(c)
+		}) + "]"
+	case 11:
+		return g.template(c)
+	case 12:
+		cons := g.expr(c)
+		if strings.HasPrefix(cons, "(") {
+			// a parenthesized consequent could be parameters of an arrow
+			// function with a Flow return type
+			cons = "+" + cons
+		}
+		return "(" + g.expr(c) + " ? " + cons + " : " + g.expr(c) + ")"
+	case 13:
+		return "(" + g.ref() + " " + assignOps[g.choose(len(assignOps))] + " " + g.expr(c) + ")"
+	case 14:
+		return "new " + g.ref() + "(" + g.args(c) + ")"
+	case 15:
+		return g.jsx(c)
+	case 16:
+		return "(/" + []string{"a+b", "[é-ü]", "\\d{2,}", "(x|y)?"}[g.choose(4)] + "/" + []string{"", "g", "iu"}[g.choose(3)] + ")"
+	case 17:
+		return "(" + g.expr(c) + ": " + g.typ() + ")"
+	case 18:
+		return "(class " + g.name() + " { " + g.member() + " })"
+	case 19:
+		if c.async {
+			return "(await " + g.expr(c) + ")"
+		}
+	case 20:
+		if c.gen {
+			return "(yield " + g.expr(c) + ")"
+		}
+	case 21:
+		return "(" + g.expr(c) + ", " + g.expr(c) + ")"
+	case 22:
+		return []string{"this", "null", "true", "false", "import.meta"}[g.choose(5)]
+	case 23:
+		return "import(" + g.str() + ")"
+	case 24:
+		return "(do " + g.block(scope{async: c.async, gen: c.gen}) + ")"
+	case 25:
+		return "(" + g.ref() + "::" + g.ref() + ")"
+	case 26:
+		if n := len(g.privates); n != 0 {
+			return "this." + g.privates[g.choose(n)]
+		}
+	case 27:
+		return g.ref() + "`" + g.text() + "`"
+	case 28:
+		return "(" + g.ref() + []string{"++", "--"}[g.choose(2)] + ")"
+	}
+	return g.ref()
+}
+
+func (g *gen) args(c scope) string {
+	return g.list(g.choose(4), ", ", func() string {
+		if g.choose(4) == 1 {
+			return "..." + g.expr(c)
+		}
+		return g.expr(c)
+	})
+}
+
+// arrow returns a parenthesized arrow function.
+func (g *gen) arrow(c scope) string {
+	body := scope{fn: true, async: g.choose(2) == 1}
+	s := "("
+	if body.async {
+		s += "async "
+	}
+	s += g.params()
+	if g.choose(2) == 1 {
+		s += ": " + g.typ()
+	}
+	s += " => "
+	if g.choose(2) == 1 {
+		s += g.block(body)
+	} else if e := g.expr(body); strings.HasPrefix(e, "{") {
+		s += "(" + e + ")"
+	} else {
+		s += e
+	}
+	return s + ")"
+}
+
+func (g *gen) object(c scope) string {
+	defer g.enter()()
+	return "{" + g.list(g.choose(5), ", ", func() string {
+		switch g.choose(8) {
+		case 1:
+			return g.ref()
+		case 2:
+			return "[" + g.expr(c) + "]: " + g.expr(c)
+		case 3:
+			return "..." + g.expr(c)
+		case 4:
+			return g.function(c, g.name())
+		case 5:
+			return "get " + g.name() + "() " + g.block(scope{fn: true})
+		case 6:
+			return g.str() + ": " + g.expr(c)
+		case 7:
+			return g.function(c, "*"+g.name())
+		}
+		return g.name() + ": " + g.expr(c)
+	}) + "}"
+}
+
+func (g *gen) number() string {
+	return []string{"0", "1.5", "0x1F", "1e3", "1_000", "10n", ".5", "0b101"}[g.choose(8)]
+}
+
+func (g *gen) str() string {
+	return []string{`"s"`, `'é😀'`, `"\u{1F600}"`, `"a\nb"`, `''`, `"\x41"`}[g.choose(6)]
+}
+
+func (g *gen) template(c scope) string {
+	defer g.enter()()
+	s := "`" + g.text()
+	for i := g.choose(3); i > 0; i-- {
+		s += "${" + g.expr(c) + "}" + []string{"", "\r\n", " é"}[g.choose(3)]
+	}
+	return s + "`"
+}
+
+func (g *gen) jsx(c scope) string {
+	defer g.enter()()
+	if g.choose(4) == 1 {
+		return "<>" + g.jsxChildren(c) + "</>"
+	}
+	tag := []string{"div", "A", "A.B", "svg:rect"}[g.choose(4)]
+	attrs := g.list(g.choose(4), "", func() string {
+		switch g.choose(4) {
+		case 1:
+			return " " + g.name() + "={" + g.expr(c) + "}"
+		case 2:
+			return " {..." + g.expr(c) + "}"
+		case 3:
+			return " " + g.name()
+		}
+		return " " + g.name() + `="` + g.text() + `"`
+	})
+	if g.choose(2) == 0 {
+		return "<" + tag + attrs + " />"
+	}
+	return "<" + tag + attrs + ">" + g.jsxChildren(c) + "</" + tag + ">"
+}
+
+func (g *gen) jsxChildren(c scope) string {
+	return g.list(g.choose(4), "", func() string {
+		switch g.choose(4) {
+		case 1:
+			return "{" + g.expr(c) + "}"
+		case 2:
+			return g.jsx(c)
+		case 3:
+			return "{/* " + g.text() + " */}"
+		}
+		return g.text()
+	})
+}
+
+// typ returns a Flow type annotation.
+func (g *gen) typ() string {
+	defer g.enter()()
+	switch g.choose(14) {
+	case 1:
+		return "?" + g.typ()
+	case 2:
+		return "Array<" + g.typ() + ">"
+	case 3:
+		return g.typ() + " | " + g.typ()
+	case 4:
+		return "{ " + g.name() + ": " + g.typ() + ", " + g.name() + "?: " + g.typ() + " }"
+	case 5:
+		return "{| " + g.name() + ": " + g.typ() + " |}"
+	case 6:
+		return "((" + g.name() + ": " + g.typ() + ") => " + g.typ() + ")"
+	case 7:
+		return "[" + g.typ() + ", " + g.typ() + "]"
+	case 8:
+		return []string{`"lit"`, "1", "true", "null", "void", "mixed", "any"}[g.choose(7)]
+	case 9:
+		return "typeof " + g.ref()
+	case 10:
+		return "A.B"
+	case 11:
+		return g.typ() + "[]"
+	case 12:
+		return "{ [" + g.name() + ": string]: " + g.typ() + " }"
+	case 13:
+		return g.typ() + " & " + g.typ()
+	}
+	return []string{"number", "string", "boolean"}[g.choose(3)]
+}
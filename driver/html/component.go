@@ -0,0 +1,92 @@
+package html
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+// Kinds of scripts of single-file components, in addition to KindModule.
+const (
+	KindSetup   = "setup"   // Vue <script setup>
+	KindContext = "context" // Svelte <script context="module"> or <script module>
+)
+
+// ComponentExtensions are extensions of single-file components.
+var ComponentExtensions = []string{".vue", ".svelte"}
+
+// IsComponent reports if a file name has one of the component extensions.
+func IsComponent(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range ComponentExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// langs maps values of the lang attribute to the languages of scripts.
+var langs = map[string]string{
+	"":           "js",
+	"js":         "js",
+	"javascript": "js",
+	"jsx":        "jsx",
+	"ts":         "ts",
+	"typescript": "ts",
+	"tsx":        "tsx",
+}
+
+// unsupportedLangs are languages of scripts that are extracted, but cannot be
+// parsed by the driver, thus they are skipped by ParseComponent. Flow shares
+// only a part of the TypeScript syntax, thus parsing TypeScript as Flow would
+// fail on some scripts and produce a wrong AST for others.
+var unsupportedLangs = map[string]bool{
+	"ts":  true,
+	"tsx": true,
+}
+
+// ExtractComponent returns all scripts of a single-file component. Templates
+// and styles are skipped, as well as scripts in other languages.
+func ExtractComponent(doc string) []Script {
+	var out []Script
+	scan(doc, func(name string, attrs []attr, start, end int) {
+		if name != "script" || scriptKind(attrs) == "" {
+			return
+		}
+		s := Script{
+			Kind: KindModule, Element: name,
+			Source: doc[start:end], Start: start, End: end,
+		}
+		lang := ""
+		for _, a := range attrs {
+			switch a.name {
+			case "setup":
+				s.Kind = KindSetup
+			case "module":
+				s.Kind = KindContext
+			case "context":
+				if strings.EqualFold(a.value, "module") {
+					s.Kind = KindContext
+				}
+			case "lang":
+				lang = strings.ToLower(strings.TrimSpace(a.value))
+			}
+		}
+		var ok bool
+		if s.Lang, ok = langs[lang]; ok {
+			out = append(out, s)
+		}
+	})
+	return out
+}
+
+// ParseComponent is the same as Parse, but for single-file components.
+// All scripts are returned under a Component node, except for TypeScript
+// scripts: they are skipped, since the driver cannot parse them.
+func ParseComponent(ctx context.Context, d driver.Native, doc string) (nodes.Node, error) {
+	return parseScripts(ctx, d, doc, "Component", ExtractComponent(doc))
+}
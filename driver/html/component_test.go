@@ -0,0 +1,201 @@
+package html
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/normalizer"
+	"github.com/bblfsh/javascript-driver/driver/parser"
+)
+
+const testVue = `<template>
+  <button @click="inc" onclick="ignored()">{{ count }} — ✓</button>
+</template>
+
+<script>
+export default { name: 'Counter' }
+</script>
+
+<script setup lang="jsx">
+import { ref } from 'vue'
+const count = ref(<b>0</b>)
+</script>
+
+<style scoped>
+button { color: red }
+</style>
+`
+
+const testSvelte = `<script context="module">
+  export const prerender = true;
+</script>
+
+<script lang="coffee">square = (x) -> x * x</script>
+
+<script>
+  export let name;
+</script>
+
+<h1>Hello {name}!</h1>
+`
+
+type testComponentScript struct {
+	Kind, Lang, Source string
+}
+
+func TestExtractComponent(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		doc  string
+		exp  []testComponentScript
+	}{
+		{name: "vue", doc: testVue, exp: []testComponentScript{
+			{Kind: KindModule, Lang: "js", Source: "\nexport default { name: 'Counter' }\n"},
+			{Kind: KindSetup, Lang: "jsx", Source: "\nimport { ref } from 'vue'\nconst count = ref(<b>0</b>)\n"},
+		}},
+		{name: "svelte", doc: testSvelte, exp: []testComponentScript{
+			{Kind: KindContext, Lang: "js", Source: "\n  export const prerender = true;\n"},
+			{Kind: KindModule, Lang: "js", Source: "\n  export let name;\n"},
+		}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var got []testComponentScript
+			for _, s := range ExtractComponent(c.doc) {
+				if c.doc[s.Start:s.End] != s.Source {
+					t.Errorf("unexpected span: %q", c.doc[s.Start:s.End])
+				}
+				got = append(got, testComponentScript{Kind: s.Kind, Lang: s.Lang, Source: s.Source})
+			}
+			if !reflect.DeepEqual(c.exp, got) {
+				t.Fatalf("unexpected scripts:\n%+v\n%+v", c.exp, got)
+			}
+		})
+	}
+}
+
+func TestParseComponent(t *testing.T) {
+	ctx := context.Background()
+	for _, c := range []struct {
+		name  string
+		doc   string
+		names int
+	}{
+		{name: "vue", doc: testVue, names: 7},
+		{name: "svelte", doc: testSvelte, names: 2},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			ast, err := ParseComponent(ctx, parser.NewDriver(0), c.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sem, err := normalizer.Transforms.Do(ctx, driver.ModeSemantic, c.doc, ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if typ := uast.TypeOf(sem); typ != "javascript:Component" {
+				t.Fatalf("unexpected root: %q", typ)
+			}
+			if names := checkIdentifiers(t, c.doc, sem); names != c.names {
+				t.Fatalf("expected %d identifiers, got %d", c.names, names)
+			}
+		})
+	}
+}
+
+func TestParseComponentTypeScript(t *testing.T) {
+	for _, lang := range []string{"ts", "TypeScript", "tsx"} {
+		doc := "<script>export default {}</script>\n<script setup lang=" + lang + ">\nconst a: number = 1\n</script>"
+		ast, err := ParseComponent(context.Background(), parser.NewDriver(0), doc)
+		if err != nil {
+			t.Fatalf("%s: %v", lang, err)
+		}
+		// only the JavaScript script is returned
+		scripts := ast.(nodes.Object)["scripts"].(nodes.Array)
+		if len(scripts) != 1 {
+			t.Fatalf("%s: expected 1 script, got %d", lang, len(scripts))
+		} else if s := scripts[0].(nodes.Object); s["kind"] != nodes.String(KindModule) || s["lang"] != nodes.String("js") {
+			t.Fatalf("%s: unexpected script: %v", lang, s)
+		}
+	}
+}
+
+func TestNativeRouting(t *testing.T) {
+	jsx := []string{
+		"<html>\n  <body>{content}</body>\n</html>;",
+		"<template name={name} />;",
+		"<script>{code}</script>;",
+		"<Script lang='ts' />;",
+	}
+	cases := []struct {
+		name, src, exp string
+	}{
+		{src: testDoc, exp: "HTMLDocument"},
+		{name: "page.html", src: "<p onclick='a()'>", exp: "HTMLDocument"},
+		{name: "counter.vue", src: testVue, exp: "Component"},
+		{name: "App.svelte", src: testSvelte, exp: "Component"},
+		// components are not recognized by the content
+		{src: testVue, exp: ""},
+	}
+	for _, src := range jsx {
+		cases = append(cases,
+			struct{ name, src, exp string }{src: src, exp: "File"},
+			struct{ name, src, exp string }{name: "view.jsx", src: src, exp: "File"},
+		)
+	}
+	d := NewNative(parser.NewDriver(0))
+	for _, c := range cases {
+		ctx := context.Background()
+		if c.name != "" {
+			ctx = WithFilename(ctx, c.name)
+		}
+		ast, err := d.Parse(ctx, c.src)
+		if c.exp == "" {
+			if err == nil {
+				t.Errorf("%s %q: expected an error", c.name, c.src)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s %q: %v", c.name, c.src, err)
+		} else if typ := ast.(nodes.Object)["type"]; typ != nodes.String(c.exp) {
+			t.Errorf("%s %q: expected %s, got %v", c.name, c.src, c.exp, typ)
+		}
+	}
+}
+
+// nativeModule is a driver module that returns native ASTs.
+type nativeModule struct {
+	driver.DriverModule
+	d driver.Native
+}
+
+func (m nativeModule) Parse(ctx context.Context, src string, opts *driver.ParseOptions) (nodes.Node, error) {
+	return m.d.Parse(ctx, src)
+}
+
+func TestDriverFilename(t *testing.T) {
+	d := NewDriver(nativeModule{d: NewNative(parser.NewDriver(0))})
+	ctx := context.Background()
+	for _, c := range []struct {
+		opts *driver.ParseOptions
+		exp  string
+	}{
+		{opts: &driver.ParseOptions{Filename: "src/Counter.vue"}, exp: "Component"},
+		{opts: &driver.ParseOptions{Filename: "index.HTML"}, exp: "HTMLDocument"},
+		{opts: &driver.ParseOptions{Filename: "view.jsx"}, exp: "File"},
+		{opts: nil, exp: "File"},
+	} {
+		ast, err := d.Parse(ctx, "<script>{a}</script>", c.opts)
+		if err != nil {
+			t.Fatal(err)
+		} else if typ := ast.(nodes.Object)["type"]; typ != nodes.String(c.exp) {
+			t.Errorf("%+v: expected %s, got %v", c.opts, c.exp, typ)
+		}
+	}
+}
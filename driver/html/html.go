@@ -0,0 +1,398 @@
+// Package html parses JavaScript embedded in HTML documents and in Vue and
+// Svelte single-file components.
+//
+// Inline scripts and event handler attributes are extracted from the document,
+// parsed separately by the native driver and returned under a single root
+// node with positions relative to the document:
+//
+//	HTMLDocument
+//	  scripts: [HTMLScript{kind, element, attribute, file: File}]
+//
+// Only script blocks are extracted from components:
+//
+//	Component
+//	  scripts: [HTMLScript{kind, element, lang, file: File}]
+//
+// The extraction follows the tokenization rules of the HTML standard closely
+// enough for real documents, but does not build the DOM: the nesting of
+// elements is not checked and scripts inside of comments are skipped.
+package html
+
+import (
+	"html"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Kinds of scripts.
+const (
+	KindClassic = "classic" // <script> or <script type="text/javascript">
+	KindModule  = "module"  // <script type="module">
+	KindHandler = "handler" // event handler attribute, like onclick="..."
+)
+
+// Extensions of HTML files.
+var Extensions = []string{".html", ".htm"}
+
+// IsHTML reports if a file name has one of the HTML extensions.
+func IsHTML(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDocument reports if the source starts as an HTML document: with a doctype,
+// after optional comments. It is not valid JavaScript, unlike an <html> tag,
+// which may start a JSX element.
+func IsDocument(src string) bool {
+	s := skipPrologue(src)
+	const p = "<!doctype html"
+	if len(s) > len(p) && strings.EqualFold(s[:len(p)], p) {
+		if c := s[len(p)]; isSpace(c) || c == '>' {
+			return true
+		}
+	}
+	return false
+}
+
+// skipPrologue skips the byte order mark, spaces and comments.
+func skipPrologue(s string) string {
+	s = strings.TrimPrefix(s, "\uFEFF")
+	for {
+		s = strings.TrimLeft(s, " \t\n\f\r")
+		if !strings.HasPrefix(s, "<!--") {
+			return s
+		}
+		s = s[skipComment(s, 4):]
+	}
+}
+
+// jsTypes are MIME types of classic scripts, as in the HTML standard.
+var jsTypes = map[string]bool{
+	"application/ecmascript":   true,
+	"application/javascript":   true,
+	"application/x-ecmascript": true,
+	"application/x-javascript": true,
+	"text/ecmascript":          true,
+	"text/javascript":          true,
+	"text/javascript1.0":       true,
+	"text/javascript1.1":       true,
+	"text/javascript1.2":       true,
+	"text/javascript1.3":       true,
+	"text/javascript1.4":       true,
+	"text/javascript1.5":       true,
+	"text/jscript":             true,
+	"text/livescript":          true,
+	"text/x-ecmascript":        true,
+	"text/x-javascript":        true,
+}
+
+// rawTextElements contain text that is not parsed as markup.
+var rawTextElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"textarea": true,
+	"title":    true,
+	"xmp":      true,
+	"iframe":   true,
+	"noembed":  true,
+	"noframes": true,
+}
+
+// Script is a piece of JavaScript embedded into a document.
+type Script struct {
+	Kind string
+	// Element is the lower case name of the element containing the script.
+	Element string
+	// Attribute is the lower case name of the attribute for handlers.
+	Attribute string
+	// Lang is the language of component scripts: js, jsx, ts or tsx.
+	Lang string
+	// Source of the script. Character references in attributes are decoded.
+	Source string
+	// Start and End are byte offsets of the script in the document.
+	Start, End int
+
+	// segs map offsets of the decoded source to the document, sorted by src.
+	// Nil if the source is copied verbatim.
+	segs []segment
+}
+
+type segment struct {
+	src, doc int
+}
+
+// DocOffset converts a byte offset in the script source to the document.
+// Offsets inside of a decoded character reference are mapped to its start.
+func (s *Script) DocOffset(off int) int {
+	seg := segment{}
+	if i := sort.Search(len(s.segs), func(i int) bool {
+		return s.segs[i].src > off
+	}); i > 0 {
+		seg = s.segs[i-1]
+	}
+	doc := seg.doc + off - seg.src
+	if doc > s.End-s.Start {
+		doc = s.End - s.Start
+	}
+	return s.Start + doc
+}
+
+// attr is an attribute of a start tag.
+type attr struct {
+	name       string
+	value      string // raw value, with character references
+	start, end int
+}
+
+// Extract returns all scripts of a document in the order of appearance.
+func Extract(doc string) []Script {
+	var out []Script
+	scan(doc, func(name string, attrs []attr, start, end int) {
+		out = appendHandlers(out, name, attrs)
+		if name != "script" {
+			return
+		}
+		if kind := scriptKind(attrs); kind != "" {
+			out = append(out, Script{
+				Kind: kind, Element: name,
+				Source: doc[start:end], Start: start, End: end,
+			})
+		}
+	})
+	return out
+}
+
+// scan calls fn for each start tag of a document. For raw text elements,
+// start and end are the offsets of the content, otherwise both are -1.
+func scan(doc string, fn func(name string, attrs []attr, start, end int)) {
+	for i := 0; i < len(doc); {
+		j := strings.IndexByte(doc[i:], '<')
+		if j < 0 {
+			break
+		}
+		i += j
+		rest := doc[i+1:]
+		switch {
+		case strings.HasPrefix(rest, "!--"):
+			i = skipComment(doc, i+4)
+		case strings.HasPrefix(rest, "!"), strings.HasPrefix(rest, "?"):
+			i = skipPast(doc, i+1, ">")
+		case strings.HasPrefix(rest, "/"):
+			i = skipPast(doc, i+2, ">")
+		case len(rest) != 0 && isLetter(rest[0]):
+			var name string
+			var attrs []attr
+			name, attrs, i = readStartTag(doc, i+1)
+			if !rawTextElements[name] {
+				fn(name, attrs, -1, -1)
+				continue
+			}
+			start := i
+			end := findEndTag(doc, i, name)
+			i = skipPast(doc, end, ">")
+			fn(name, attrs, start, end)
+		default:
+			i++
+		}
+	}
+}
+
+// scriptKind returns the kind of a script element, or an empty string if it
+// is not an inline JavaScript.
+func scriptKind(attrs []attr) string {
+	typ, hasType := "", false
+	for _, a := range attrs {
+		switch a.name {
+		case "src":
+			// the content is ignored by browsers
+			return ""
+		case "type":
+			if !hasType {
+				typ, hasType = strings.ToLower(strings.TrimSpace(html.UnescapeString(a.value))), true
+			}
+		}
+	}
+	switch {
+	case typ == "" || jsTypes[typ]:
+		return KindClassic
+	case typ == "module":
+		return KindModule
+	}
+	return ""
+}
+
+// appendHandlers adds all event handlers of an element.
+func appendHandlers(out []Script, elem string, attrs []attr) []Script {
+	for _, a := range attrs {
+		if len(a.name) <= 2 || !strings.HasPrefix(a.name, "on") || strings.TrimSpace(a.value) == "" {
+			continue
+		}
+		s := Script{
+			Kind: KindHandler, Element: elem, Attribute: a.name,
+			Start: a.start, End: a.end,
+		}
+		s.Source, s.segs = decode(a.value)
+		out = append(out, s)
+	}
+	return out
+}
+
+// decode replaces character references in an attribute value. It returns
+// segments that map the decoded value back to the raw one.
+func decode(raw string) (string, []segment) {
+	if strings.IndexByte(raw, '&') < 0 {
+		return raw, nil
+	}
+	var (
+		buf  strings.Builder
+		segs []segment
+	)
+	// a reference never contains '&', thus chunks starting with it can be
+	// decoded independently
+	for i := 0; i < len(raw); {
+		j := strings.IndexByte(raw[i+1:], '&')
+		if j < 0 {
+			j = len(raw)
+		} else {
+			j += i + 1
+		}
+		chunk := raw[i:j]
+		dec := html.UnescapeString(chunk)
+		segs = append(segs, segment{src: buf.Len(), doc: i})
+		if dec != chunk {
+			// the tail after the reference is copied verbatim
+			n := commonSuffix(chunk, dec)
+			if n >= len(dec) {
+				n = len(dec) - 1
+			}
+			segs = append(segs, segment{src: buf.Len() + len(dec) - n, doc: j - n})
+		}
+		buf.WriteString(dec)
+		i = j
+	}
+	return buf.String(), segs
+}
+
+func commonSuffix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
+
+// readStartTag reads a tag name and attributes starting at i, right after
+// '<'. It returns the offset after the tag.
+func readStartTag(doc string, i int) (string, []attr, int) {
+	start := i
+	for i < len(doc) && !isSpace(doc[i]) && doc[i] != '/' && doc[i] != '>' {
+		i++
+	}
+	name := strings.ToLower(doc[start:i])
+	var attrs []attr
+	for i < len(doc) {
+		for i < len(doc) && (isSpace(doc[i]) || doc[i] == '/') {
+			i++
+		}
+		if i >= len(doc) {
+			break
+		} else if doc[i] == '>' {
+			i++
+			break
+		}
+		// the first character of a name may be '='
+		ns := i
+		for i++; i < len(doc) && !isSpace(doc[i]) && doc[i] != '/' && doc[i] != '>' && doc[i] != '='; i++ {
+		}
+		a := attr{name: strings.ToLower(doc[ns:i])}
+		j := i
+		for j < len(doc) && isSpace(doc[j]) {
+			j++
+		}
+		if j < len(doc) && doc[j] == '=' {
+			i = j + 1
+			for i < len(doc) && isSpace(doc[i]) {
+				i++
+			}
+			if i < len(doc) && (doc[i] == '"' || doc[i] == '\'') {
+				q := doc[i]
+				a.start = i + 1
+				a.end = a.start
+				for a.end < len(doc) && doc[a.end] != q {
+					a.end++
+				}
+				i = a.end + 1
+			} else {
+				a.start = i
+				for i < len(doc) && !isSpace(doc[i]) && doc[i] != '>' {
+					i++
+				}
+				a.end = i
+			}
+			a.value = doc[a.start:a.end]
+		}
+		attrs = append(attrs, a)
+	}
+	if i > len(doc) {
+		i = len(doc)
+	}
+	return name, attrs, i
+}
+
+// findEndTag returns the offset of the end tag of a raw text element.
+func findEndTag(doc string, i int, name string) int {
+	for {
+		j := strings.Index(doc[i:], "</")
+		if j < 0 {
+			return len(doc)
+		}
+		i += j
+		k := i + 2 + len(name)
+		if k <= len(doc) && strings.EqualFold(doc[i+2:k], name) &&
+			(k == len(doc) || isSpace(doc[k]) || doc[k] == '/' || doc[k] == '>') {
+			return i
+		}
+		i += 2
+	}
+}
+
+// skipComment returns the offset after the end of a comment starting at i.
+func skipComment(doc string, i int) int {
+	// "<!-->" and "<!--->" are empty comments
+	if strings.HasPrefix(doc[i:], ">") {
+		return i + 1
+	} else if strings.HasPrefix(doc[i:], "->") {
+		return i + 2
+	}
+	return skipPast(doc, i, "-->")
+}
+
+// skipPast returns the offset after the first occurrence of a string.
+func skipPast(doc string, i int, s string) int {
+	if i > len(doc) {
+		return len(doc)
+	}
+	j := strings.Index(doc[i:], s)
+	if j < 0 {
+		return len(doc)
+	}
+	return i + j + len(s)
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\f', '\r':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package html
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/normalizer"
+	"github.com/bblfsh/javascript-driver/driver/parser"
+)
+
+const testDoc = `<!DOCTYPE html>
+<html>
+<head>
+  <title>a <script> in the títle 😀</title>
+  <script src="lib.js"></script>
+  <script>var a = 1;</script>
+  <script type="text/template"><div>{{x}}</div></script>
+  <!-- <script>commented()</script> -->
+  <script type="module">import b from './b.js';</SCRIPT >
+</head>
+<body onload="init()">
+  <button ONCLICK='alert(&quot;hi&quot;); return false' onmouseover=hover()>π</button>
+  <p title="onclick=x()">text</p>
+</body>
+</html>
+`
+
+type testScript struct {
+	Kind, Element, Attribute, Source, Doc string
+}
+
+func TestExtract(t *testing.T) {
+	var got []testScript
+	for _, s := range Extract(testDoc) {
+		got = append(got, testScript{
+			Kind: s.Kind, Element: s.Element, Attribute: s.Attribute,
+			Source: s.Source, Doc: testDoc[s.Start:s.End],
+		})
+	}
+	exp := []testScript{
+		{Kind: KindClassic, Element: "script", Source: "var a = 1;", Doc: "var a = 1;"},
+		{Kind: KindModule, Element: "script", Source: "import b from './b.js';", Doc: "import b from './b.js';"},
+		{Kind: KindHandler, Element: "body", Attribute: "onload", Source: "init()", Doc: "init()"},
+		{
+			Kind: KindHandler, Element: "button", Attribute: "onclick",
+			Source: `alert("hi"); return false`, Doc: "alert(&quot;hi&quot;); return false",
+		},
+		{Kind: KindHandler, Element: "button", Attribute: "onmouseover", Source: "hover()", Doc: "hover()"},
+	}
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("unexpected scripts:\n%+v\n%+v", exp, got)
+	}
+}
+
+func TestDocOffset(t *testing.T) {
+	const doc = `<a onclick="f(&quot;x&amp;y&quot;, 1)">`
+	s := Extract(doc)[0]
+	if s.Source != `f("x&y", 1)` {
+		t.Fatalf("unexpected source: %q", s.Source)
+	}
+	for _, c := range []struct {
+		src, doc string
+	}{
+		{src: "f(", doc: "f("},
+		{src: "x", doc: "x"},
+		{src: "y", doc: "y"},
+		{src: ", 1)", doc: ", 1)"},
+	} {
+		off := s.DocOffset(strings.Index(s.Source, c.src))
+		if !strings.HasPrefix(doc[off:], c.doc) {
+			t.Fatalf("%q is mapped to %q", c.src, doc[off:])
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	ctx := context.Background()
+	ast, err := Parse(ctx, parser.NewDriver(0), testDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sem, err := normalizer.Transforms.Do(ctx, driver.ModeSemantic, testDoc, ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := sem.(nodes.Object)
+	if typ := uast.TypeOf(root); typ != "javascript:HTMLDocument" {
+		t.Fatalf("unexpected root: %q", typ)
+	}
+	scripts := root["scripts"].(nodes.Array)
+	if len(scripts) != 5 {
+		t.Fatalf("expected 5 scripts, got %d", len(scripts))
+	}
+	if names := checkIdentifiers(t, testDoc, sem); names != 5 {
+		t.Fatalf("expected 5 identifiers, got %d", names)
+	}
+}
+
+// checkIdentifiers checks that all identifiers of the semantic UAST point to
+// their names in the document. It returns the number of identifiers.
+func checkIdentifiers(t *testing.T, doc string, sem nodes.Node) int {
+	var names int
+	nodes.WalkPreOrder(sem, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok || uast.TypeOf(obj) != "uast:Identifier" {
+			return true
+		}
+		name := string(obj["Name"].(nodes.String))
+		pos := uast.PositionsOf(obj)
+		start, end := pos.Start(), pos.End()
+		if start == nil || end == nil {
+			// created by the normalizer
+			return true
+		}
+		// identifiers with type annotations include them, as in Babel
+		if got := doc[start.Offset:end.Offset]; !strings.HasPrefix(got, name) {
+			t.Errorf("identifier %q points to %q", name, got)
+		}
+		line := strings.Count(doc[:start.Offset], "\n") + 1
+		col := int(start.Offset) - strings.LastIndex(doc[:start.Offset], "\n")
+		if int(start.Line) != line || int(start.Col) != col {
+			t.Errorf("identifier %q is at %d:%d, expected %d:%d", name, start.Line, start.Col, line, col)
+		}
+		names++
+		return true
+	})
+	return names
+}
+
+func TestParseError(t *testing.T) {
+	const doc = "<p>\n  <b onclick='a b'>x</b><script>ok()</script><script>c d</script>"
+	_, err := Parse(context.Background(), parser.NewDriver(0), doc)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	exp := []string{
+		"onclick attribute at 2:15: Unexpected token, expected \";\" (1:2)",
+		"onclick attribute at 2:15: Unexpected token, expected \";\" (1:2)",
+		"classic script at 2:54: Unexpected token, expected \";\" (1:2)",
+		"classic script at 2:54: Unexpected token, expected \";\" (1:2)",
+	}
+	var got []string
+	for _, e := range err.(*driver.ErrMulti).Errors {
+		got = append(got, e.Error())
+	}
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("unexpected errors:\n%q", got)
+	}
+}
+
+func TestIsDocument(t *testing.T) {
+	for _, c := range []struct {
+		src string
+		exp bool
+	}{
+		{src: "<!DOCTYPE html>\n<p>", exp: true},
+		{src: "\uFEFF  <!-- generated -->\n<!doctype HTML>", exp: true},
+		// may be JSX
+		{src: "<html>", exp: false},
+		{src: "<HTML lang=en>", exp: false},
+		{src: "<!-- comment\nvar a = <html/>", exp: false},
+		{src: "<htmlElement/>", exp: false},
+		{src: "var a = 1", exp: false},
+	} {
+		if got := IsDocument(c.src); got != c.exp {
+			t.Errorf("%q: expected %v, got %v", c.src, c.exp, got)
+		}
+	}
+}
+
+func TestNative(t *testing.T) {
+	d := NewNative(parser.NewDriver(0))
+	ast, err := d.Parse(context.Background(), testDoc)
+	if err != nil {
+		t.Fatal(err)
+	} else if typ := ast.(nodes.Object)["type"]; typ != nodes.String("HTMLDocument") {
+		t.Fatalf("unexpected root: %v", typ)
+	}
+	ast, err = d.Parse(context.Background(), "<html/>")
+	if err != nil {
+		t.Fatal(err)
+	} else if typ := ast.(nodes.Object)["type"]; typ != nodes.String("File") {
+		t.Fatalf("unexpected root: %v", typ)
+	}
+}
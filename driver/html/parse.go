@@ -0,0 +1,240 @@
+package html
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	derrors "github.com/bblfsh/sdk/v3/driver/errors"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/transformer/positioner"
+)
+
+// Parse extracts all scripts from a document and parses them with the native
+// driver. It returns a native AST of the whole document, with the same
+// positional information as the native driver returns for a single script:
+// UTF-16 offsets and locations with one-based lines and zero-based columns.
+//
+// If any of the scripts cannot be parsed, errors of all scripts are returned.
+// Driver failures are returned as is.
+func Parse(ctx context.Context, d driver.Native, doc string) (nodes.Node, error) {
+	return parseScripts(ctx, d, doc, "HTMLDocument", Extract(doc))
+}
+
+// parseScripts parses scripts of a document and returns them under a root
+// node of a given type.
+func parseScripts(ctx context.Context, d driver.Native, doc, typ string, scripts []Script) (nodes.Node, error) {
+	idx := positioner.NewIndex([]byte(doc), &positioner.IndexOptions{Unicode: true})
+	root, err := node(idx, typ, 0, len(doc))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		list nodes.Array
+		errs []error
+	)
+	for _, s := range scripts {
+		s := s
+		if unsupportedLangs[s.Lang] {
+			// other scripts of the component are still returned
+			continue
+		}
+		ast, err := d.Parse(ctx, s.Source)
+		if driver.ErrDriverFailure.Is(err) {
+			return nil, err
+		} else if err != nil {
+			errs = append(errs, scriptErrors(idx, &s, err)...)
+			continue
+		}
+		n, err := node(idx, "HTMLScript", s.Start, s.End)
+		if err != nil {
+			return nil, err
+		}
+		n["kind"] = nodes.String(s.Kind)
+		n["element"] = nodes.String(s.Element)
+		if s.Attribute != "" {
+			n["attribute"] = nodes.String(s.Attribute)
+		}
+		if s.Lang != "" {
+			n["lang"] = nodes.String(s.Lang)
+		}
+		if err = remap(idx, &s, ast); err != nil {
+			return nil, err
+		}
+		n["file"] = ast
+		list = append(list, n)
+	}
+	if len(errs) != 0 {
+		return nil, derrors.Join(errs)
+	}
+	if list == nil {
+		list = nodes.Array{}
+	}
+	root["scripts"] = list
+	return root, nil
+}
+
+// scriptErrors prefixes syntax errors of a script with its position.
+func scriptErrors(idx *positioner.Index, s *Script, err error) []error {
+	line, col, _ := idx.LineCol(s.Start)
+	prefix := fmt.Sprintf("%s script at %d:%d", s.Kind, line, col)
+	if s.Attribute != "" {
+		prefix = fmt.Sprintf("%s attribute at %d:%d", s.Attribute, line, col)
+	}
+	errs := []error{err}
+	if m, ok := err.(*derrors.ErrMulti); ok {
+		errs = m.Errors
+	}
+	out := make([]error, 0, len(errs))
+	for _, e := range errs {
+		out = append(out, fmt.Errorf("%s: %s", prefix, strings.TrimSpace(e.Error())))
+	}
+	return out
+}
+
+// node creates a native node spanning a part of the document.
+func node(idx *positioner.Index, typ string, start, end int) (nodes.Object, error) {
+	n := nodes.Object{"type": nodes.String(typ)}
+	if err := setPos(idx, n, start, end); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// setPos sets positional fields of a native node given byte offsets in the document.
+func setPos(idx *positioner.Index, n nodes.Object, start, end int) error {
+	loc, _ := n["loc"].(nodes.Object)
+	if loc == nil {
+		loc = nodes.Object{}
+	} else {
+		loc = loc.CloneObject()
+	}
+	for _, p := range []struct {
+		key string
+		off int
+	}{
+		{"start", start},
+		{"end", end},
+	} {
+		off16, err := idx.ToUTF16Offset(p.off)
+		if err != nil {
+			return err
+		}
+		line, col, err := idx.ToUTF16LineCol(p.off)
+		if err != nil {
+			return err
+		}
+		n[p.key] = nodes.Int(off16)
+		loc[p.key] = nodes.Object{"line": nodes.Int(line), "column": nodes.Int(col - 1)}
+	}
+	n["loc"] = loc
+	return nil
+}
+
+// remap converts positions of all nodes in the native AST of a script to the document.
+func remap(idx *positioner.Index, s *Script, ast nodes.Node) error {
+	sidx := positioner.NewIndex([]byte(s.Source), &positioner.IndexOptions{Unicode: true})
+	var visit func(n nodes.Node) error
+	visit = func(n nodes.Node) error {
+		switch n := n.(type) {
+		case nodes.Object:
+			start, ok1 := toInt(n["start"])
+			end, ok2 := toInt(n["end"])
+			if ok1 && ok2 {
+				bstart, err := sidx.FromUTF16Offset(start)
+				if err != nil {
+					return err
+				}
+				bend, err := sidx.FromUTF16Offset(end)
+				if err != nil {
+					return err
+				}
+				if err = setPos(idx, n, s.DocOffset(bstart), s.DocOffset(bend)); err != nil {
+					return err
+				}
+			}
+			for k, v := range n {
+				if k == "loc" {
+					continue
+				}
+				if err := visit(v); err != nil {
+					return err
+				}
+			}
+		case nodes.Array:
+			for _, v := range n {
+				if err := visit(v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return visit(ast)
+}
+
+func toInt(n nodes.Node) (int, bool) {
+	switch n := n.(type) {
+	case nodes.Int:
+		return int(n), true
+	case nodes.Uint:
+		return int(n), true
+	case nodes.Float:
+		return int(n), float64(int(n)) == float64(n)
+	}
+	return 0, false
+}
+
+// filenameKey is a context key of the file name of a parse request.
+type filenameKey struct{}
+
+// WithFilename returns a context that passes the file name of a source to the
+// driver returned by NewNative.
+func WithFilename(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, filenameKey{}, name)
+}
+
+// NewNative wraps a native driver to parse HTML documents and single-file
+// components. Native drivers receive no file names, thus the name is passed
+// in the context by WithFilename or NewDriver, and the source is routed by
+// its extension. Without a name, only sources starting with a doctype are
+// parsed as HTML: JSX may look like markup, as in "<html>...</html>". All
+// other sources are parsed by the driver.
+func NewNative(d driver.Native) driver.Native {
+	return &htmlDriver{Native: d}
+}
+
+type htmlDriver struct {
+	driver.Native
+}
+
+// Parse implements driver.Native.
+func (d *htmlDriver) Parse(ctx context.Context, src string) (nodes.Node, error) {
+	name, _ := ctx.Value(filenameKey{}).(string)
+	switch {
+	case IsHTML(name) || IsDocument(src):
+		return Parse(ctx, d.Native, src)
+	case IsComponent(name):
+		return ParseComponent(ctx, d.Native, src)
+	}
+	return d.Native.Parse(ctx, src)
+}
+
+// NewDriver wraps a driver to pass file names of requests to the native
+// driver returned by NewNative.
+func NewDriver(d driver.DriverModule) driver.DriverModule {
+	return &namedDriver{DriverModule: d}
+}
+
+type namedDriver struct {
+	driver.DriverModule
+}
+
+// Parse implements driver.Driver.
+func (d *namedDriver) Parse(ctx context.Context, src string, opts *driver.ParseOptions) (nodes.Node, error) {
+	if opts != nil && opts.Filename != "" {
+		ctx = WithFilename(ctx, opts.Filename)
+	}
+	return d.DriverModule.Parse(ctx, src, opts)
+}
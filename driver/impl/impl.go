@@ -1,11 +1,111 @@
+// Package impl creates the driver served by the driver binary.
 package impl
 
 import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/driver/manifest"
 	"github.com/bblfsh/sdk/v3/driver/native"
 	"github.com/bblfsh/sdk/v3/driver/server"
+
+	"github.com/bblfsh/javascript-driver/driver/cache"
+	"github.com/bblfsh/javascript-driver/driver/cli"
+	"github.com/bblfsh/javascript-driver/driver/html"
+	"github.com/bblfsh/javascript-driver/driver/limits"
+	"github.com/bblfsh/javascript-driver/driver/normalizer"
+	"github.com/bblfsh/javascript-driver/driver/pool"
+	"github.com/bblfsh/javascript-driver/driver/sourcemap"
+	"github.com/bblfsh/javascript-driver/driver/tokens"
+)
+
+// Environment variables that configure the pool of native processes.
+// The server does not accept custom flags, thus the environment is used.
+const (
+	envWorkers   = "JS_DRIVER_WORKERS"     // number of native processes, defaults to the number of CPUs
+	envQueueSize = "JS_DRIVER_QUEUE_SIZE"  // number of requests waiting for a native process
+	envTimeout   = "JS_DRIVER_TIMEOUT"     // parse timeout for requests without a deadline, e.g. "30s"
+	envCacheSize = "JS_DRIVER_CACHE_SIZE"  // number of parse results cached in memory
+	envCacheDir  = "JS_DRIVER_CACHE_DIR"   // directory to store all parse results
+	envMaps      = "JS_DRIVER_SOURCE_MAPS" // attach original positions from inline source maps, if "1" or "true"
+	envBundles   = "JS_DRIVER_BUNDLES"     // split bundles into modules, if "1" or "true"
 )
 
-func init() {
-	// Can be overridden to link a native driver into a Go driver server.
-	server.DefaultDriver = native.NewDriver(native.UTF8)
+// NewDriver creates the driver module served by the driver binary. The native
+// parsers are created by cli.NewPool, as for the standalone commands, and
+// configured by the environment.
+//
+// Unlike the driver created by server.Run, file names are passed to the HTML
+// driver, parse results are served from the cache, if any, and source maps
+// and tokens are applied if enabled. Native parsers read tokens.EnvTokens
+// themselves.
+func NewDriver() (driver.DriverModule, error) {
+	bundles, _ := strconv.ParseBool(os.Getenv(envBundles))
+	// The in-process parser is linked in and selected by cli.EnvBackend.
+	d := cli.NewPool(native.Binary, pool.Config{
+		Size:      envInt(envWorkers),
+		QueueSize: envInt(envQueueSize),
+		Timeout:   envDuration(envTimeout),
+	}, bundles)
+	m, err := manifest.Load(server.ManifestLocation)
+	if err != nil {
+		return nil, err
+	}
+	dr, err := driver.NewDriverFrom(d, m, normalizer.Transforms)
+	if err != nil {
+		return nil, err
+	}
+	var dm driver.DriverModule = html.NewDriver(dr)
+	maps, _ := strconv.ParseBool(os.Getenv(envMaps))
+	toks := tokens.Enabled()
+	if toks {
+		dm = tokens.NewDriver(dm)
+	}
+	if maps {
+		dm = sourcemap.NewDriver(dm)
+	}
+	if size, dir := envInt(envCacheSize), os.Getenv(envCacheDir); size > 0 || dir != "" {
+		c, err := cache.New(size, dir)
+		if err != nil {
+			return nil, err
+		}
+		// cached results of a different configuration must not be reused
+		settings := fmt.Sprintf("backend=%s tokens=%t maps=%t bundles=%t limits=%+v",
+			os.Getenv(cli.EnvBackend), toks, maps, bundles, limits.FromEnv())
+		dm = cache.NewDriver(dm, c, settings)
+	}
+	return dm, nil
+}
+
+// envInt reads an integer from the environment. It returns zero if the
+// variable is not set or invalid.
+func envInt(name string) int {
+	s := os.Getenv(name)
+	if s == "" {
+		return 0
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid value of %s: %v\n", name, err)
+		return 0
+	}
+	return v
+}
+
+// envDuration reads a duration from the environment. It returns zero if the
+// variable is not set or invalid.
+func envDuration(name string) time.Duration {
+	s := os.Getenv(name)
+	if s == "" {
+		return 0
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid value of %s: %v\n", name, err)
+		return 0
+	}
+	return v
 }
@@ -0,0 +1,62 @@
+// Package fixturetest loads the driver fixtures in tests of other packages.
+package fixturetest
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/uastyaml"
+)
+
+// Dir is the fixtures directory relative to the driver packages.
+const Dir = "../../fixtures"
+
+// Glob returns the paths of the fixtures matching any of the patterns, which
+// are relative to Dir. It fails the test if nothing matches.
+func Glob(t testing.TB, patterns ...string) []string {
+	var files []string
+	for _, pattern := range patterns {
+		list, err := filepath.Glob(filepath.Join(Dir, pattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, list...)
+	}
+	if len(files) == 0 {
+		t.Fatal("no fixtures found")
+	}
+	return files
+}
+
+// Load reads a tree in the YAML format of the fixtures.
+func Load(t testing.TB, path string) nodes.Node {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := uastyaml.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return n
+}
+
+// LoadUAST reads the annotated UAST of a fixture, like "bench_gcd.js".
+func LoadUAST(t testing.TB, name string) nodes.Node {
+	return Load(t, filepath.Join(Dir, name+".uast"))
+}
+
+// Equal reports if both lists have the same strings in the same order.
+func Equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
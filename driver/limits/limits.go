@@ -0,0 +1,151 @@
+// Package limits rejects inputs that are too large or too deeply nested to
+// be parsed and transformed safely.
+//
+// The same limits are enforced by the native parser, which reads them from
+// the same environment variables. The driver checks them again before any
+// transformation, since the recursive transforms are bounded only by the
+// size of the native AST.
+package limits
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+// ErrTooComplex is returned for inputs that exceed one of the limits.
+var ErrTooComplex = errors.NewKind("too complex: %s")
+
+// codeTooComplex prefixes the error returned by the native parser for inputs
+// that exceed one of the limits.
+const codeTooComplex = "E_TOO_COMPLEX: "
+
+// Environment variables for the limits. JS_DRIVER_MAX_HEAP is only used by the native parser.
+const (
+	EnvMaxSize  = "JS_DRIVER_MAX_SIZE"  // bytes of source
+	EnvMaxNodes = "JS_DRIVER_MAX_NODES" // nodes in the native AST
+	EnvMaxDepth = "JS_DRIVER_MAX_DEPTH" // nesting depth of the native AST
+)
+
+// Limits for a single file. A zero or negative value disables a limit.
+type Limits struct {
+	// MaxSize is the maximal size of the source in bytes.
+	MaxSize int
+	// MaxNodes is the maximal number of nodes in the native AST.
+	// Only objects with a type are counted, positions are not.
+	MaxNodes int
+	// MaxDepth is the maximal nesting depth of nodes in the native AST.
+	MaxDepth int
+}
+
+// Default limits are the same as the defaults of the native parser. All
+// limits are disabled, so inputs are only rejected if a limit is set in the
+// environment; the native parser still fails on inputs that overflow its stack.
+var Default = Limits{}
+
+// FromEnv returns the default limits overridden by environment variables.
+func FromEnv() Limits {
+	l := Default
+	envInt(EnvMaxSize, &l.MaxSize)
+	envInt(EnvMaxNodes, &l.MaxNodes)
+	envInt(EnvMaxDepth, &l.MaxDepth)
+	return l
+}
+
+func envInt(name string, v *int) {
+	s := os.Getenv(name)
+	if s == "" {
+		return
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid value of %s: %v\n", name, err)
+		return
+	}
+	*v = n
+}
+
+// CheckSource checks the size of the source.
+func (l Limits) CheckSource(src string) error {
+	if l.MaxSize > 0 && len(src) > l.MaxSize {
+		return ErrTooComplex.New(fmt.Sprintf("source size %d exceeds %d bytes", len(src), l.MaxSize))
+	}
+	return nil
+}
+
+// Check counts nodes of the native AST and their nesting depth. It does not
+// recurse, thus it is safe to call for trees of any depth.
+func (l Limits) Check(root nodes.Node) error {
+	type item struct {
+		n     nodes.Node
+		depth int
+	}
+	count := 0
+	stack := []item{{n: root}}
+	for len(stack) != 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch n := it.n.(type) {
+		case nodes.Object:
+			if _, ok := n["type"].(nodes.String); ok {
+				it.depth++
+				count++
+				if l.MaxNodes > 0 && count > l.MaxNodes {
+					return ErrTooComplex.New(fmt.Sprintf("number of nodes exceeds %d", l.MaxNodes))
+				}
+				if l.MaxDepth > 0 && it.depth > l.MaxDepth {
+					return ErrTooComplex.New(fmt.Sprintf("nesting depth exceeds %d", l.MaxDepth))
+				}
+			}
+			for _, v := range n {
+				stack = append(stack, item{n: v, depth: it.depth})
+			}
+		case nodes.Array:
+			for _, v := range n {
+				stack = append(stack, item{n: v, depth: it.depth})
+			}
+		}
+	}
+	return nil
+}
+
+// NewDriver wraps a native driver to reject sources and ASTs exceeding the limits.
+func NewDriver(d driver.Native, l Limits) driver.Native {
+	return &limitedDriver{Native: d, l: l}
+}
+
+type limitedDriver struct {
+	driver.Native
+	l Limits
+}
+
+// Parse implements driver.Native.
+func (d *limitedDriver) Parse(ctx context.Context, src string) (nodes.Node, error) {
+	if err := d.l.CheckSource(src); err != nil {
+		return nil, err
+	}
+	ast, err := d.Native.Parse(ctx, src)
+	if err != nil {
+		return nil, nativeError(err)
+	}
+	if err = d.l.Check(ast); err != nil {
+		return nil, err
+	}
+	return ast, nil
+}
+
+// nativeError converts the too complex error of the native parser to
+// ErrTooComplex. Other errors are returned as is.
+func nativeError(err error) error {
+	msg := err.Error()
+	if !strings.HasPrefix(msg, codeTooComplex) {
+		return err
+	}
+	return ErrTooComplex.New(strings.TrimPrefix(msg, codeTooComplex))
+}
@@ -0,0 +1,142 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+// nested returns an AST of array expressions with a given number of nodes.
+func nested(depth int) nodes.Node {
+	var n nodes.Node = nodes.Object{"type": nodes.String("NumericLiteral"), "value": nodes.Int(1)}
+	for i := 1; i < depth; i++ {
+		n = nodes.Object{
+			"type":     nodes.String("ArrayExpression"),
+			"elements": nodes.Array{n},
+			"loc":      nodes.Object{"start": nodes.Object{"line": nodes.Int(1)}},
+		}
+	}
+	return n
+}
+
+func TestCheckDepth(t *testing.T) {
+	l := Limits{MaxDepth: 10}
+	if err := l.Check(nested(10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Check(nested(11)); !ErrTooComplex.Is(err) {
+		t.Fatalf("expected too complex error, got %v", err)
+	}
+	// must not overflow the stack
+	if err := l.Check(nested(200000)); !ErrTooComplex.Is(err) {
+		t.Fatalf("expected too complex error, got %v", err)
+	}
+	if err := (Limits{}).Check(nested(50000)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckNodes(t *testing.T) {
+	body := make(nodes.Array, 0, 10)
+	for i := 0; i < 10; i++ {
+		body = append(body, nodes.Object{"type": nodes.String("EmptyStatement")})
+	}
+	ast := nodes.Object{"type": nodes.String("Program"), "body": body}
+	if err := (Limits{MaxNodes: 11}).Check(ast); err != nil {
+		t.Fatal(err)
+	}
+	if err := (Limits{MaxNodes: 10}).Check(ast); !ErrTooComplex.Is(err) {
+		t.Fatalf("expected too complex error, got %v", err)
+	}
+}
+
+func TestCheckSource(t *testing.T) {
+	l := Limits{MaxSize: 4}
+	if err := l.CheckSource("a+b;"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.CheckSource("ä+b;"); !ErrTooComplex.Is(err) {
+		t.Fatalf("expected too complex error, got %v", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	defer os.Unsetenv(EnvMaxDepth)
+	defer os.Unsetenv(EnvMaxNodes)
+	os.Setenv(EnvMaxDepth, "10")
+	os.Setenv(EnvMaxNodes, "x")
+	l := FromEnv()
+	if exp := (Limits{MaxSize: Default.MaxSize, MaxNodes: Default.MaxNodes, MaxDepth: 10}); l != exp {
+		t.Fatalf("unexpected limits: %+v", l)
+	}
+}
+
+func TestDefaultDisabled(t *testing.T) {
+	if err := FromEnv().Check(nested(50000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := FromEnv().CheckSource(string(make([]byte, 32<<20))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// astDriver returns a fixed AST and counts the requests.
+type astDriver struct {
+	driver.Native
+	ast   nodes.Node
+	calls int
+}
+
+func (d *astDriver) Parse(ctx context.Context, src string) (nodes.Node, error) {
+	d.calls++
+	return d.ast, nil
+}
+
+func TestDriver(t *testing.T) {
+	nd := &astDriver{ast: nested(5)}
+	d := NewDriver(nd, Limits{MaxSize: 10, MaxDepth: 4})
+	ctx := context.Background()
+	if _, err := d.Parse(ctx, "[[[[1]]]]"); !ErrTooComplex.Is(err) {
+		t.Fatalf("expected too complex error, got %v", err)
+	}
+	// large sources are not sent to the parser
+	if _, err := d.Parse(ctx, "[[[[[1]]]]]"); !ErrTooComplex.Is(err) {
+		t.Fatalf("expected too complex error, got %v", err)
+	} else if nd.calls != 1 {
+		t.Fatalf("unexpected number of requests: %d", nd.calls)
+	}
+	nd.ast = nested(4)
+	if _, err := d.Parse(ctx, "[[[1]]]"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// errDriver fails all requests with the same error.
+type errDriver struct {
+	driver.Native
+	err error
+}
+
+func (d errDriver) Parse(ctx context.Context, src string) (nodes.Node, error) {
+	return nil, d.err
+}
+
+func TestDriverNativeError(t *testing.T) {
+	ctx := context.Background()
+	d := NewDriver(errDriver{err: errors.New("E_TOO_COMPLEX: nesting depth exceeds 3")}, Limits{})
+	_, err := d.Parse(ctx, "[[[1]]]")
+	if !ErrTooComplex.Is(err) {
+		t.Fatalf("expected too complex error, got %v", err)
+	} else if err.Error() != "too complex: nesting depth exceeds 3" {
+		t.Fatalf("unexpected message: %q", err)
+	}
+	// other errors are returned as is
+	syntax := errors.New("Unexpected token (1:1)")
+	if _, err = NewDriver(errDriver{err: syntax}, Limits{}).Parse(ctx, "a"); err != syntax {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
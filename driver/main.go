@@ -1,12 +1,26 @@
 package main
 
 import (
-	_ "github.com/bblfsh/javascript-driver/driver/impl"
-	"github.com/bblfsh/javascript-driver/driver/normalizer"
+	"os"
 
 	"github.com/bblfsh/sdk/v3/driver/server"
+
+	"github.com/bblfsh/javascript-driver/driver/cli"
+	"github.com/bblfsh/javascript-driver/driver/impl"
 )
 
 func main() {
-	server.Run(normalizer.Transforms)
+	// standalone commands work without the server
+	if code, ok := cli.Main(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+	// server.Run accepts only a native driver and runs the transforms itself,
+	// thus file names would not reach the HTML driver; see impl.NewDriver.
+	d, err := impl.NewDriver()
+	if err != nil {
+		panic(err)
+	}
+	if err = server.NewServer(d).Start(); err != nil {
+		panic(err)
+	}
 }
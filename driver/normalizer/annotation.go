@@ -4,7 +4,8 @@ import (
 	"github.com/bblfsh/sdk/v3/uast"
 	"github.com/bblfsh/sdk/v3/uast/role"
 	. "github.com/bblfsh/sdk/v3/uast/transformer"
-	"github.com/bblfsh/sdk/v3/uast/transformer/positioner"
+
+	"github.com/bblfsh/javascript-driver/driver/charset"
 )
 
 // Native is the of list `transformer.Transformer` to apply to a native AST.
@@ -22,8 +23,13 @@ var Native = Transformers([][]Transformer{
 
 // PreprocessCode is a preprocessor stage that can use the source code to
 // fix tokens and positional information.
+//
+// Annotated identifiers are shrunk to the name as written in the source.
+// Sources in encodings other than UTF-8 are transcoded by the native driver,
+// thus positions are mapped back to the original source.
 var PreprocessCode = []CodeTransformer{
-	positioner.FromUTF16Offset(),
+	tightIdentifiers{},
+	charset.FromUTF16Offset(),
 }
 
 var (
@@ -118,18 +124,21 @@ var Annotations = []Mapping{
 	AnnotateType("File", nil, role.File),
 	AnnotateType("Program", nil, role.Module),
 
-	// Comments
-	AnnotateType("CommentLine", MapObj(Obj{
-		"value": UncommentCLike("text"),
-	}, Obj{
-		uast.KeyToken: Var("text"),
-	}), role.Comment, role.Noop),
+	// HTML documents and components, see the html package
+	AnnotateType("HTMLDocument", nil, role.File),
+	AnnotateType("Component", nil, role.File),
+	AnnotateType("HTMLScript", nil, role.Module),
 
-	AnnotateType("CommentBlock", MapObj(Obj{
-		"value": UncommentCLike("text"),
-	}, Obj{
-		uast.KeyToken: Var("text"),
-	}), role.Comment, role.Noop, role.Block),
+	// Bundles split into modules, see the bundle package
+	AnnotateType("Bundle", nil, role.File),
+	AnnotateType("BundleModule", nil, role.Module),
+
+	// Tokens are only listed if enabled, see the tokens package
+	AnnotateType("Token", nil),
+
+	// Comments; tokens include delimiters, see commentTokens
+	AnnotateType("CommentLine", nil, role.Comment, role.Noop),
+	AnnotateType("CommentBlock", nil, role.Comment, role.Noop, role.Block),
 
 	// Identifiers
 	AnnotateType("Identifier",
@@ -1,21 +1,172 @@
 package normalizer
 
 import (
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf16"
 
 	"github.com/bblfsh/sdk/v3/uast"
 	"github.com/bblfsh/sdk/v3/uast/nodes"
 	. "github.com/bblfsh/sdk/v3/uast/transformer"
+
+	"github.com/bblfsh/javascript-driver/driver/charset"
 )
 
 var Preprocess = Transformers([][]Transformer{
 	{Mappings(Preprocessors...)},
+	{commentTokens},
 }...)
 
 var Normalize = Transformers([][]Transformer{
 	{Mappings(Normalizers...)},
 }...)
 
+// Denormalize converts the semantic UAST produced by Normalize back to the
+// Preprocessed native AST.
+var Denormalize = reverseMappings(Normalizers...)
+
+// tightIdentifiers shrinks positions of native identifiers with a Flow type
+// annotation or an optional mark to the name itself. Babel extends them to
+// the end of the annotation, while the annotation is a node of its own.
+//
+// The end of the name is found in the source, since the name may be written
+// with unicode escapes, like "\u0061bc". Offsets must still be in UTF-16 code
+// units, thus it runs before charset.FromUTF16Offset.
+type tightIdentifiers struct{}
+
+// OnCode implements transformer.CodeTransformer.
+func (tightIdentifiers) OnCode(code string) Transformer {
+	var src []uint16 // decoded on the first identifier
+	return TransformObjFunc(func(o nodes.Object) (nodes.Object, bool, error) {
+		if uast.TypeOf(o) != "Identifier" {
+			return o, false, nil
+		}
+		if o["typeAnnotation"] == nil && o["optional"] != nodes.Bool(true) {
+			return o, false, nil
+		}
+		name, _ := o["name"].(nodes.String)
+		pos := uast.PositionsOf(o)
+		start, end := pos.Start(), pos.End()
+		if start == nil || end == nil {
+			return o, false, nil
+		}
+		if src == nil {
+			src = utf16.Encode([]rune(charset.Decode(code).String()))
+		}
+		n, ok := nameLen(src, int(start.Offset), string(name))
+		if !ok || start.Offset+uint32(n) >= end.Offset {
+			return o, false, nil
+		}
+		e := *end
+		e.Offset = start.Offset + uint32(n)
+		pos[uast.KeyEnd] = e
+		o = o.CloneObject()
+		o[uast.KeyPos] = pos.ToObject()
+		return o, true, nil
+	})
+}
+
+// nameLen returns the length of a name written in the source at a given
+// offset, in UTF-16 code units. Characters of the name may be written as
+// unicode escapes. It returns false if the source does not match the name.
+func nameLen(src []uint16, start int, name string) (int, bool) {
+	i := start
+	for _, r := range name {
+		if i+1 < len(src) && src[i] == '\\' && src[i+1] == 'u' {
+			c, n, ok := unicodeEscape(src[i+2:])
+			if !ok || c != r {
+				return 0, false
+			}
+			i += 2 + n
+			continue
+		}
+		for _, c := range utf16.Encode([]rune{r}) {
+			if i >= len(src) || src[i] != c {
+				return 0, false
+			}
+			i++
+		}
+	}
+	return i - start, true
+}
+
+// unicodeEscape decodes a unicode escape after the "\u" prefix: four hex
+// digits, or any number of them in braces. It returns the character and the
+// number of code units of the escape.
+func unicodeEscape(s []uint16) (rune, int, bool) {
+	digits, n := s, 4
+	if len(s) != 0 && s[0] == '{' {
+		end := 1
+		for end < len(s) && s[end] != '}' {
+			end++
+		}
+		if end == len(s) {
+			return 0, 0, false
+		}
+		digits, n = s[1:end], end+1
+	} else if len(s) < 4 {
+		return 0, 0, false
+	} else {
+		digits = s[:4]
+	}
+	v, err := strconv.ParseUint(string(utf16.Decode(digits)), 16, 32)
+	if err != nil || v > unicode.MaxRune {
+		return 0, 0, false
+	}
+	return rune(v), n, true
+}
+
+// KeyTextPos is a field of comments with positions of the text without
+// delimiters. Positions of the node cover the whole comment.
+const KeyTextPos = "textPos"
+
+// commentTokens sets tokens of comments to the whole comment, including the
+// delimiters, thus tokens match the positions of the nodes. Positions of the
+// text are stored in KeyTextPos.
+//
+// Delimiters are not known from the native AST, but they can be recovered from
+// the length of the comment: line comments start with "//", or with "<!--" and
+// "-->" in scripts.
+var commentTokens = TransformObjFunc(func(o nodes.Object) (nodes.Object, bool, error) {
+	typ, _ := o[uast.KeyType].(nodes.String)
+	if typ != "CommentLine" && typ != "CommentBlock" {
+		return o, false, nil
+	}
+	val, ok := o["value"].(nodes.String)
+	pos := uast.PositionsOf(o)
+	start, end := pos.Start(), pos.End()
+	if !ok || start == nil || end == nil {
+		return o, false, nil
+	}
+	// offsets are converted from UTF-16 later, by PreprocessCode
+	n := uint32(len(utf16.Encode([]rune(string(val)))))
+	var prefix, suffix string
+	if typ == "CommentBlock" {
+		prefix, suffix = "/*", "*/"
+	} else if end.Offset >= start.Offset+n {
+		switch end.Offset - start.Offset - n {
+		case 2:
+			prefix = "//"
+		case 3:
+			prefix = "-->"
+		case 4:
+			prefix = "<!--"
+		}
+	}
+	if prefix == "" || end.Offset-start.Offset != n+uint32(len(prefix)+len(suffix)) {
+		return o, false, nil
+	}
+	text := uast.Positions{
+		uast.KeyStart: {Offset: start.Offset + uint32(len(prefix))},
+		uast.KeyEnd:   {Offset: end.Offset - uint32(len(suffix))},
+	}
+	o = o.CloneObject()
+	o[uast.KeyToken] = nodes.String(prefix + string(val) + suffix)
+	o[KeyTextPos] = text.ToObject()
+	return o, true, nil
+})
+
 // Preprocessors is a block of AST preprocessing rules rules.
 var Preprocessors = []Mapping{
 	// ObjectToNode defines how to normalize common fields of native AST
@@ -78,107 +229,87 @@ func preprocessString(typ string) Mapping {
 	)
 }
 
-// allNormalizedTypes are all uast.KeyType that are processed by current normalizer
-// where we currently drop the coments node off.
-var allNormalizedTypes = []nodes.Value{
-	nodes.String("Identifier"),
-	nodes.String("JSXIdentifier"),
-	nodes.String("StringLiteral"),
-	nodes.String("CommentLine"),
-	nodes.String("CommentBlock"),
-	nodes.String("BlockStatement"),
-	nodes.String("ImportDeclaration"),
-	nodes.String("ImportSpecifier"),
-	nodes.String("ImportDefaultSpecifier"),
-	nodes.String("ImportNamespaceSpecifier"),
-	nodes.String("FunctionDeclaration"),
-}
-
 // Normalizers is the main block of normalization rules to convert native AST to semantic UAST.
+//
+// All rules must be reversible: native fields that have no place in the semantic
+// node are kept on it as-is (see mapSemantic), so the native AST can be restored
+// from the semantic UAST alone.
+// Rules are reversed in the opposite order, thus a rule that produces a semantic
+// node with additional fields must go after other rules for the same type.
 var Normalizers = []Mapping{
-	//FIXME(bzz): save all 3 *Commnets in a new parent Group node \w order
-	Map( // this is not reversible
-		Part("_", Fields{
-			{Name: uast.KeyType, Op: Check(In(allNormalizedTypes...), Var("t"))},
-			{Name: "leadingComments", Drop: true, Op: Any()},
-		}),
-		Part("_", Obj{uast.KeyType: Var("t")}),
-	),
-	Map( // this is not reversible
-		Part("_", Fields{
-			{Name: uast.KeyType, Op: Check(In(allNormalizedTypes...), Var("t"))},
-			{Name: "innerComments", Drop: true, Op: Any()},
-		}),
-		Part("_", Obj{uast.KeyType: Var("t")}),
-	),
-	Map( // this is not reversible
-		Part("_", Fields{
-			{Name: uast.KeyType, Op: Check(In(allNormalizedTypes...), Var("t"))},
-			{Name: "trailingComments", Drop: true, Op: Any()},
-		}),
-		Part("_", Obj{uast.KeyType: Var("t")}),
-	),
-	MapSemantic("Identifier", uast.Identifier{}, MapObj(
-		Fields{
-			{Name: "name", Op: Var("name")},
-			//FIXME(bzz): map Flow variable types properly
-			{Name: "typeAnnotation", Drop: true, Op: Any()},
-			//FIXME(bzz): map Flow "Optional Prameter" properly
-			{Name: "optional", Drop: true, Op: Any()},
+	mapSemantic("Identifier", uast.Identifier{}, MapObj(
+		Obj{
+			"name": Var("name"),
 		},
 		Obj{
 			"Name": Var("name"),
 		},
-	)),
-	MapSemantic("JSXIdentifier", uast.Identifier{}, MapObj(
+	),
+		//FIXME(bzz): map Flow variable types properly
+		optional("typeAnnotation"),
+		//FIXME(bzz): map Flow "Optional Prameter" properly
+		optional("optional"),
+	),
+	// marked to distinguish it from Identifier in the reverse direction
+	extend(mapSemantic("JSXIdentifier", uast.Identifier{}, MapObj(
 		Obj{
 			"name": Var("name"),
 		},
 		Obj{
 			"Name": Var("name"),
 		},
-	)),
-	MapSemantic("StringLiteral", uast.String{}, MapObj(
-		Fields{
-			{Name: "value", Op: Var("val")},
-			{Name: uast.KeyToken, Drop: true, Op: Check(singleQuote{}, Any())}, // only used in Annotated
+	)), Obj{"jsx": Bool(true)}),
+	// the token can be restored from the value, if the literal uses canonical escapes
+	mapSemantic("StringLiteral", uast.String{}, MapObj(
+		Obj{
+			"value":       Var("val"),
+			uast.KeyToken: quoted('\'', Var("val")),
 		},
 		Obj{
 			"Value":  Var("val"),
 			"Format": String("single"),
 		},
 	)),
-	MapSemantic("StringLiteral", uast.String{}, MapObj(
-		Fields{
-			{Name: "value", Op: Var("val")},
-			{Name: uast.KeyToken, Drop: true, Op: Any()}, // only used in Annotated
+	mapSemantic("StringLiteral", uast.String{}, MapObj(
+		Obj{
+			"value":       Var("val"),
+			uast.KeyToken: quoted('"', Var("val")),
 		},
 		Obj{
 			"Value": Var("val"),
 		},
 	)),
-	MapSemantic("CommentLine", uast.Comment{}, MapObj(
+	// otherwise, the token is kept in the semantic node
+	mapSemantic("StringLiteral", uast.String{}, MapObj(
 		Obj{
-			"value": CommentText([2]string{"", ""}, "comm"),
+			"value": Var("val"),
 		},
-		CommentNode(false, "comm", nil),
-	)),
-	MapSemantic("CommentBlock", uast.Comment{}, MapObj(
 		Obj{
-			"value": CommentText([2]string{"", ""}, "comm"),
+			"Value":  Var("val"),
+			"Format": String("single"),
 		},
-		CommentNode(true, "comm", nil),
-	)),
-	MapSemantic("BlockStatement", uast.Block{}, MapObj(
-		Fields{
-			{Name: "body", Op: Var("stmts")},
-			{Name: "directives", Op: Arr()}, // TODO: find an example
+	), Field{Name: uast.KeyToken, Op: Check(singleQuote{}, Var("raw"))}),
+	mapSemantic("StringLiteral", uast.String{}, MapObj(
+		Obj{
+			"value": Var("val"),
 		},
 		Obj{
-			"Statements": Var("stmts"),
+			"Value": Var("val"),
+		},
+	), Field{Name: uast.KeyToken, Op: Var("raw")}),
+	mapComment("CommentLine", false),
+	mapComment("CommentBlock", true),
+	// directives of function bodies, like "use strict", are the first statements
+	mapSemantic("BlockStatement", uast.Block{}, MapObj(
+		Obj{
+			"body":       Var("stmts"),
+			"directives": Var("dirs"),
+		},
+		Obj{
+			"Statements": directivesFirst{dirs: Var("dirs"), stmts: Var("stmts")},
 		},
 	)),
-	MapSemantic("ImportDeclaration", uast.Import{}, MapObj(
+	mapSemantic("ImportDeclaration", uast.Import{}, MapObj(
 		Fields{
 			{Name: "source", Op: Var("path")},
 			// empty un-used array
@@ -191,7 +322,7 @@ var Normalizers = []Mapping{
 	// importKind switch, set only by flow plugin
 	// https://github.com/babel/babel/blob/master/packages/babel-parser/ast/spec.md#importdeclaration
 	// TODO(bzz): this mapping misses 'typeof' case
-	MapSemantic("ImportDeclaration", uast.Import{}, MapObj(
+	mapSemantic("ImportDeclaration", uast.Import{}, MapObj(
 		CasesObj("case",
 			// common
 			Fields{
@@ -208,21 +339,6 @@ var Normalizers = []Mapping{
 						{Name: "local", Op: Var("local")},
 					}),
 				},
-				// specific type
-				{
-					"importKind": String("type"),
-					"specifiers": ArrWith(Var("names"),
-						UASTType(uast.Alias{}, Obj{
-							uast.KeyPos: Var("local_pos"),
-							"Name":      Var("local"),
-							"Node": UASTType(uast.Identifier{}, Obj{
-								uast.KeyPos: Any(),
-								//TODO(bzz): save imported Identifer in Nodes
-								// https://github.com/babel/babel/blob/master/packages/babel-parser/ast/spec.md#importspecifier
-								"Name": Any(),
-							}),
-						})),
-				},
 				// normal import
 				{
 					"importKind": String("value"),
@@ -242,27 +358,48 @@ var Normalizers = []Mapping{
 					"Names": Var("names"),
 					"All":   Bool(true),
 				},
-				// specific type
-				{
-					"Path": UASTType(uast.Alias{}, Obj{
-						uast.KeyPos: Var("local_pos"),
-						"Name":      Var("local"),
-						"Node":      Var("path"),
-					}),
-					"Names": Var("names"),
-					//FIXME(bzz): this should be true ONLY for wildcard imports
-					"All": Bool(true),
-				},
 				// normal import
 				{
 					"Path":  Var("path"),
-					"Names": Var("names"),
+					"Names": Check(Not(Is(nil)), Var("names")),
 					"All":   Bool(false),
 				},
 			},
 		),
 	)),
-	MapSemantic("ImportSpecifier", uast.Alias{}, MapObj(
+	// specific type
+	//
+	// The first specifier is matched with PrependOne rather than ArrWith:
+	// ArrWith takes it out of the list, but the reverse mapping appends it to
+	// the end, so the order of the native specifiers would change.
+	extend(mapSemantic("ImportDeclaration", uast.Import{}, MapObj(
+		Obj{
+			"source": Var("path"),
+			"specifiers": PrependOne(
+				UASTType(uast.Alias{}, Obj{
+					uast.KeyPos: Var("local_pos"),
+					"Name":      Var("local"),
+					"Node":      Var("imported"),
+				}),
+				Var("names"),
+			),
+		},
+		Obj{
+			"Path": UASTType(uast.Alias{}, Obj{
+				uast.KeyPos: Var("local_pos"),
+				"Name":      Var("local"),
+				"Node":      Var("path"),
+			}),
+			"Names": Var("names"),
+			//FIXME(bzz): this should be true ONLY for wildcard imports
+			"All": Bool(true),
+		},
+	), Field{Name: "importKind", Op: String("type")}), Obj{
+		//TODO(bzz): save imported Identifer in Nodes
+		// https://github.com/babel/babel/blob/master/packages/babel-parser/ast/spec.md#importspecifier
+		"imported": Var("imported"),
+	}),
+	mapSemantic("ImportSpecifier", uast.Alias{}, MapObj(
 		Obj{
 			"importKind": Is(nil),
 			"local":      Var("local"),
@@ -273,7 +410,7 @@ var Normalizers = []Mapping{
 			"Node": Var("imp"),
 		},
 	)),
-	MapSemantic("ImportDefaultSpecifier", uast.Alias{}, MapObj(
+	mapSemantic("ImportDefaultSpecifier", uast.Alias{}, MapObj(
 		Fields{
 			{Name: "local", Op: Var("local")},
 		},
@@ -285,22 +422,12 @@ var Normalizers = []Mapping{
 			}),
 		},
 	)),
-	MapSemantic("FunctionDeclaration", uast.FunctionGroup{}, MapObj(
+	mapSemantic("FunctionDeclaration", uast.FunctionGroup{}, MapObj(
 		Fields{
 			{Name: "id", Op: Var("name")},
 			{Name: "generator", Op: Var("gen")}, // FIXME: define channels in SDK? or return a function?
 			{Name: "async", Op: Var("async")},   // TODO: async
 			{Name: "body", Op: Var("body")},
-			//FIXME(bzz): map Flow predicate properly
-			// https://flow.org/en/docs/types/functions/#toc-predicate-functions
-			{Name: "predicate", Drop: true, Op: Any()},
-			//FIXME(bzz): map Flow return type annotations
-			// https://flow.org/en/docs/types/functions/#toc-function-returns
-			{Name: "returnType", Drop: true, Op: Any()},
-			//FIXME(bzz): map Flow generic types annotations
-			// https://flow.org/en/docs/types/generics/
-			// see fixtures/ext_typedecl.js#34 func makeWeakCache
-			{Name: "typeParameters", Drop: true, Op: Any()},
 			{Name: "params", Op: Each("params", Cases("param_case",
 				// Identifier
 				Check(
@@ -363,7 +490,164 @@ var Normalizers = []Mapping{
 				}),
 			),
 		},
-	)),
+	),
+		//FIXME(bzz): map Flow predicate properly
+		// https://flow.org/en/docs/types/functions/#toc-predicate-functions
+		optional("predicate"),
+		//FIXME(bzz): map Flow return type annotations
+		// https://flow.org/en/docs/types/functions/#toc-function-returns
+		optional("returnType"),
+		//FIXME(bzz): map Flow generic types annotations
+		// https://flow.org/en/docs/types/generics/
+		// see fixtures/ext_typedecl.js#34 func makeWeakCache
+		optional("typeParameters"),
+	),
+}
+
+// commentFields are native fields with comments attached to a node.
+var commentFields = []string{"leadingComments", "innerComments", "trailingComments"}
+
+// mapSemantic is like MapSemantic, but keeps native comments and the given fields
+// on the semantic node, so the mapping can be reversed.
+func mapSemantic(nativeType string, semType interface{}, m ObjMapping, keep ...Field) ObjMapping {
+	fields := make(Fields, 0, len(commentFields)+len(keep))
+	for _, name := range commentFields {
+		fields = append(fields, optional(name))
+	}
+	fields = append(fields, keep...)
+	src, dst := MapSemantic(nativeType, semType, m).ObjMapping()
+	return MapObj(JoinObj(src, fields), JoinObj(dst, fields))
+}
+
+// mapComment maps a comment to uast.Comment. The token of the comment is kept,
+// while KeyTextPos is not a part of the schema, thus it is dropped and restored
+// from the positions and the token in the reverse direction, see textPos.
+func mapComment(nativeType string, block bool) ObjMapping {
+	src, dst := mapSemantic(nativeType, uast.Comment{}, MapObj(
+		Obj{
+			"value": CommentText([2]string{"", ""}, "comm"),
+		},
+		CommentNode(block, "comm", nil),
+	), optional(uast.KeyToken)).ObjMapping()
+	// commentTokens sets both the token and the text positions
+	src = JoinObj(src, Fields{{Name: KeyTextPos, Op: textPos{}, Optional: "has_" + uast.KeyToken}})
+	return MapObj(src, dst)
+}
+
+// textPos matches positions of the text of a comment, as set by commentTokens.
+// They are constructed from the positions of the comment without the
+// delimiters, which are known from the token.
+type textPos struct{}
+
+func (textPos) Kinds() nodes.Kind {
+	return nodes.KindObject
+}
+
+func (textPos) Check(st *State, n nodes.Node) (bool, error) {
+	_, ok := n.(nodes.Object)
+	return ok, nil
+}
+
+func (textPos) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	tok, err := st.MustGetVar(uast.KeyToken)
+	if err != nil {
+		return nil, err
+	}
+	token, _ := tok.(nodes.String)
+	var prefix, suffix int
+	switch {
+	case strings.HasPrefix(string(token), "/*"):
+		prefix, suffix = 2, 2
+	case strings.HasPrefix(string(token), "<!--"):
+		prefix = 4
+	case strings.HasPrefix(string(token), "-->"):
+		prefix = 3
+	case strings.HasPrefix(string(token), "//"):
+		prefix = 2
+	default:
+		return nil, ErrUnexpectedValue.New(tok)
+	}
+	var pos [2]*uast.Position
+	for i, name := range []string{uast.KeyStart, uast.KeyEnd} {
+		v, err := st.MustGetVar(name)
+		if err != nil {
+			return nil, err
+		}
+		obj, _ := v.(nodes.Object)
+		if pos[i] = uast.AsPosition(obj); pos[i] == nil {
+			return nil, ErrUnexpectedValue.New(v)
+		}
+	}
+	start, end := *pos[0], *pos[1]
+	// delimiters are ASCII and do not span lines
+	start.Offset += uint32(prefix)
+	end.Offset -= uint32(suffix)
+	if start.Line != 0 {
+		start.Col += uint32(prefix)
+	}
+	if end.Line != 0 {
+		end.Col -= uint32(suffix)
+	}
+	return uast.Positions{uast.KeyStart: start, uast.KeyEnd: end}.ToObject(), nil
+}
+
+// extend adds fields to the semantic node.
+func extend(m ObjMapping, fields Obj) ObjMapping {
+	src, dst := m.ObjMapping()
+	return MapObj(src, JoinObj(dst, fields))
+}
+
+// optional keeps an optional native field as-is.
+func optional(name string) Field {
+	return Field{Name: name, Op: Var(name), Optional: "has_" + name}
+}
+
+// directivesFirst is a list of statements that starts with the directives of
+// a block. In the reverse direction, leading Directive nodes are split from the
+// list.
+type directivesFirst struct {
+	dirs, stmts Op
+}
+
+func (op directivesFirst) Kinds() nodes.Kind {
+	return nodes.KindArray
+}
+
+func (op directivesFirst) Check(st *State, n nodes.Node) (bool, error) {
+	arr, ok := n.(nodes.Array)
+	if !ok {
+		return false, nil
+	}
+	i := 0
+	for i < len(arr) && uast.TypeOf(arr[i]) == "Directive" {
+		i++
+	}
+	dirs, stmts := nodes.Array{}, nodes.Array{}
+	dirs = append(dirs, arr[:i]...)
+	stmts = append(stmts, arr[i:]...)
+	if ok, err := op.dirs.Check(st, dirs); !ok || err != nil {
+		return false, err
+	}
+	return op.stmts.Check(st, stmts)
+}
+
+func (op directivesFirst) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	dirs, err := op.dirs.Construct(st, nil)
+	if err != nil {
+		return nil, err
+	}
+	stmts, err := op.stmts.Construct(st, nil)
+	if err != nil {
+		return nil, err
+	}
+	d, ok1 := dirs.(nodes.Array)
+	s, ok2 := stmts.(nodes.Array)
+	if (!ok1 && dirs != nil) || (!ok2 && stmts != nil) {
+		return nil, ErrExpectedList.New(nodes.Array{dirs, stmts})
+	}
+	out := make(nodes.Array, 0, len(d)+len(s))
+	out = append(out, d...)
+	return append(out, s...), nil
 }
 
 type singleQuote struct {
@@ -381,3 +665,139 @@ func (op singleQuote) Check(st *State, n nodes.Node) (bool, error) {
 	s := string(sn)
 	return strings.HasPrefix(s, `'`) && strings.HasSuffix(s, `'`), nil
 }
+
+// quoted converts a string token quoted with q to its value and checks it with
+// the given operation. Only tokens using canonical escapes are matched, thus the
+// token can be restored from the value in the reverse direction.
+func quoted(q byte, op Op) Op {
+	return Check(canonicalQuote(q), StringConv(op, func(s string) (string, error) {
+		v, _ := unquote(s, q)
+		return v, nil
+	}, func(s string) (string, error) {
+		return quote(s, q), nil
+	}))
+}
+
+type canonicalQuote byte
+
+func (op canonicalQuote) Kinds() nodes.Kind {
+	return nodes.KindString
+}
+
+func (op canonicalQuote) Check(st *State, n nodes.Node) (bool, error) {
+	sn, ok := n.(nodes.String)
+	if !ok {
+		return false, nil
+	}
+	_, ok = unquote(string(sn), byte(op))
+	return ok, nil
+}
+
+// quoteEscapes are the only escape sequences that quote generates.
+var quoteEscapes = map[byte]byte{'\\': '\\', 'n': '\n', 'r': '\r'}
+
+// unquote decodes a string token quoted with q. It returns false if the token
+// is not exactly the one quote would generate for the value.
+func unquote(s string, q byte) (string, bool) {
+	if len(s) < 2 || s[0] != q || s[len(s)-1] != q {
+		return "", false
+	}
+	s = s[1 : len(s)-1]
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case q, '\n', '\r':
+			return "", false
+		case '\\':
+			if i++; i == len(s) {
+				return "", false
+			}
+			if s[i] == q {
+				buf.WriteByte(q)
+			} else if e, ok := quoteEscapes[s[i]]; ok {
+				buf.WriteByte(e)
+			} else {
+				return "", false
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String(), true
+}
+
+// quote encodes the value as a string token quoted with q.
+func quote(s string, q byte) string {
+	var buf strings.Builder
+	buf.WriteByte(q)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case q, '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte(q)
+	return buf.String()
+}
+
+// reverseMappings returns a transformation that reverses all mappings.
+//
+// Mappings are applied to a parent node first, since the parent might be matched
+// by the semantic form of its children in the forward direction.
+func reverseMappings(maps ...Mapping) Transformer {
+	rev := make(reversed, 0, len(maps))
+	for i := len(maps) - 1; i >= 0; i-- {
+		rev = append(rev, Reverse(maps[i]))
+	}
+	return rev
+}
+
+type reversed []Mapping
+
+func (m reversed) Do(root nodes.Node) (nodes.Node, error) {
+	var errs []error
+	st := NewState()
+	var apply func(n nodes.Node) nodes.Node
+	apply = func(n nodes.Node) nodes.Node {
+		for _, mp := range m {
+			src, dst := mp.Mapping()
+			st.Reset()
+			if ok, err := src.Check(st, n); err != nil {
+				errs = append(errs, err)
+				continue
+			} else if !ok {
+				continue
+			}
+			nn, err := dst.Construct(st, nil)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			n = nn
+		}
+		switch n := n.(type) {
+		case nodes.Object:
+			out := make(nodes.Object, len(n))
+			for k, v := range n {
+				out[k] = apply(v)
+			}
+			return out
+		case nodes.Array:
+			out := make(nodes.Array, len(n))
+			for i, v := range n {
+				out[i] = apply(v)
+			}
+			return out
+		}
+		return n
+	}
+	out := apply(root)
+	return out, NewMultiError(errs...)
+}
@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"unicode"
+	"unicode/utf16"
+)
+
+// Character classes follow the ECMAScript specification. The source is stored
+// as UTF-16 code units, since all positions reported by Babel are measured in
+// them.
+
+func isNewLine(c rune) bool {
+	return c == '\n' || c == '\r' || c == 0x2028 || c == 0x2029
+}
+
+func isWhitespace(c rune) bool {
+	switch c {
+	case 0x0009, 0x000b, 0x000c, ' ', 0x00a0, 0x1680, 0x202f, 0x205f, 0x3000, 0xfeff:
+		return true
+	}
+	return c >= 0x2000 && c <= 0x200a
+}
+
+func isIdentifierStart(c rune) bool {
+	switch {
+	case c < 'A':
+		return c == '$'
+	case c <= 'Z':
+		return true
+	case c < 'a':
+		return c == '_'
+	case c <= 'z':
+		return true
+	case c < 0xaa:
+		return false
+	}
+	return unicode.In(c, unicode.L, unicode.Nl, unicode.Other_ID_Start) &&
+		!unicode.In(c, unicode.Pattern_Syntax, unicode.Pattern_White_Space)
+}
+
+func isIdentifierChar(c rune) bool {
+	switch {
+	case c < '0':
+		return c == '$'
+	case c < ':':
+		return true
+	case c < 'A':
+		return false
+	case c <= 'Z':
+		return true
+	case c < 'a':
+		return c == '_'
+	case c <= 'z':
+		return true
+	case c < 0xaa:
+		return false
+	case c == 0x200c || c == 0x200d:
+		return true
+	}
+	return unicode.In(c, unicode.L, unicode.Nl, unicode.Other_ID_Start,
+		unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc, unicode.Other_ID_Continue) &&
+		!unicode.In(c, unicode.Pattern_Syntax, unicode.Pattern_White_Space)
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+// hasLineBreak reports if a slice of the source contains a line terminator.
+func hasLineBreak(s []uint16) bool {
+	for _, c := range s {
+		if isNewLine(rune(c)) {
+			return true
+		}
+	}
+	return false
+}
+
+// codePointAt returns a code point that starts at a given position of the
+// source, combining surrogate pairs. It returns -1 at the end of the source.
+func codePointAt(s []uint16, i int) rune {
+	if i >= len(s) {
+		return -1
+	}
+	c := rune(s[i])
+	if utf16.IsSurrogate(c) && c < 0xdc00 && i+1 < len(s) {
+		if r := utf16.DecodeRune(c, rune(s[i+1])); r != unicode.ReplacementChar {
+			return r
+		}
+	}
+	return c
+}
+
+// runeLen returns the number of code units needed to encode a code point.
+func runeLen(c rune) int {
+	if c > 0xffff {
+		return 2
+	}
+	return 1
+}
+
+// decode converts a slice of the source to a string. Lone surrogates are
+// replaced with the replacement character.
+func decode(s []uint16) string {
+	return string(utf16.Decode(s))
+}
+
+// appendRune appends a code point to a UTF-16 string.
+func appendRune(s []uint16, c rune) []uint16 {
+	if c > 0xffff {
+		r1, r2 := utf16.EncodeRune(c)
+		return append(s, uint16(r1), uint16(r2))
+	}
+	return append(s, uint16(c))
+}
@@ -0,0 +1,166 @@
+package parser
+
+// Comments are attached to nodes the same way Babel does it: each finished
+// node takes the comments preceding it as leading comments and the comments
+// that follow it as trailing comments.
+
+func lastNode(l []*Node) *Node {
+	return l[len(l)-1]
+}
+
+func (p *parser) addComment(c *Node) {
+	if !p.flowPragmaSet {
+		p.flowPragma = detectFlowPragma(c.str("value"))
+		if p.flowPragma != "" {
+			p.flowPragmaSet = true
+		}
+	}
+	p.state.trailingComments = append(p.state.trailingComments, c)
+	p.state.leadingComments = append(p.state.leadingComments, c)
+}
+
+// comments returns comments of a node stored under a given key.
+func comments(n *Node, key string) ([]*Node, bool) {
+	v, ok := n.props[key]
+	if !ok {
+		return nil, false
+	}
+	l, _ := v.([]*Node)
+	return l, true
+}
+
+// adjustCommentsAfterTrailingComma moves comments that follow the last
+// element of a list to the element.
+func (p *parser) adjustCommentsAfterTrailingComma(n *Node, elements []*Node) {
+	if len(p.state.leadingComments) == 0 {
+		return
+	}
+	var last *Node
+	for i := len(elements); last == nil && i > 0; {
+		i--
+		last = elements[i]
+	}
+	if last == nil {
+		return
+	}
+
+	var kept []*Node
+	for _, c := range p.state.leadingComments {
+		if c.End >= p.state.commentPreviousNode.End {
+			kept = append(kept, c)
+		}
+	}
+	p.state.leadingComments = kept
+	if len(p.state.leadingComments) > 0 {
+		last.set("trailingComments", p.state.leadingComments)
+		p.state.leadingComments = nil
+	}
+}
+
+func (p *parser) processComment(n *Node) {
+	if n.Type == "Program" && len(n.list("body")) > 0 {
+		return
+	}
+	stack := p.state.commentStack
+	var firstChild, lastChild *Node
+	var trailing []*Node
+	hasTrailing := false
+
+	if len(p.state.trailingComments) > 0 {
+		// if the first comment comes after the node, all of them do
+		if p.state.trailingComments[0].Start >= n.End {
+			trailing, hasTrailing = p.state.trailingComments, true
+			p.state.trailingComments = nil
+		} else {
+			// a mix of leading and trailing comments, leadingComments
+			// contains the same items and will be processed below
+			p.state.trailingComments = nil
+		}
+	} else if len(stack) > 0 {
+		last := lastNode(stack)
+		if l, _ := comments(last, "trailingComments"); len(l) > 0 && l[0].Start >= n.End {
+			trailing, hasTrailing = l, true
+			last.del("trailingComments")
+		}
+	}
+
+	// eat the stack
+	if len(stack) > 0 && lastNode(stack).Start >= n.Start {
+		firstChild = lastNode(stack)
+		stack = stack[:len(stack)-1]
+	}
+	for len(stack) > 0 && lastNode(stack).Start >= n.Start {
+		lastChild = lastNode(stack)
+		stack = stack[:len(stack)-1]
+	}
+	p.state.commentStack = stack
+	if lastChild == nil && firstChild != nil {
+		lastChild = firstChild
+	}
+
+	if firstChild != nil && n.Type == "ObjectExpression" {
+		p.adjustCommentsAfterTrailingComma(n, n.list("properties"))
+	}
+
+	if lastChild != nil {
+		if leading, ok := comments(lastChild, "leadingComments"); ok {
+			if lastChild != n && len(leading) > 0 && lastNode(leading).End <= n.Start {
+				n.set("leadingComments", leading)
+				lastChild.del("leadingComments")
+			} else {
+				// a leading comment of an anonymous class was taken by its
+				// first method, take it back
+				for i := len(leading) - 2; i >= 0; i-- {
+					if leading[i].End <= n.Start {
+						n.set("leadingComments", leading[:i+1:i+1])
+						lastChild.set("leadingComments", leading[i+1:])
+						break
+					}
+				}
+			}
+		}
+	} else if len(p.state.leadingComments) > 0 {
+		if lastNode(p.state.leadingComments).End <= n.Start {
+			if prev := p.state.commentPreviousNode; prev != nil {
+				var kept []*Node
+				for _, c := range p.state.leadingComments {
+					if c.End >= prev.End {
+						kept = append(kept, c)
+					}
+				}
+				p.state.leadingComments = kept
+			}
+			if len(p.state.leadingComments) > 0 {
+				n.set("leadingComments", p.state.leadingComments)
+				p.state.leadingComments = nil
+			}
+		} else {
+			// Comments may end up as leading comments in special cases,
+			// like return without a value. Split them into leading comments
+			// of the node and trailing comments after it.
+			i := 0
+			for ; i < len(p.state.leadingComments); i++ {
+				if p.state.leadingComments[i].End > n.Start {
+					break
+				}
+			}
+			if i > 0 {
+				n.set("leadingComments", append([]*Node{}, p.state.leadingComments[:i]...))
+			}
+			trailing = append([]*Node{}, p.state.leadingComments[i:]...)
+			hasTrailing = len(trailing) > 0
+		}
+	}
+
+	p.state.commentPreviousNode = n
+
+	if hasTrailing {
+		if len(trailing) > 0 && trailing[0].Start >= n.Start && lastNode(trailing).End <= n.End {
+			n.set("innerComments", trailing)
+		} else {
+			n.set("trailingComments", trailing)
+		}
+	}
+
+	p.state.commentStack = append(p.state.commentStack, n)
+}
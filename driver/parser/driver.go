@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"context"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	derrors "github.com/bblfsh/sdk/v3/driver/errors"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/limits"
+)
+
+// DefaultMaxDepth is the nesting limit used when the limit is disabled.
+// Unlike the native parser, Go cannot recover from a stack overflow.
+const DefaultMaxDepth = 1 << 16
+
+// Driver is a native driver that runs the parser in-process.
+// It is safe for concurrent use.
+type Driver struct {
+	maxDepth int
+	tokens   bool
+}
+
+var _ driver.Native = (*Driver)(nil)
+
+// NewDriver creates an in-process native driver. Sources nested deeper than
+// maxDepth are rejected as too complex; DefaultMaxDepth is used if it is zero.
+func NewDriver(maxDepth int) *Driver {
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	return &Driver{maxDepth: maxDepth}
+}
+
+// WithTokens returns a copy of the driver that adds the list of tokens to
+// the File node, as the native driver does if JS_DRIVER_TOKENS is set.
+func (d *Driver) WithTokens() *Driver {
+	c := *d
+	c.tokens = true
+	return &c
+}
+
+// Start implements driver.Native. It does nothing.
+func (d *Driver) Start() error {
+	return nil
+}
+
+// Parse implements driver.Native. Errors are the same as the native parser
+// replies with: one syntax error for each parsing mode.
+func (d *Driver) Parse(ctx context.Context, src string) (nodes.Node, error) {
+	file, err := ParseGuess(ctx, src, Options{MaxDepth: d.maxDepth, Tokens: d.tokens})
+	switch e := err.(type) {
+	case nil:
+		return file.ToNode(), nil
+	case *GuessError:
+		errs := make([]error, 0, len(e.Errors))
+		for _, serr := range e.Errors {
+			errs = append(errs, serr)
+		}
+		return nil, derrors.Join(errs)
+	}
+	if err == ErrTooDeep {
+		return nil, limits.ErrTooComplex.New(err.Error())
+	}
+	// the request was cancelled or the parser failed
+	return nil, driver.ErrDriverFailure.Wrap(err)
+}
+
+// Close implements driver.Native. It does nothing.
+func (d *Driver) Close() error {
+	return nil
+}
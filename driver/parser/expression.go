@@ -0,0 +1,1733 @@
+package parser
+
+import "strings"
+
+// refPos is a position shared between parsing functions, like the positions
+// of shorthand default values that are only valid in patterns.
+type refPos struct {
+	start int
+}
+
+// afterLeftParse is called by parseMaybeAssign after the left side is parsed.
+type afterLeftParse func(n *Node, startPos int, startLoc Position) *Node
+
+func (p *parser) checkPropClash(prop *Node, protoSeen *bool) {
+	if prop.Type == "SpreadElement" || prop.flag("computed") || prop.has("kind") || prop.flag("shorthand") {
+		return
+	}
+	key := prop.node("key")
+	var name string
+	if key.Type == "Identifier" {
+		name = key.str("name")
+	} else if s, ok := key.get("value").(string); ok {
+		name = s
+	}
+	if name == "__proto__" {
+		if *protoSeen {
+			p.raise(key.Start, "Redefinition of __proto__ property")
+		}
+		*protoSeen = true
+	}
+}
+
+// parseExpression parses a comma separated list of expressions.
+func (p *parser) parseExpression(noIn bool, ref *refPos) *Node {
+	startPos, startLoc := p.state.start, p.state.startLoc
+	expr := p.parseMaybeAssign(noIn, ref, nil, nil)
+	if p.match(ttComma) {
+		n := p.startNodeAt(startPos, startLoc)
+		exprs := []*Node{expr}
+		for p.eat(ttComma) {
+			exprs = append(exprs, p.parseMaybeAssign(noIn, ref, nil, nil))
+		}
+		n.set("expressions", exprs)
+		p.toReferencedList(exprs, false)
+		return p.finishNode(n, "SequenceExpression")
+	}
+	return expr
+}
+
+// parseMaybeAssign parses an assignment expression. Flow and JSX make it
+// ambiguous, since "<" may start type parameters of an arrow function or a
+// JSX element.
+func (p *parser) parseMaybeAssign(noIn bool, ref *refPos, after afterLeftParse, refNeedsArrowPos *refPos) *Node {
+	p.enter()
+	defer p.leave()
+
+	var jsxError *SyntaxError
+	if p.match(ttJSXTagStart) || p.isRelational("<") {
+		st := p.state.clone(false)
+		scopes := p.scope.snapshot()
+		var res *Node
+		err := p.try(func() {
+			res = p.parseMaybeAssignBase(noIn, ref, after, refNeedsArrowPos)
+		})
+		if err == nil {
+			return res
+		}
+		p.state = st
+		p.scope.restore(scopes)
+		// remove the JSX contexts added for the tag start
+		if p.curContext() == ctJSXOpenTag {
+			p.popContext()
+			p.popContext()
+		}
+		jsxError = err
+	}
+
+	if jsxError != nil || p.isRelational("<") {
+		var arrow, typeParams *Node
+		err := p.try(func() {
+			typeParams = p.flowParseTypeParameterDeclaration()
+			arrow = p.forwardNoArrowParamsConversionAt(typeParams, func() *Node {
+				return p.parseMaybeAssignBase(noIn, ref, after, refNeedsArrowPos)
+			})
+			arrow.set("typeParameters", typeParams)
+			p.resetStartLocationFromNode(arrow, typeParams)
+		})
+		if err != nil {
+			if jsxError != nil {
+				panic(jsxError)
+			}
+			panic(err)
+		}
+		if arrow.Type == "ArrowFunctionExpression" {
+			return arrow
+		} else if jsxError != nil {
+			panic(jsxError)
+		}
+		p.raise(typeParams.Start, "Expected an arrow function after this type parameter declaration")
+	}
+	return p.parseMaybeAssignBase(noIn, ref, after, refNeedsArrowPos)
+}
+
+func (p *parser) parseMaybeAssignBase(noIn bool, ref *refPos, after afterLeftParse, refNeedsArrowPos *refPos) *Node {
+	startPos, startLoc := p.state.start, p.state.startLoc
+	if p.isContextual("yield") {
+		if p.scope.inGenerator() {
+			left := p.parseYield(noIn)
+			if after != nil {
+				left = after(left, startPos, startLoc)
+			}
+			return left
+		}
+		// the tokenizer assumed an expression after yield
+		p.state.exprAllowed = false
+	}
+
+	failOnShorthandAssign := false
+	if ref == nil {
+		ref = &refPos{}
+		failOnShorthandAssign = true
+	}
+	if p.match(ttParenL) || p.match(ttName) {
+		p.state.potentialArrowAt = p.state.start
+	}
+
+	left := p.parseMaybeConditional(noIn, ref, refNeedsArrowPos)
+	if after != nil {
+		left = after(left, startPos, startLoc)
+	}
+	if p.state.typ.isAssign {
+		n := p.startNodeAt(startPos, startLoc)
+		op, _ := p.state.value.(string)
+		n.set("operator", op)
+		if op == "??=" {
+			p.expectPlugin("logicalAssignment", -1)
+		}
+		if op == "||=" || op == "&&=" {
+			p.expectPlugin("logicalAssignment", -1)
+		}
+		if p.match(ttEq) {
+			n.set("left", p.toAssignable(left, false, "assignment expression"))
+		} else {
+			n.set("left", left)
+		}
+		// the shorthand default was used correctly
+		ref.start = 0
+
+		p.checkLVal(left, bindNone, nil, "assignment expression")
+		if left.parenthesized() {
+			msg := ""
+			if left.Type == "ObjectPattern" {
+				msg = "`({a}) = 0`"
+			} else if left.Type == "ArrayPattern" {
+				msg = "`([a]) = 0`"
+			}
+			if msg != "" {
+				p.raise(left.Start, "You're trying to assign to a parenthesized expression, eg. instead of "+
+					msg+" use "+strings.NewReplacer("(", "", ")", "").Replace(msg))
+			}
+		}
+		p.next()
+		n.set("right", p.parseMaybeAssign(noIn, nil, nil, nil))
+		return p.finishNode(n, "AssignmentExpression")
+	} else if failOnShorthandAssign && ref.start != 0 {
+		p.unexpected(ref.start)
+	}
+	return left
+}
+
+func (p *parser) parseMaybeConditional(noIn bool, ref *refPos, refNeedsArrowPos *refPos) *Node {
+	startPos, startLoc := p.state.start, p.state.startLoc
+	potentialArrowAt := p.state.potentialArrowAt
+	expr := p.parseExprOps(noIn, ref)
+	if expr.Type == "ArrowFunctionExpression" && expr.Start == potentialArrowAt {
+		return expr
+	}
+	if ref != nil && ref.start != 0 {
+		return expr
+	}
+	return p.parseConditional(expr, noIn, startPos, startLoc, refNeedsArrowPos)
+}
+
+// parseConditional parses a conditional expression. Flow makes it ambiguous,
+// since "a ? (b) : c => d" may contain an arrow function with a return type.
+func (p *parser) parseConditional(expr *Node, noIn bool, startPos int, startLoc Position, refNeedsArrowPos *refPos) *Node {
+	if !p.match(ttQuestion) {
+		return expr
+	}
+	// only parse speculatively when coming from inside parentheses
+	if refNeedsArrowPos != nil {
+		st := p.state.clone(false)
+		scopes := p.scope.snapshot()
+		var res *Node
+		err := p.try(func() {
+			res = p.parseConditionalBase(expr, noIn, startPos, startLoc)
+		})
+		if err == nil {
+			return res
+		}
+		p.state = st
+		p.scope.restore(scopes)
+		refNeedsArrowPos.start = err.Pos
+		if refNeedsArrowPos.start == 0 {
+			refNeedsArrowPos.start = p.state.start
+		}
+		return expr
+	}
+
+	p.expect(ttQuestion)
+	st := p.state.clone(false)
+	scopes := p.scope.snapshot()
+	originalNoArrowAt := p.state.noArrowAt
+	n := p.startNodeAt(startPos, startLoc)
+	consequent, failed := p.tryParseConditionalConsequent()
+	valid, invalid := p.getArrowLikeExpressions(consequent, false)
+
+	if failed || len(invalid) > 0 {
+		noArrowAt := append([]int(nil), originalNoArrowAt...)
+		if len(invalid) > 0 {
+			p.state = st.clone(false)
+			p.scope.restore(scopes)
+			for _, n := range invalid {
+				noArrowAt = append(noArrowAt, n.Start)
+			}
+			p.state.noArrowAt = noArrowAt
+			consequent, failed = p.tryParseConditionalConsequent()
+			valid, invalid = p.getArrowLikeExpressions(consequent, false)
+		}
+		if failed && len(valid) > 1 {
+			p.raise(st.start, "Ambiguous expression: wrap the arrow functions in parentheses to disambiguate.")
+		}
+		if failed && len(valid) == 1 {
+			p.state = st.clone(false)
+			p.scope.restore(scopes)
+			p.state.noArrowAt = append(append([]int(nil), noArrowAt...), valid[0].Start)
+			consequent, failed = p.tryParseConditionalConsequent()
+		}
+		p.getArrowLikeExpressions(consequent, true)
+	}
+
+	p.state.noArrowAt = originalNoArrowAt
+	p.expect(ttColon)
+	n.set("test", expr)
+	n.set("consequent", consequent)
+	n.set("alternate", p.forwardNoArrowParamsConversionAt(n, func() *Node {
+		return p.parseMaybeAssign(noIn, nil, nil, nil)
+	}))
+	return p.finishNode(n, "ConditionalExpression")
+}
+
+func (p *parser) parseConditionalBase(expr *Node, noIn bool, startPos int, startLoc Position) *Node {
+	if p.eat(ttQuestion) {
+		n := p.startNodeAt(startPos, startLoc)
+		n.set("test", expr)
+		n.set("consequent", p.parseMaybeAssign(false, nil, nil, nil))
+		p.expect(ttColon)
+		n.set("alternate", p.parseMaybeAssign(noIn, nil, nil, nil))
+		return p.finishNode(n, "ConditionalExpression")
+	}
+	return expr
+}
+
+func (p *parser) tryParseConditionalConsequent() (*Node, bool) {
+	p.state.noArrowParamsConversionAt = append(p.state.noArrowParamsConversionAt, p.state.start)
+	consequent := p.parseMaybeAssign(false, nil, nil, nil)
+	failed := !p.match(ttColon)
+	p.state.noArrowParamsConversionAt = p.state.noArrowParamsConversionAt[:len(p.state.noArrowParamsConversionAt)-1]
+	return consequent, failed
+}
+
+// getArrowLikeExpressions returns arrow functions with return types that
+// could be parenthesized expressions, split into the ones with parameters
+// that can be converted to patterns and the others.
+func (p *parser) getArrowLikeExpressions(n *Node, disallowInvalid bool) (valid, invalid []*Node) {
+	stack := []*Node{n}
+	var arrows []*Node
+	for len(stack) != 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n.Type == "ArrowFunctionExpression" {
+			if n.node("typeParameters") != nil || n.node("returnType") == nil {
+				// not ambiguous
+				p.finishArrowValidation(n)
+			} else {
+				arrows = append(arrows, n)
+			}
+			stack = append(stack, n.node("body"))
+		} else if n.Type == "ConditionalExpression" {
+			stack = append(stack, n.node("consequent"), n.node("alternate"))
+		}
+	}
+	if disallowInvalid {
+		for _, a := range arrows {
+			p.finishArrowValidation(a)
+		}
+		return arrows, nil
+	}
+	for _, a := range arrows {
+		ok := true
+		for _, param := range a.list("params") {
+			if !p.isAssignable(param, true) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			valid = append(valid, a)
+		} else {
+			invalid = append(invalid, a)
+		}
+	}
+	return valid, invalid
+}
+
+func (p *parser) finishArrowValidation(n *Node) {
+	n.set("params", p.toAssignableList(n.list("params"), true, "arrow function parameters"))
+	p.scope.enter(functionFlags(false, false) | scopeArrow)
+	p.checkParamsBase(n, false)
+	p.scope.exit()
+}
+
+func (p *parser) forwardNoArrowParamsConversionAt(n *Node, parse func() *Node) *Node {
+	if containsInt(p.state.noArrowParamsConversionAt, n.Start) {
+		p.state.noArrowParamsConversionAt = append(p.state.noArrowParamsConversionAt, p.state.start)
+		res := parse()
+		p.state.noArrowParamsConversionAt = p.state.noArrowParamsConversionAt[:len(p.state.noArrowParamsConversionAt)-1]
+		return res
+	}
+	return parse()
+}
+
+func containsInt(l []int, v int) bool {
+	for _, x := range l {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// parseExprOps parses binary operators.
+func (p *parser) parseExprOps(noIn bool, ref *refPos) *Node {
+	startPos, startLoc := p.state.start, p.state.startLoc
+	potentialArrowAt := p.state.potentialArrowAt
+	expr := p.parseMaybeUnary(ref)
+	if expr.Type == "ArrowFunctionExpression" && expr.Start == potentialArrowAt {
+		return expr
+	}
+	if ref != nil && ref.start != 0 {
+		return expr
+	}
+	return p.parseExprOp(expr, startPos, startLoc, -1, noIn)
+}
+
+// parseExprOp parses the right side of binary operators with the precedence
+// greater than minPrec.
+func (p *parser) parseExprOp(left *Node, leftStartPos int, leftStartLoc Position, minPrec int, noIn bool) *Node {
+	for {
+		prec := p.state.typ.binop
+		if prec < 0 || (noIn && p.match(ttIn)) || prec <= minPrec {
+			return left
+		}
+		op, _ := p.state.value.(string)
+		n := p.startNodeAt(leftStartPos, leftStartLoc)
+		n.set("left", left)
+		n.set("operator", op)
+		if op == "**" && left.Type == "UnaryExpression" && !left.parenthesized() {
+			p.raise(left.node("argument").Start, "Illegal expression. Wrap left hand side or entire exponentiation in parentheses.")
+		}
+		t := p.state.typ
+		if t == ttPipeline {
+			p.state.inPipeline = true
+		}
+		p.next()
+		if t == ttPipeline && p.match(ttName) && p.state.value == "await" && p.scope.inAsync() {
+			p.raise(p.state.start, `Unexpected "await" after pipeline body; await must have parentheses in minimal proposal`)
+		}
+
+		p.enter()
+		startPos, startLoc := p.state.start, p.state.startLoc
+		rightPrec := prec
+		if t.rightAssociative {
+			rightPrec = prec - 1
+		}
+		n.set("right", p.parseExprOp(p.parseMaybeUnary(nil), startPos, startLoc, rightPrec, noIn))
+		p.leave()
+
+		typ := "BinaryExpression"
+		if t == ttLogicalOR || t == ttLogicalAND || t == ttNullishCoalescing {
+			typ = "LogicalExpression"
+		}
+		left = p.finishNode(n, typ)
+	}
+}
+
+// parseMaybeUnary parses unary operators, both prefix and postfix.
+func (p *parser) parseMaybeUnary(ref *refPos) *Node {
+	if p.isContextual("await") && p.scope.inAsync() {
+		return p.parseAwait()
+	} else if p.state.typ.prefix {
+		p.enter()
+		defer p.leave()
+		n := p.startNode()
+		update := p.match(ttIncDec)
+		op, _ := p.state.value.(string)
+		n.set("operator", op)
+		n.set("prefix", true)
+		p.next()
+		arg := p.parseMaybeUnary(nil)
+		n.set("argument", arg)
+		if ref != nil && ref.start != 0 {
+			p.unexpected(ref.start)
+		}
+		if update {
+			p.checkLVal(arg, bindNone, nil, "prefix operation")
+		} else if p.state.strict && op == "delete" {
+			if arg.Type == "Identifier" {
+				p.raise(n.Start, "Deleting local variable in strict mode")
+			} else if arg.Type == "MemberExpression" && arg.node("property").Type == "PrivateName" {
+				p.raise(n.Start, "Deleting a private field is not allowed")
+			}
+		}
+		if update {
+			return p.finishNode(n, "UpdateExpression")
+		}
+		return p.finishNode(n, "UnaryExpression")
+	}
+
+	startPos, startLoc := p.state.start, p.state.startLoc
+	expr := p.parseExprSubscripts(ref)
+	if ref != nil && ref.start != 0 {
+		return expr
+	}
+	for p.state.typ.postfix && !p.canInsertSemicolon() {
+		n := p.startNodeAt(startPos, startLoc)
+		n.set("operator", p.state.value)
+		n.set("prefix", false)
+		n.set("argument", expr)
+		p.checkLVal(expr, bindNone, nil, "postfix operation")
+		p.next()
+		expr = p.finishNode(n, "UpdateExpression")
+	}
+	return expr
+}
+
+func (p *parser) parseExprSubscripts(ref *refPos) *Node {
+	startPos, startLoc := p.state.start, p.state.startLoc
+	potentialArrowAt := p.state.potentialArrowAt
+	expr := p.parseExprAtom(ref)
+	if expr.Type == "ArrowFunctionExpression" && expr.Start == potentialArrowAt {
+		return expr
+	}
+	if ref != nil && ref.start != 0 {
+		return expr
+	}
+	return p.parseSubscripts(expr, startPos, startLoc, false)
+}
+
+type subscriptState struct {
+	optionalChainMember bool
+	maybeAsyncArrow     bool
+	stop                bool
+}
+
+func (p *parser) parseSubscripts(base *Node, startPos int, startLoc Position, noCalls bool) *Node {
+	if base.Type == "Identifier" && base.str("name") == "async" && containsInt(p.state.noArrowAt, startPos) {
+		p.next()
+		n := p.startNodeAt(startPos, startLoc)
+		n.set("callee", base)
+		n.set("arguments", p.parseCallExpressionArguments(ttParenR, false, false, nil))
+		base = p.finishNode(n, "CallExpression")
+	} else if base.Type == "Identifier" && base.str("name") == "async" && p.isRelational("<") {
+		st := p.state.clone(false)
+		scopes := p.scope.snapshot()
+		var res *Node
+		err := p.try(func() {
+			res = p.parseAsyncArrowWithTypeParameters(startPos, startLoc)
+		})
+		if err == nil && res != nil {
+			return res
+		}
+		p.state = st
+		p.scope.restore(scopes)
+		err2 := p.try(func() {
+			res = p.parseSubscriptsBase(base, startPos, startLoc, noCalls)
+		})
+		if err2 != nil {
+			if err != nil {
+				panic(err)
+			}
+			panic(err2)
+		}
+		return res
+	}
+	return p.parseSubscriptsBase(base, startPos, startLoc, noCalls)
+}
+
+func (p *parser) parseSubscriptsBase(base *Node, startPos int, startLoc Position, noCalls bool) *Node {
+	st := &subscriptState{maybeAsyncArrow: p.atPossibleAsync(base)}
+	for {
+		base = p.parseSubscript(base, startPos, startLoc, noCalls, st)
+		// after a subscript this is not "async" for sure
+		st.maybeAsyncArrow = false
+		if st.stop {
+			return base
+		}
+	}
+}
+
+// parseSubscript parses a member access, a call, or a tagged template.
+func (p *parser) parseSubscript(base *Node, startPos int, startLoc Position, noCalls bool, st *subscriptState) *Node {
+	if p.match(ttQuestionDot) && p.isLookaheadRelational("<") {
+		st.optionalChainMember = true
+		if noCalls {
+			st.stop = true
+			return base
+		}
+		p.next()
+		n := p.startNodeAt(startPos, startLoc)
+		n.set("callee", base)
+		n.set("typeArguments", p.flowParseTypeParameterInstantiation())
+		p.expect(ttParenL)
+		n.set("arguments", p.parseCallExpressionArguments(ttParenR, false, false, nil))
+		n.set("optional", true)
+		return p.finishNode(n, "OptionalCallExpression")
+	} else if !noCalls && p.shouldParseTypes() && p.isRelational("<") {
+		n := p.startNodeAt(startPos, startLoc)
+		n.set("callee", base)
+		var res *Node
+		if p.speculate(func() {
+			n.set("typeArguments", p.flowParseTypeParameterInstantiationCallOrNew())
+			p.expect(ttParenL)
+			n.set("arguments", p.parseCallExpressionArguments(ttParenR, false, false, nil))
+			if st.optionalChainMember {
+				n.set("optional", false)
+				res = p.finishNode(n, "OptionalCallExpression")
+			} else {
+				res = p.finishNode(n, "CallExpression")
+			}
+		}) {
+			return res
+		}
+	}
+
+	if !noCalls && p.match(ttDoubleColon) {
+		n := p.startNodeAt(startPos, startLoc)
+		n.set("object", base)
+		p.next()
+		n.set("callee", p.parseNoCallExpr())
+		st.stop = true
+		return p.parseSubscripts(p.finishNode(n, "BindExpression"), startPos, startLoc, noCalls)
+	} else if p.match(ttQuestionDot) {
+		st.optionalChainMember = true
+		if noCalls && p.lookahead().typ == ttParenL {
+			st.stop = true
+			return base
+		}
+		p.next()
+		n := p.startNodeAt(startPos, startLoc)
+		if p.eat(ttBracketL) {
+			n.set("object", base)
+			n.set("property", p.parseExpression(false, nil))
+			n.set("computed", true)
+			n.set("optional", true)
+			p.expect(ttBracketR)
+			return p.finishNode(n, "OptionalMemberExpression")
+		} else if p.eat(ttParenL) {
+			n.set("callee", base)
+			n.set("arguments", p.parseCallExpressionArguments(ttParenR, false, false, nil))
+			n.set("optional", true)
+			return p.finishNode(n, "OptionalCallExpression")
+		}
+		n.set("object", base)
+		n.set("property", p.parseIdentifier(true))
+		n.set("computed", false)
+		n.set("optional", true)
+		return p.finishNode(n, "OptionalMemberExpression")
+	} else if p.eat(ttDot) {
+		n := p.startNodeAt(startPos, startLoc)
+		n.set("object", base)
+		n.set("property", p.parseMaybePrivateName())
+		n.set("computed", false)
+		if st.optionalChainMember {
+			n.set("optional", false)
+			return p.finishNode(n, "OptionalMemberExpression")
+		}
+		return p.finishNode(n, "MemberExpression")
+	} else if p.eat(ttBracketL) {
+		n := p.startNodeAt(startPos, startLoc)
+		n.set("object", base)
+		n.set("property", p.parseExpression(false, nil))
+		n.set("computed", true)
+		p.expect(ttBracketR)
+		if st.optionalChainMember {
+			n.set("optional", false)
+			return p.finishNode(n, "OptionalMemberExpression")
+		}
+		return p.finishNode(n, "MemberExpression")
+	} else if !noCalls && p.match(ttParenL) {
+		oldMaybeInArrowParameters := p.state.maybeInArrowParameters
+		oldYieldPos, oldAwaitPos := p.state.yieldPos, p.state.awaitPos
+		p.state.maybeInArrowParameters = true
+		p.state.yieldPos = -1
+		p.state.awaitPos = -1
+		p.next()
+
+		n := p.startNodeAt(startPos, startLoc)
+		n.set("callee", base)
+		refTrailingCommaPos := &refPos{start: -1}
+		args := p.parseCallExpressionArguments(ttParenR, st.maybeAsyncArrow, base.Type == "Import", refTrailingCommaPos)
+		n.set("arguments", args)
+		if !st.optionalChainMember {
+			p.finishCallExpression(n)
+		} else {
+			p.finishNode(n, "OptionalCallExpression")
+		}
+
+		if st.maybeAsyncArrow && p.shouldParseAsyncArrow() {
+			st.stop = true
+			if refTrailingCommaPos.start > -1 {
+				p.raise(refTrailingCommaPos.start, "A trailing comma is not permitted after the rest element")
+			}
+			n = p.parseAsyncArrowFromCallExpression(p.startNodeAt(startPos, startLoc), n)
+			p.checkYieldAwaitInDefaultParams()
+			p.state.yieldPos = oldYieldPos
+			p.state.awaitPos = oldAwaitPos
+		} else {
+			p.toReferencedListDeep(args, false)
+			// keep the old positions for cases like (x = async(yield)) => {}
+			if oldYieldPos != -1 {
+				p.state.yieldPos = oldYieldPos
+			}
+			if !oldMaybeInArrowParameters || oldAwaitPos != -1 {
+				p.state.awaitPos = oldAwaitPos
+			}
+		}
+		p.state.maybeInArrowParameters = oldMaybeInArrowParameters
+		return n
+	} else if p.match(ttBackQuote) {
+		return p.parseTaggedTemplateExpression(startPos, startLoc, base, st)
+	}
+	st.stop = true
+	return base
+}
+
+func (p *parser) parseTaggedTemplateExpression(startPos int, startLoc Position, base *Node, st *subscriptState) *Node {
+	n := p.startNodeAt(startPos, startLoc)
+	n.set("tag", base)
+	n.set("quasi", p.parseTemplate(true))
+	if st.optionalChainMember {
+		p.raise(startPos, "Tagged Template Literals are not allowed in optionalChain")
+	}
+	return p.finishNode(n, "TaggedTemplateExpression")
+}
+
+func (p *parser) atPossibleAsync(base *Node) bool {
+	return base.Type == "Identifier" && base.str("name") == "async" &&
+		p.state.lastTokEnd == base.End && !p.canInsertSemicolon() &&
+		decode(p.input[base.Start:base.End]) == "async"
+}
+
+func (p *parser) finishCallExpression(n *Node) *Node {
+	if n.node("callee").Type == "Import" {
+		args := n.list("arguments")
+		if len(args) != 1 {
+			p.raise(n.Start, "import() requires exactly one argument")
+		}
+		if len(args) > 0 && args[0] != nil && args[0].Type == "SpreadElement" {
+			p.raise(args[0].Start, "... is not allowed in import()")
+		}
+	}
+	return p.finishNode(n, "CallExpression")
+}
+
+func (p *parser) parseCallExpressionArguments(close *tokenType, possibleAsyncArrow, dynamicImport bool, refTrailingCommaPos *refPos) []*Node {
+	elts := []*Node{}
+	innerParenStart := 0
+	first := true
+	for !p.eat(close) {
+		if first {
+			first = false
+		} else {
+			p.expect(ttComma)
+			if p.eat(close) {
+				if dynamicImport {
+					p.raise(p.state.lastTokStart, "Trailing comma is disallowed inside import(...) arguments")
+				}
+				break
+			}
+		}
+		// inner parentheses are not allowed in parameters of async arrows
+		if p.match(ttParenL) && innerParenStart == 0 {
+			innerParenStart = p.state.start
+		}
+		if possibleAsyncArrow {
+			elts = append(elts, p.parseExprListItem(false, &refPos{}, &refPos{}, refTrailingCommaPos))
+		} else {
+			elts = append(elts, p.parseExprListItem(false, nil, nil, nil))
+		}
+	}
+	if possibleAsyncArrow && innerParenStart != 0 && p.shouldParseAsyncArrow() {
+		p.unexpected(-1)
+	}
+	return elts
+}
+
+func (p *parser) shouldParseAsyncArrow() bool {
+	return p.match(ttColon) || (p.match(ttArrow) && !p.canInsertSemicolon())
+}
+
+func (p *parser) parseAsyncArrowFromCallExpression(n, call *Node) *Node {
+	if p.match(ttColon) {
+		old := p.state.noAnonFunctionType
+		p.state.noAnonFunctionType = true
+		n.set("returnType", p.flowParseTypeAnnotation())
+		p.state.noAnonFunctionType = old
+	}
+	p.expect(ttArrow)
+	p.parseArrowExpression(n, call.list("arguments"), true)
+	return n
+}
+
+// parseNoCallExpr parses an expression without calls, like a callee of new.
+func (p *parser) parseNoCallExpr() *Node {
+	startPos, startLoc := p.state.start, p.state.startLoc
+	return p.parseSubscripts(p.parseExprAtom(nil), startPos, startLoc, true)
+}
+
+// parseExprAtom parses an atomic expression: an identifier, a literal, or an
+// expression in brackets of any kind.
+func (p *parser) parseExprAtom(ref *refPos) *Node {
+	// JSX
+	if p.match(ttJSXText) {
+		return p.parseLiteral(p.state.value, "JSXText")
+	} else if p.match(ttJSXTagStart) {
+		return p.jsxParseElement()
+	} else if p.isRelational("<") && p.charAt(p.state.pos) != '!' {
+		// "<" is not allowed where an expression is expected, thus it is JSX
+		p.finishToken(ttJSXTagStart, nil)
+		return p.jsxParseElement()
+	}
+
+	// the tokenizer read a division where an expression is expected
+	if p.match(ttSlash) {
+		p.readRegexp()
+	}
+
+	canBeArrow := p.state.potentialArrowAt == p.state.start
+	switch p.state.typ {
+	case ttSuper:
+		if !p.scope.allowSuper() {
+			p.raise(p.state.start, "super is only allowed in object methods and classes")
+		}
+		n := p.startNode()
+		p.next()
+		if p.match(ttParenL) && !p.scope.allowDirectSuper() {
+			p.raise(n.Start, "super() is only valid inside a class constructor of a subclass. "+
+				"Maybe a typo in the method name ('constructor') or not extending another class?")
+		}
+		if !p.match(ttParenL) && !p.match(ttBracketL) && !p.match(ttDot) {
+			p.unexpected(-1)
+		}
+		return p.finishNode(n, "Super")
+
+	case ttImport:
+		n := p.startNode()
+		p.next()
+		if p.match(ttDot) {
+			return p.parseImportMetaProperty(n)
+		}
+		if !p.match(ttParenL) {
+			p.unexpectedType(-1, ttParenL)
+		}
+		return p.finishNode(n, "Import")
+
+	case ttThis:
+		n := p.startNode()
+		p.next()
+		return p.finishNode(n, "ThisExpression")
+
+	case ttName:
+		n := p.startNode()
+		containsEsc := p.state.containsEsc
+		id := p.parseIdentifier(false)
+		if !containsEsc && id.str("name") == "async" && p.match(ttFunction) && !p.canInsertSemicolon() {
+			p.next()
+			return p.parseFunction(n, 0, true)
+		} else if canBeArrow && !containsEsc && id.str("name") == "async" && p.match(ttName) && !p.canInsertSemicolon() {
+			// async x => {}
+			params := []*Node{p.parseIdentifier(false)}
+			p.expect(ttArrow)
+			p.parseArrowExpression(n, params, true)
+			return n
+		}
+		if canBeArrow && p.match(ttArrow) && !p.canInsertSemicolon() {
+			p.next()
+			p.parseArrowExpression(n, []*Node{id}, false)
+			return n
+		}
+		return id
+
+	case ttDo:
+		n := p.startNode()
+		p.next()
+		oldLabels := p.state.labels
+		p.state.labels = nil
+		n.set("body", p.parseBlock(false, false))
+		p.state.labels = oldLabels
+		return p.finishNode(n, "DoExpression")
+
+	case ttRegexp:
+		v := p.state.value.(regexpValue)
+		n := p.parseLiteral(undefined, "RegExpLiteral")
+		n.set("pattern", v.pattern)
+		n.set("flags", v.flags)
+		return n
+
+	case ttNum:
+		return p.parseLiteral(p.state.value, "NumericLiteral")
+
+	case ttBigint:
+		return p.parseLiteral(p.state.value, "BigIntLiteral")
+
+	case ttString:
+		return p.parseLiteral(p.state.value, "StringLiteral")
+
+	case ttNull:
+		n := p.startNode()
+		p.next()
+		return p.finishNode(n, "NullLiteral")
+
+	case ttTrue, ttFalse:
+		return p.parseBooleanLiteral()
+
+	case ttParenL:
+		return p.parseParenAndDistinguishExpression(canBeArrow)
+
+	case ttBracketL:
+		n := p.startNode()
+		p.next()
+		elems := p.parseExprList(ttBracketR, true, ref)
+		n.set("elements", elems)
+		if !p.state.maybeInArrowParameters {
+			// this could be an array pattern, like ([a: string]) => {}
+			p.toReferencedList(elems, false)
+		}
+		return p.finishNode(n, "ArrayExpression")
+
+	case ttBraceL:
+		return p.parseObj(false, ref)
+
+	case ttFunction:
+		return p.parseFunctionExpression()
+
+	case ttAt, ttClass:
+		if p.match(ttAt) {
+			p.parseDecorators(false)
+		}
+		n := p.startNode()
+		p.takeDecorators(n)
+		return p.parseClass(n, false, false)
+
+	case ttNew:
+		return p.parseNew()
+
+	case ttBackQuote:
+		return p.parseTemplate(false)
+
+	case ttDoubleColon:
+		n := p.startNode()
+		p.next()
+		n.set("object", nil)
+		callee := p.parseNoCallExpr()
+		n.set("callee", callee)
+		if callee.Type == "MemberExpression" {
+			return p.finishNode(n, "BindExpression")
+		}
+		p.raise(callee.Start, "Binding should be performed on object property.")
+	}
+	p.unexpected(-1)
+	return nil
+}
+
+func (p *parser) parseBooleanLiteral() *Node {
+	n := p.startNode()
+	n.set("value", p.match(ttTrue))
+	p.next()
+	return p.finishNode(n, "BooleanLiteral")
+}
+
+func (p *parser) parseMaybePrivateName() *Node {
+	if p.match(ttHash) {
+		n := p.startNode()
+		p.next()
+		p.assertNoSpace("Unexpected space between # and identifier")
+		n.set("id", p.parseIdentifier(true))
+		return p.finishNode(n, "PrivateName")
+	}
+	return p.parseIdentifier(true)
+}
+
+func (p *parser) assertNoSpace(msg string) {
+	if p.state.start > p.state.lastTokEnd {
+		p.raise(p.state.lastTokEnd, msg)
+	}
+}
+
+func (p *parser) parseFunctionExpression() *Node {
+	n := p.startNode()
+	meta := p.startNode()
+	p.next()
+	meta = p.createIdentifier(meta, "function")
+	if p.scope.inGenerator() && p.eat(ttDot) {
+		return p.parseMetaProperty(n, meta, "sent")
+	}
+	return p.parseFunction(n, 0, false)
+}
+
+func (p *parser) parseMetaProperty(n, meta *Node, propertyName string) *Node {
+	n.set("meta", meta)
+	if meta.str("name") == "function" && propertyName == "sent" {
+		if p.isContextual(propertyName) {
+			p.expectPlugin("functionSent", -1)
+		}
+		p.unexpected(-1)
+	}
+	containsEsc := p.state.containsEsc
+	prop := p.parseIdentifier(true)
+	n.set("property", prop)
+	if prop.str("name") != propertyName || containsEsc {
+		name := meta.str("name")
+		p.raise(prop.Start, "The only valid meta property for "+name+" is "+name+"."+propertyName)
+	}
+	return p.finishNode(n, "MetaProperty")
+}
+
+func (p *parser) parseImportMetaProperty(n *Node) *Node {
+	id := p.createIdentifier(p.startNodeAtNode(n), "import")
+	p.expect(ttDot)
+	if p.isContextual("meta") {
+		if !p.inModule {
+			p.raise(id.Start, `import.meta may appear only with 'sourceType: "module"'`)
+		}
+	}
+	return p.parseMetaProperty(n, id, "meta")
+}
+
+// parseLiteral parses a literal. The value may be undefined, which omits it
+// from the output.
+func (p *parser) parseLiteral(value interface{}, typ string) *Node {
+	return p.parseLiteralAt(value, typ, p.state.start, p.state.startLoc)
+}
+
+func (p *parser) parseLiteralAt(value interface{}, typ string, startPos int, startLoc Position) *Node {
+	n := p.startNodeAt(startPos, startLoc)
+	n.addExtra("rawValue", value)
+	n.addExtra("raw", decode(p.input[startPos:p.state.end]))
+	n.set("value", value)
+	p.next()
+	return p.finishNode(n, typ)
+}
+
+// parseParenAndDistinguishExpression parses a parenthesized expression or
+// parameters of an arrow function.
+func (p *parser) parseParenAndDistinguishExpression(canBeArrow bool) *Node {
+	canBeArrow = canBeArrow && !containsInt(p.state.noArrowAt, p.state.start)
+
+	startPos, startLoc := p.state.start, p.state.startLoc
+	p.expect(ttParenL)
+
+	oldMaybeInArrowParameters := p.state.maybeInArrowParameters
+	oldYieldPos, oldAwaitPos := p.state.yieldPos, p.state.awaitPos
+	p.state.maybeInArrowParameters = true
+	p.state.yieldPos = -1
+	p.state.awaitPos = -1
+
+	innerStartPos, innerStartLoc := p.state.start, p.state.startLoc
+	exprList := []*Node{}
+	ref := &refPos{}
+	refNeedsArrowPos := &refPos{}
+	first := true
+	spreadStart, optionalCommaStart := 0, 0
+	for !p.match(ttParenR) {
+		if first {
+			first = false
+		} else {
+			if refNeedsArrowPos.start != 0 {
+				p.expectAt(ttComma, refNeedsArrowPos.start)
+			} else {
+				p.expect(ttComma)
+			}
+			if p.match(ttParenR) {
+				optionalCommaStart = p.state.start
+				break
+			}
+		}
+		if p.match(ttEllipsis) {
+			spreadStartPos, spreadStartLoc := p.state.start, p.state.startLoc
+			spreadStart = p.state.start
+			exprList = append(exprList, p.parseParenItem(p.parseRestBinding(), spreadStartPos, spreadStartLoc))
+			p.checkCommaAfterRest()
+			break
+		}
+		exprList = append(exprList, p.parseMaybeAssign(false, ref, p.parseParenItem, refNeedsArrowPos))
+	}
+
+	innerEndPos, innerEndLoc := p.state.start, p.state.startLoc
+	p.expect(ttParenR)
+	p.state.maybeInArrowParameters = oldMaybeInArrowParameters
+
+	arrowNode := p.startNodeAt(startPos, startLoc)
+	if canBeArrow && p.shouldParseArrow() {
+		if arrowNode = p.parseArrow(arrowNode); arrowNode != nil {
+			p.checkYieldAwaitInDefaultParams()
+			p.state.yieldPos = oldYieldPos
+			p.state.awaitPos = oldAwaitPos
+			for _, param := range exprList {
+				if param.parenthesized() {
+					p.unexpected(param.extra("parenStart").(int))
+				}
+			}
+			p.parseArrowExpression(arrowNode, exprList, false)
+			return arrowNode
+		}
+	}
+
+	// keep the old positions for cases like (x = (yield)) => {}
+	if oldYieldPos != -1 {
+		p.state.yieldPos = oldYieldPos
+	}
+	if oldAwaitPos != -1 {
+		p.state.awaitPos = oldAwaitPos
+	}
+	if len(exprList) == 0 {
+		p.unexpected(p.state.lastTokStart)
+	}
+	if optionalCommaStart != 0 {
+		p.unexpected(optionalCommaStart)
+	}
+	if spreadStart != 0 {
+		p.unexpected(spreadStart)
+	}
+	if ref.start != 0 {
+		p.unexpected(ref.start)
+	}
+	if refNeedsArrowPos.start != 0 {
+		p.unexpected(refNeedsArrowPos.start)
+	}
+
+	p.toReferencedListDeep(exprList, true)
+	var val *Node
+	if len(exprList) > 1 {
+		val = p.startNodeAt(innerStartPos, innerStartLoc)
+		val.set("expressions", exprList)
+		p.finishNodeAt(val, "SequenceExpression", innerEndPos, innerEndLoc)
+	} else {
+		val = exprList[0]
+	}
+	val.addExtra("parenthesized", true)
+	val.addExtra("parenStart", startPos)
+	return val
+}
+
+func (p *parser) shouldParseArrow() bool {
+	return p.match(ttColon) || (p.match(ttArrow) && !p.canInsertSemicolon())
+}
+
+// parseArrow parses an optional return type and the arrow. It returns nil
+// if there is no arrow.
+func (p *parser) parseArrow(n *Node) *Node {
+	if p.match(ttColon) {
+		p.speculate(func() {
+			old := p.state.noAnonFunctionType
+			p.state.noAnonFunctionType = true
+			typeNode := p.startNode()
+			typ, predicate := p.flowParseTypeAndPredicateInitialiser()
+			n.set("predicate", predicate)
+			p.state.noAnonFunctionType = old
+			if p.canInsertSemicolon() {
+				p.unexpected(-1)
+			}
+			if !p.match(ttArrow) {
+				p.unexpected(-1)
+			}
+			// assign after it is clear that it is an arrow
+			if typ != nil {
+				typeNode.set("typeAnnotation", typ)
+				n.set("returnType", p.finishNode(typeNode, "TypeAnnotation"))
+			} else {
+				n.set("returnType", nil)
+			}
+		})
+	}
+	if p.eat(ttArrow) {
+		return n
+	}
+	return nil
+}
+
+// parseParenItem parses an optional mark and a type cast after an item in
+// parentheses.
+func (p *parser) parseParenItem(n *Node, startPos int, startLoc Position) *Node {
+	if p.eat(ttQuestion) {
+		n.set("optional", true)
+		// include the question mark in the node
+		p.resetEndLocation(n)
+	}
+	if p.match(ttColon) {
+		cast := p.startNodeAt(startPos, startLoc)
+		cast.set("expression", n)
+		cast.set("typeAnnotation", p.flowParseTypeAnnotation())
+		return p.finishNode(cast, "TypeCastExpression")
+	}
+	return n
+}
+
+func (p *parser) parseNew() *Node {
+	n := p.startNode()
+	meta := p.startNode()
+	p.next()
+	meta = p.createIdentifier(meta, "new")
+	if p.eat(ttDot) {
+		prop := p.parseMetaProperty(n, meta, "target")
+		if !p.scope.inNonArrowFunction() && !p.state.inClassProperty {
+			p.raise(prop.Start, "new.target can only be used in functions or class properties")
+		}
+		return prop
+	}
+	callee := p.parseNoCallExpr()
+	n.set("callee", callee)
+	if callee.Type == "Import" {
+		p.raise(callee.Start, "Cannot use new with import(...)")
+	} else if callee.Type == "OptionalMemberExpression" || callee.Type == "OptionalCallExpression" {
+		p.raise(p.state.lastTokEnd, "constructors in/after an Optional Chain are not allowed")
+	} else if p.eat(ttQuestionDot) {
+		p.raise(p.state.start, "constructors in/after an Optional Chain are not allowed")
+	}
+	p.parseNewArguments(n)
+	return p.finishNode(n, "NewExpression")
+}
+
+func (p *parser) parseNewArguments(n *Node) {
+	var targs *Node
+	if p.shouldParseTypes() && p.isRelational("<") {
+		p.speculate(func() {
+			targs = p.flowParseTypeParameterInstantiationCallOrNew()
+		})
+	}
+	n.set("typeArguments", targs)
+
+	if p.eat(ttParenL) {
+		args := p.parseExprList(ttParenR, false, nil)
+		p.toReferencedList(args, false)
+		n.set("arguments", args)
+	} else {
+		n.set("arguments", []*Node{})
+	}
+}
+
+func (p *parser) parseTemplateElement(isTagged bool) *Node {
+	elem := p.startNode()
+	if p.state.value == nil {
+		if !isTagged {
+			pos := p.state.invalidTemplateEscapePosition
+			if pos < 0 {
+				pos = 0
+			}
+			p.raise(pos, "Invalid escape sequence in template")
+		}
+		p.state.invalidTemplateEscapePosition = -1
+	}
+	raw := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(decode(p.input[p.state.start:p.state.end]))
+	elem.set("value", map[string]interface{}{
+		"raw":    raw,
+		"cooked": p.state.value,
+	})
+	p.next()
+	elem.set("tail", p.match(ttBackQuote))
+	return p.finishNode(elem, "TemplateElement")
+}
+
+func (p *parser) parseTemplate(isTagged bool) *Node {
+	n := p.startNode()
+	p.next()
+	exprs := []*Node{}
+	cur := p.parseTemplateElement(isTagged)
+	quasis := []*Node{cur}
+	for !cur.flag("tail") {
+		p.expect(ttDollarBraceL)
+		exprs = append(exprs, p.parseExpression(false, nil))
+		p.expect(ttBraceR)
+		cur = p.parseTemplateElement(isTagged)
+		quasis = append(quasis, cur)
+	}
+	n.set("expressions", exprs)
+	n.set("quasis", quasis)
+	p.next()
+	return p.finishNode(n, "TemplateLiteral")
+}
+
+// parseObj parses an object expression or an object pattern.
+func (p *parser) parseObj(isPattern bool, ref *refPos) *Node {
+	protoSeen := false
+	first := true
+	n := p.startNode()
+	props := []*Node{}
+	p.next()
+	for !p.eat(ttBraceR) {
+		if first {
+			first = false
+		} else {
+			p.expect(ttComma)
+			if p.eat(ttBraceR) {
+				break
+			}
+		}
+		prop := p.parseObjectMember(isPattern, ref)
+		if !isPattern {
+			p.checkPropClash(prop, &protoSeen)
+		}
+		if prop.flag("shorthand") {
+			prop.addExtra("shorthand", true)
+		}
+		props = append(props, prop)
+		// comments are attached using the list
+		n.set("properties", props)
+	}
+	n.set("properties", props)
+	if isPattern {
+		return p.finishNode(n, "ObjectPattern")
+	}
+	return p.finishNode(n, "ObjectExpression")
+}
+
+func (p *parser) isAsyncProp(prop *Node) bool {
+	return !prop.flag("computed") && prop.node("key").Type == "Identifier" && prop.node("key").str("name") == "async" &&
+		(p.match(ttName) || p.match(ttNum) || p.match(ttString) || p.match(ttBracketL) || p.state.typ.keyword != "" || p.match(ttStar)) &&
+		!p.hasPrecedingLineBreak()
+}
+
+func (p *parser) parseObjectMember(isPattern bool, ref *refPos) *Node {
+	if p.match(ttAt) {
+		p.raise(p.state.start, "Stage 2 decorators disallow object literal property decorators")
+	}
+	prop := p.startNode()
+	if p.match(ttEllipsis) {
+		if isPattern {
+			p.next()
+			// only identifiers are allowed here
+			prop.set("argument", p.parseIdentifier(false))
+			p.checkCommaAfterRest()
+			return p.finishNode(prop, "RestElement")
+		}
+		return p.parseSpread(ref, nil)
+	}
+
+	prop.set("method", false)
+	startPos, startLoc := -1, Position{}
+	if isPattern || ref != nil {
+		startPos, startLoc = p.state.start, p.state.startLoc
+	}
+	isGenerator := false
+	if !isPattern {
+		isGenerator = p.eat(ttStar)
+	}
+	containsEsc := p.state.containsEsc
+	p.parsePropertyName(prop)
+	isAsync := false
+	if !isPattern && !containsEsc && !isGenerator && p.isAsyncProp(prop) {
+		isAsync = true
+		isGenerator = p.eat(ttStar)
+		p.parsePropertyName(prop)
+	}
+	p.parseObjPropValue(prop, startPos, startLoc, isGenerator, isAsync, isPattern, ref, containsEsc)
+	return prop
+}
+
+func (p *parser) isGetterOrSetterMethod(prop *Node, isPattern bool) bool {
+	key := prop.node("key")
+	return !isPattern && !prop.flag("computed") && key.Type == "Identifier" &&
+		(key.str("name") == "get" || key.str("name") == "set") &&
+		(p.match(ttString) || p.match(ttNum) || p.match(ttBracketL) || p.match(ttName) || p.state.typ.keyword != "")
+}
+
+func (p *parser) checkGetterSetterParams(method *Node) {
+	params := method.list("params")
+	count := 1
+	if method.str("kind") == "get" {
+		count = 0
+	}
+	if len(params) != count {
+		if method.str("kind") == "get" {
+			p.raise(method.Start, "getter must not have any formal parameters")
+		} else {
+			p.raise(method.Start, "setter must have exactly one formal parameter")
+		}
+	}
+	if method.str("kind") == "set" && params[len(params)-1].Type == "RestElement" {
+		p.raise(method.Start, "setter function argument must not be a rest parameter")
+	}
+}
+
+func (p *parser) parseObjectMethod(prop *Node, isGenerator, isAsync, isPattern, containsEsc bool) *Node {
+	if isAsync || isGenerator || p.match(ttParenL) {
+		if isPattern {
+			p.unexpected(-1)
+		}
+		prop.set("kind", "method")
+		prop.set("method", true)
+		return p.parseMethod(prop, isGenerator, isAsync, false, false, "ObjectMethod", false)
+	}
+	if !containsEsc && p.isGetterOrSetterMethod(prop, isPattern) {
+		if isGenerator || isAsync {
+			p.unexpected(-1)
+		}
+		prop.set("kind", prop.node("key").str("name"))
+		p.parsePropertyName(prop)
+		p.parseMethod(prop, false, false, false, false, "ObjectMethod", false)
+		p.checkGetterSetterParams(prop)
+		return prop
+	}
+	return nil
+}
+
+func (p *parser) parseObjectProperty(prop *Node, startPos int, startLoc Position, isPattern bool, ref *refPos) *Node {
+	prop.set("shorthand", false)
+	if p.eat(ttColon) {
+		if isPattern {
+			prop.set("value", p.parseMaybeDefault(p.state.start, p.state.startLoc, nil))
+		} else {
+			prop.set("value", p.parseMaybeAssign(false, ref, nil, nil))
+		}
+		return p.finishNode(prop, "ObjectProperty")
+	}
+	key := prop.node("key")
+	if !prop.flag("computed") && key.Type == "Identifier" {
+		p.checkReservedWord(key.str("name"), key.Start, true, true)
+		if isPattern {
+			prop.set("value", p.parseMaybeDefault(startPos, startLoc, key.clone()))
+		} else if p.match(ttEq) && ref != nil {
+			if ref.start == 0 {
+				ref.start = p.state.start
+			}
+			prop.set("value", p.parseMaybeDefault(startPos, startLoc, key.clone()))
+		} else {
+			prop.set("value", key.clone())
+		}
+		prop.set("shorthand", true)
+		return p.finishNode(prop, "ObjectProperty")
+	}
+	return nil
+}
+
+func (p *parser) parseObjPropValue(prop *Node, startPos int, startLoc Position, isGenerator, isAsync, isPattern bool, ref *refPos, containsEsc bool) {
+	// variance is only allowed in classes
+	if v := prop.node("variance"); v != nil {
+		p.unexpected(v.Start)
+	}
+	prop.del("variance")
+	var typeParams *Node
+	// a method with type parameters
+	if p.isRelational("<") {
+		typeParams = p.flowParseTypeParameterDeclaration()
+		if !p.match(ttParenL) {
+			p.unexpected(-1)
+		}
+	}
+
+	n := p.parseObjectMethod(prop, isGenerator, isAsync, isPattern, containsEsc)
+	if n == nil {
+		n = p.parseObjectProperty(prop, startPos, startLoc, isPattern, ref)
+	}
+	if n == nil {
+		p.unexpected(-1)
+	}
+
+	if typeParams != nil {
+		if v := prop.node("value"); v != nil {
+			v.set("typeParameters", typeParams)
+		} else {
+			prop.set("typeParameters", typeParams)
+		}
+	}
+}
+
+// parsePropertyName parses a key of a property, with an optional Flow
+// variance.
+func (p *parser) parsePropertyName(prop *Node) *Node {
+	variance := p.flowParseVariance()
+	if p.eat(ttBracketL) {
+		prop.set("computed", true)
+		prop.set("key", p.parseMaybeAssign(false, nil, nil, nil))
+		p.expect(ttBracketR)
+	} else {
+		old := p.state.inPropertyName
+		p.state.inPropertyName = true
+		var key *Node
+		if p.match(ttNum) || p.match(ttString) {
+			key = p.parseExprAtom(nil)
+		} else {
+			key = p.parseMaybePrivateName()
+		}
+		prop.set("key", key)
+		if key.Type != "PrivateName" {
+			// private names are never computed
+			prop.set("computed", false)
+		}
+		p.state.inPropertyName = old
+	}
+	prop.set("variance", variance)
+	return prop.node("key")
+}
+
+func (p *parser) initFunction(n *Node, isAsync bool) {
+	n.set("id", nil)
+	n.set("generator", false)
+	n.set("async", isAsync)
+}
+
+// parseMethod parses parameters and a body of an object or class method.
+func (p *parser) parseMethod(n *Node, isGenerator, isAsync, isConstructor, allowDirectSuper bool, typ string, inClassScope bool) *Node {
+	oldYieldPos, oldAwaitPos := p.state.yieldPos, p.state.awaitPos
+	p.state.yieldPos = -1
+	p.state.awaitPos = -1
+
+	p.initFunction(n, isAsync)
+	n.set("generator", isGenerator)
+	flags := functionFlags(isAsync, isGenerator) | scopeSuper
+	if inClassScope {
+		flags |= scopeClass
+	}
+	if allowDirectSuper {
+		flags |= scopeDirectSuper
+	}
+	p.scope.enter(flags)
+	p.parseFunctionParams(n, isConstructor)
+	p.checkYieldAwaitInDefaultParams()
+	p.parseFunctionBodyAndFinish(n, typ, true)
+	p.scope.exit()
+
+	p.state.yieldPos = oldYieldPos
+	p.state.awaitPos = oldAwaitPos
+	return n
+}
+
+// parseArrowExpression parses a body of an arrow function with given params.
+func (p *parser) parseArrowExpression(n *Node, params []*Node, isAsync bool) *Node {
+	p.scope.enter(functionFlags(isAsync, false) | scopeArrow)
+	p.initFunction(n, isAsync)
+
+	oldMaybeInArrowParameters := p.state.maybeInArrowParameters
+	oldYieldPos, oldAwaitPos := p.state.yieldPos, p.state.awaitPos
+	p.state.maybeInArrowParameters = false
+	p.state.yieldPos = -1
+	p.state.awaitPos = -1
+
+	if params != nil {
+		p.setArrowFunctionParameters(n, params)
+	}
+	p.parseFunctionBody(n, true, false)
+
+	p.scope.exit()
+	p.state.maybeInArrowParameters = oldMaybeInArrowParameters
+	p.state.yieldPos = oldYieldPos
+	p.state.awaitPos = oldAwaitPos
+	return p.finishNode(n, "ArrowFunctionExpression")
+}
+
+func (p *parser) setArrowFunctionParameters(n *Node, params []*Node) {
+	if containsInt(p.state.noArrowParamsConversionAt, n.Start) {
+		n.set("params", params)
+	} else {
+		n.set("params", p.toAssignableList(params, true, "arrow function parameters"))
+	}
+}
+
+func isStrictBody(n *Node) bool {
+	body := n.node("body")
+	if body == nil || body.Type != "BlockStatement" {
+		return false
+	}
+	for _, d := range body.list("directives") {
+		if d.node("value").get("value") == "use strict" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseFunctionBodyAndFinish(n *Node, typ string, isMethod bool) {
+	if p.match(ttColon) {
+		typeNode := p.startNode()
+		t, predicate := p.flowParseTypeAndPredicateInitialiser()
+		n.set("predicate", predicate)
+		if t != nil {
+			typeNode.set("typeAnnotation", t)
+			n.set("returnType", p.finishNode(typeNode, "TypeAnnotation"))
+		} else {
+			n.set("returnType", nil)
+		}
+	}
+	p.parseFunctionBody(n, false, isMethod)
+	p.finishNode(n, typ)
+}
+
+func isSimpleParamList(params []*Node) bool {
+	for _, param := range params {
+		if param.Type != "Identifier" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseFunctionBody parses a body of a function, which is an expression for
+// arrow functions.
+func (p *parser) parseFunctionBody(n *Node, allowExpression, isMethod bool) {
+	isExpression := allowExpression && !p.match(ttBraceL)
+	oldStrict := p.state.strict
+	oldInParameters := p.state.inParameters
+	p.state.inParameters = false
+
+	if isExpression {
+		n.set("body", p.parseMaybeAssign(false, nil, nil, nil))
+		p.checkParams(n, false, allowExpression)
+	} else {
+		nonSimple := !isSimpleParamList(n.list("params"))
+		// labels are not visible in functions
+		oldLabels := p.state.labels
+		p.state.labels = nil
+		body := p.parseBlock(true, false)
+		n.set("body", body)
+		p.state.labels = oldLabels
+
+		useStrict := isStrictBody(n)
+		if useStrict && nonSimple {
+			pos := n.Start
+			if k := n.str("kind"); (k == "method" || k == "constructor") && n.node("key") != nil {
+				pos = n.node("key").End
+			}
+			p.raise(pos, "Illegal 'use strict' directive in function with non-simple parameter list")
+		}
+		if useStrict {
+			p.state.strict = true
+		}
+		p.checkParams(n, !oldStrict && !useStrict && !allowExpression && !isMethod && !nonSimple, allowExpression)
+	}
+	p.state.inParameters = oldInParameters
+
+	// the function name must not be eval or arguments in strict mode
+	if p.state.strict && n.node("id") != nil {
+		p.checkLVal(n.node("id"), bindOutside, nil, "function name")
+	}
+	p.state.strict = oldStrict
+}
+
+func (p *parser) checkParams(n *Node, allowDuplicates, isArrowFunction bool) {
+	if isArrowFunction && containsInt(p.state.noArrowParamsConversionAt, n.Start) {
+		return
+	}
+	p.checkParamsBase(n, allowDuplicates)
+}
+
+func (p *parser) checkParamsBase(n *Node, allowDuplicates bool) {
+	var names map[string]bool
+	if !allowDuplicates {
+		names = make(map[string]bool)
+	}
+	for _, param := range n.list("params") {
+		p.checkLVal(param, bindVar, names, "function parameter list")
+	}
+}
+
+// parseExprList parses a comma separated list of expressions. If allowEmpty
+// is set, empty elements are represented by nil.
+func (p *parser) parseExprList(close *tokenType, allowEmpty bool, ref *refPos) []*Node {
+	elts := []*Node{}
+	first := true
+	for !p.eat(close) {
+		if first {
+			first = false
+		} else {
+			p.expect(ttComma)
+			if p.eat(close) {
+				break
+			}
+		}
+		elts = append(elts, p.parseExprListItem(allowEmpty, ref, nil, nil))
+	}
+	return elts
+}
+
+func (p *parser) parseExprListItem(allowEmpty bool, ref, refNeedsArrowPos, refTrailingCommaPos *refPos) *Node {
+	if allowEmpty && p.match(ttComma) {
+		return nil
+	} else if p.match(ttEllipsis) {
+		startPos, startLoc := p.state.start, p.state.startLoc
+		elt := p.parseParenItem(p.parseSpread(ref, refNeedsArrowPos), startPos, startLoc)
+		if refTrailingCommaPos != nil && p.match(ttComma) {
+			refTrailingCommaPos.start = p.state.start
+		}
+		return elt
+	}
+	return p.parseMaybeAssign(false, ref, p.parseParenItem, refNeedsArrowPos)
+}
+
+// parseIdentifier parses an identifier. If liberal is set, keywords and
+// reserved words are allowed.
+func (p *parser) parseIdentifier(liberal bool) *Node {
+	n := p.startNode()
+	name := p.parseIdentifierName(n.Start, liberal)
+	return p.createIdentifier(n, name)
+}
+
+func (p *parser) createIdentifier(n *Node, name string) *Node {
+	n.set("name", name)
+	n.Loc.IdentifierName = name
+	return p.finishNode(n, "Identifier")
+}
+
+func (p *parser) parseIdentifierName(pos int, liberal bool) string {
+	var name string
+	if p.match(ttName) {
+		name, _ = p.state.value.(string)
+	} else if p.state.typ.keyword != "" {
+		name = p.state.typ.keyword
+		// class and function push a context that is never popped if the
+		// keyword is used as a name, unless it follows a dot
+		if (name == "class" || name == "function") &&
+			(p.state.lastTokEnd != p.state.lastTokStart+1 || p.charAt(p.state.lastTokStart) != '.') {
+			p.popContext()
+		}
+	} else {
+		p.unexpected(-1)
+	}
+	if !liberal {
+		p.checkReservedWord(name, p.state.start, p.state.typ.keyword != "", false)
+	}
+	p.next()
+	return name
+}
+
+var reservedWordsStrict = map[string]bool{
+	"implements": true, "interface": true, "let": true, "package": true, "private": true,
+	"protected": true, "public": true, "static": true, "yield": true,
+}
+
+func isReservedWord(word string, inModule bool) bool {
+	return (inModule && word == "await") || word == "enum"
+}
+
+func isStrictReservedWord(word string, inModule bool) bool {
+	return isReservedWord(word, inModule) || reservedWordsStrict[word]
+}
+
+func isStrictBindOnlyReservedWord(word string) bool {
+	return word == "eval" || word == "arguments"
+}
+
+func isStrictBindReservedWord(word string, inModule bool) bool {
+	return isStrictReservedWord(word, inModule) || isStrictBindOnlyReservedWord(word)
+}
+
+func isKeyword(word string) bool {
+	return keywords[word] != nil
+}
+
+func (p *parser) checkReservedWord(word string, startLoc int, checkKeywords, isBinding bool) {
+	if p.scope.inGenerator() && word == "yield" {
+		p.raise(startLoc, "Can not use 'yield' as identifier inside a generator")
+	}
+	if word == "await" {
+		if p.scope.inAsync() {
+			p.raise(startLoc, "Can not use 'await' as identifier inside an async function")
+		}
+		if p.state.awaitPos == -1 && p.state.maybeInArrowParameters {
+			p.state.awaitPos = p.state.start
+		}
+	}
+	if p.state.inClassProperty && word == "arguments" {
+		p.raise(startLoc, "'arguments' is not allowed in class field initializer")
+	}
+	if checkKeywords && isKeyword(word) {
+		p.raise(startLoc, "Unexpected keyword '"+word+"'")
+	}
+	reserved := isReservedWord
+	if p.state.strict {
+		if isBinding {
+			reserved = isStrictBindReservedWord
+		} else {
+			reserved = isStrictReservedWord
+		}
+	}
+	if reserved(word, p.inModule) {
+		if !p.scope.inAsync() && word == "await" {
+			p.raise(startLoc, "Can not use keyword 'await' outside an async function")
+		}
+		p.raise(startLoc, "Unexpected reserved word '"+word+"'")
+	}
+}
+
+func (p *parser) parseAwait() *Node {
+	n := p.startNode()
+	p.next()
+	if p.state.maybeInArrowParameters && p.state.awaitPos == -1 {
+		p.state.awaitPos = n.Start
+	}
+	if p.match(ttStar) {
+		p.raise(n.Start, "await* has been removed from the async functions proposal. Use Promise.all() instead.")
+	}
+	n.set("argument", p.parseMaybeUnary(nil))
+	return p.finishNode(n, "AwaitExpression")
+}
+
+func (p *parser) parseYield(noIn bool) *Node {
+	n := p.startNode()
+	if p.state.inParameters {
+		p.raise(n.Start, "yield is not allowed in generator parameters")
+	}
+	if p.state.maybeInArrowParameters && p.state.yieldPos == -1 {
+		p.state.yieldPos = n.Start
+	}
+	p.next()
+	if p.match(ttSemi) || p.canInsertSemicolon() || (!p.match(ttStar) && !p.state.typ.startsExpr) {
+		n.set("delegate", false)
+		n.set("argument", nil)
+	} else {
+		n.set("delegate", p.eat(ttStar))
+		n.set("argument", p.parseMaybeAssign(noIn, nil, nil, nil))
+	}
+	return p.finishNode(n, "YieldExpression")
+}
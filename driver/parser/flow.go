@@ -0,0 +1,1183 @@
+package parser
+
+import "strconv"
+
+// Flow type annotations. The functions follow the flow plugin of Babel.
+
+var reservedTypes = map[string]bool{
+	"any": true, "bool": true, "boolean": true, "empty": true, "extends": true,
+	"false": true, "interface": true, "mixed": true, "null": true, "number": true,
+	"static": true, "string": true, "true": true, "typeof": true, "void": true,
+	"_": true,
+}
+
+// exportSuggestions are alternatives for unsupported "declare export" forms.
+var exportSuggestions = map[string]string{
+	"const":     "declare export var",
+	"let":       "declare export var",
+	"type":      "export type",
+	"interface": "export interface",
+}
+
+// objectTypeOpts controls the syntax allowed in an object type.
+type objectTypeOpts struct {
+	allowStatic  bool
+	allowExact   bool
+	allowSpread  bool
+	allowProto   bool
+	allowInexact bool
+}
+
+// shouldParseTypes reports if type arguments of calls are allowed, which
+// requires the @flow pragma.
+func (p *parser) shouldParseTypes() bool {
+	return p.flowPragma == "flow"
+}
+
+func (p *parser) checkReservedType(word string, pos int) {
+	if reservedTypes[word] {
+		p.raise(pos, "Cannot overwrite reserved type "+word)
+	}
+}
+
+func (p *parser) checkNotUnderscore(word string) {
+	if word == "_" {
+		p.unexpectedMsg(-1, "`_` is only allowed as a type argument to call or new")
+	}
+}
+
+func (p *parser) flowParseRestrictedIdentifier(liberal bool) *Node {
+	v, _ := p.state.value.(string)
+	p.checkReservedType(v, p.state.start)
+	return p.parseIdentifier(liberal)
+}
+
+// flowParseTypeInitialiser parses a type after a given token.
+func (p *parser) flowParseTypeInitialiser(tok *tokenType) *Node {
+	oldInType := p.state.inType
+	p.state.inType = true
+	p.expect(tok)
+	typ := p.flowParseType()
+	p.state.inType = oldInType
+	return typ
+}
+
+func (p *parser) flowParsePredicate() *Node {
+	n := p.startNode()
+	moduloLoc, moduloPos := p.state.startLoc, p.state.start
+	p.expect(ttModulo)
+	checksLoc := p.state.startLoc
+	p.expectContextual("checks")
+	// % and checks must be adjacent
+	if moduloLoc.Line != checksLoc.Line || moduloLoc.Column != checksLoc.Column-1 {
+		p.raise(moduloPos, "Spaces between ´%´ and ´checks´ are not allowed here.")
+	}
+	if p.eat(ttParenL) {
+		n.set("value", p.parseExpression(false, nil))
+		p.expect(ttParenR)
+		return p.finishNode(n, "DeclaredPredicate")
+	}
+	return p.finishNode(n, "InferredPredicate")
+}
+
+// flowParseTypeAndPredicateInitialiser parses a return type of a function,
+// which may be followed by a predicate.
+func (p *parser) flowParseTypeAndPredicateInitialiser() (typ, predicate *Node) {
+	oldInType := p.state.inType
+	p.state.inType = true
+	p.expect(ttColon)
+	if p.match(ttModulo) {
+		p.state.inType = oldInType
+		return nil, p.flowParsePredicate()
+	}
+	typ = p.flowParseType()
+	p.state.inType = oldInType
+	if p.match(ttModulo) {
+		predicate = p.flowParsePredicate()
+	}
+	return typ, predicate
+}
+
+func (p *parser) flowParseDeclareClass(n *Node) *Node {
+	p.next()
+	p.flowParseInterfaceish(n, true)
+	return p.finishNode(n, "DeclareClass")
+}
+
+func (p *parser) flowParseDeclareFunction(n *Node) *Node {
+	p.next()
+	id := p.parseIdentifier(false)
+	n.set("id", id)
+	p.scope.declareName(id.str("name"), bindFlowDeclareFn, id.Start)
+
+	typ := p.startNode()
+	container := p.startNode()
+	if p.isRelational("<") {
+		typ.set("typeParameters", p.flowParseTypeParameterDeclaration())
+	} else {
+		typ.set("typeParameters", nil)
+	}
+	p.expect(ttParenL)
+	params, rest := p.flowParseFunctionTypeParams(nil)
+	typ.set("params", params)
+	typ.set("rest", rest)
+	p.expect(ttParenR)
+	ret, predicate := p.flowParseTypeAndPredicateInitialiser()
+	typ.set("returnType", ret)
+	n.set("predicate", predicate)
+
+	container.set("typeAnnotation", p.finishNode(typ, "FunctionTypeAnnotation"))
+	id.set("typeAnnotation", p.finishNode(container, "TypeAnnotation"))
+	p.resetEndLocation(id)
+	p.semicolon()
+	return p.finishNode(n, "DeclareFunction")
+}
+
+// flowParseDeclare parses a declaration after the declare keyword.
+func (p *parser) flowParseDeclare(n *Node, insideModule bool) *Node {
+	switch {
+	case p.match(ttClass):
+		return p.flowParseDeclareClass(n)
+	case p.match(ttFunction):
+		return p.flowParseDeclareFunction(n)
+	case p.match(ttVar):
+		return p.flowParseDeclareVariable(n)
+	case p.eatContextual("module"):
+		if p.match(ttDot) {
+			return p.flowParseDeclareModuleExports(n)
+		}
+		if insideModule {
+			p.unexpectedMsg(-1, "`declare module` cannot be used inside another `declare module`")
+		}
+		return p.flowParseDeclareModule(n)
+	case p.isContextual("type"):
+		return p.flowParseDeclareTypeAlias(n)
+	case p.isContextual("opaque"):
+		return p.flowParseDeclareOpaqueType(n)
+	case p.isContextual("interface"):
+		return p.flowParseDeclareInterface(n)
+	case p.match(ttExport):
+		return p.flowParseDeclareExportDeclaration(n, insideModule)
+	}
+	p.unexpected(-1)
+	return nil
+}
+
+func (p *parser) flowParseDeclareVariable(n *Node) *Node {
+	p.next()
+	id := p.flowParseTypeAnnotatableIdentifier(true)
+	n.set("id", id)
+	p.scope.declareName(id.str("name"), bindVar, id.Start)
+	p.semicolon()
+	return p.finishNode(n, "DeclareVariable")
+}
+
+func isEsModuleType(n *Node) bool {
+	if n.Type == "DeclareExportAllDeclaration" {
+		return true
+	}
+	if n.Type != "DeclareExportDeclaration" {
+		return false
+	}
+	decl := n.node("declaration")
+	return decl == nil || (decl.Type != "TypeAlias" && decl.Type != "InterfaceDeclaration")
+}
+
+func (p *parser) flowParseDeclareModule(n *Node) *Node {
+	p.scope.enter(scopeOther)
+	if p.match(ttString) {
+		n.set("id", p.parseExprAtom(nil))
+	} else {
+		n.set("id", p.parseIdentifier(false))
+	}
+
+	block := p.startNode()
+	n.set("body", block)
+	body := []*Node{}
+	block.set("body", body)
+	p.expect(ttBraceL)
+	for !p.match(ttBraceR) {
+		stmt := p.startNode()
+		if p.match(ttImport) {
+			p.next()
+			if !p.isContextual("type") && !p.match(ttTypeof) {
+				p.raise(p.state.lastTokStart, "Imports within a `declare module` body must always be `import type` or `import typeof`")
+			}
+			p.parseImport(stmt)
+		} else {
+			if !p.eatContextual("declare") {
+				p.unexpectedMsg(-1, "Only declares and type imports are allowed inside declare module")
+			}
+			stmt = p.flowParseDeclare(stmt, true)
+		}
+		body = append(body, stmt)
+		block.set("body", body)
+	}
+	p.scope.exit()
+	p.expect(ttBraceR)
+	p.finishNode(block, "BlockStatement")
+
+	const errorMessage = "Found both `declare module.exports` and `declare export` in the same module. " +
+		"Modules can only have 1 since they are either an ES module or they are a CommonJS module"
+	kind := ""
+	hasModuleExport := false
+	for _, stmt := range body {
+		if isEsModuleType(stmt) {
+			if kind == "CommonJS" {
+				p.unexpectedMsg(stmt.Start, errorMessage)
+			}
+			kind = "ES"
+		} else if stmt.Type == "DeclareModuleExports" {
+			if hasModuleExport {
+				p.unexpectedMsg(stmt.Start, "Duplicate `declare module.exports` statement")
+			}
+			if kind == "ES" {
+				p.unexpectedMsg(stmt.Start, errorMessage)
+			}
+			kind = "CommonJS"
+			hasModuleExport = true
+		}
+	}
+	if kind == "" {
+		kind = "CommonJS"
+	}
+	n.set("kind", kind)
+	return p.finishNode(n, "DeclareModule")
+}
+
+func (p *parser) flowParseDeclareExportDeclaration(n *Node, insideModule bool) *Node {
+	p.expect(ttExport)
+	if p.eat(ttDefault) {
+		if p.match(ttFunction) || p.match(ttClass) {
+			// declare export default class ...
+			// declare export default function ...
+			n.set("declaration", p.flowParseDeclare(p.startNode(), false))
+		} else {
+			// declare export default [type];
+			n.set("declaration", p.flowParseType())
+			p.semicolon()
+		}
+		n.set("default", true)
+		return p.finishNode(n, "DeclareExportDeclaration")
+	}
+
+	if p.match(ttConst) || p.isLet("") || ((p.isContextual("type") || p.isContextual("interface")) && !insideModule) {
+		label, _ := p.state.value.(string)
+		p.unexpectedMsg(p.state.start, "`declare export "+label+"` is not supported. Use `"+exportSuggestions[label]+"` instead")
+	}
+
+	if p.match(ttVar) || p.match(ttFunction) || p.match(ttClass) || p.isContextual("opaque") {
+		n.set("declaration", p.flowParseDeclare(p.startNode(), false))
+		n.set("default", false)
+		return p.finishNode(n, "DeclareExportDeclaration")
+	} else if p.match(ttStar) || p.match(ttBraceL) || p.isContextual("interface") || p.isContextual("type") || p.isContextual("opaque") {
+		n = p.parseExport(n)
+		if n.Type == "ExportNamedDeclaration" {
+			n.Type = "ExportDeclaration"
+			n.set("default", false)
+			n.del("exportKind")
+		}
+		n.Type = "Declare" + n.Type
+		return n
+	}
+	p.unexpected(-1)
+	return nil
+}
+
+func (p *parser) flowParseDeclareModuleExports(n *Node) *Node {
+	p.expect(ttDot)
+	p.expectContextual("exports")
+	n.set("typeAnnotation", p.flowParseTypeAnnotation())
+	p.semicolon()
+	return p.finishNode(n, "DeclareModuleExports")
+}
+
+func (p *parser) flowParseDeclareTypeAlias(n *Node) *Node {
+	p.next()
+	p.flowParseTypeAlias(n)
+	n.Type = "DeclareTypeAlias"
+	return n
+}
+
+func (p *parser) flowParseDeclareOpaqueType(n *Node) *Node {
+	p.next()
+	p.flowParseOpaqueType(n, true)
+	n.Type = "DeclareOpaqueType"
+	return n
+}
+
+func (p *parser) flowParseDeclareInterface(n *Node) *Node {
+	p.next()
+	p.flowParseInterfaceish(n, false)
+	return p.finishNode(n, "DeclareInterface")
+}
+
+// flowParseInterfaceish parses the common part of interfaces and declared
+// classes.
+func (p *parser) flowParseInterfaceish(n *Node, isClass bool) {
+	id := p.flowParseRestrictedIdentifier(!isClass)
+	n.set("id", id)
+	if isClass {
+		p.scope.declareName(id.str("name"), bindFunction, id.Start)
+	} else {
+		p.scope.declareName(id.str("name"), bindLexical, id.Start)
+	}
+	if p.isRelational("<") {
+		n.set("typeParameters", p.flowParseTypeParameterDeclaration())
+	} else {
+		n.set("typeParameters", nil)
+	}
+
+	extends, implements, mixins := []*Node{}, []*Node{}, []*Node{}
+	if p.eat(ttExtends) {
+		for {
+			extends = append(extends, p.flowParseInterfaceExtends())
+			if isClass || !p.eat(ttComma) {
+				break
+			}
+		}
+	}
+	if p.isContextual("mixins") {
+		p.next()
+		for {
+			mixins = append(mixins, p.flowParseInterfaceExtends())
+			if !p.eat(ttComma) {
+				break
+			}
+		}
+	}
+	if p.isContextual("implements") {
+		p.next()
+		for {
+			implements = append(implements, p.flowParseInterfaceExtends())
+			if !p.eat(ttComma) {
+				break
+			}
+		}
+	}
+	n.set("extends", extends)
+	n.set("implements", implements)
+	n.set("mixins", mixins)
+
+	n.set("body", p.flowParseObjectType(objectTypeOpts{
+		allowStatic: isClass,
+		allowProto:  isClass,
+	}))
+}
+
+func (p *parser) flowParseInterfaceExtends() *Node {
+	n := p.startNode()
+	n.set("id", p.flowParseQualifiedTypeIdentifier(-1, Position{}, nil))
+	if p.isRelational("<") {
+		n.set("typeParameters", p.flowParseTypeParameterInstantiation())
+	} else {
+		n.set("typeParameters", nil)
+	}
+	return p.finishNode(n, "InterfaceExtends")
+}
+
+func (p *parser) flowParseInterface(n *Node) *Node {
+	p.flowParseInterfaceish(n, false)
+	return p.finishNode(n, "InterfaceDeclaration")
+}
+
+func (p *parser) flowParseTypeAlias(n *Node) *Node {
+	id := p.flowParseRestrictedIdentifier(false)
+	n.set("id", id)
+	p.scope.declareName(id.str("name"), bindLexical, id.Start)
+	if p.isRelational("<") {
+		n.set("typeParameters", p.flowParseTypeParameterDeclaration())
+	} else {
+		n.set("typeParameters", nil)
+	}
+	n.set("right", p.flowParseTypeInitialiser(ttEq))
+	p.semicolon()
+	return p.finishNode(n, "TypeAlias")
+}
+
+func (p *parser) flowParseOpaqueType(n *Node, declare bool) *Node {
+	p.expectContextual("type")
+	id := p.flowParseRestrictedIdentifier(true)
+	n.set("id", id)
+	p.scope.declareName(id.str("name"), bindLexical, id.Start)
+	if p.isRelational("<") {
+		n.set("typeParameters", p.flowParseTypeParameterDeclaration())
+	} else {
+		n.set("typeParameters", nil)
+	}
+
+	// the supertype is optional
+	n.set("supertype", nil)
+	if p.match(ttColon) {
+		n.set("supertype", p.flowParseTypeInitialiser(ttColon))
+	}
+	n.set("impltype", nil)
+	if !declare {
+		n.set("impltype", p.flowParseTypeInitialiser(ttEq))
+	}
+	p.semicolon()
+	return p.finishNode(n, "OpaqueType")
+}
+
+// type parameters
+
+func (p *parser) flowParseTypeParameter(requireDefault bool) *Node {
+	nodeStart := p.state.start
+	n := p.startNode()
+	variance := p.flowParseVariance()
+	ident := p.flowParseTypeAnnotatableIdentifier(false)
+	n.set("name", ident.str("name"))
+	n.set("variance", variance)
+	if bound := ident.node("typeAnnotation"); bound != nil {
+		n.set("bound", bound)
+	}
+
+	if p.match(ttEq) {
+		p.eat(ttEq)
+		n.set("default", p.flowParseType())
+	} else if requireDefault {
+		p.unexpectedMsg(nodeStart, "Type parameter declaration needs a default, since a preceding type parameter declaration has a default.")
+	}
+	return p.finishNode(n, "TypeParameter")
+}
+
+func (p *parser) flowParseTypeParameterDeclaration() *Node {
+	oldInType := p.state.inType
+	n := p.startNode()
+	params := []*Node{}
+	n.set("params", params)
+	p.state.inType = true
+
+	if p.isRelational("<") || p.match(ttJSXTagStart) {
+		p.next()
+	} else {
+		p.unexpected(-1)
+	}
+	defaultRequired := false
+	for {
+		param := p.flowParseTypeParameter(defaultRequired)
+		params = append(params, param)
+		n.set("params", params)
+		if param.has("default") {
+			defaultRequired = true
+		}
+		if !p.isRelational(">") {
+			p.expect(ttComma)
+		}
+		if p.isRelational(">") {
+			break
+		}
+	}
+	p.expectRelational(">")
+	p.state.inType = oldInType
+	return p.finishNode(n, "TypeParameterDeclaration")
+}
+
+func (p *parser) flowParseTypeParameterInstantiation() *Node {
+	n := p.startNode()
+	oldInType := p.state.inType
+	params := []*Node{}
+	n.set("params", params)
+	p.state.inType = true
+
+	p.expectRelational("<")
+	oldNoAnonFunctionType := p.state.noAnonFunctionType
+	p.state.noAnonFunctionType = false
+	for !p.isRelational(">") {
+		params = append(params, p.flowParseType())
+		n.set("params", params)
+		if !p.isRelational(">") {
+			p.expect(ttComma)
+		}
+	}
+	p.state.noAnonFunctionType = oldNoAnonFunctionType
+	p.expectRelational(">")
+	p.state.inType = oldInType
+	return p.finishNode(n, "TypeParameterInstantiation")
+}
+
+// flowParseTypeParameterInstantiationCallOrNew parses type arguments of
+// calls, which may use "_" to infer an argument.
+func (p *parser) flowParseTypeParameterInstantiationCallOrNew() *Node {
+	n := p.startNode()
+	oldInType := p.state.inType
+	params := []*Node{}
+	n.set("params", params)
+	p.state.inType = true
+
+	p.expectRelational("<")
+	for !p.isRelational(">") {
+		params = append(params, p.flowParseTypeOrImplicitInstantiation())
+		n.set("params", params)
+		if !p.isRelational(">") {
+			p.expect(ttComma)
+		}
+	}
+	p.expectRelational(">")
+	p.state.inType = oldInType
+	return p.finishNode(n, "TypeParameterInstantiation")
+}
+
+func (p *parser) flowParseInterfaceType() *Node {
+	n := p.startNode()
+	p.expectContextual("interface")
+	extends := []*Node{}
+	if p.eat(ttExtends) {
+		for {
+			extends = append(extends, p.flowParseInterfaceExtends())
+			if !p.eat(ttComma) {
+				break
+			}
+		}
+	}
+	n.set("extends", extends)
+	n.set("body", p.flowParseObjectType(objectTypeOpts{}))
+	return p.finishNode(n, "InterfaceTypeAnnotation")
+}
+
+// object types
+
+func (p *parser) flowParseObjectPropertyKey() *Node {
+	if p.match(ttNum) || p.match(ttString) {
+		return p.parseExprAtom(nil)
+	}
+	return p.parseIdentifier(true)
+}
+
+func (p *parser) flowParseObjectTypeIndexer(n *Node, isStatic bool, variance *Node) *Node {
+	n.set("static", isStatic)
+	// the bracket has been consumed already
+	if p.lookahead().typ == ttColon {
+		n.set("id", p.flowParseObjectPropertyKey())
+		n.set("key", p.flowParseTypeInitialiser(ttColon))
+	} else {
+		n.set("id", nil)
+		n.set("key", p.flowParseType())
+	}
+	p.expect(ttBracketR)
+	n.set("value", p.flowParseTypeInitialiser(ttColon))
+	n.set("variance", variance)
+	return p.finishNode(n, "ObjectTypeIndexer")
+}
+
+func (p *parser) flowParseObjectTypeInternalSlot(n *Node, isStatic bool) *Node {
+	n.set("static", isStatic)
+	// both brackets have been consumed already
+	n.set("id", p.flowParseObjectPropertyKey())
+	p.expect(ttBracketR)
+	p.expect(ttBracketR)
+	if p.isRelational("<") || p.match(ttParenL) {
+		n.set("method", true)
+		n.set("optional", false)
+		n.set("value", p.flowParseObjectTypeMethodish(p.startNodeAtNode(n)))
+	} else {
+		n.set("method", false)
+		if p.eat(ttQuestion) {
+			n.set("optional", true)
+		}
+		n.set("value", p.flowParseTypeInitialiser(ttColon))
+	}
+	return p.finishNode(n, "ObjectTypeInternalSlot")
+}
+
+func (p *parser) flowParseObjectTypeMethodish(n *Node) *Node {
+	params := []*Node{}
+	n.set("params", params)
+	n.set("rest", nil)
+	n.set("typeParameters", nil)
+
+	if p.isRelational("<") {
+		n.set("typeParameters", p.flowParseTypeParameterDeclaration())
+	}
+	p.expect(ttParenL)
+	for !p.match(ttParenR) && !p.match(ttEllipsis) {
+		params = append(params, p.flowParseFunctionTypeParam())
+		n.set("params", params)
+		if !p.match(ttParenR) {
+			p.expect(ttComma)
+		}
+	}
+	if p.eat(ttEllipsis) {
+		n.set("rest", p.flowParseFunctionTypeParam())
+	}
+	p.expect(ttParenR)
+	n.set("returnType", p.flowParseTypeInitialiser(ttColon))
+	return p.finishNode(n, "FunctionTypeAnnotation")
+}
+
+func (p *parser) flowParseObjectTypeCallProperty(n *Node, isStatic bool) *Node {
+	value := p.startNode()
+	n.set("static", isStatic)
+	n.set("value", p.flowParseObjectTypeMethodish(value))
+	return p.finishNode(n, "ObjectTypeCallProperty")
+}
+
+func (p *parser) flowParseObjectType(o objectTypeOpts) *Node {
+	oldInType := p.state.inType
+	p.state.inType = true
+
+	obj := p.startNode()
+	var callProperties, properties, indexers, internalSlots []*Node
+	obj.set("callProperties", []*Node{})
+	obj.set("properties", []*Node{})
+	obj.set("indexers", []*Node{})
+	obj.set("internalSlots", []*Node{})
+
+	var endDelim *tokenType
+	exact, inexact := false, false
+	if o.allowExact && p.match(ttBraceBarL) {
+		p.expect(ttBraceBarL)
+		endDelim = ttBraceBarR
+		exact = true
+	} else {
+		p.expect(ttBraceL)
+		endDelim = ttBraceR
+	}
+	obj.set("exact", exact)
+
+	allowStatic := o.allowStatic
+	for !p.match(endDelim) {
+		isStatic := false
+		protoStart := -1
+		n := p.startNode()
+
+		if o.allowProto && p.isContextual("proto") {
+			if l := p.lookahead(); l.typ != ttColon && l.typ != ttQuestion {
+				p.next()
+				protoStart = p.state.start
+				allowStatic = false
+			}
+		}
+		if allowStatic && p.isContextual("static") {
+			// static is a valid property name
+			if l := p.lookahead(); l.typ != ttColon && l.typ != ttQuestion {
+				p.next()
+				isStatic = true
+			}
+		}
+
+		variance := p.flowParseVariance()
+		if p.eat(ttBracketL) {
+			if protoStart >= 0 {
+				p.unexpected(protoStart)
+			}
+			if p.eat(ttBracketL) {
+				if variance != nil {
+					p.unexpected(variance.Start)
+				}
+				internalSlots = append(internalSlots, p.flowParseObjectTypeInternalSlot(n, isStatic))
+				obj.set("internalSlots", internalSlots)
+			} else {
+				indexers = append(indexers, p.flowParseObjectTypeIndexer(n, isStatic, variance))
+				obj.set("indexers", indexers)
+			}
+		} else if p.match(ttParenL) || p.isRelational("<") {
+			if protoStart >= 0 {
+				p.unexpected(protoStart)
+			}
+			if variance != nil {
+				p.unexpected(variance.Start)
+			}
+			callProperties = append(callProperties, p.flowParseObjectTypeCallProperty(n, isStatic))
+			obj.set("callProperties", callProperties)
+		} else {
+			kind := "init"
+			if p.isContextual("get") || p.isContextual("set") {
+				if l := p.lookahead(); l.typ == ttName || l.typ == ttString || l.typ == ttNum {
+					kind, _ = p.state.value.(string)
+					p.next()
+				}
+			}
+			allowInexact := o.allowInexact || !exact
+			prop := p.flowParseObjectTypeProperty(n, isStatic, protoStart, variance, kind, o.allowSpread, allowInexact)
+			if prop == nil {
+				inexact = true
+			} else {
+				properties = append(properties, prop)
+				obj.set("properties", properties)
+			}
+		}
+		p.flowObjectTypeSemicolon()
+	}
+	p.expect(endDelim)
+
+	// the inexact flag is only set on object types that are not bodies of
+	// interfaces or declared classes, which do not allow spreads
+	if o.allowSpread {
+		obj.set("inexact", inexact)
+	}
+	out := p.finishNode(obj, "ObjectTypeAnnotation")
+	p.state.inType = oldInType
+	return out
+}
+
+// flowParseObjectTypeProperty parses a property of an object type. It returns
+// nil for the explicit inexact marker.
+func (p *parser) flowParseObjectTypeProperty(n *Node, isStatic bool, protoStart int, variance *Node, kind string, allowSpread, allowInexact bool) *Node {
+	if p.eat(ttEllipsis) {
+		if p.match(ttComma) || p.match(ttSemi) || p.match(ttBraceR) || p.match(ttBraceBarR) {
+			if !allowSpread {
+				p.raise(p.state.lastTokStart, "Explicit inexact syntax cannot appear in class or interface definitions")
+			} else if !allowInexact {
+				p.raise(p.state.lastTokStart, "Explicit inexact syntax cannot appear inside an explicit exact object type")
+			}
+			if variance != nil {
+				p.raise(variance.Start, "Explicit inexact syntax cannot have variance")
+			}
+			return nil
+		}
+		if !allowSpread {
+			p.raise(p.state.lastTokStart, "Spread operator cannot appear in class or interface definitions")
+		}
+		if protoStart >= 0 {
+			p.unexpected(protoStart)
+		}
+		if variance != nil {
+			p.raise(variance.Start, "Spread properties cannot have variance")
+		}
+		n.set("argument", p.flowParseType())
+		return p.finishNode(n, "ObjectTypeSpreadProperty")
+	}
+
+	n.set("key", p.flowParseObjectPropertyKey())
+	n.set("static", isStatic)
+	n.set("proto", protoStart >= 0)
+	n.set("kind", kind)
+
+	optional := false
+	if p.isRelational("<") || p.match(ttParenL) {
+		// a method
+		n.set("method", true)
+		if protoStart >= 0 {
+			p.unexpected(protoStart)
+		}
+		if variance != nil {
+			p.unexpected(variance.Start)
+		}
+		n.set("value", p.flowParseObjectTypeMethodish(p.startNodeAtNode(n)))
+		if kind == "get" || kind == "set" {
+			p.flowCheckGetterSetterParams(n)
+		}
+	} else {
+		if kind != "init" {
+			p.unexpected(-1)
+		}
+		n.set("method", false)
+		if p.eat(ttQuestion) {
+			optional = true
+		}
+		n.set("value", p.flowParseTypeInitialiser(ttColon))
+		n.set("variance", variance)
+	}
+	n.set("optional", optional)
+	return p.finishNode(n, "ObjectTypeProperty")
+}
+
+func (p *parser) flowCheckGetterSetterParams(prop *Node) {
+	count := 1
+	if prop.str("kind") == "get" {
+		count = 0
+	}
+	value := prop.node("value")
+	length := len(value.list("params"))
+	if value.node("rest") != nil {
+		length++
+	}
+	if length != count {
+		if prop.str("kind") == "get" {
+			p.raise(prop.Start, "getter must not have any formal parameters")
+		} else {
+			p.raise(prop.Start, "setter must have exactly one formal parameter")
+		}
+	}
+	if prop.str("kind") == "set" && value.node("rest") != nil {
+		p.raise(prop.Start, "setter function argument must not be a rest parameter")
+	}
+}
+
+func (p *parser) flowObjectTypeSemicolon() {
+	if !p.eat(ttSemi) && !p.eat(ttComma) && !p.match(ttBraceR) && !p.match(ttBraceBarR) {
+		p.unexpected(-1)
+	}
+}
+
+// flowParseQualifiedTypeIdentifier parses a dotted type name. A negative
+// start position means the current token.
+func (p *parser) flowParseQualifiedTypeIdentifier(startPos int, startLoc Position, id *Node) *Node {
+	if startPos < 0 {
+		startPos, startLoc = p.state.start, p.state.startLoc
+	}
+	n := id
+	if n == nil {
+		n = p.parseIdentifier(false)
+	}
+	for p.eat(ttDot) {
+		q := p.startNodeAt(startPos, startLoc)
+		q.set("qualification", n)
+		q.set("id", p.parseIdentifier(false))
+		n = p.finishNode(q, "QualifiedTypeIdentifier")
+	}
+	return n
+}
+
+func (p *parser) flowParseGenericType(startPos int, startLoc Position, id *Node) *Node {
+	n := p.startNodeAt(startPos, startLoc)
+	n.set("typeParameters", nil)
+	n.set("id", p.flowParseQualifiedTypeIdentifier(startPos, startLoc, id))
+	if p.isRelational("<") {
+		n.set("typeParameters", p.flowParseTypeParameterInstantiation())
+	}
+	return p.finishNode(n, "GenericTypeAnnotation")
+}
+
+func (p *parser) flowParseTypeofType() *Node {
+	n := p.startNode()
+	p.expect(ttTypeof)
+	n.set("argument", p.flowParsePrimaryType())
+	return p.finishNode(n, "TypeofTypeAnnotation")
+}
+
+func (p *parser) flowParseTupleType() *Node {
+	n := p.startNode()
+	types := []*Node{}
+	n.set("types", types)
+	p.expect(ttBracketL)
+	for p.state.pos < len(p.input) && !p.match(ttBracketR) {
+		types = append(types, p.flowParseType())
+		n.set("types", types)
+		if p.match(ttBracketR) {
+			break
+		}
+		p.expect(ttComma)
+	}
+	p.expect(ttBracketR)
+	return p.finishNode(n, "TupleTypeAnnotation")
+}
+
+func (p *parser) flowParseFunctionTypeParam() *Node {
+	var name, typ *Node
+	optional := false
+	n := p.startNode()
+	if l := p.lookahead(); l.typ == ttColon || l.typ == ttQuestion {
+		name = p.parseIdentifier(false)
+		if p.eat(ttQuestion) {
+			optional = true
+		}
+		typ = p.flowParseTypeInitialiser(ttColon)
+	} else {
+		typ = p.flowParseType()
+	}
+	n.set("name", name)
+	n.set("optional", optional)
+	n.set("typeAnnotation", typ)
+	return p.finishNode(n, "FunctionTypeParam")
+}
+
+func (p *parser) reinterpretTypeAsFunctionTypeParam(typ *Node) *Node {
+	n := p.startNodeAtNode(typ)
+	n.set("name", nil)
+	n.set("optional", false)
+	n.set("typeAnnotation", typ)
+	return p.finishNode(n, "FunctionTypeParam")
+}
+
+func (p *parser) flowParseFunctionTypeParams(params []*Node) ([]*Node, *Node) {
+	if params == nil {
+		params = []*Node{}
+	}
+	var rest *Node
+	for !p.match(ttParenR) && !p.match(ttEllipsis) {
+		params = append(params, p.flowParseFunctionTypeParam())
+		if !p.match(ttParenR) {
+			p.expect(ttComma)
+		}
+	}
+	if p.eat(ttEllipsis) {
+		rest = p.flowParseFunctionTypeParam()
+	}
+	return params, rest
+}
+
+func (p *parser) flowIdentToTypeAnnotation(startPos int, startLoc Position, n, id *Node) *Node {
+	switch id.str("name") {
+	case "any":
+		return p.finishNode(n, "AnyTypeAnnotation")
+	case "bool", "boolean":
+		return p.finishNode(n, "BooleanTypeAnnotation")
+	case "mixed":
+		return p.finishNode(n, "MixedTypeAnnotation")
+	case "empty":
+		return p.finishNode(n, "EmptyTypeAnnotation")
+	case "number":
+		return p.finishNode(n, "NumberTypeAnnotation")
+	case "string":
+		return p.finishNode(n, "StringTypeAnnotation")
+	}
+	p.checkNotUnderscore(id.str("name"))
+	return p.flowParseGenericType(startPos, startLoc, id)
+}
+
+// flowParsePrimaryType parses a type that is not a union, an intersection,
+// an array or a nullable type.
+func (p *parser) flowParsePrimaryType() *Node {
+	p.enter()
+	defer p.leave()
+
+	startPos, startLoc := p.state.start, p.state.startLoc
+	n := p.startNode()
+	oldNoAnonFunctionType := p.state.noAnonFunctionType
+
+	switch p.state.typ {
+	case ttName:
+		if p.isContextual("interface") {
+			return p.flowParseInterfaceType()
+		}
+		return p.flowIdentToTypeAnnotation(startPos, startLoc, n, p.parseIdentifier(false))
+	case ttBraceL:
+		return p.flowParseObjectType(objectTypeOpts{allowSpread: true, allowInexact: true})
+	case ttBraceBarL:
+		return p.flowParseObjectType(objectTypeOpts{allowExact: true, allowSpread: true})
+	case ttBracketL:
+		return p.flowParseTupleType()
+	case ttRelational:
+		if p.state.value == "<" {
+			n.set("typeParameters", p.flowParseTypeParameterDeclaration())
+			p.expect(ttParenL)
+			params, rest := p.flowParseFunctionTypeParams(nil)
+			n.set("params", params)
+			n.set("rest", rest)
+			p.expect(ttParenR)
+			p.expect(ttArrow)
+			n.set("returnType", p.flowParseType())
+			return p.finishNode(n, "FunctionTypeAnnotation")
+		}
+	case ttParenL:
+		p.next()
+		// check if this is a grouped type
+		isGroupedType := false
+		if !p.match(ttParenR) && !p.match(ttEllipsis) {
+			if p.match(ttName) {
+				t := p.lookahead().typ
+				isGroupedType = t != ttQuestion && t != ttColon
+			} else {
+				isGroupedType = true
+			}
+		}
+
+		var typ *Node
+		if isGroupedType {
+			p.state.noAnonFunctionType = false
+			typ = p.flowParseType()
+			p.state.noAnonFunctionType = oldNoAnonFunctionType
+
+			// a comma or "=>" after the parenthesis means a function type
+			if p.state.noAnonFunctionType || !(p.match(ttComma) || (p.match(ttParenR) && p.lookahead().typ == ttArrow)) {
+				p.expect(ttParenR)
+				return typ
+			}
+			p.eat(ttComma)
+		}
+
+		var params []*Node
+		if typ != nil {
+			params = []*Node{p.reinterpretTypeAsFunctionTypeParam(typ)}
+		}
+		params, rest := p.flowParseFunctionTypeParams(params)
+		n.set("params", params)
+		n.set("rest", rest)
+		p.expect(ttParenR)
+		p.expect(ttArrow)
+		n.set("returnType", p.flowParseType())
+		n.set("typeParameters", nil)
+		return p.finishNode(n, "FunctionTypeAnnotation")
+	case ttString:
+		return p.parseLiteral(p.state.value, "StringLiteralTypeAnnotation")
+	case ttTrue, ttFalse:
+		n.set("value", p.match(ttTrue))
+		p.next()
+		return p.finishNode(n, "BooleanLiteralTypeAnnotation")
+	case ttPlusMin:
+		if p.state.value == "-" {
+			p.next()
+			if p.match(ttNum) || p.match(ttBigint) {
+				typ := "NumberLiteralTypeAnnotation"
+				if p.match(ttBigint) {
+					typ = "BigIntLiteralTypeAnnotation"
+				}
+				return p.parseLiteralAt(negate(p.state.value), typ, n.Start, n.Loc.Start)
+			}
+			p.unexpectedMsg(-1, `Unexpected token, expected "number" or "bigint"`)
+		}
+		p.unexpected(-1)
+	case ttNum:
+		return p.parseLiteral(p.state.value, "NumberLiteralTypeAnnotation")
+	case ttBigint:
+		return p.parseLiteral(p.state.value, "BigIntLiteralTypeAnnotation")
+	case ttVoid:
+		p.next()
+		return p.finishNode(n, "VoidTypeAnnotation")
+	case ttNull:
+		p.next()
+		return p.finishNode(n, "NullLiteralTypeAnnotation")
+	case ttThis:
+		p.next()
+		return p.finishNode(n, "ThisTypeAnnotation")
+	case ttStar:
+		p.next()
+		return p.finishNode(n, "ExistsTypeAnnotation")
+	default:
+		if p.state.typ.keyword == "typeof" {
+			return p.flowParseTypeofType()
+		} else if p.state.typ.keyword != "" {
+			label := p.state.typ.label
+			p.next()
+			return p.createIdentifier(n, label)
+		}
+	}
+	p.unexpected(-1)
+	return nil
+}
+
+// negate returns a negated value of a numeric token.
+func negate(v interface{}) interface{} {
+	switch v := v.(type) {
+	case float64:
+		return -v
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return -f
+	}
+	return v
+}
+
+func (p *parser) flowParsePostfixType() *Node {
+	startPos, startLoc := p.state.start, p.state.startLoc
+	typ := p.flowParsePrimaryType()
+	for p.match(ttBracketL) && !p.canInsertSemicolon() {
+		n := p.startNodeAt(startPos, startLoc)
+		n.set("elementType", typ)
+		p.expect(ttBracketL)
+		p.expect(ttBracketR)
+		typ = p.finishNode(n, "ArrayTypeAnnotation")
+	}
+	return typ
+}
+
+func (p *parser) flowParsePrefixType() *Node {
+	n := p.startNode()
+	if p.eat(ttQuestion) {
+		p.enter()
+		n.set("typeAnnotation", p.flowParsePrefixType())
+		p.leave()
+		return p.finishNode(n, "NullableTypeAnnotation")
+	}
+	return p.flowParsePostfixType()
+}
+
+func (p *parser) flowParseAnonFunctionWithoutParens() *Node {
+	param := p.flowParsePrefixType()
+	if !p.state.noAnonFunctionType && p.eat(ttArrow) {
+		n := p.startNodeAtNode(param)
+		n.set("params", []*Node{p.reinterpretTypeAsFunctionTypeParam(param)})
+		n.set("rest", nil)
+		n.set("returnType", p.flowParseType())
+		n.set("typeParameters", nil)
+		return p.finishNode(n, "FunctionTypeAnnotation")
+	}
+	return param
+}
+
+func (p *parser) flowParseIntersectionType() *Node {
+	n := p.startNode()
+	p.eat(ttBitwiseAND)
+	typ := p.flowParseAnonFunctionWithoutParens()
+	types := []*Node{typ}
+	for p.eat(ttBitwiseAND) {
+		types = append(types, p.flowParseAnonFunctionWithoutParens())
+	}
+	if len(types) == 1 {
+		return typ
+	}
+	n.set("types", types)
+	return p.finishNode(n, "IntersectionTypeAnnotation")
+}
+
+func (p *parser) flowParseUnionType() *Node {
+	n := p.startNode()
+	p.eat(ttBitwiseOR)
+	typ := p.flowParseIntersectionType()
+	types := []*Node{typ}
+	for p.eat(ttBitwiseOR) {
+		types = append(types, p.flowParseIntersectionType())
+	}
+	if len(types) == 1 {
+		return typ
+	}
+	n.set("types", types)
+	return p.finishNode(n, "UnionTypeAnnotation")
+}
+
+func (p *parser) flowParseType() *Node {
+	oldInType := p.state.inType
+	p.state.inType = true
+	typ := p.flowParseUnionType()
+	p.state.inType = oldInType
+	// a brace after a generic function type is a statement, except in
+	// arrow functions
+	p.state.exprAllowed = p.state.exprAllowed || p.state.noAnonFunctionType
+	return typ
+}
+
+func (p *parser) flowParseTypeOrImplicitInstantiation() *Node {
+	if p.match(ttName) && p.state.value == "_" {
+		startPos, startLoc := p.state.start, p.state.startLoc
+		id := p.parseIdentifier(false)
+		return p.flowParseGenericType(startPos, startLoc, id)
+	}
+	return p.flowParseType()
+}
+
+func (p *parser) flowParseTypeAnnotation() *Node {
+	n := p.startNode()
+	n.set("typeAnnotation", p.flowParseTypeInitialiser(ttColon))
+	return p.finishNode(n, "TypeAnnotation")
+}
+
+func (p *parser) flowParseTypeAnnotatableIdentifier(allowPrimitiveOverride bool) *Node {
+	var ident *Node
+	if allowPrimitiveOverride {
+		ident = p.parseIdentifier(false)
+	} else {
+		ident = p.flowParseRestrictedIdentifier(false)
+	}
+	if p.match(ttColon) {
+		ident.set("typeAnnotation", p.flowParseTypeAnnotation())
+		p.resetEndLocation(ident)
+	}
+	return ident
+}
+
+func (p *parser) flowParseVariance() *Node {
+	if !p.match(ttPlusMin) {
+		return nil
+	}
+	variance := p.startNode()
+	if p.state.value == "+" {
+		variance.set("kind", "plus")
+	} else {
+		variance.set("kind", "minus")
+	}
+	p.next()
+	return p.finishNode(variance, "Variance")
+}
+
+// parseAsyncArrowWithTypeParameters parses an async arrow function with type
+// parameters, like async <T>(x: T) => x. It returns nil if there is no arrow.
+func (p *parser) parseAsyncArrowWithTypeParameters(startPos int, startLoc Position) *Node {
+	n := p.startNodeAt(startPos, startLoc)
+	p.parseFunctionParams(n, false)
+	if p.parseArrow(n) == nil {
+		return nil
+	}
+	return p.parseArrowExpression(n, nil, true)
+}
@@ -0,0 +1,425 @@
+package parser
+
+import (
+	"html"
+	"strconv"
+)
+
+// JSX syntax. The functions follow the jsx plugin of Babel.
+
+// xhtmlEntities are the named character references recognized in JSX, which
+// are the entities of XHTML 1.0.
+var xhtmlEntities = map[string]string{}
+
+func init() {
+	names := []string{
+		"quot", "amp", "apos", "lt", "gt", "nbsp", "iexcl", "cent", "pound",
+		"curren", "yen", "brvbar", "sect", "uml", "copy", "ordf", "laquo", "not",
+		"shy", "reg", "macr", "deg", "plusmn", "sup2", "sup3", "acute", "micro",
+		"para", "middot", "cedil", "sup1", "ordm", "raquo", "frac14", "frac12",
+		"frac34", "iquest", "Agrave", "Aacute", "Acirc", "Atilde", "Auml", "Aring",
+		"AElig", "Ccedil", "Egrave", "Eacute", "Ecirc", "Euml", "Igrave", "Iacute",
+		"Icirc", "Iuml", "ETH", "Ntilde", "Ograve", "Oacute", "Ocirc", "Otilde",
+		"Ouml", "times", "Oslash", "Ugrave", "Uacute", "Ucirc", "Uuml", "Yacute",
+		"THORN", "szlig", "agrave", "aacute", "acirc", "atilde", "auml", "aring",
+		"aelig", "ccedil", "egrave", "eacute", "ecirc", "euml", "igrave", "iacute",
+		"icirc", "iuml", "eth", "ntilde", "ograve", "oacute", "ocirc", "otilde",
+		"ouml", "divide", "oslash", "ugrave", "uacute", "ucirc", "uuml", "yacute",
+		"thorn", "yuml", "OElig", "oelig", "Scaron", "scaron", "Yuml", "fnof",
+		"circ", "tilde", "Alpha", "Beta", "Gamma", "Delta", "Epsilon", "Zeta",
+		"Eta", "Theta", "Iota", "Kappa", "Lambda", "Mu", "Nu", "Xi", "Omicron",
+		"Pi", "Rho", "Sigma", "Tau", "Upsilon", "Phi", "Chi", "Psi", "Omega",
+		"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "eta", "theta",
+		"iota", "kappa", "lambda", "mu", "nu", "xi", "omicron", "pi", "rho",
+		"sigmaf", "sigma", "tau", "upsilon", "phi", "chi", "psi", "omega",
+		"thetasym", "upsih", "piv", "ensp", "emsp", "thinsp", "zwnj", "zwj", "lrm",
+		"rlm", "ndash", "mdash", "lsquo", "rsquo", "sbquo", "ldquo", "rdquo",
+		"bdquo", "dagger", "Dagger", "bull", "hellip", "permil", "prime", "Prime",
+		"lsaquo", "rsaquo", "oline", "frasl", "euro", "image", "weierp", "real",
+		"trade", "alefsym", "larr", "uarr", "rarr", "darr", "harr", "crarr",
+		"lArr", "uArr", "rArr", "dArr", "hArr", "forall", "part", "exist", "empty",
+		"nabla", "isin", "notin", "ni", "prod", "sum", "minus", "lowast", "radic",
+		"prop", "infin", "ang", "and", "or", "cap", "cup", "int", "there4", "sim",
+		"cong", "asymp", "ne", "equiv", "le", "ge", "sub", "sup", "nsub", "sube",
+		"supe", "oplus", "otimes", "perp", "sdot", "lceil", "rceil", "lfloor",
+		"rfloor", "loz", "spades", "clubs", "hearts", "diams",
+	}
+	for _, name := range names {
+		xhtmlEntities[name] = html.UnescapeString("&" + name + ";")
+	}
+	// HTML5 maps the angle brackets to different characters
+	xhtmlEntities["lang"] = "〈"
+	xhtmlEntities["rang"] = "〉"
+}
+
+func isHexString(s string) bool {
+	for _, c := range s {
+		if !isDigit(c) && !(c >= 'a' && c <= 'f') && !(c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isDecimalString(s string) bool {
+	for _, c := range s {
+		if !isDigit(c) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// jsxReadToken reads a text of an element.
+func (p *parser) jsxReadToken() {
+	var out []uint16
+	chunkStart := p.state.pos
+	for {
+		if p.state.pos >= len(p.input) {
+			p.raise(p.state.start, "Unterminated JSX contents")
+		}
+		ch := rune(p.input[p.state.pos])
+		switch {
+		case ch == '<' || ch == '{':
+			if p.state.pos == p.state.start {
+				if ch == '<' && p.state.exprAllowed {
+					p.state.pos++
+					p.finishToken(ttJSXTagStart, nil)
+					return
+				}
+				p.readTokenFromCode(ch)
+				return
+			}
+			out = append(out, p.input[chunkStart:p.state.pos]...)
+			p.finishToken(ttJSXText, decode(out))
+			return
+		case ch == '&':
+			out = append(out, p.input[chunkStart:p.state.pos]...)
+			out = append(out, p.jsxReadEntity()...)
+			chunkStart = p.state.pos
+		case isNewLine(ch):
+			out = append(out, p.input[chunkStart:p.state.pos]...)
+			out = append(out, p.jsxReadNewLine(true)...)
+			chunkStart = p.state.pos
+		default:
+			p.state.pos++
+		}
+	}
+}
+
+func (p *parser) jsxReadNewLine(normalizeCRLF bool) []uint16 {
+	ch := p.input[p.state.pos]
+	var out []uint16
+	p.state.pos++
+	if ch == '\r' && p.charAt(p.state.pos) == '\n' {
+		p.state.pos++
+		if normalizeCRLF {
+			out = []uint16{'\n'}
+		} else {
+			out = []uint16{'\r', '\n'}
+		}
+	} else {
+		out = []uint16{ch}
+	}
+	p.state.curLine++
+	p.state.lineStart = p.state.pos
+	return out
+}
+
+func (p *parser) jsxReadString(quote rune) {
+	var out []uint16
+	p.state.pos++
+	chunkStart := p.state.pos
+	for {
+		if p.state.pos >= len(p.input) {
+			p.raise(p.state.start, "Unterminated string constant")
+		}
+		ch := rune(p.input[p.state.pos])
+		if ch == quote {
+			break
+		}
+		if ch == '&' {
+			out = append(out, p.input[chunkStart:p.state.pos]...)
+			out = append(out, p.jsxReadEntity()...)
+			chunkStart = p.state.pos
+		} else if isNewLine(ch) {
+			out = append(out, p.input[chunkStart:p.state.pos]...)
+			out = append(out, p.jsxReadNewLine(false)...)
+			chunkStart = p.state.pos
+		} else {
+			p.state.pos++
+		}
+	}
+	out = append(out, p.input[chunkStart:p.state.pos]...)
+	p.state.pos++
+	p.finishToken(ttString, decode(out))
+}
+
+// jsxReadEntity reads a character reference. An unknown reference is read
+// as a single ampersand.
+func (p *parser) jsxReadEntity() []uint16 {
+	var str []uint16
+	count := 0
+	entity := ""
+	p.state.pos++
+	startPos := p.state.pos
+	for p.state.pos < len(p.input) && count < 10 {
+		count++
+		ch := p.input[p.state.pos]
+		p.state.pos++
+		if ch == ';' {
+			s := decode(str)
+			if len(s) > 0 && s[0] == '#' {
+				var code uint64
+				var err error = strconv.ErrSyntax
+				if len(s) > 1 && s[1] == 'x' {
+					if isHexString(s[2:]) {
+						code, err = strconv.ParseUint(s[2:], 16, 32)
+					}
+				} else if isDecimalString(s[1:]) {
+					code, err = strconv.ParseUint(s[1:], 10, 32)
+				}
+				if err == nil && code <= 0x10FFFF {
+					entity = string(rune(code))
+				}
+			} else {
+				entity = xhtmlEntities[s]
+			}
+			break
+		}
+		str = append(str, ch)
+	}
+	if entity == "" {
+		p.state.pos = startPos
+		return []uint16{'&'}
+	}
+	return appendRune(nil, []rune(entity)[0])
+}
+
+// jsxReadWord reads a name of an element or an attribute, which may contain
+// dashes.
+func (p *parser) jsxReadWord() {
+	start := p.state.pos
+	for {
+		p.state.pos++
+		ch := p.charAt(p.state.pos)
+		if ch < 0 || !(isIdentifierChar(ch) || ch == '-') {
+			break
+		}
+	}
+	p.finishToken(ttJSXName, decode(p.input[start:p.state.pos]))
+}
+
+func isFragment(n *Node) bool {
+	return n != nil && (n.Type == "JSXOpeningFragment" || n.Type == "JSXClosingFragment")
+}
+
+// qualifiedJSXName returns a name of an element as written in the source.
+func qualifiedJSXName(n *Node) string {
+	switch n.Type {
+	case "JSXIdentifier":
+		return n.str("name")
+	case "JSXNamespacedName":
+		return n.node("namespace").str("name") + ":" + n.node("name").str("name")
+	case "JSXMemberExpression":
+		return qualifiedJSXName(n.node("object")) + "." + qualifiedJSXName(n.node("property"))
+	}
+	panic("unexpected JSX name type: " + n.Type)
+}
+
+func (p *parser) jsxParseIdentifier() *Node {
+	n := p.startNode()
+	if p.match(ttJSXName) {
+		n.set("name", p.state.value)
+	} else if p.state.typ.keyword != "" {
+		n.set("name", p.state.typ.keyword)
+	} else {
+		p.unexpected(-1)
+	}
+	p.next()
+	return p.finishNode(n, "JSXIdentifier")
+}
+
+func (p *parser) jsxParseNamespacedName() *Node {
+	startPos, startLoc := p.state.start, p.state.startLoc
+	name := p.jsxParseIdentifier()
+	if !p.eat(ttColon) {
+		return name
+	}
+	n := p.startNodeAt(startPos, startLoc)
+	n.set("namespace", name)
+	n.set("name", p.jsxParseIdentifier())
+	return p.finishNode(n, "JSXNamespacedName")
+}
+
+func (p *parser) jsxParseElementName() *Node {
+	startPos, startLoc := p.state.start, p.state.startLoc
+	n := p.jsxParseNamespacedName()
+	for p.eat(ttDot) {
+		m := p.startNodeAt(startPos, startLoc)
+		m.set("object", n)
+		m.set("property", p.jsxParseIdentifier())
+		n = p.finishNode(m, "JSXMemberExpression")
+	}
+	return n
+}
+
+func (p *parser) jsxParseAttributeValue() *Node {
+	switch p.state.typ {
+	case ttBraceL:
+		n := p.startNode()
+		p.next()
+		n = p.jsxParseExpressionContainer(n)
+		if n.node("expression").Type == "JSXEmptyExpression" {
+			p.raise(n.Start, "JSX attributes must only be assigned a non-empty expression")
+		}
+		return n
+	case ttJSXTagStart, ttString:
+		return p.parseExprAtom(nil)
+	}
+	p.raise(p.state.start, "JSX value should be either an expression or a quoted JSX text")
+	return nil
+}
+
+// jsxParseEmptyExpression creates a node for the space between the braces
+// of an empty expression container.
+func (p *parser) jsxParseEmptyExpression() *Node {
+	n := p.startNodeAt(p.state.lastTokEnd, p.state.lastTokEndLoc)
+	return p.finishNodeAt(n, "JSXEmptyExpression", p.state.start, p.state.startLoc)
+}
+
+func (p *parser) jsxParseSpreadChild(n *Node) *Node {
+	p.next()
+	n.set("expression", p.parseExpression(false, nil))
+	p.expect(ttBraceR)
+	return p.finishNode(n, "JSXSpreadChild")
+}
+
+func (p *parser) jsxParseExpressionContainer(n *Node) *Node {
+	if p.match(ttBraceR) {
+		n.set("expression", p.jsxParseEmptyExpression())
+	} else {
+		n.set("expression", p.parseExpression(false, nil))
+	}
+	p.expect(ttBraceR)
+	return p.finishNode(n, "JSXExpressionContainer")
+}
+
+func (p *parser) jsxParseAttribute() *Node {
+	n := p.startNode()
+	if p.eat(ttBraceL) {
+		p.expect(ttEllipsis)
+		n.set("argument", p.parseMaybeAssign(false, nil, nil, nil))
+		p.expect(ttBraceR)
+		return p.finishNode(n, "JSXSpreadAttribute")
+	}
+	n.set("name", p.jsxParseNamespacedName())
+	if p.eat(ttEq) {
+		n.set("value", p.jsxParseAttributeValue())
+	} else {
+		n.set("value", nil)
+	}
+	return p.finishNode(n, "JSXAttribute")
+}
+
+func (p *parser) jsxParseOpeningElementAt(startPos int, startLoc Position) *Node {
+	n := p.startNodeAt(startPos, startLoc)
+	if p.match(ttJSXTagEnd) {
+		p.expect(ttJSXTagEnd)
+		return p.finishNode(n, "JSXOpeningFragment")
+	}
+	n.set("name", p.jsxParseElementName())
+
+	attributes := []*Node{}
+	for !p.match(ttSlash) && !p.match(ttJSXTagEnd) {
+		attributes = append(attributes, p.jsxParseAttribute())
+	}
+	n.set("attributes", attributes)
+	n.set("selfClosing", p.eat(ttSlash))
+	p.expect(ttJSXTagEnd)
+	return p.finishNode(n, "JSXOpeningElement")
+}
+
+func (p *parser) jsxParseClosingElementAt(startPos int, startLoc Position) *Node {
+	n := p.startNodeAt(startPos, startLoc)
+	if p.match(ttJSXTagEnd) {
+		p.expect(ttJSXTagEnd)
+		return p.finishNode(n, "JSXClosingFragment")
+	}
+	n.set("name", p.jsxParseElementName())
+	p.expect(ttJSXTagEnd)
+	return p.finishNode(n, "JSXClosingElement")
+}
+
+// jsxParseElementAt parses an element or a fragment after the opening angle
+// bracket.
+func (p *parser) jsxParseElementAt(startPos int, startLoc Position) *Node {
+	p.enter()
+	defer p.leave()
+
+	n := p.startNodeAt(startPos, startLoc)
+	children := []*Node{}
+	opening := p.jsxParseOpeningElementAt(startPos, startLoc)
+	var closing *Node
+
+	if !opening.flag("selfClosing") {
+	contents:
+		for {
+			switch p.state.typ {
+			case ttJSXTagStart:
+				startPos, startLoc = p.state.start, p.state.startLoc
+				p.next()
+				if p.eat(ttSlash) {
+					closing = p.jsxParseClosingElementAt(startPos, startLoc)
+					break contents
+				}
+				children = append(children, p.jsxParseElementAt(startPos, startLoc))
+			case ttJSXText:
+				children = append(children, p.parseExprAtom(nil))
+			case ttBraceL:
+				c := p.startNode()
+				p.next()
+				if p.match(ttEllipsis) {
+					children = append(children, p.jsxParseSpreadChild(c))
+				} else {
+					children = append(children, p.jsxParseExpressionContainer(c))
+				}
+			default:
+				p.unexpected(-1)
+			}
+		}
+
+		switch {
+		case isFragment(opening) && !isFragment(closing):
+			p.raise(closing.Start, "Expected corresponding JSX closing tag for <>")
+		case !isFragment(opening) && isFragment(closing):
+			p.raise(closing.Start, "Expected corresponding JSX closing tag for <"+qualifiedJSXName(opening.node("name"))+">")
+		case !isFragment(opening) && !isFragment(closing):
+			if qualifiedJSXName(closing.node("name")) != qualifiedJSXName(opening.node("name")) {
+				p.raise(closing.Start, "Expected corresponding JSX closing tag for <"+qualifiedJSXName(opening.node("name"))+">")
+			}
+		}
+	}
+
+	if isFragment(opening) {
+		n.set("openingFragment", opening)
+		n.set("closingFragment", closing)
+	} else {
+		n.set("openingElement", opening)
+		n.set("closingElement", closing)
+	}
+	n.set("children", children)
+	if p.isRelational("<") {
+		p.raise(p.state.start, "Adjacent JSX elements must be wrapped in an enclosing tag. Did you want a JSX fragment <>...</>?")
+	}
+	if isFragment(opening) {
+		return p.finishNode(n, "JSXFragment")
+	}
+	return p.finishNode(n, "JSXElement")
+}
+
+func (p *parser) jsxParseElement() *Node {
+	startPos, startLoc := p.state.start, p.state.startLoc
+	p.next()
+	return p.jsxParseElementAt(startPos, startLoc)
+}
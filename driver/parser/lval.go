@@ -0,0 +1,351 @@
+package parser
+
+// Conversion of expressions to patterns and parsing of bindings.
+
+// toAssignable converts an expression to a pattern in place.
+func (p *parser) toAssignable(n *Node, isBinding bool, contextDescription string) *Node {
+	if n == nil {
+		return n
+	}
+	if n.Type == "TypeCastExpression" {
+		n = p.typeCastToParameter(n)
+	}
+	switch n.Type {
+	case "Identifier", "ObjectPattern", "ArrayPattern", "AssignmentPattern":
+	case "ObjectExpression":
+		n.Type = "ObjectPattern"
+		props := n.list("properties")
+		for i, prop := range props {
+			isLast := i == len(props)-1
+			p.toAssignableObjectExpressionProp(prop, isBinding, isLast)
+		}
+	case "ObjectProperty":
+		p.toAssignable(n.node("value"), isBinding, contextDescription)
+	case "SpreadElement":
+		p.checkToRestConversion(n)
+		n.Type = "RestElement"
+		p.toAssignable(n.node("argument"), isBinding, contextDescription)
+	case "ArrayExpression":
+		n.Type = "ArrayPattern"
+		p.toAssignableList(n.list("elements"), isBinding, contextDescription)
+	case "AssignmentExpression":
+		if n.str("operator") != "=" {
+			p.raise(n.node("left").End, "Only '=' operator can be used for specifying default value.")
+		}
+		n.Type = "AssignmentPattern"
+		n.del("operator")
+	case "MemberExpression":
+		if !isBinding {
+			break
+		}
+		fallthrough
+	default:
+		msg := "Invalid left-hand side"
+		if contextDescription != "" {
+			msg += " in " + contextDescription
+		} else {
+			msg += "expression"
+		}
+		p.raise(n.Start, msg)
+	}
+	return n
+}
+
+func (p *parser) toAssignableObjectExpressionProp(prop *Node, isBinding, isLast bool) {
+	if prop.Type == "ObjectMethod" {
+		if k := prop.str("kind"); k == "get" || k == "set" {
+			p.raise(prop.node("key").Start, "Object pattern can't contain getter or setter")
+		}
+		p.raise(prop.node("key").Start, "Object pattern can't contain methods")
+	} else if prop.Type == "SpreadElement" && !isLast {
+		p.raiseRestNotLast(prop.Start)
+	}
+	p.toAssignable(prop, isBinding, "object destructuring pattern")
+}
+
+// toAssignableList converts a list of expressions to patterns in place.
+func (p *parser) toAssignableList(list []*Node, isBinding bool, contextDescription string) []*Node {
+	for i, expr := range list {
+		if expr != nil && expr.Type == "TypeCastExpression" {
+			list[i] = p.typeCastToParameter(expr)
+		}
+	}
+	end := len(list)
+	if end > 0 {
+		last := list[end-1]
+		if last != nil && last.Type == "RestElement" {
+			end--
+		} else if last != nil && last.Type == "SpreadElement" {
+			last.Type = "RestElement"
+			arg := p.toAssignable(last.node("argument"), isBinding, contextDescription)
+			switch arg.Type {
+			case "Identifier", "MemberExpression", "ArrayPattern", "ObjectPattern":
+			default:
+				p.unexpected(arg.Start)
+			}
+			end--
+		}
+	}
+	for _, elt := range list[:end] {
+		if elt == nil {
+			continue
+		}
+		p.toAssignable(elt, isBinding, contextDescription)
+		if elt.Type == "RestElement" {
+			p.raiseRestNotLast(elt.Start)
+		}
+	}
+	return list
+}
+
+// typeCastToParameter moves the type of a type cast to its expression.
+func (p *parser) typeCastToParameter(n *Node) *Node {
+	expr := n.node("expression")
+	typ := n.node("typeAnnotation")
+	expr.set("typeAnnotation", typ)
+	p.resetEndLocationAt(expr, typ.End, typ.Loc.End)
+	return expr
+}
+
+// toReferencedList checks that type casts in a list are parenthesized.
+func (p *parser) toReferencedList(list []*Node, isParenthesizedExpr bool) []*Node {
+	for _, expr := range list {
+		if expr != nil && expr.Type == "TypeCastExpression" && !expr.parenthesized() &&
+			(len(list) > 1 || !isParenthesizedExpr) {
+			p.raise(expr.node("typeAnnotation").Start, "The type cast expression is expected to be wrapped with parenthesis")
+		}
+	}
+	return list
+}
+
+func (p *parser) toReferencedListDeep(list []*Node, isParenthesizedExpr bool) []*Node {
+	p.toReferencedList(list, isParenthesizedExpr)
+	for _, expr := range list {
+		if expr != nil && expr.Type == "ArrayExpression" {
+			p.toReferencedListDeep(expr.list("elements"), false)
+		}
+	}
+	return list
+}
+
+// isAssignable reports if an expression can be converted to a pattern.
+func (p *parser) isAssignable(n *Node, isBinding bool) bool {
+	switch n.Type {
+	case "Identifier", "ObjectPattern", "ArrayPattern", "AssignmentPattern":
+		return true
+	case "ObjectExpression":
+		props := n.list("properties")
+		for i, prop := range props {
+			if prop.Type == "ObjectMethod" || (i != len(props)-1 && prop.Type == "SpreadElement") || !p.isAssignable(prop, false) {
+				return false
+			}
+		}
+		return true
+	case "ObjectProperty":
+		return p.isAssignable(n.node("value"), false)
+	case "SpreadElement":
+		return p.isAssignable(n.node("argument"), false)
+	case "ArrayExpression":
+		for _, e := range n.list("elements") {
+			if e != nil && !p.isAssignable(e, false) {
+				return false
+			}
+		}
+		return true
+	case "AssignmentExpression":
+		return n.str("operator") == "="
+	case "TypeCastExpression":
+		return p.isAssignable(n.node("expression"), false)
+	case "MemberExpression", "OptionalMemberExpression":
+		return !isBinding
+	}
+	return false
+}
+
+func (p *parser) parseSpread(ref, refNeedsArrowPos *refPos) *Node {
+	n := p.startNode()
+	p.next()
+	n.set("argument", p.parseMaybeAssign(false, ref, nil, refNeedsArrowPos))
+	if p.state.commaAfterSpreadAt == -1 && p.match(ttComma) {
+		p.state.commaAfterSpreadAt = p.state.start
+	}
+	return p.finishNode(n, "SpreadElement")
+}
+
+func (p *parser) parseRestBinding() *Node {
+	n := p.startNode()
+	p.next()
+	n.set("argument", p.parseBindingAtom())
+	return p.finishNode(n, "RestElement")
+}
+
+// parseBindingAtom parses an identifier or a destructuring pattern.
+func (p *parser) parseBindingAtom() *Node {
+	switch p.state.typ {
+	case ttBracketL:
+		n := p.startNode()
+		p.next()
+		n.set("elements", p.parseBindingList(ttBracketR, true))
+		return p.finishNode(n, "ArrayPattern")
+	case ttBraceL:
+		return p.parseObj(true, nil)
+	}
+	return p.parseIdentifier(false)
+}
+
+func (p *parser) parseBindingList(close *tokenType, allowEmpty bool) []*Node {
+	elts := []*Node{}
+	first := true
+	for !p.eat(close) {
+		if first {
+			first = false
+		} else {
+			p.expect(ttComma)
+		}
+		if allowEmpty && p.match(ttComma) {
+			elts = append(elts, nil)
+		} else if p.eat(close) {
+			break
+		} else if p.match(ttEllipsis) {
+			elts = append(elts, p.parseAssignableListItemTypes(p.parseRestBinding()))
+			p.checkCommaAfterRest()
+			p.expect(close)
+			break
+		} else {
+			if p.match(ttAt) {
+				p.raise(p.state.start, "Stage 2 decorators cannot be used to decorate parameters")
+			}
+			elts = append(elts, p.parseAssignableListItem())
+		}
+	}
+	return elts
+}
+
+func (p *parser) parseAssignableListItem() *Node {
+	left := p.parseMaybeDefault(-1, Position{}, nil)
+	p.parseAssignableListItemTypes(left)
+	return p.parseMaybeDefault(left.Start, left.Loc.Start, left)
+}
+
+// parseAssignableListItemTypes parses an optional mark and a type of a
+// parameter. The span of the parameter includes the type.
+func (p *parser) parseAssignableListItemTypes(param *Node) *Node {
+	if p.eat(ttQuestion) {
+		if param.Type != "Identifier" {
+			p.raise(param.Start, "A binding pattern parameter cannot be optional in an implementation signature.")
+		}
+		param.set("optional", true)
+	}
+	if p.match(ttColon) {
+		param.set("typeAnnotation", p.flowParseTypeAnnotation())
+	}
+	p.resetEndLocation(param)
+	return param
+}
+
+// parseMaybeDefault parses an optional default value of a binding. A negative
+// start position means the current token.
+func (p *parser) parseMaybeDefault(startPos int, startLoc Position, left *Node) *Node {
+	if startPos < 0 {
+		startPos, startLoc = p.state.start, p.state.startLoc
+	}
+	if left == nil {
+		left = p.parseBindingAtom()
+	}
+	n := left
+	if p.eat(ttEq) {
+		n = p.startNodeAt(startPos, startLoc)
+		n.set("left", left)
+		n.set("right", p.parseMaybeAssign(false, nil, nil, nil))
+		p.finishNode(n, "AssignmentPattern")
+	}
+	if typ := n.node("typeAnnotation"); n.Type == "AssignmentPattern" && typ != nil && n.node("right").Start < typ.Start {
+		p.raise(typ.Start, "Type annotations must come before default assignments, "+
+			"e.g. instead of `age = 25: number` use `age: number = 25`")
+	}
+	return n
+}
+
+// checkLVal checks that an expression can be assigned to and declares the
+// bindings. If names is set, duplicate names are reported.
+func (p *parser) checkLVal(expr *Node, bindingType int, names map[string]bool, contextDescription string) {
+	switch expr.Type {
+	case "Identifier":
+		name := expr.str("name")
+		if p.state.strict {
+			reserved := isStrictBindOnlyReservedWord(name)
+			if bindingType == bindNone {
+				reserved = isStrictBindReservedWord(name, p.inModule)
+			}
+			if reserved {
+				verb := "Binding"
+				if bindingType == bindNone {
+					verb = "Assigning to"
+				}
+				p.raise(expr.Start, verb+" '"+name+"' in strict mode")
+			}
+		}
+		if names != nil {
+			if names[name] {
+				p.raise(expr.Start, "Argument name clash")
+			}
+			names[name] = true
+		}
+		if bindingType == bindLexical && name == "let" {
+			p.raise(expr.Start, "'let' is not allowed to be used as a name in 'let' or 'const' declarations.")
+		}
+		if bindingType&bindNone == 0 {
+			p.scope.declareName(name, bindingType, expr.Start)
+		}
+	case "MemberExpression":
+		if bindingType != bindNone {
+			p.raise(expr.Start, "Binding member expression")
+		}
+	case "ObjectPattern":
+		for _, prop := range expr.list("properties") {
+			if prop.Type == "ObjectProperty" {
+				prop = prop.node("value")
+			}
+			p.checkLVal(prop, bindingType, names, "object destructuring pattern")
+		}
+	case "ArrayPattern":
+		for _, elem := range expr.list("elements") {
+			if elem != nil {
+				p.checkLVal(elem, bindingType, names, "array destructuring pattern")
+			}
+		}
+	case "AssignmentPattern":
+		p.checkLVal(expr.node("left"), bindingType, names, "assignment pattern")
+	case "RestElement":
+		p.checkLVal(expr.node("argument"), bindingType, names, "rest element")
+	case "TypeCastExpression":
+	default:
+		msg := "Invalid"
+		if bindingType != bindNone {
+			msg = "Binding invalid"
+		}
+		msg += " left-hand side"
+		if contextDescription != "" {
+			msg += " in " + contextDescription
+		} else {
+			msg += "expression"
+		}
+		p.raise(expr.Start, msg)
+	}
+}
+
+func (p *parser) checkToRestConversion(n *Node) {
+	if t := n.node("argument").Type; t != "Identifier" && t != "MemberExpression" {
+		p.raise(n.node("argument").Start, "Invalid rest operator's argument")
+	}
+}
+
+func (p *parser) checkCommaAfterRest() {
+	if p.match(ttComma) {
+		p.raiseRestNotLast(p.state.start)
+	}
+}
+
+func (p *parser) raiseRestNotLast(pos int) {
+	p.raise(pos, "Rest element must be last element")
+}
@@ -0,0 +1,174 @@
+package parser
+
+import (
+	"math"
+
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+// Position is a line and a column in the source. Lines start at 1, columns
+// start at 0. Columns are measured in UTF-16 code units, as in Babel.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// SourceLocation is a span of a node in the source.
+type SourceLocation struct {
+	Start Position
+	End   Position
+	// IdentifierName is set for identifiers only.
+	IdentifierName string
+}
+
+// Node is a node of the Babel AST. Fields specific to a node type are stored
+// in a map, thus a field can be unset, set to null or set to a value, which
+// is significant for the output.
+type Node struct {
+	Type  string
+	Start int // offset in UTF-16 code units
+	End   int
+	Loc   SourceLocation
+
+	props map[string]interface{}
+}
+
+func (n *Node) set(key string, v interface{}) {
+	if n.props == nil {
+		n.props = make(map[string]interface{})
+	}
+	n.props[key] = v
+}
+
+func (n *Node) get(key string) interface{} {
+	return n.props[key]
+}
+
+func (n *Node) has(key string) bool {
+	_, ok := n.props[key]
+	return ok
+}
+
+func (n *Node) del(key string) {
+	delete(n.props, key)
+}
+
+func (n *Node) node(key string) *Node {
+	v, _ := n.props[key].(*Node)
+	return v
+}
+
+func (n *Node) list(key string) []*Node {
+	v, _ := n.props[key].([]*Node)
+	return v
+}
+
+func (n *Node) str(key string) string {
+	v, _ := n.props[key].(string)
+	return v
+}
+
+func (n *Node) flag(key string) bool {
+	v, _ := n.props[key].(bool)
+	return v
+}
+
+// extra returns a value from the extra field of a node.
+func (n *Node) extra(key string) interface{} {
+	m, _ := n.props["extra"].(map[string]interface{})
+	return m[key]
+}
+
+func (n *Node) addExtra(key string, v interface{}) {
+	m, _ := n.props["extra"].(map[string]interface{})
+	if m == nil {
+		m = make(map[string]interface{})
+		n.set("extra", m)
+	}
+	m[key] = v
+}
+
+func (n *Node) parenthesized() bool {
+	v, _ := n.extra("parenthesized").(bool)
+	return v
+}
+
+// undefined is stored in extra values that must be omitted from the output,
+// like properties set to undefined in JavaScript.
+type undefinedValue struct{}
+
+var undefined = undefinedValue{}
+
+// ToNode converts the AST to a generic tree, as if it was serialized to JSON
+// by the native parser.
+func (n *Node) ToNode() nodes.Node {
+	if n == nil {
+		return nil
+	}
+	obj := make(nodes.Object, len(n.props)+4)
+	for k, v := range n.props {
+		if v == undefined {
+			continue
+		}
+		obj[k] = toNode(v)
+	}
+	obj["type"] = nodes.String(n.Type)
+	obj["start"] = nodes.Int(n.Start)
+	obj["end"] = nodes.Int(n.End)
+	loc := nodes.Object{
+		"start": position(n.Loc.Start),
+		"end":   position(n.Loc.End),
+	}
+	if n.Loc.IdentifierName != "" {
+		loc["identifierName"] = nodes.String(n.Loc.IdentifierName)
+	}
+	obj["loc"] = loc
+	return obj
+}
+
+func position(p Position) nodes.Node {
+	return nodes.Object{
+		"line":   nodes.Int(p.Line),
+		"column": nodes.Int(p.Column),
+	}
+}
+
+func toNode(v interface{}) nodes.Node {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case *Node:
+		return v.ToNode()
+	case []*Node:
+		arr := make(nodes.Array, 0, len(v))
+		for _, c := range v {
+			arr = append(arr, c.ToNode())
+		}
+		return arr
+	case map[string]interface{}:
+		obj := make(nodes.Object, len(v))
+		for k, c := range v {
+			if c == undefined {
+				continue
+			}
+			obj[k] = toNode(c)
+		}
+		return obj
+	case string:
+		return nodes.String(v)
+	case bool:
+		return nodes.Bool(v)
+	case int:
+		return nodes.Int(v)
+	case float64:
+		// JSON has no representation for these values
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil
+		}
+		if float64(int64(v)) == v {
+			return nodes.Int(int64(v))
+		}
+		return nodes.Float(v)
+	}
+	panic("unexpected value in the AST")
+}
@@ -0,0 +1,416 @@
+// Package parser implements a JavaScript parser that produces the same AST as
+// the Babel parser used by the native driver, with the same set of plugins.
+//
+// It allows to run the driver without Node.js.
+//
+// The parser is a port of @babel/parser, which is distributed under the MIT
+// license; see the NOTICE file in this directory.
+package parser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// SyntaxError is returned for invalid sources. The message includes the
+// position in the same format as Babel.
+type SyntaxError struct {
+	Message string
+	Pos     int // offset in UTF-16 code units
+	Loc     Position
+}
+
+func (e *SyntaxError) Error() string {
+	return e.Message
+}
+
+// SourceType is a goal symbol of the grammar.
+type SourceType int
+
+const (
+	Module SourceType = iota
+	Script
+)
+
+func (t SourceType) String() string {
+	if t == Script {
+		return "script"
+	}
+	return "module"
+}
+
+// Options of the parser.
+type Options struct {
+	SourceType SourceType
+	// AllowImportExportEverywhere allows import and export declarations
+	// outside of the top level.
+	AllowImportExportEverywhere bool
+	// AllowReturnOutsideFunction allows return statements at the top level.
+	AllowReturnOutsideFunction bool
+	// MaxDepth limits the nesting of the grammar, zero means no limit.
+	MaxDepth int
+	// Tokens adds the list of all tokens and comments to the File node.
+	Tokens bool
+}
+
+// ErrTooDeep is raised when the nesting of the source exceeds the limit.
+var ErrTooDeep = fmt.Errorf("nesting is too deep for the parser")
+
+// InternalError is returned if the parser fails because of a bug rather than
+// an invalid source.
+type InternalError struct {
+	Value interface{} // recovered from the panic
+}
+
+func (e *InternalError) Error() string {
+	return fmt.Sprintf("internal parser error: %v", e.Value)
+}
+
+// abort is used to stop the parser with an error other than syntax error.
+type abort struct {
+	err error
+}
+
+// parser is a recursive descent parser that mirrors the structure of Babel,
+// which makes it easier to keep the AST identical.
+type parser struct {
+	ctx   context.Context
+	opts  Options
+	input []uint16
+	state *state
+	scope *scopeHandler
+
+	inModule    bool
+	isLookahead bool
+
+	// flow pragma is detected in the first comment before any code
+	flowPragmaSet bool
+	flowPragma    string
+
+	depth int
+	ticks int
+}
+
+// Parse parses the source and returns the File node.
+func Parse(ctx context.Context, src string, opts Options) (file *Node, err error) {
+	p := &parser{
+		ctx:      ctx,
+		opts:     opts,
+		input:    utf16.Encode([]rune(src)),
+		inModule: opts.SourceType == Module,
+	}
+	p.state = newState(p.inModule)
+	p.scope = newScopeHandler(p)
+	defer func() {
+		if r := recover(); r != nil {
+			switch r := r.(type) {
+			case *SyntaxError:
+				err = r
+			case abort:
+				err = r.err
+			default:
+				err = &InternalError{Value: r}
+			}
+			file = nil
+		}
+	}()
+	return p.parse(), nil
+}
+
+// GuessError is returned by ParseGuess if the source is invalid in all modes.
+type GuessError struct {
+	// Errors for each mode, in the order of guessing.
+	Errors []*SyntaxError
+}
+
+// Error joins all messages, as the native driver does.
+func (e *GuessError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Message)
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// ParseGuess parses the source as a module and then as a script, as the
+// native driver does. Only MaxDepth and Tokens of the options are used.
+// If both modes fail, a GuessError is returned.
+func ParseGuess(ctx context.Context, src string, opts Options) (*Node, error) {
+	modes := []Options{
+		{SourceType: Module, AllowImportExportEverywhere: true},
+		{SourceType: Script, AllowReturnOutsideFunction: true},
+	}
+	gerr := &GuessError{}
+	for _, mode := range modes {
+		mode.MaxDepth, mode.Tokens = opts.MaxDepth, opts.Tokens
+		file, err := Parse(ctx, src, mode)
+		if err == nil {
+			return file, nil
+		}
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			// other modes would fail the same way
+			return nil, err
+		}
+		gerr.Errors = append(gerr.Errors, serr)
+	}
+	return nil, gerr
+}
+
+// tick checks if the parsing was cancelled. It is called for each token.
+func (p *parser) tick() {
+	p.ticks++
+	if p.ticks%1024 != 0 || p.ctx == nil {
+		return
+	}
+	if err := p.ctx.Err(); err != nil {
+		panic(abort{err: err})
+	}
+}
+
+// enter tracks the nesting of the grammar to avoid stack exhaustion.
+func (p *parser) enter() {
+	p.depth++
+	if p.opts.MaxDepth > 0 && p.depth > p.opts.MaxDepth {
+		panic(abort{err: ErrTooDeep})
+	}
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+// lineInfo returns a position of an offset in the source.
+func (p *parser) lineInfo(offset int) Position {
+	line, cur := 1, 0
+	for i := 0; i < offset && i < len(p.input); i++ {
+		c := p.input[i]
+		if c == '\r' && i+1 < len(p.input) && p.input[i+1] == '\n' {
+			i++
+		}
+		if isNewLine(rune(c)) {
+			line++
+			cur = i + 1
+		}
+	}
+	return Position{Line: line, Column: offset - cur}
+}
+
+// raise stops the parser with a syntax error at a given position.
+func (p *parser) raise(pos int, msg string) {
+	loc := p.lineInfo(pos)
+	panic(&SyntaxError{
+		Message: fmt.Sprintf("%s (%d:%d)", msg, loc.Line, loc.Column),
+		Pos:     pos,
+		Loc:     loc,
+	})
+}
+
+// try runs a function and recovers from syntax errors.
+func (p *parser) try(fnc func()) (err *SyntaxError) {
+	depth := p.depth
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			p.depth = depth
+			err = e
+		}
+	}()
+	fnc()
+	return nil
+}
+
+// speculate runs a function and restores the state if it fails.
+func (p *parser) speculate(fnc func()) bool {
+	st := p.state.clone(false)
+	scopes := p.scope.snapshot()
+	if err := p.try(fnc); err != nil {
+		p.state = st
+		p.scope.restore(scopes)
+		return false
+	}
+	return true
+}
+
+func (p *parser) parse() *Node {
+	p.scope.enter(scopeProgram)
+	file := p.startNode()
+	program := p.startNode()
+	p.nextToken()
+	return p.parseTopLevel(file, program)
+}
+
+// util
+
+func (p *parser) isRelational(op string) bool {
+	return p.match(ttRelational) && p.state.value == op
+}
+
+func (p *parser) isLookaheadRelational(op string) bool {
+	l := p.lookahead()
+	return l.typ == ttRelational && l.value == op
+}
+
+func (p *parser) expectRelational(op string) {
+	if p.isRelational(op) {
+		p.next()
+	} else {
+		p.unexpectedType(-1, ttRelational)
+	}
+}
+
+func (p *parser) eatRelational(op string) bool {
+	if p.isRelational(op) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) isContextual(name string) bool {
+	return p.match(ttName) && p.state.value == name && !p.state.containsEsc
+}
+
+func (p *parser) isLookaheadContextual(name string) bool {
+	l := p.lookahead()
+	return l.typ == ttName && l.value == name
+}
+
+func (p *parser) eatContextual(name string) bool {
+	return p.isContextual(name) && p.eat(ttName)
+}
+
+func (p *parser) expectContextual(name string) {
+	if !p.eatContextual(name) {
+		p.unexpected(-1)
+	}
+}
+
+func (p *parser) canInsertSemicolon() bool {
+	return p.match(ttEOF) || p.match(ttBraceR) || p.hasPrecedingLineBreak()
+}
+
+func (p *parser) hasPrecedingLineBreak() bool {
+	return hasLineBreak(p.input[p.state.lastTokEnd:p.state.start])
+}
+
+func (p *parser) isLineTerminator() bool {
+	return p.eat(ttSemi) || p.canInsertSemicolon()
+}
+
+// semicolon consumes a semicolon or pretends there is one.
+func (p *parser) semicolon() {
+	if !p.isLineTerminator() {
+		p.unexpectedType(-1, ttSemi)
+	}
+}
+
+func (p *parser) expect(t *tokenType) {
+	if !p.eat(t) {
+		p.unexpectedType(-1, t)
+	}
+}
+
+func (p *parser) expectAt(t *tokenType, pos int) {
+	if !p.eat(t) {
+		p.unexpectedType(pos, t)
+	}
+}
+
+// unexpected raises an "Unexpected token" error at a given position, or at
+// the current token if the position is negative.
+func (p *parser) unexpected(pos int) {
+	p.unexpectedMsg(pos, "Unexpected token")
+}
+
+func (p *parser) unexpectedType(pos int, t *tokenType) {
+	p.unexpectedMsg(pos, fmt.Sprintf("Unexpected token, expected %q", t.label))
+}
+
+func (p *parser) unexpectedMsg(pos int, msg string) {
+	if pos < 0 {
+		pos = p.state.start
+	}
+	p.raise(pos, msg)
+}
+
+// expectPlugin raises an error for syntax of the plugins that are not
+// enabled by the native driver.
+func (p *parser) expectPlugin(name string, pos int) {
+	if pos < 0 {
+		pos = p.state.start
+	}
+	p.raise(pos, fmt.Sprintf("This experimental syntax requires enabling the parser plugin: '%s'", name))
+}
+
+func (p *parser) checkYieldAwaitInDefaultParams() {
+	if p.state.yieldPos != -1 && (p.state.awaitPos == -1 || p.state.yieldPos < p.state.awaitPos) {
+		p.raise(p.state.yieldPos, "Yield cannot be used as name inside a generator function")
+	}
+	if p.state.awaitPos != -1 {
+		p.raise(p.state.awaitPos, "Await cannot be used as name inside an async function")
+	}
+}
+
+// nodes
+
+func (p *parser) startNode() *Node {
+	return p.startNodeAt(p.state.start, p.state.startLoc)
+}
+
+func (p *parser) startNodeAt(pos int, loc Position) *Node {
+	return &Node{Start: pos, Loc: SourceLocation{Start: loc}}
+}
+
+func (p *parser) startNodeAtNode(n *Node) *Node {
+	return p.startNodeAt(n.Start, n.Loc.Start)
+}
+
+func (p *parser) finishNode(n *Node, typ string) *Node {
+	return p.finishNodeAt(n, typ, p.state.lastTokEnd, p.state.lastTokEndLoc)
+}
+
+func (p *parser) finishNodeAt(n *Node, typ string, pos int, loc Position) *Node {
+	n.Type = typ
+	n.End = pos
+	n.Loc.End = loc
+	p.processComment(n)
+	return n
+}
+
+func (p *parser) resetStartLocation(n *Node, start int, startLoc Position) {
+	n.Start = start
+	n.Loc.Start = startLoc
+}
+
+func (p *parser) resetEndLocation(n *Node) {
+	p.resetEndLocationAt(n, p.state.lastTokEnd, p.state.lastTokEndLoc)
+}
+
+func (p *parser) resetEndLocationAt(n *Node, end int, endLoc Position) {
+	n.End = end
+	n.Loc.End = endLoc
+}
+
+func (p *parser) resetStartLocationFromNode(n, from *Node) {
+	p.resetStartLocation(n, from.Start, from.Loc.Start)
+}
+
+// clone returns a shallow copy of a node without the attached comments.
+func (n *Node) clone() *Node {
+	c := *n
+	c.props = make(map[string]interface{}, len(n.props))
+	for k, v := range n.props {
+		switch k {
+		case "leadingComments", "trailingComments", "innerComments":
+			continue
+		}
+		c.props[k] = v
+	}
+	return &c
+}
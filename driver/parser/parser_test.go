@@ -0,0 +1,217 @@
+package parser
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/uastyaml"
+
+	"github.com/bblfsh/javascript-driver/driver/charset"
+	"github.com/bblfsh/javascript-driver/driver/internal/fixturetest"
+	"github.com/bblfsh/javascript-driver/driver/limits"
+)
+
+// TestFixtures checks that the parser produces the same AST as the native
+// driver for all fixtures.
+func TestFixtures(t *testing.T) {
+	files := fixturetest.Glob(t, "*.js", filepath.Join("encoding", "*.js"), filepath.Join("escaped", "*.js"))
+	for _, path := range files {
+		path := path
+		exp, err := ioutil.ReadFile(path + ".native")
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			src, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the native driver receives sources transcoded to UTF-8
+			file, err := ParseGuess(context.Background(), charset.Decode(string(src)).String(), Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			expAST, err := uastyaml.Unmarshal(exp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := file.ToNode()
+			if !nodes.Equal(expAST, got) {
+				data, err := uastyaml.Marshal(got)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Fatalf("unexpected AST:\n%s", diffLines(string(exp), string(data)))
+			}
+		})
+	}
+}
+
+// diffLines returns the first differing line with some context.
+func diffLines(exp, got string) string {
+	el, gl := strings.Split(exp, "\n"), strings.Split(got, "\n")
+	for i := 0; i < len(el) && i < len(gl); i++ {
+		if el[i] == gl[i] {
+			continue
+		}
+		from := i - 10
+		if from < 0 {
+			from = 0
+		}
+		return "expected:\n" + strings.Join(el[from:i+1], "\n") + "\ngot:\n" + strings.Join(gl[from:i+1], "\n")
+	}
+	return "expected:\n" + exp + "\ngot:\n" + got
+}
+
+func TestSyntaxError(t *testing.T) {
+	src, err := ioutil.ReadFile(filepath.Join(fixturetest.Dir, "_syntax_error.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseGuess(context.Background(), string(src), Options{})
+	gerr, ok := err.(*GuessError)
+	if !ok {
+		t.Fatalf("expected a syntax error, got %v", err)
+	} else if len(gerr.Errors) != 2 {
+		t.Fatalf("expected an error for each mode, got %d", len(gerr.Errors))
+	}
+	const exp = "Unexpected token (2:11), Unexpected token (2:11)"
+	if gerr.Error() != exp {
+		t.Fatalf("unexpected error: %q", gerr.Error())
+	}
+}
+
+var casesSourceType = []struct {
+	name string
+	src  string
+	typ  string
+}{
+	{name: "module", src: "import x from 'y'", typ: "module"},
+	{name: "script", src: "with (x) {}", typ: "script"},
+	{name: "return", src: "return 1", typ: "script"},
+}
+
+func TestSourceType(t *testing.T) {
+	for _, c := range casesSourceType {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			file, err := ParseGuess(context.Background(), c.src, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if typ := file.node("program").str("sourceType"); typ != c.typ {
+				t.Fatalf("expected %q, got %q", c.typ, typ)
+			}
+		})
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	src := strings.Repeat("(", 1000) + "1" + strings.Repeat(")", 1000)
+	if _, err := ParseGuess(context.Background(), src, Options{MaxDepth: 100}); err != ErrTooDeep {
+		t.Fatalf("expected too deep error, got %v", err)
+	}
+	if _, err := ParseGuess(context.Background(), src, Options{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	src := strings.Repeat("x;\n", 10000)
+	if _, err := ParseGuess(ctx, src, Options{}); err != context.Canceled {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+// TestContextUnderflow checks sources that close more token contexts than
+// they open.
+func TestContextUnderflow(t *testing.T) {
+	for _, src := range []string{
+		"</", "</a>", "</a> {}",
+		"this?. class", "get?. function", "a?.class\n{}", "x = a?.function + 1",
+	} {
+		t.Run(src, func(t *testing.T) {
+			_, err := ParseGuess(context.Background(), src, Options{})
+			if _, ok := err.(*InternalError); ok {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// panicContext fails the parser with a runtime error, like a bug would.
+type panicContext struct {
+	context.Context
+}
+
+func (panicContext) Err() error {
+	var m map[string]int
+	m["a"]++
+	return nil
+}
+
+func TestInternalError(t *testing.T) {
+	src := strings.Repeat("x;\n", 10000)
+	ctx := panicContext{context.Background()}
+	if _, err := ParseGuess(ctx, src, Options{}); err == nil {
+		t.Fatal("expected an error")
+	} else if _, ok := err.(*InternalError); !ok {
+		t.Fatalf("expected an internal error, got %v", err)
+	}
+	if _, err := NewDriver(0).Parse(ctx, src); !driver.ErrDriverFailure.Is(err) {
+		t.Fatalf("expected driver failure, got %v", err)
+	}
+}
+
+func TestDriver(t *testing.T) {
+	d := NewDriver(100)
+	_, err := d.Parse(context.Background(), "a b")
+	if e, ok := err.(*driver.ErrMulti); !ok || len(e.Errors) != 2 {
+		t.Fatalf("expected an error for each mode, got %v", err)
+	}
+	src := strings.Repeat("[", 1000) + strings.Repeat("]", 1000)
+	if _, err = d.Parse(context.Background(), src); !limits.ErrTooComplex.Is(err) {
+		t.Fatalf("expected too complex error, got %v", err)
+	}
+	ast, err := d.Parse(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	} else if typ := ast.(nodes.Object)["type"]; typ != nodes.String("File") {
+		t.Fatalf("unexpected root: %v", typ)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	// speculative parsing of the arrow function must not leave extra tokens
+	src := "f = (a) => a; /* c */ x = `t${y}`"
+	file, err := ParseGuess(context.Background(), src, Options{Tokens: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, tok := range file.list("tokens") {
+		labels = append(labels, tok.str("label"))
+	}
+	exp := []string{
+		"name", "=", "(", "name", ")", "=>", "name", ";", "CommentBlock",
+		"name", "=", "`", "template", "${", "name", "}", "template", "`", "eof",
+	}
+	if strings.Join(labels, " ") != strings.Join(exp, " ") {
+		t.Fatalf("unexpected tokens:\n%q\nvs\n%q", exp, labels)
+	}
+	if file, err = ParseGuess(context.Background(), src, Options{}); err != nil {
+		t.Fatal(err)
+	} else if file.has("tokens") {
+		t.Fatal("unexpected tokens")
+	}
+}
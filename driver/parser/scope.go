@@ -0,0 +1,305 @@
+package parser
+
+import "strings"
+
+// Scope flags.
+const (
+	scopeOther       = 0
+	scopeProgram     = 1 << 0
+	scopeFunction    = 1 << 1
+	scopeAsync       = 1 << 2
+	scopeGenerator   = 1 << 3
+	scopeArrow       = 1 << 4
+	scopeSimpleCatch = 1 << 5
+	scopeSuper       = 1 << 6
+	scopeDirectSuper = 1 << 7
+	scopeClass       = 1 << 8
+
+	scopeVar = scopeProgram | scopeFunction
+)
+
+func functionFlags(isAsync, isGenerator bool) int {
+	f := scopeFunction
+	if isAsync {
+		f |= scopeAsync
+	}
+	if isGenerator {
+		f |= scopeGenerator
+	}
+	return f
+}
+
+// Binding flags.
+const (
+	bindKindValue = 1 << 0
+	bindKindType  = 1 << 1
+
+	bindScopeVar      = 1 << 2 // var-style binding
+	bindScopeLexical  = 1 << 3 // let- or const-style binding
+	bindScopeFunction = 1 << 4 // function declaration
+
+	bindFlagsNone          = 1 << 5
+	bindFlagsClass         = 1 << 6
+	bindFlagsFlowDeclareFn = 1 << 7
+
+	bindClass    = bindKindValue | bindKindType | bindScopeLexical | bindFlagsClass
+	bindLexical  = bindKindValue | bindScopeLexical
+	bindVar      = bindKindValue | bindScopeVar
+	bindFunction = bindKindValue | bindScopeFunction
+	bindNone     = bindFlagsNone
+	bindOutside  = bindKindValue | bindFlagsNone
+
+	bindFlowDeclareFn = bindFlagsFlowDeclareFn
+)
+
+type scope struct {
+	flags            int
+	vars             map[string]bool
+	lexical          []string // ordered, the first one is a catch parameter
+	functions        map[string]bool
+	declareFunctions map[string]bool
+}
+
+func (s *scope) hasLexical(name string) bool {
+	for _, l := range s.lexical {
+		if l == name {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeHandler tracks declarations to detect redeclarations and undefined
+// exports.
+type scopeHandler struct {
+	p     *parser
+	stack []*scope
+
+	undefinedExports     map[string]int
+	undefinedExportNames []string // in the order of exports
+}
+
+func newScopeHandler(p *parser) *scopeHandler {
+	return &scopeHandler{p: p, undefinedExports: make(map[string]int)}
+}
+
+// scopeSnapshot is a copy of the scopes used to parse speculatively.
+type scopeSnapshot struct {
+	stack   []scope
+	exports map[string]int
+	names   []string
+}
+
+func copySet(m map[string]bool) map[string]bool {
+	c := make(map[string]bool, len(m))
+	for k := range m {
+		c[k] = true
+	}
+	return c
+}
+
+func (h *scopeHandler) snapshot() scopeSnapshot {
+	s := scopeSnapshot{
+		exports: make(map[string]int, len(h.undefinedExports)),
+		names:   append([]string(nil), h.undefinedExportNames...),
+	}
+	for _, sc := range h.stack {
+		s.stack = append(s.stack, scope{
+			flags:            sc.flags,
+			vars:             copySet(sc.vars),
+			lexical:          append([]string(nil), sc.lexical...),
+			functions:        copySet(sc.functions),
+			declareFunctions: copySet(sc.declareFunctions),
+		})
+	}
+	for k, v := range h.undefinedExports {
+		s.exports[k] = v
+	}
+	return s
+}
+
+func (h *scopeHandler) restore(s scopeSnapshot) {
+	h.stack = h.stack[:0]
+	for i := range s.stack {
+		sc := s.stack[i]
+		h.stack = append(h.stack, &sc)
+	}
+	h.undefinedExports = s.exports
+	h.undefinedExportNames = s.names
+}
+
+func (h *scopeHandler) cur() *scope {
+	return h.stack[len(h.stack)-1]
+}
+
+func (h *scopeHandler) currentVarScope() *scope {
+	for i := len(h.stack) - 1; ; i-- {
+		if s := h.stack[i]; s.flags&scopeVar != 0 {
+			return s
+		}
+	}
+}
+
+func (h *scopeHandler) currentThisScope() *scope {
+	for i := len(h.stack) - 1; ; i-- {
+		if s := h.stack[i]; (s.flags&scopeVar != 0 || s.flags&scopeClass != 0) && s.flags&scopeArrow == 0 {
+			return s
+		}
+	}
+}
+
+func (h *scopeHandler) inFunction() bool {
+	return h.currentVarScope().flags&scopeFunction != 0
+}
+
+func (h *scopeHandler) inGenerator() bool {
+	return h.currentVarScope().flags&scopeGenerator != 0
+}
+
+func (h *scopeHandler) inAsync() bool {
+	return h.currentVarScope().flags&scopeAsync != 0
+}
+
+func (h *scopeHandler) allowSuper() bool {
+	return h.currentThisScope().flags&scopeSuper != 0
+}
+
+func (h *scopeHandler) allowDirectSuper() bool {
+	return h.currentThisScope().flags&scopeDirectSuper != 0
+}
+
+func (h *scopeHandler) inNonArrowFunction() bool {
+	return h.currentThisScope().flags&scopeFunction != 0
+}
+
+func (h *scopeHandler) treatFunctionsAsVar() bool {
+	return h.treatFunctionsAsVarInScope(h.cur())
+}
+
+func (h *scopeHandler) treatFunctionsAsVarInScope(s *scope) bool {
+	return s.flags&scopeFunction != 0 || (!h.p.inModule && s.flags&scopeProgram != 0)
+}
+
+func (h *scopeHandler) enter(flags int) {
+	h.stack = append(h.stack, &scope{
+		flags:            flags,
+		vars:             make(map[string]bool),
+		functions:        make(map[string]bool),
+		declareFunctions: make(map[string]bool),
+	})
+}
+
+func (h *scopeHandler) exit() {
+	h.stack = h.stack[:len(h.stack)-1]
+}
+
+func (h *scopeHandler) declareName(name string, bindingType int, pos int) {
+	s := h.cur()
+	if bindingType&bindFlagsFlowDeclareFn != 0 {
+		h.checkRedeclarationInScope(s, name, bindingType, pos)
+		h.maybeExportDefined(s, name)
+		s.declareFunctions[name] = true
+		return
+	}
+	if bindingType&bindScopeLexical != 0 || bindingType&bindScopeFunction != 0 {
+		h.checkRedeclarationInScope(s, name, bindingType, pos)
+		if bindingType&bindScopeFunction != 0 {
+			s.functions[name] = true
+		} else {
+			s.lexical = append(s.lexical, name)
+		}
+		if bindingType&bindScopeLexical != 0 {
+			h.maybeExportDefined(s, name)
+		}
+	} else if bindingType&bindScopeVar != 0 {
+		for i := len(h.stack) - 1; i >= 0; i-- {
+			s = h.stack[i]
+			h.checkRedeclarationInScope(s, name, bindingType, pos)
+			s.vars[name] = true
+			h.maybeExportDefined(s, name)
+			if s.flags&scopeVar != 0 {
+				break
+			}
+		}
+	}
+	if h.p.inModule && s.flags&scopeProgram != 0 {
+		h.deleteExport(name)
+	}
+}
+
+func (h *scopeHandler) maybeExportDefined(s *scope, name string) {
+	if h.p.inModule && s.flags&scopeProgram != 0 {
+		h.deleteExport(name)
+	}
+}
+
+func (h *scopeHandler) deleteExport(name string) {
+	if _, ok := h.undefinedExports[name]; !ok {
+		return
+	}
+	delete(h.undefinedExports, name)
+	for i, n := range h.undefinedExportNames {
+		if n == name {
+			h.undefinedExportNames = append(h.undefinedExportNames[:i:i], h.undefinedExportNames[i+1:]...)
+			break
+		}
+	}
+}
+
+func (h *scopeHandler) checkRedeclarationInScope(s *scope, name string, bindingType int, pos int) {
+	if h.isRedeclaredInScope(s, name, bindingType) {
+		h.p.raise(pos, "Identifier '"+name+"' has already been declared")
+	}
+}
+
+func (h *scopeHandler) isRedeclaredInScope(s *scope, name string, bindingType int) bool {
+	if bindingType&bindFlagsFlowDeclareFn != 0 {
+		return !s.declareFunctions[name] && (s.hasLexical(name) || s.functions[name])
+	}
+	if bindingType&bindKindValue == 0 {
+		return false
+	}
+	if bindingType&bindScopeLexical != 0 {
+		return s.hasLexical(name) || s.functions[name] || s.vars[name]
+	}
+	if bindingType&bindScopeFunction != 0 {
+		return s.hasLexical(name) || (!h.treatFunctionsAsVarInScope(s) && s.vars[name])
+	}
+	return (s.hasLexical(name) && !(s.flags&scopeSimpleCatch != 0 && s.lexical[0] == name)) ||
+		(!h.treatFunctionsAsVarInScope(s) && s.functions[name])
+}
+
+// checkLocalExport records an export of a name that is not declared yet.
+func (h *scopeHandler) checkLocalExport(id *Node) {
+	top := h.stack[0]
+	name := id.str("name")
+	if !top.hasLexical(name) && !top.vars[name] && !top.functions[name] && !top.declareFunctions[name] {
+		if _, ok := h.undefinedExports[name]; !ok {
+			h.undefinedExportNames = append(h.undefinedExportNames, name)
+		}
+		h.undefinedExports[name] = id.Start
+	}
+}
+
+// detectFlowPragma returns "flow" or "noflow" if a comment contains the
+// corresponding pragma.
+func detectFlowPragma(s string) string {
+	for i := strings.IndexByte(s, '@'); i >= 0; {
+		rest := s[i+1:]
+		for _, p := range []string{"noflow", "flow"} {
+			if strings.HasPrefix(rest, p) && (len(rest) == len(p) || !isWordByte(rest[len(p)])) {
+				return p
+			}
+		}
+		j := strings.IndexByte(rest, '@')
+		if j < 0 {
+			break
+		}
+		i += j + 1
+	}
+	return ""
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,128 @@
+package parser
+
+// label is an entry of the label set of a statement.
+type label struct {
+	name           string
+	kind           string // "loop", "switch" or empty
+	statementStart int
+}
+
+// state is the state of the tokenizer and the parser. It is copied to parse
+// speculatively and to look ahead.
+type state struct {
+	strict bool
+
+	// potentialArrowAt is a position of a possible arrow function.
+	potentialArrowAt int
+	// noArrowAt and noArrowParamsConversionAt are used by Flow to handle
+	// ambiguous arrow functions in conditional expressions.
+	noArrowAt                 []int
+	noArrowParamsConversionAt []int
+	commaAfterSpreadAt        int
+
+	inParameters           bool
+	maybeInArrowParameters bool
+	inPipeline             bool
+	inType                 bool
+	noAnonFunctionType     bool
+	inPropertyName         bool
+	inClassProperty        bool
+	hasFlowComment         bool
+	isIterator             bool
+
+	classLevel     int
+	labels         []label
+	decoratorStack [][]*Node
+
+	// yieldPos and awaitPos are the positions of the first yield and await
+	// used as identifiers in arrow function parameters.
+	yieldPos int
+	awaitPos int
+
+	comments []*Node
+	// tokens are only appended to, thus clones may share them
+	tokens              []*Node
+	trailingComments    []*Node
+	leadingComments     []*Node
+	commentStack        []*Node
+	commentPreviousNode *Node
+
+	pos       int
+	lineStart int
+	curLine   int
+
+	// the current token
+	typ      *tokenType
+	value    interface{}
+	start    int
+	end      int
+	startLoc Position
+	endLoc   Position
+
+	// the previous token
+	lastTokStart    int
+	lastTokEnd      int
+	lastTokStartLoc Position
+	lastTokEndLoc   Position
+
+	context     []*tokContext
+	exprAllowed bool
+
+	containsEsc   bool
+	containsOctal bool
+	octalPosition int
+
+	invalidTemplateEscapePosition int
+
+	exportedIdentifiers []string
+}
+
+func newState(strict bool) *state {
+	return &state{
+		strict:                        strict,
+		potentialArrowAt:              -1,
+		commaAfterSpreadAt:            -1,
+		decoratorStack:                [][]*Node{nil},
+		yieldPos:                      -1,
+		awaitPos:                      -1,
+		curLine:                       1,
+		typ:                           ttEOF,
+		startLoc:                      Position{Line: 1},
+		endLoc:                        Position{Line: 1},
+		context:                       []*tokContext{ctBraceStatement},
+		exprAllowed:                   true,
+		octalPosition:                 -1,
+		invalidTemplateEscapePosition: -1,
+	}
+}
+
+func (s *state) curPosition() Position {
+	return Position{Line: s.curLine, Column: s.pos - s.lineStart}
+}
+
+func copyNodes(l []*Node) []*Node {
+	if l == nil {
+		return nil
+	}
+	return append([]*Node{}, l...)
+}
+
+// clone copies the state. If skipArrays is set, the lists are shared with the
+// original state, which is only safe for a short lookahead.
+func (s *state) clone(skipArrays bool) *state {
+	c := *s
+	if skipArrays {
+		return &c
+	}
+	c.noArrowAt = append([]int(nil), s.noArrowAt...)
+	c.noArrowParamsConversionAt = append([]int(nil), s.noArrowParamsConversionAt...)
+	c.labels = append([]label(nil), s.labels...)
+	c.decoratorStack = append([][]*Node(nil), s.decoratorStack...)
+	c.comments = copyNodes(s.comments)
+	c.trailingComments = copyNodes(s.trailingComments)
+	c.leadingComments = copyNodes(s.leadingComments)
+	c.commentStack = copyNodes(s.commentStack)
+	c.context = append([]*tokContext(nil), s.context...)
+	c.exportedIdentifiers = append([]string(nil), s.exportedIdentifiers...)
+	return &c
+}
@@ -0,0 +1,1711 @@
+package parser
+
+// Function flags of parseFunction.
+const (
+	funcStatement = 1 << iota
+	funcHangingStatement
+	funcNullableID
+)
+
+var (
+	loopLabel   = label{kind: "loop"}
+	switchLabel = label{kind: "switch"}
+)
+
+// parseTopLevel parses a program, which is a list of statements.
+func (p *parser) parseTopLevel(file, program *Node) *Node {
+	program.set("sourceType", p.opts.SourceType.String())
+	program.set("interpreter", p.parseInterpreterDirective())
+	p.parseBlockBody(program, true, true, ttEOF)
+
+	if p.inModule {
+		for _, name := range p.scope.undefinedExportNames {
+			p.raise(p.scope.undefinedExports[name], "Export '"+name+"' is not defined")
+		}
+	}
+	file.set("program", p.finishNode(program, "Program"))
+	file.set("comments", append([]*Node{}, p.state.comments...))
+	if p.opts.Tokens {
+		file.set("tokens", append([]*Node{}, p.state.tokens...))
+	}
+	return p.finishNode(file, "File")
+}
+
+func (p *parser) parseInterpreterDirective() *Node {
+	if !p.match(ttInterpreterDirective) {
+		return nil
+	}
+	n := p.startNode()
+	n.set("value", p.state.value)
+	p.next()
+	return p.finishNode(n, "InterpreterDirective")
+}
+
+func (p *parser) stmtToDirective(stmt *Node) *Node {
+	expr := stmt.node("expression")
+	lit := p.startNodeAt(expr.Start, expr.Loc.Start)
+	directive := p.startNodeAt(stmt.Start, stmt.Loc.Start)
+
+	raw := decode(p.input[expr.Start:expr.End])
+	val := raw[1 : len(raw)-1] // remove the quotes
+	lit.set("value", val)
+	lit.addExtra("raw", raw)
+	lit.addExtra("rawValue", val)
+
+	directive.set("value", p.finishNodeAt(lit, "DirectiveLiteral", expr.End, expr.Loc.End))
+	return p.finishNodeAt(directive, "Directive", stmt.End, stmt.Loc.End)
+}
+
+func (p *parser) isValidDirective(stmt *Node) bool {
+	if stmt.Type != "ExpressionStatement" {
+		return false
+	}
+	expr := stmt.node("expression")
+	return expr.Type == "StringLiteral" && !expr.parenthesized()
+}
+
+// parseStatement parses a single statement. The context is set for
+// statements that are bodies of other statements, like "if" or "label".
+func (p *parser) parseStatement(context string, topLevel bool) *Node {
+	p.enter()
+	defer p.leave()
+
+	// interface is a reserved word in strict mode
+	if p.state.strict && p.isContextual("interface") {
+		n := p.startNode()
+		p.next()
+		return p.flowParseInterface(n)
+	}
+	if p.match(ttAt) {
+		p.parseDecorators(true)
+	}
+	return p.parseStatementContent(context, topLevel)
+}
+
+func (p *parser) parseStatementContent(context string, topLevel bool) *Node {
+	starttype := p.state.typ
+	n := p.startNode()
+	kind := ""
+	if p.isLet(context) {
+		starttype = ttVar
+		kind = "let"
+	}
+
+	switch starttype {
+	case ttBreak, ttContinue:
+		return p.parseBreakContinueStatement(n, starttype.keyword)
+	case ttDebugger:
+		p.next()
+		p.semicolon()
+		return p.finishNode(n, "DebuggerStatement")
+	case ttDo:
+		return p.parseDoStatement(n)
+	case ttFor:
+		return p.parseForStatement(n)
+	case ttFunction:
+		if p.lookahead().typ == ttDot {
+			break
+		}
+		if context != "" {
+			if p.state.strict {
+				p.raise(p.state.start, "In strict mode code, functions can only be declared at top level or inside a block")
+			} else if context != "if" && context != "label" {
+				p.raise(p.state.start, "In non-strict mode code, functions can only be declared at top level, inside a block, or as the body of an if statement")
+			}
+		}
+		return p.parseFunctionStatement(n, false, context == "")
+	case ttClass:
+		if context != "" {
+			p.unexpected(-1)
+		}
+		return p.parseClass(n, true, false)
+	case ttIf:
+		return p.parseIfStatement(n)
+	case ttReturn:
+		return p.parseReturnStatement(n)
+	case ttSwitch:
+		return p.parseSwitchStatement(n)
+	case ttThrow:
+		return p.parseThrowStatement(n)
+	case ttTry:
+		return p.parseTryStatement(n)
+	case ttConst, ttVar:
+		if kind == "" {
+			kind, _ = p.state.value.(string)
+		}
+		if context != "" && kind != "var" {
+			p.unexpectedMsg(p.state.start, "Lexical declaration cannot appear in a single-statement context")
+		}
+		return p.parseVarStatement(n, kind)
+	case ttWhile:
+		return p.parseWhileStatement(n)
+	case ttWith:
+		return p.parseWithStatement(n)
+	case ttBraceL:
+		return p.parseBlock(false, true)
+	case ttSemi:
+		p.next()
+		return p.finishNode(n, "EmptyStatement")
+	case ttExport, ttImport:
+		next := p.lookahead()
+		if next.typ == ttParenL || next.typ == ttDot {
+			break
+		}
+		if !p.opts.AllowImportExportEverywhere && !topLevel {
+			p.raise(p.state.start, "'import' and 'export' may only appear at the top level")
+		}
+		p.next()
+		var res *Node
+		if starttype == ttImport {
+			res = p.parseImport(n)
+		} else {
+			res = p.parseExport(n)
+		}
+		p.assertModuleNodeAllowed(n)
+		return res
+	default:
+		if p.isAsyncFunction() {
+			if context != "" {
+				p.unexpectedMsg(-1, "Async functions can only be declared at the top level or inside a block")
+			}
+			p.next()
+			return p.parseFunctionStatement(n, true, context == "")
+		}
+	}
+
+	// an expression or a labeled statement
+	maybeName := p.state.value
+	expr := p.parseExpression(false, nil)
+	if starttype == ttName && expr.Type == "Identifier" && p.eat(ttColon) {
+		name, _ := maybeName.(string)
+		return p.parseLabeledStatement(n, name, expr, context)
+	}
+	return p.parseExpressionStatement(n, expr)
+}
+
+func (p *parser) assertModuleNodeAllowed(n *Node) {
+	// Flow type imports and exports are allowed in scripts
+	if (n.Type == "ImportDeclaration" && hasTypeImportKind(n)) ||
+		((n.Type == "ExportNamedDeclaration" || n.Type == "ExportAllDeclaration") && n.str("exportKind") == "type") {
+		return
+	}
+	if !p.opts.AllowImportExportEverywhere && !p.inModule {
+		p.raise(n.Start, "'import' and 'export' may appear only with 'sourceType: module'")
+	}
+}
+
+// skipWhiteSpace returns the position after whitespace and comments.
+func (p *parser) skipWhiteSpace(pos int) int {
+	for pos < len(p.input) {
+		c := rune(p.input[pos])
+		switch {
+		case isWhitespace(c) || isNewLine(c):
+			pos++
+		case c == '/' && p.charAt(pos+1) == '/':
+			pos += 2
+			for pos < len(p.input) && !isNewLine(rune(p.input[pos])) {
+				pos++
+			}
+		case c == '/' && p.charAt(pos+1) == '*':
+			end := indexOf(p.input, []uint16{'*', '/'}, pos+2)
+			if end < 0 {
+				return pos
+			}
+			pos = end + 2
+		default:
+			return pos
+		}
+	}
+	return pos
+}
+
+// isLet reports if the current "let" starts a declaration.
+func (p *parser) isLet(context string) bool {
+	if !p.isContextual("let") {
+		return false
+	}
+	next := p.skipWhiteSpace(p.state.pos)
+	nextCh := p.charAt(next)
+	// "let [" is never an expression statement
+	if nextCh == '[' {
+		return true
+	}
+	if context != "" {
+		return false
+	}
+	if nextCh == '{' {
+		return true
+	}
+	if isIdentifierStart(nextCh) {
+		pos := next + 1
+		for pos < len(p.input) && isIdentifierChar(rune(p.input[pos])) {
+			pos++
+		}
+		ident := decode(p.input[next:pos])
+		if ident != "in" && ident != "instanceof" {
+			return true
+		}
+	}
+	return false
+}
+
+// isAsyncFunction reports if the current "async" starts a function.
+func (p *parser) isAsyncFunction() bool {
+	if !p.isContextual("async") {
+		return false
+	}
+	pos := p.state.pos
+	next := p.skipWhiteSpace(pos)
+	if hasLineBreak(p.input[pos:next]) || next+8 > len(p.input) || decode(p.input[next:next+8]) != "function" {
+		return false
+	}
+	return next+8 == len(p.input) || !isIdentifierChar(rune(p.input[next+8]))
+}
+
+func (p *parser) parseDecorators(allowExport bool) {
+	i := len(p.state.decoratorStack) - 1
+	for p.match(ttAt) {
+		d := p.parseDecorator()
+		p.state.decoratorStack[i] = append(p.state.decoratorStack[i], d)
+	}
+	if p.match(ttExport) {
+		if !allowExport {
+			p.unexpected(-1)
+		}
+		p.raise(p.state.start, "Using the export keyword between a decorator and a class is not allowed. Please use `export @dec class` instead.")
+	} else if !p.match(ttClass) {
+		p.raise(p.state.start, "Leading decorators must be attached to a class declaration")
+	}
+}
+
+func (p *parser) parseDecorator() *Node {
+	n := p.startNode()
+	p.next()
+	// decorators of nested class expressions are separate
+	p.state.decoratorStack = append(p.state.decoratorStack, nil)
+	startPos, startLoc := p.state.start, p.state.startLoc
+	var expr *Node
+	if p.eat(ttParenL) {
+		expr = p.parseExpression(false, nil)
+		p.expect(ttParenR)
+	} else {
+		expr = p.parseIdentifier(false)
+		for p.eat(ttDot) {
+			m := p.startNodeAt(startPos, startLoc)
+			m.set("object", expr)
+			m.set("property", p.parseMaybePrivateName())
+			m.set("computed", false)
+			expr = p.finishNode(m, "MemberExpression")
+		}
+	}
+	n.set("expression", p.parseMaybeDecoratorArguments(expr))
+	p.state.decoratorStack = p.state.decoratorStack[:len(p.state.decoratorStack)-1]
+	return p.finishNode(n, "Decorator")
+}
+
+func (p *parser) parseMaybeDecoratorArguments(expr *Node) *Node {
+	if p.eat(ttParenL) {
+		n := p.startNodeAtNode(expr)
+		n.set("callee", expr)
+		args := p.parseCallExpressionArguments(ttParenR, false, false, nil)
+		n.set("arguments", args)
+		p.toReferencedList(args, false)
+		return p.finishNode(n, "CallExpression")
+	}
+	return expr
+}
+
+func (p *parser) parseBreakContinueStatement(n *Node, keyword string) *Node {
+	isBreak := keyword == "break"
+	p.next()
+	if p.isLineTerminator() {
+		n.set("label", nil)
+	} else {
+		n.set("label", p.parseIdentifier(false))
+		p.semicolon()
+	}
+
+	lab := n.node("label")
+	i := 0
+	for ; i < len(p.state.labels); i++ {
+		l := p.state.labels[i]
+		if lab == nil || l.name == lab.str("name") {
+			if l.kind != "" && (isBreak || l.kind == "loop") {
+				break
+			}
+			if lab != nil && isBreak {
+				break
+			}
+		}
+	}
+	if i == len(p.state.labels) {
+		p.raise(n.Start, "Unsyntactic "+keyword)
+	}
+	if isBreak {
+		return p.finishNode(n, "BreakStatement")
+	}
+	return p.finishNode(n, "ContinueStatement")
+}
+
+func (p *parser) parseHeaderExpression() *Node {
+	p.expect(ttParenL)
+	val := p.parseExpression(false, nil)
+	p.expect(ttParenR)
+	return val
+}
+
+func (p *parser) pushLabel(l label) {
+	p.state.labels = append(p.state.labels, l)
+}
+
+func (p *parser) popLabel() {
+	p.state.labels = p.state.labels[:len(p.state.labels)-1]
+}
+
+func (p *parser) parseDoStatement(n *Node) *Node {
+	p.next()
+	p.pushLabel(loopLabel)
+	n.set("body", p.parseStatement("do", false))
+	p.popLabel()
+	p.expect(ttWhile)
+	n.set("test", p.parseHeaderExpression())
+	p.eat(ttSemi)
+	return p.finishNode(n, "DoWhileStatement")
+}
+
+// parseForStatement parses all kinds of for loops. It is not known if it is a
+// regular for or a for-in/for-of loop until the first semicolon or "in".
+func (p *parser) parseForStatement(n *Node) *Node {
+	p.next()
+	p.pushLabel(loopLabel)
+
+	awaitAt := -1
+	if p.scope.inAsync() && p.eatContextual("await") {
+		awaitAt = p.state.lastTokStart
+	}
+	p.scope.enter(scopeOther)
+	p.expect(ttParenL)
+
+	if p.match(ttSemi) {
+		if awaitAt > -1 {
+			p.unexpected(awaitAt)
+		}
+		return p.parseFor(n, nil)
+	}
+
+	isLet := p.isLet("")
+	if p.match(ttVar) || p.match(ttConst) || isLet {
+		init := p.startNode()
+		kind := "let"
+		if !isLet {
+			kind, _ = p.state.value.(string)
+		}
+		p.next()
+		p.parseVar(init, true, kind)
+		p.finishNode(init, "VariableDeclaration")
+		if (p.match(ttIn) || p.isContextual("of")) && len(init.list("declarations")) == 1 {
+			return p.parseForIn(n, init, awaitAt)
+		}
+		if awaitAt > -1 {
+			p.unexpected(awaitAt)
+		}
+		return p.parseFor(n, init)
+	}
+
+	ref := &refPos{}
+	init := p.parseExpression(true, ref)
+	if p.match(ttIn) || p.isContextual("of") {
+		desc := "for-in statement"
+		if p.isContextual("of") {
+			desc = "for-of statement"
+		}
+		p.toAssignable(init, false, desc)
+		p.checkLVal(init, bindNone, nil, desc)
+		return p.parseForIn(n, init, awaitAt)
+	} else if ref.start != 0 {
+		p.unexpected(ref.start)
+	}
+	if awaitAt > -1 {
+		p.unexpected(awaitAt)
+	}
+	return p.parseFor(n, init)
+}
+
+func (p *parser) parseFor(n, init *Node) *Node {
+	n.set("init", init)
+	p.expect(ttSemi)
+	if p.match(ttSemi) {
+		n.set("test", nil)
+	} else {
+		n.set("test", p.parseExpression(false, nil))
+	}
+	p.expect(ttSemi)
+	if p.match(ttParenR) {
+		n.set("update", nil)
+	} else {
+		n.set("update", p.parseExpression(false, nil))
+	}
+	p.expect(ttParenR)
+	n.set("body", p.parseStatement("for", false))
+	p.scope.exit()
+	p.popLabel()
+	return p.finishNode(n, "ForStatement")
+}
+
+func (p *parser) parseForIn(n, init *Node, awaitAt int) *Node {
+	isForIn := p.match(ttIn)
+	p.next()
+	if isForIn {
+		if awaitAt > -1 {
+			p.unexpected(awaitAt)
+		}
+	} else {
+		n.set("await", awaitAt > -1)
+	}
+
+	if init.Type == "VariableDeclaration" {
+		decl := init.list("declarations")[0]
+		if decl.node("init") != nil && (!isForIn || p.state.strict || init.str("kind") != "var" || decl.node("id").Type != "Identifier") {
+			loop := "for-of"
+			if isForIn {
+				loop = "for-in"
+			}
+			p.raise(init.Start, loop+" loop variable declaration may not have an initializer")
+		}
+	} else if init.Type == "AssignmentPattern" {
+		p.raise(init.Start, "Invalid left-hand side in for-loop")
+	}
+
+	n.set("left", init)
+	if isForIn {
+		n.set("right", p.parseExpression(false, nil))
+	} else {
+		n.set("right", p.parseMaybeAssign(false, nil, nil, nil))
+	}
+	p.expect(ttParenR)
+	n.set("body", p.parseStatement("for", false))
+	p.scope.exit()
+	p.popLabel()
+	if isForIn {
+		return p.finishNode(n, "ForInStatement")
+	}
+	return p.finishNode(n, "ForOfStatement")
+}
+
+func (p *parser) parseFunctionStatement(n *Node, isAsync, declarationPosition bool) *Node {
+	p.next()
+	flags := funcStatement
+	if !declarationPosition {
+		flags |= funcHangingStatement
+	}
+	return p.parseFunction(n, flags, isAsync)
+}
+
+func (p *parser) parseIfStatement(n *Node) *Node {
+	p.next()
+	n.set("test", p.parseHeaderExpression())
+	n.set("consequent", p.parseStatement("if", false))
+	if p.eat(ttElse) {
+		n.set("alternate", p.parseStatement("if", false))
+	} else {
+		n.set("alternate", nil)
+	}
+	return p.finishNode(n, "IfStatement")
+}
+
+func (p *parser) parseReturnStatement(n *Node) *Node {
+	if !p.scope.inFunction() && !p.opts.AllowReturnOutsideFunction {
+		p.raise(p.state.start, "'return' outside of function")
+	}
+	p.next()
+	if p.isLineTerminator() {
+		n.set("argument", nil)
+	} else {
+		n.set("argument", p.parseExpression(false, nil))
+		p.semicolon()
+	}
+	return p.finishNode(n, "ReturnStatement")
+}
+
+func (p *parser) parseSwitchStatement(n *Node) *Node {
+	p.next()
+	n.set("discriminant", p.parseHeaderExpression())
+	cases := []*Node{}
+	n.set("cases", cases)
+	p.expect(ttBraceL)
+	p.pushLabel(switchLabel)
+	p.scope.enter(scopeOther)
+
+	var cur *Node
+	var consequent []*Node
+	sawDefault := false
+	for !p.match(ttBraceR) {
+		if p.match(ttCase) || p.match(ttDefault) {
+			isCase := p.match(ttCase)
+			if cur != nil {
+				p.finishNode(cur, "SwitchCase")
+			}
+			cur = p.startNode()
+			consequent = []*Node{}
+			cur.set("consequent", consequent)
+			cases = append(cases, cur)
+			n.set("cases", cases)
+			p.next()
+			if isCase {
+				cur.set("test", p.parseExpression(false, nil))
+			} else {
+				if sawDefault {
+					p.raise(p.state.lastTokStart, "Multiple default clauses")
+				}
+				sawDefault = true
+				cur.set("test", nil)
+			}
+			p.expect(ttColon)
+		} else {
+			if cur == nil {
+				p.unexpected(-1)
+			}
+			consequent = append(consequent, p.parseStatement("", false))
+			cur.set("consequent", consequent)
+		}
+	}
+	p.scope.exit()
+	if cur != nil {
+		p.finishNode(cur, "SwitchCase")
+	}
+	p.next() // the closing brace
+	p.popLabel()
+	return p.finishNode(n, "SwitchStatement")
+}
+
+func (p *parser) parseThrowStatement(n *Node) *Node {
+	p.next()
+	if hasLineBreak(p.input[p.state.lastTokEnd:p.state.start]) {
+		p.raise(p.state.lastTokEnd, "Illegal newline after throw")
+	}
+	n.set("argument", p.parseExpression(false, nil))
+	p.semicolon()
+	return p.finishNode(n, "ThrowStatement")
+}
+
+func (p *parser) parseTryStatement(n *Node) *Node {
+	p.next()
+	n.set("block", p.parseBlock(false, true))
+	n.set("handler", nil)
+
+	if p.match(ttCatch) {
+		clause := p.startNode()
+		p.next()
+		if p.match(ttParenL) {
+			p.expect(ttParenL)
+			param := p.parseBindingAtom()
+			clause.set("param", param)
+			if param.Type == "Identifier" {
+				p.scope.enter(scopeSimpleCatch)
+			} else {
+				p.scope.enter(scopeOther)
+			}
+			p.checkLVal(param, bindLexical, nil, "catch clause")
+			p.expect(ttParenR)
+		} else {
+			clause.set("param", nil)
+			p.scope.enter(scopeOther)
+		}
+		clause.set("body", p.parseBlock(false, false))
+		p.scope.exit()
+		n.set("handler", p.finishNode(clause, "CatchClause"))
+	}
+
+	if p.eat(ttFinally) {
+		n.set("finalizer", p.parseBlock(false, true))
+	} else {
+		n.set("finalizer", nil)
+	}
+	if n.node("handler") == nil && n.node("finalizer") == nil {
+		p.raise(n.Start, "Missing catch or finally clause")
+	}
+	return p.finishNode(n, "TryStatement")
+}
+
+func (p *parser) parseVarStatement(n *Node, kind string) *Node {
+	p.next()
+	p.parseVar(n, false, kind)
+	p.semicolon()
+	return p.finishNode(n, "VariableDeclaration")
+}
+
+func (p *parser) parseWhileStatement(n *Node) *Node {
+	p.next()
+	n.set("test", p.parseHeaderExpression())
+	p.pushLabel(loopLabel)
+	n.set("body", p.parseStatement("while", false))
+	p.popLabel()
+	return p.finishNode(n, "WhileStatement")
+}
+
+func (p *parser) parseWithStatement(n *Node) *Node {
+	if p.state.strict {
+		p.raise(p.state.start, "'with' in strict mode")
+	}
+	p.next()
+	n.set("object", p.parseHeaderExpression())
+	n.set("body", p.parseStatement("with", false))
+	return p.finishNode(n, "WithStatement")
+}
+
+func (p *parser) parseLabeledStatement(n *Node, maybeName string, expr *Node, context string) *Node {
+	for _, l := range p.state.labels {
+		if l.name == maybeName {
+			p.raise(expr.Start, "Label '"+maybeName+"' is already declared")
+		}
+	}
+
+	kind := ""
+	if p.state.typ.isLoop {
+		kind = "loop"
+	} else if p.match(ttSwitch) {
+		kind = "switch"
+	}
+	for i := len(p.state.labels) - 1; i >= 0; i-- {
+		l := &p.state.labels[i]
+		if l.statementStart != n.Start {
+			break
+		}
+		l.statementStart = p.state.start
+		l.kind = kind
+	}
+	p.pushLabel(label{name: maybeName, kind: kind, statementStart: p.state.start})
+
+	bodyContext := "label"
+	if context != "" {
+		bodyContext = context
+		if !containsLabel(context) {
+			bodyContext = context + "label"
+		}
+	}
+	n.set("body", p.parseStatement(bodyContext, false))
+	p.popLabel()
+	n.set("label", expr)
+	return p.finishNode(n, "LabeledStatement")
+}
+
+func containsLabel(context string) bool {
+	for i := 0; i+5 <= len(context); i++ {
+		if context[i:i+5] == "label" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseExpressionStatement finishes an expression statement, or a Flow
+// declaration that starts with a contextual keyword.
+func (p *parser) parseExpressionStatement(n, expr *Node) *Node {
+	if expr.Type == "Identifier" {
+		name := expr.str("name")
+		if name == "declare" {
+			if p.match(ttClass) || p.match(ttName) || p.match(ttFunction) || p.match(ttVar) || p.match(ttExport) {
+				return p.flowParseDeclare(n, false)
+			}
+		} else if p.match(ttName) {
+			switch name {
+			case "interface":
+				return p.flowParseInterface(n)
+			case "type":
+				return p.flowParseTypeAlias(n)
+			case "opaque":
+				return p.flowParseOpaqueType(n, false)
+			}
+		}
+	}
+	n.set("expression", expr)
+	p.semicolon()
+	return p.finishNode(n, "ExpressionStatement")
+}
+
+// parseBlock parses a block statement. Function bodies do not create a new
+// lexical scope and allow directives.
+func (p *parser) parseBlock(allowDirectives, createNewLexicalScope bool) *Node {
+	n := p.startNode()
+	p.expect(ttBraceL)
+	if createNewLexicalScope {
+		p.scope.enter(scopeOther)
+	}
+	p.parseBlockBody(n, allowDirectives, false, ttBraceR)
+	if createNewLexicalScope {
+		p.scope.exit()
+	}
+	return p.finishNode(n, "BlockStatement")
+}
+
+func (p *parser) parseBlockBody(n *Node, allowDirectives, topLevel bool, end *tokenType) {
+	body := []*Node{}
+	directives := []*Node{}
+	n.set("body", body)
+	n.set("directives", directives)
+
+	parsedNonDirective := false
+	oldStrict, sawStrict := false, false
+	octalPosition := -1
+	for !p.eat(end) {
+		if !parsedNonDirective && p.state.containsOctal && octalPosition < 0 {
+			octalPosition = p.state.octalPosition
+		}
+		stmt := p.parseStatement("", topLevel)
+		if allowDirectives && !parsedNonDirective && p.isValidDirective(stmt) {
+			d := p.stmtToDirective(stmt)
+			directives = append(directives, d)
+			n.set("directives", directives)
+			if !sawStrict && d.node("value").get("value") == "use strict" {
+				sawStrict = true
+				oldStrict = p.state.strict
+				p.setStrict(true)
+				if octalPosition >= 0 {
+					p.raise(octalPosition, "Octal literal in strict mode")
+				}
+			}
+			continue
+		}
+		parsedNonDirective = true
+		body = append(body, stmt)
+		n.set("body", body)
+	}
+	if sawStrict && !oldStrict {
+		p.setStrict(false)
+	}
+}
+
+// parseVar parses a list of variable declarators.
+func (p *parser) parseVar(n *Node, isFor bool, kind string) *Node {
+	decls := []*Node{}
+	n.set("declarations", decls)
+	n.set("kind", kind)
+	for {
+		decl := p.startNode()
+		p.parseVarId(decl, kind)
+		if p.eat(ttEq) {
+			decl.set("init", p.parseMaybeAssign(isFor, nil, nil, nil))
+		} else {
+			if kind == "const" && !(p.match(ttIn) || p.isContextual("of")) {
+				p.unexpected(-1)
+			} else if decl.node("id").Type != "Identifier" && !(isFor && (p.match(ttIn) || p.isContextual("of"))) {
+				p.raise(p.state.lastTokEnd, "Complex binding patterns require an initialization value")
+			}
+			decl.set("init", nil)
+		}
+		decls = append(decls, p.finishNode(decl, "VariableDeclarator"))
+		n.set("declarations", decls)
+		if !p.eat(ttComma) {
+			break
+		}
+	}
+	return n
+}
+
+func (p *parser) parseVarId(decl *Node, kind string) {
+	id := p.parseBindingAtom()
+	decl.set("id", id)
+	bind := bindLexical
+	if kind == "var" {
+		bind = bindVar
+	}
+	p.checkLVal(id, bind, nil, "variable declaration")
+	if p.match(ttColon) {
+		id.set("typeAnnotation", p.flowParseTypeAnnotation())
+		// the type is included in the span of the identifier
+		p.resetEndLocation(id)
+	}
+}
+
+// parseFunction parses a function declaration or expression after the
+// function keyword.
+func (p *parser) parseFunction(n *Node, flags int, isAsync bool) *Node {
+	isStatement := flags&funcStatement != 0
+	isHangingStatement := flags&funcHangingStatement != 0
+	requireID := isStatement && flags&funcNullableID == 0
+
+	p.initFunction(n, isAsync)
+	if p.match(ttStar) && isHangingStatement {
+		p.unexpectedMsg(p.state.start, "Generators can only be declared at the top level or inside a block")
+	}
+	isGenerator := p.eat(ttStar)
+	n.set("generator", isGenerator)
+	if isStatement {
+		n.set("id", p.parseFunctionID(requireID))
+	}
+
+	oldInClassProperty := p.state.inClassProperty
+	oldYieldPos, oldAwaitPos := p.state.yieldPos, p.state.awaitPos
+	p.state.inClassProperty = false
+	p.state.yieldPos = -1
+	p.state.awaitPos = -1
+
+	p.scope.enter(functionFlags(isAsync, isGenerator))
+	if !isStatement {
+		n.set("id", p.parseFunctionID(false))
+	}
+	p.parseFunctionParams(n, false)
+	if isStatement {
+		p.parseFunctionBodyAndFinish(n, "FunctionDeclaration", false)
+	} else {
+		p.parseFunctionBodyAndFinish(n, "FunctionExpression", false)
+	}
+	p.scope.exit()
+
+	if isStatement && !isHangingStatement {
+		// validated after the body, as Babel does
+		p.checkFunctionStatementID(n)
+	}
+	p.state.inClassProperty = oldInClassProperty
+	p.state.yieldPos = oldYieldPos
+	p.state.awaitPos = oldAwaitPos
+	return n
+}
+
+func (p *parser) parseFunctionID(requireID bool) *Node {
+	if requireID || p.match(ttName) {
+		return p.parseIdentifier(false)
+	}
+	return nil
+}
+
+func (p *parser) checkFunctionStatementID(n *Node) {
+	id := n.node("id")
+	if id == nil {
+		return
+	}
+	bind := bindFunction
+	if p.state.strict || n.flag("generator") || n.flag("async") {
+		if p.scope.treatFunctionsAsVar() {
+			bind = bindVar
+		} else {
+			bind = bindLexical
+		}
+	}
+	p.checkLVal(id, bind, nil, "function name")
+}
+
+func (p *parser) parseFunctionParams(n *Node, allowModifiers bool) {
+	if k := n.str("kind"); k != "get" && k != "set" && p.isRelational("<") {
+		n.set("typeParameters", p.flowParseTypeParameterDeclaration())
+	}
+	oldInParameters := p.state.inParameters
+	p.state.inParameters = true
+	p.expect(ttParenL)
+	n.set("params", p.parseBindingList(ttParenR, false))
+	p.state.inParameters = oldInParameters
+	p.checkYieldAwaitInDefaultParams()
+}
+
+// parseClass parses a class declaration or expression after the decorators.
+func (p *parser) parseClass(n *Node, isStatement, optionalID bool) *Node {
+	p.next()
+	p.takeDecorators(n)
+
+	// a class is always strict mode code
+	oldStrict := p.state.strict
+	p.state.strict = true
+
+	p.parseClassID(n, isStatement, optionalID)
+	p.parseClassSuper(n)
+	n.set("body", p.parseClassBody(n.node("superClass") != nil))
+
+	p.state.strict = oldStrict
+	if isStatement {
+		return p.finishNode(n, "ClassDeclaration")
+	}
+	return p.finishNode(n, "ClassExpression")
+}
+
+func (p *parser) takeDecorators(n *Node) {
+	i := len(p.state.decoratorStack) - 1
+	if decorators := p.state.decoratorStack[i]; len(decorators) > 0 {
+		n.set("decorators", decorators)
+		p.resetStartLocationFromNode(n, decorators[0])
+		p.state.decoratorStack[i] = nil
+	}
+}
+
+func (p *parser) parseClassID(n *Node, isStatement, optionalID bool) {
+	if p.match(ttName) {
+		id := p.parseIdentifier(false)
+		n.set("id", id)
+		if isStatement {
+			p.checkLVal(id, bindClass, nil, "class name")
+		}
+	} else if optionalID || !isStatement {
+		n.set("id", nil)
+	} else {
+		p.unexpectedMsg(-1, "A class name is required")
+	}
+	if p.isRelational("<") {
+		n.set("typeParameters", p.flowParseTypeParameterDeclaration())
+	}
+}
+
+func (p *parser) parseClassSuper(n *Node) {
+	if p.eat(ttExtends) {
+		n.set("superClass", p.parseExprSubscripts(nil))
+	} else {
+		n.set("superClass", nil)
+	}
+	if n.node("superClass") != nil && p.isRelational("<") {
+		n.set("superTypeParameters", p.flowParseTypeParameterInstantiation())
+	}
+	if p.isContextual("implements") {
+		p.next()
+		implemented := []*Node{}
+		for {
+			impl := p.startNode()
+			impl.set("id", p.flowParseRestrictedIdentifier(true))
+			if p.isRelational("<") {
+				impl.set("typeParameters", p.flowParseTypeParameterInstantiation())
+			} else {
+				impl.set("typeParameters", nil)
+			}
+			implemented = append(implemented, p.finishNode(impl, "ClassImplements"))
+			if !p.eat(ttComma) {
+				break
+			}
+		}
+		n.set("implements", implemented)
+	}
+}
+
+// classBody collects the members of a class body.
+type classBody struct {
+	node           *Node
+	body           []*Node
+	hadConstructor bool
+}
+
+func (b *classBody) push(member *Node) {
+	b.body = append(b.body, member)
+	b.node.set("body", b.body)
+}
+
+func (p *parser) parseClassBody(constructorAllowsSuper bool) *Node {
+	p.state.classLevel++
+	var decorators []*Node
+	b := &classBody{node: p.startNode(), body: []*Node{}}
+	b.node.set("body", b.body)
+	p.expect(ttBraceL)
+
+	for !p.eat(ttBraceR) {
+		if p.eat(ttSemi) {
+			if len(decorators) > 0 {
+				p.raise(p.state.lastTokEnd, "Decorators must not be followed by a semicolon")
+			}
+			continue
+		}
+		if p.match(ttAt) {
+			decorators = append(decorators, p.parseDecorator())
+			continue
+		}
+		member := p.startNode()
+		// steal the decorators if there are any
+		if len(decorators) > 0 {
+			member.set("decorators", decorators)
+			p.resetStartLocationFromNode(member, decorators[0])
+			decorators = nil
+		}
+		p.parseClassMember(b, member, constructorAllowsSuper)
+		if member.str("kind") == "constructor" && len(member.list("decorators")) > 0 {
+			p.raise(member.Start, "Decorators can't be used with a constructor. Did you mean '@dec class { ... }'?")
+		}
+	}
+	if len(decorators) > 0 {
+		p.raise(p.state.start, "You have trailing decorators with no method")
+	}
+	p.state.classLevel--
+	return p.finishNode(b.node, "ClassBody")
+}
+
+func (p *parser) parseClassMember(b *classBody, member *Node, constructorAllowsSuper bool) {
+	isStatic := p.isContextual("static")
+	if isStatic && p.parseClassMemberFromModifier(b, member) {
+		// a member named "static"
+		return
+	}
+	p.parseClassMemberWithIsStatic(b, member, isStatic, constructorAllowsSuper)
+}
+
+func (p *parser) parseClassMemberFromModifier(b *classBody, member *Node) bool {
+	containsEsc := p.state.containsEsc
+	key := p.parseIdentifier(true) // eats the modifier
+	if p.isClassMethod() {
+		member.set("kind", "method")
+		member.set("computed", false)
+		member.set("key", key)
+		member.set("static", false)
+		p.pushClassMethod(b, member, false, false, false, false)
+		return true
+	} else if p.isClassProperty() {
+		member.set("computed", false)
+		member.set("key", key)
+		member.set("static", false)
+		b.push(p.parseClassProperty(member))
+		return true
+	} else if containsEsc {
+		p.unexpected(-1)
+	}
+	return false
+}
+
+func (p *parser) parseClassMemberWithIsStatic(b *classBody, member *Node, isStatic, constructorAllowsSuper bool) {
+	member.set("static", isStatic)
+
+	if p.eat(ttStar) {
+		// a generator
+		member.set("kind", "method")
+		p.parseClassPropertyName(member)
+		if member.node("key").Type == "PrivateName" {
+			p.pushClassPrivateMethod(b, member, true, false)
+			return
+		}
+		if p.isNonstaticConstructor(member) {
+			p.raise(member.node("key").Start, "Constructor can't be a generator")
+		}
+		p.pushClassMethod(b, member, true, false, false, false)
+		return
+	}
+
+	containsEsc := p.state.containsEsc
+	key := p.parseClassPropertyName(member)
+	isPrivate := key.Type == "PrivateName"
+	// not a computed key or a string literal
+	isSimple := key.Type == "Identifier"
+
+	if p.isClassMethod() {
+		member.set("kind", "method")
+		if isPrivate {
+			p.pushClassPrivateMethod(b, member, false, false)
+			return
+		}
+		isConstructor := p.isNonstaticConstructor(member)
+		allowsDirectSuper := false
+		if isConstructor {
+			member.set("kind", "constructor")
+			if member.has("decorators") {
+				p.raise(member.Start, "You can't attach decorators to a class constructor")
+			}
+			if b.hadConstructor {
+				p.raise(key.Start, "Duplicate constructor in the same class")
+			}
+			b.hadConstructor = true
+			allowsDirectSuper = constructorAllowsSuper
+		}
+		p.pushClassMethod(b, member, false, false, isConstructor, allowsDirectSuper)
+	} else if p.isClassProperty() {
+		if isPrivate {
+			b.push(p.parseClassPrivateProperty(member))
+		} else {
+			p.pushClassProperty(b, member)
+		}
+	} else if isSimple && key.str("name") == "async" && !containsEsc && !p.isLineTerminator() {
+		// an async method
+		isGenerator := p.eat(ttStar)
+		member.set("kind", "method")
+		// the name parsed so far was "async"
+		p.parseClassPropertyName(member)
+		if member.node("key").Type == "PrivateName" {
+			p.pushClassPrivateMethod(b, member, isGenerator, true)
+		} else {
+			if p.isNonstaticConstructor(member) {
+				p.raise(member.node("key").Start, "Constructor can't be an async function")
+			}
+			p.pushClassMethod(b, member, isGenerator, true, false, false)
+		}
+	} else if isSimple && (key.str("name") == "get" || key.str("name") == "set") && !containsEsc &&
+		!(p.match(ttStar) && p.isLineTerminator()) {
+		// a getter or a setter, "get\n*" is a property followed by a generator
+		member.set("kind", key.str("name"))
+		p.parseClassPropertyName(member)
+		if member.node("key").Type == "PrivateName" {
+			p.pushClassPrivateMethod(b, member, false, false)
+		} else {
+			if p.isNonstaticConstructor(member) {
+				p.raise(member.node("key").Start, "Constructor can't have get/set modifier")
+			}
+			p.pushClassMethod(b, member, false, false, false, false)
+		}
+		p.checkGetterSetterParams(member)
+	} else if p.isLineTerminator() {
+		// an uninitialized class property
+		if isPrivate {
+			b.push(p.parseClassPrivateProperty(member))
+		} else {
+			p.pushClassProperty(b, member)
+		}
+	} else {
+		p.unexpected(-1)
+	}
+}
+
+func (p *parser) parseClassPropertyName(member *Node) *Node {
+	key := p.parsePropertyName(member)
+	if !member.flag("computed") && member.flag("static") && (key.str("name") == "prototype" || key.get("value") == "prototype") {
+		p.raise(key.Start, "Classes may not have static property named prototype")
+	}
+	if key.Type == "PrivateName" && key.node("id").str("name") == "constructor" {
+		p.raise(key.Start, "Classes may not have a private field named '#constructor'")
+	}
+	return key
+}
+
+func (p *parser) isClassMethod() bool {
+	return p.isRelational("<") || p.match(ttParenL)
+}
+
+func (p *parser) isClassProperty() bool {
+	return p.match(ttColon) || p.match(ttEq) || p.match(ttSemi) || p.match(ttBraceR)
+}
+
+func (p *parser) isNonstaticConstructor(method *Node) bool {
+	if p.match(ttColon) || method.flag("computed") || method.flag("static") {
+		return false
+	}
+	key := method.node("key")
+	return key.str("name") == "constructor" || key.get("value") == "constructor"
+}
+
+func (p *parser) pushClassProperty(b *classBody, prop *Node) {
+	// methods may be named constructor, properties may not
+	if p.isNonstaticConstructor(prop) {
+		p.raise(prop.node("key").Start, "Classes may not have a non-static field named 'constructor'")
+	}
+	b.push(p.parseClassProperty(prop))
+}
+
+func (p *parser) pushClassMethod(b *classBody, method *Node, isGenerator, isAsync, isConstructor, allowsDirectSuper bool) {
+	p.methodTypeParameters(method)
+	b.push(p.parseMethod(method, isGenerator, isAsync, isConstructor, allowsDirectSuper, "ClassMethod", true))
+}
+
+func (p *parser) pushClassPrivateMethod(b *classBody, method *Node, isGenerator, isAsync bool) {
+	p.methodTypeParameters(method)
+	b.push(p.parseMethod(method, isGenerator, isAsync, false, false, "ClassPrivateMethod", true))
+}
+
+// methodTypeParameters drops the variance of a method, which is only allowed
+// for properties, and parses type parameters of the method.
+func (p *parser) methodTypeParameters(method *Node) {
+	if v := method.node("variance"); v != nil {
+		p.unexpected(v.Start)
+	}
+	method.del("variance")
+	if p.isRelational("<") {
+		method.set("typeParameters", p.flowParseTypeParameterDeclaration())
+	}
+}
+
+func (p *parser) parseClassPrivateProperty(n *Node) *Node {
+	if p.match(ttColon) {
+		n.set("typeAnnotation", p.flowParseTypeAnnotation())
+	}
+	p.state.inClassProperty = true
+	p.scope.enter(scopeClass | scopeSuper)
+	if p.eat(ttEq) {
+		n.set("value", p.parseMaybeAssign(false, nil, nil, nil))
+	} else {
+		n.set("value", nil)
+	}
+	p.semicolon()
+	p.state.inClassProperty = false
+	p.scope.exit()
+	return p.finishNode(n, "ClassPrivateProperty")
+}
+
+func (p *parser) parseClassProperty(n *Node) *Node {
+	if p.match(ttColon) {
+		n.set("typeAnnotation", p.flowParseTypeAnnotation())
+	}
+	p.state.inClassProperty = true
+	p.scope.enter(scopeClass | scopeSuper)
+	if p.eat(ttEq) {
+		n.set("value", p.parseMaybeAssign(false, nil, nil, nil))
+	} else {
+		n.set("value", nil)
+	}
+	p.semicolon()
+	p.state.inClassProperty = false
+	p.scope.exit()
+	return p.finishNode(n, "ClassProperty")
+}
+
+// parseExport parses an export declaration after the export keyword.
+func (p *parser) parseExport(n *Node) *Node {
+	res := p.parseExportBase(n)
+	if (res.Type == "ExportNamedDeclaration" || res.Type == "ExportAllDeclaration") && res.str("exportKind") == "" {
+		res.set("exportKind", "value")
+	}
+	return res
+}
+
+func (p *parser) parseExportBase(n *Node) *Node {
+	hasDefault := p.maybeParseExportDefaultSpecifier(n)
+	parseAfterDefault := !hasDefault || p.eat(ttComma)
+	hasStar := parseAfterDefault && p.eatExportStar(n)
+	hasNamespace := hasStar && p.maybeParseExportNamespaceSpecifier(n)
+	parseAfterNamespace := parseAfterDefault && (!hasNamespace || p.eat(ttComma))
+	isFromRequired := hasDefault || hasStar
+
+	if hasStar && !hasNamespace {
+		if hasDefault {
+			p.unexpected(-1)
+		}
+		p.parseExportFrom(n, true)
+		return p.finishNode(n, "ExportAllDeclaration")
+	}
+
+	hasSpecifiers := p.maybeParseExportNamedSpecifiers(n)
+	if (hasDefault && parseAfterDefault && !hasStar && !hasSpecifiers) ||
+		(hasNamespace && parseAfterNamespace && !hasSpecifiers) {
+		p.unexpectedType(-1, ttBraceL)
+	}
+
+	hasDeclaration := false
+	if isFromRequired || hasSpecifiers {
+		p.parseExportFrom(n, isFromRequired)
+	} else {
+		hasDeclaration = p.maybeParseExportDeclaration(n)
+	}
+	if isFromRequired || hasSpecifiers || hasDeclaration {
+		p.checkExport(n, true, false, n.node("source") != nil)
+		return p.finishNode(n, "ExportNamedDeclaration")
+	}
+
+	if p.eat(ttDefault) {
+		n.set("declaration", p.parseExportDefaultExpression())
+		p.checkExport(n, true, true, false)
+		return p.finishNode(n, "ExportDefaultDeclaration")
+	}
+	p.unexpectedType(-1, ttBraceL)
+	return nil
+}
+
+func (p *parser) eatExportStar(n *Node) bool {
+	if p.eat(ttStar) {
+		return true
+	}
+	if p.isContextual("type") && p.lookahead().typ == ttStar {
+		n.set("exportKind", "type")
+		p.next()
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) maybeParseExportDefaultSpecifier(n *Node) bool {
+	if p.isExportDefaultSpecifier() {
+		p.expectPlugin("exportDefaultFrom", -1)
+	}
+	return false
+}
+
+func (p *parser) maybeParseExportNamespaceSpecifier(n *Node) bool {
+	if p.isContextual("as") {
+		p.expectPlugin("exportNamespaceFrom", -1)
+	}
+	return false
+}
+
+func (p *parser) maybeParseExportNamedSpecifiers(n *Node) bool {
+	if p.match(ttBraceL) {
+		specs := append(n.list("specifiers"), p.parseExportSpecifiers()...)
+		n.set("specifiers", specs)
+		n.set("source", nil)
+		n.set("declaration", nil)
+		return true
+	}
+	return false
+}
+
+func (p *parser) maybeParseExportDeclaration(n *Node) bool {
+	if p.shouldParseExportDeclaration() {
+		if p.isContextual("async") {
+			// export async;
+			if next := p.lookahead(); next.typ != ttFunction {
+				p.unexpectedMsg(next.start, `Unexpected token, expected "function"`)
+			}
+		}
+		n.set("specifiers", []*Node{})
+		n.set("source", nil)
+		n.set("declaration", p.parseExportDeclaration(n))
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseExportDefaultExpression() *Node {
+	expr := p.startNode()
+	isAsync := p.isAsyncFunction()
+	if p.match(ttFunction) || isAsync {
+		p.next()
+		if isAsync {
+			p.next()
+		}
+		return p.parseFunction(expr, funcStatement|funcNullableID, isAsync)
+	} else if p.match(ttClass) {
+		return p.parseClass(expr, true, true)
+	} else if p.match(ttAt) {
+		p.unexpectedMsg(p.state.start, "Decorators must be placed *before* the 'export' keyword. "+
+			"You can set the 'decoratorsBeforeExport' option to false to use the 'export @decorator class {}' syntax")
+	} else if p.match(ttConst) || p.match(ttVar) || p.isLet("") {
+		p.raise(p.state.start, "Only expressions, functions or classes are allowed as the `default` export.")
+	}
+	res := p.parseMaybeAssign(false, nil, nil, nil)
+	p.semicolon()
+	return res
+}
+
+// parseExportDeclaration parses an exported declaration, including Flow
+// types, opaque types and interfaces.
+func (p *parser) parseExportDeclaration(n *Node) *Node {
+	switch {
+	case p.isContextual("type"):
+		n.set("exportKind", "type")
+		decl := p.startNode()
+		p.next()
+		if p.match(ttBraceL) {
+			// export type { foo, bar };
+			n.set("specifiers", p.parseExportSpecifiers())
+			p.parseExportFrom(n, false)
+			return nil
+		}
+		return p.flowParseTypeAlias(decl)
+	case p.isContextual("opaque"):
+		n.set("exportKind", "type")
+		decl := p.startNode()
+		p.next()
+		return p.flowParseOpaqueType(decl, false)
+	case p.isContextual("interface"):
+		n.set("exportKind", "type")
+		decl := p.startNode()
+		p.next()
+		return p.flowParseInterface(decl)
+	}
+	return p.parseStatement("", false)
+}
+
+func (p *parser) isExportDefaultSpecifier() bool {
+	if p.match(ttName) {
+		v, _ := p.state.value.(string)
+		switch v {
+		case "type", "interface", "opaque", "async", "let":
+			return false
+		}
+		return true
+	}
+	if !p.match(ttDefault) {
+		return false
+	}
+	l := p.lookahead()
+	return l.typ == ttComma || (l.typ == ttName && l.value == "from")
+}
+
+func (p *parser) parseExportFrom(n *Node, expect bool) {
+	if p.eatContextual("from") {
+		n.set("source", p.parseImportSource())
+		p.checkExport(n, false, false, false)
+	} else if expect {
+		p.unexpected(-1)
+	} else {
+		n.set("source", nil)
+	}
+	p.semicolon()
+}
+
+func (p *parser) shouldParseExportDeclaration() bool {
+	if p.isContextual("type") || p.isContextual("interface") || p.isContextual("opaque") {
+		return true
+	}
+	if p.match(ttAt) {
+		p.unexpectedMsg(p.state.start, "Decorators must be placed *before* the 'export' keyword. "+
+			"You can set the 'decoratorsBeforeExport' option to false to use the 'export @decorator class {}' syntax")
+	}
+	switch p.state.typ.keyword {
+	case "var", "const", "function", "class":
+		return true
+	}
+	return p.isLet("") || p.isAsyncFunction()
+}
+
+func (p *parser) checkExport(n *Node, checkNames, isDefault, isFrom bool) {
+	if checkNames {
+		if isDefault {
+			p.checkDuplicateExports(n, "default")
+		} else if specs := n.list("specifiers"); len(specs) > 0 {
+			for _, spec := range specs {
+				p.checkDuplicateExports(spec, spec.node("exported").str("name"))
+				if local := spec.node("local"); !isFrom && local != nil {
+					// keywords are not allowed as local names
+					p.checkReservedWord(local.str("name"), local.Start, true, false)
+					p.scope.checkLocalExport(local)
+				}
+			}
+		} else if decl := n.node("declaration"); decl != nil {
+			switch decl.Type {
+			case "FunctionDeclaration", "ClassDeclaration":
+				p.checkDuplicateExports(n, decl.node("id").str("name"))
+			case "VariableDeclaration":
+				for _, d := range decl.list("declarations") {
+					p.checkDeclaration(d.node("id"))
+				}
+			}
+		}
+	}
+
+	if i := len(p.state.decoratorStack) - 1; len(p.state.decoratorStack[i]) > 0 {
+		decl := n.node("declaration")
+		if decl == nil || (decl.Type != "ClassDeclaration" && decl.Type != "ClassExpression") {
+			p.raise(n.Start, "You can only use decorators on an export when exporting a class")
+		}
+		p.takeDecorators(decl)
+	}
+}
+
+func (p *parser) checkDeclaration(n *Node) {
+	switch n.Type {
+	case "Identifier":
+		p.checkDuplicateExports(n, n.str("name"))
+	case "ObjectPattern":
+		for _, prop := range n.list("properties") {
+			p.checkDeclaration(prop)
+		}
+	case "ArrayPattern":
+		for _, elem := range n.list("elements") {
+			if elem != nil {
+				p.checkDeclaration(elem)
+			}
+		}
+	case "ObjectProperty":
+		p.checkDeclaration(n.node("value"))
+	case "RestElement":
+		p.checkDeclaration(n.node("argument"))
+	case "AssignmentPattern":
+		p.checkDeclaration(n.node("left"))
+	}
+}
+
+func (p *parser) checkDuplicateExports(n *Node, name string) {
+	for _, e := range p.state.exportedIdentifiers {
+		if e != name {
+			continue
+		}
+		if name == "default" {
+			p.raise(n.Start, "Only one default export allowed per module.")
+		}
+		p.raise(n.Start, "`"+name+"` has already been exported. Exported identifiers must be unique.")
+	}
+	p.state.exportedIdentifiers = append(p.state.exportedIdentifiers, name)
+}
+
+func (p *parser) parseExportSpecifiers() []*Node {
+	specs := []*Node{}
+	first := true
+	needsFrom := false
+
+	// export { x, y as z } [from '...']
+	p.expect(ttBraceL)
+	for !p.eat(ttBraceR) {
+		if first {
+			first = false
+		} else {
+			p.expect(ttComma)
+			if p.eat(ttBraceR) {
+				break
+			}
+		}
+		isDefault := p.match(ttDefault)
+		if isDefault {
+			needsFrom = true
+		}
+		n := p.startNode()
+		local := p.parseIdentifier(isDefault)
+		n.set("local", local)
+		if p.eatContextual("as") {
+			n.set("exported", p.parseIdentifier(true))
+		} else {
+			n.set("exported", local.clone())
+		}
+		specs = append(specs, p.finishNode(n, "ExportSpecifier"))
+	}
+	if needsFrom && !p.isContextual("from") {
+		p.unexpected(-1)
+	}
+	return specs
+}
+
+func hasTypeImportKind(n *Node) bool {
+	k := n.str("importKind")
+	return k == "type" || k == "typeof"
+}
+
+func isMaybeDefaultImport(s *state) bool {
+	return (s.typ == ttName || s.typ.keyword != "") && s.value != "from"
+}
+
+// parseImport parses an import declaration after the import keyword.
+func (p *parser) parseImport(n *Node) *Node {
+	n.set("specifiers", []*Node{})
+	if !p.match(ttString) {
+		hasDefault := p.maybeParseDefaultImportSpecifier(n)
+		parseNext := !hasDefault || p.eat(ttComma)
+		hasStar := parseNext && p.maybeParseStarImportSpecifier(n)
+		if parseNext && !hasStar {
+			p.parseNamedImportSpecifiers(n)
+		}
+		p.expectContextual("from")
+	}
+	n.set("source", p.parseImportSource())
+	p.semicolon()
+	return p.finishNode(n, "ImportDeclaration")
+}
+
+func (p *parser) parseImportSource() *Node {
+	if !p.match(ttString) {
+		p.unexpected(-1)
+	}
+	return p.parseExprAtom(nil)
+}
+
+func (p *parser) pushSpecifier(n, spec *Node) {
+	n.set("specifiers", append(n.list("specifiers"), spec))
+}
+
+func (p *parser) parseImportSpecifierLocal(n, spec *Node, typ, contextDescription string) {
+	var local *Node
+	if hasTypeImportKind(n) {
+		local = p.flowParseRestrictedIdentifier(true)
+	} else {
+		local = p.parseIdentifier(false)
+	}
+	spec.set("local", local)
+	p.checkLVal(local, bindLexical, nil, contextDescription)
+	p.pushSpecifier(n, p.finishNode(spec, typ))
+}
+
+// maybeParseDefaultImportSpecifier parses an optional Flow import kind and a
+// default import.
+func (p *parser) maybeParseDefaultImportSpecifier(n *Node) bool {
+	n.set("importKind", "value")
+	kind := ""
+	if p.match(ttTypeof) {
+		kind = "typeof"
+	} else if p.isContextual("type") {
+		kind = "type"
+	}
+	if kind != "" {
+		lh := p.lookahead()
+		// import type * is not allowed
+		if kind == "type" && lh.typ == ttStar {
+			p.unexpected(lh.start)
+		}
+		if isMaybeDefaultImport(lh) || lh.typ == ttBraceL || lh.typ == ttStar {
+			p.next()
+			n.set("importKind", kind)
+		}
+	}
+
+	shouldParse := p.match(ttName)
+	if hasTypeImportKind(n) {
+		shouldParse = isMaybeDefaultImport(p.state)
+	}
+	if shouldParse {
+		p.parseImportSpecifierLocal(n, p.startNode(), "ImportDefaultSpecifier", "default import specifier")
+		return true
+	}
+	return false
+}
+
+func (p *parser) maybeParseStarImportSpecifier(n *Node) bool {
+	if p.match(ttStar) {
+		spec := p.startNode()
+		p.next()
+		p.expectContextual("as")
+		p.parseImportSpecifierLocal(n, spec, "ImportNamespaceSpecifier", "import namespace specifier")
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseNamedImportSpecifiers(n *Node) {
+	first := true
+	p.expect(ttBraceL)
+	for !p.eat(ttBraceR) {
+		if first {
+			first = false
+		} else {
+			// an attempt to destructure
+			if p.eat(ttColon) {
+				p.unexpectedMsg(-1, "ES2015 named imports do not destructure. Use another statement for destructuring after the import.")
+			}
+			p.expect(ttComma)
+			if p.eat(ttBraceR) {
+				break
+			}
+		}
+		p.parseImportSpecifier(n)
+	}
+}
+
+// parseImportSpecifier parses a named import, which may have a Flow kind.
+func (p *parser) parseImportSpecifier(n *Node) {
+	spec := p.startNode()
+	firstIdentLoc := p.state.start
+	firstIdent := p.parseIdentifier(true)
+
+	kind := ""
+	switch firstIdent.str("name") {
+	case "type", "typeof":
+		kind = firstIdent.str("name")
+	}
+
+	isBinding := false
+	if p.isContextual("as") && !p.isLookaheadContextual("as") {
+		asIdent := p.parseIdentifier(true)
+		if kind != "" && !p.match(ttName) && p.state.typ.keyword == "" {
+			// import {type as ,} or import {type as }
+			spec.set("imported", asIdent)
+			spec.set("importKind", kind)
+			spec.set("local", asIdent.clone())
+		} else {
+			// import {type as foo}
+			spec.set("imported", firstIdent)
+			spec.set("importKind", nil)
+			spec.set("local", p.parseIdentifier(false))
+		}
+	} else if kind != "" && (p.match(ttName) || p.state.typ.keyword != "") {
+		// import {type foo}
+		spec.set("imported", p.parseIdentifier(true))
+		spec.set("importKind", kind)
+		if p.eatContextual("as") {
+			spec.set("local", p.parseIdentifier(false))
+		} else {
+			isBinding = true
+			spec.set("local", spec.node("imported").clone())
+		}
+	} else {
+		isBinding = true
+		spec.set("imported", firstIdent)
+		spec.set("importKind", nil)
+		spec.set("local", firstIdent.clone())
+	}
+
+	nodeIsTypeImport := hasTypeImportKind(n)
+	specIsTypeImport := hasTypeImportKind(spec)
+	if nodeIsTypeImport && specIsTypeImport {
+		p.raise(firstIdentLoc, "The `type` and `typeof` keywords on named imports can only be used on regular `import` statements, not with `import type` and `import typeof` statements")
+	}
+	local := spec.node("local")
+	if nodeIsTypeImport || specIsTypeImport {
+		p.checkReservedType(local.str("name"), local.Start)
+	}
+	if isBinding && !nodeIsTypeImport && !specIsTypeImport {
+		p.checkReservedWord(local.str("name"), spec.Start, true, true)
+	}
+	p.checkLVal(local, bindLexical, nil, "import specifier")
+	p.pushSpecifier(n, p.finishNode(spec, "ImportSpecifier"))
+}
@@ -0,0 +1,1183 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// next reads the next token, remembering the current one as the last token.
+func (p *parser) next() {
+	if p.opts.Tokens && !p.isLookahead {
+		p.pushToken(p.state.typ.label, p.state.start, p.state.end, p.state.startLoc, p.state.endLoc)
+	}
+	p.state.lastTokEnd = p.state.end
+	p.state.lastTokStart = p.state.start
+	p.state.lastTokEndLoc = p.state.endLoc
+	p.state.lastTokStartLoc = p.state.startLoc
+	p.nextToken()
+}
+
+func (p *parser) eat(t *tokenType) bool {
+	if p.match(t) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) match(t *tokenType) bool {
+	return p.state.typ == t
+}
+
+// lookahead returns the state after the next token without consuming it.
+func (p *parser) lookahead() *state {
+	old := p.state
+	p.state = old.clone(true)
+	p.isLookahead = true
+	p.next()
+	p.isLookahead = false
+	cur := p.state
+	p.state = old
+	return cur
+}
+
+// setStrict toggles the strict mode and re-reads the current token, which
+// may be a number or a string that is invalid in the new mode.
+func (p *parser) setStrict(strict bool) {
+	p.state.strict = strict
+	if !p.match(ttNum) && !p.match(ttString) {
+		return
+	}
+	p.state.pos = p.state.start
+	for p.state.pos < p.state.lineStart {
+		p.state.lineStart = lastIndexOf(p.input, '\n', p.state.lineStart-2) + 1
+		p.state.curLine--
+	}
+	p.nextToken()
+}
+
+func lastIndexOf(s []uint16, c uint16, from int) int {
+	if from >= len(s) {
+		from = len(s) - 1
+	}
+	for i := from; i >= 0; i-- {
+		if s[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *parser) curContext() *tokContext {
+	return p.state.context[len(p.state.context)-1]
+}
+
+func (p *parser) pushContext(c *tokContext) {
+	p.state.context = append(p.state.context, c)
+}
+
+// popContext removes the current context. The base context is never removed,
+// even if the source closes more contexts than it opens.
+func (p *parser) popContext() *tokContext {
+	c := p.curContext()
+	if len(p.state.context) > 1 {
+		p.state.context = p.state.context[:len(p.state.context)-1]
+	}
+	return c
+}
+
+// charAt returns a code unit of the source, or -1 past the end.
+func (p *parser) charAt(i int) rune {
+	if i < 0 || i >= len(p.input) {
+		return -1
+	}
+	return rune(p.input[i])
+}
+
+// nextToken reads a token at the current position, skipping whitespace and
+// comments.
+func (p *parser) nextToken() {
+	p.tick()
+	cur := p.curContext()
+	if cur == nil || !cur.preserveSpace {
+		p.skipSpace()
+	}
+	p.state.containsOctal = false
+	p.state.octalPosition = -1
+	p.state.start = p.state.pos
+	p.state.startLoc = p.state.curPosition()
+	if p.state.pos >= len(p.input) {
+		p.finishToken(ttEOF, nil)
+		return
+	}
+	if cur.override != nil {
+		cur.override(p)
+	} else {
+		p.getTokenFromCode(codePointAt(p.input, p.state.pos))
+	}
+}
+
+func (p *parser) pushComment(block bool, text []uint16, start, end int, startLoc, endLoc Position) {
+	typ := "CommentLine"
+	if block {
+		typ = "CommentBlock"
+	}
+	c := &Node{Type: typ, Start: start, End: end, Loc: SourceLocation{Start: startLoc, End: endLoc}}
+	c.set("value", decode(text))
+	if p.opts.Tokens {
+		p.pushToken(typ, start, end, startLoc, endLoc)
+	}
+	p.state.comments = append(p.state.comments, c)
+	p.addComment(c)
+}
+
+// pushToken records a token in the same form as the native driver does.
+func (p *parser) pushToken(label string, start, end int, startLoc, endLoc Position) {
+	t := &Node{Type: "Token", Start: start, End: end, Loc: SourceLocation{Start: startLoc, End: endLoc}}
+	t.set("label", label)
+	p.state.tokens = append(p.state.tokens, t)
+}
+
+func (p *parser) skipBlockComment() {
+	startLoc := p.state.curPosition()
+	start := p.state.pos
+	p.state.pos += 2
+	end := indexOf(p.input, []uint16{'*', '/'}, p.state.pos)
+	if end < 0 {
+		p.raise(start, "Unterminated comment")
+	}
+	p.state.pos = end + 2
+	for i := start; i < p.state.pos; i++ {
+		c := p.input[i]
+		if c == '\r' && i+1 < p.state.pos && p.input[i+1] == '\n' {
+			i++
+		}
+		if isNewLine(rune(c)) {
+			p.state.curLine++
+			p.state.lineStart = i + 1
+		}
+	}
+	// the position is advanced for a lookahead, but the comment is not stored
+	if p.isLookahead {
+		return
+	}
+	p.pushComment(true, p.input[start+2:end], start, p.state.pos, startLoc, p.state.curPosition())
+}
+
+func indexOf(s, sub []uint16, from int) int {
+	for i := from; i+len(sub) <= len(s); i++ {
+		ok := true
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *parser) skipLineComment(startSkip int) {
+	start := p.state.pos
+	startLoc := p.state.curPosition()
+	p.state.pos += startSkip
+	ch := p.charAt(p.state.pos)
+	if p.state.pos < len(p.input) {
+		for !isNewLine(ch) {
+			p.state.pos++
+			if p.state.pos >= len(p.input) {
+				break
+			}
+			ch = p.charAt(p.state.pos)
+		}
+	}
+	if p.isLookahead {
+		return
+	}
+	p.pushComment(false, p.input[start+startSkip:p.state.pos], start, p.state.pos, startLoc, p.state.curPosition())
+}
+
+// skipSpace skips whitespace and comments.
+func (p *parser) skipSpace() {
+loop:
+	for p.state.pos < len(p.input) {
+		ch := rune(p.input[p.state.pos])
+		switch ch {
+		case ' ', 0xa0, '\t':
+			p.state.pos++
+		case '\r', '\n', 0x2028, 0x2029:
+			if ch == '\r' && p.charAt(p.state.pos+1) == '\n' {
+				p.state.pos++
+			}
+			p.state.pos++
+			p.state.curLine++
+			p.state.lineStart = p.state.pos
+		case '/':
+			switch p.charAt(p.state.pos + 1) {
+			case '*':
+				p.skipBlockComment()
+			case '/':
+				p.skipLineComment(2)
+			default:
+				break loop
+			}
+		default:
+			if !isWhitespace(ch) {
+				break loop
+			}
+			p.state.pos++
+		}
+	}
+}
+
+// finishToken is called at the end of every token. It sets the type and the
+// value of the token and updates the context.
+func (p *parser) finishToken(t *tokenType, val interface{}) {
+	if t != ttString && t != ttSemi && t != ttInterpreterDirective && !p.flowPragmaSet {
+		p.flowPragmaSet = true
+	}
+	p.state.end = p.state.pos
+	p.state.endLoc = p.state.curPosition()
+	prev := p.state.typ
+	p.state.typ = t
+	p.state.value = val
+	p.updateContext(prev)
+}
+
+func (p *parser) updateContext(prev *tokenType) {
+	// JSX overrides
+	if p.match(ttBraceL) {
+		switch p.curContext() {
+		case ctJSXOpenTag:
+			p.pushContext(ctBraceExpression)
+			p.state.exprAllowed = true
+			return
+		case ctJSXExpr:
+			p.pushContext(ctTemplateQuasi)
+			p.state.exprAllowed = true
+			return
+		}
+	} else if p.match(ttSlash) && prev == ttJSXTagStart {
+		// not an opening tag, but a closing one
+		p.popContext()
+		p.popContext()
+		p.pushContext(ctJSXCloseTag)
+		p.state.exprAllowed = false
+		return
+	}
+
+	t := p.state.typ
+	if t.keyword != "" && (prev == ttDot || prev == ttQuestionDot) {
+		p.state.exprAllowed = false
+	} else if t.updateContext != nil {
+		t.updateContext(p, prev)
+	} else {
+		p.state.exprAllowed = t.beforeExpr
+	}
+}
+
+func (p *parser) braceIsBlock(prev *tokenType) bool {
+	parent := p.curContext()
+	if parent == ctFunctionExpression || parent == ctFunctionStatement {
+		return true
+	}
+	if prev == ttColon && (parent == ctBraceStatement || parent == ctBraceExpression) {
+		return !parent.isExpr
+	}
+	// a name with an allowed expression is "yield" or "of"
+	if prev == ttReturn || (prev == ttName && p.state.exprAllowed) {
+		return hasLineBreak(p.input[p.state.lastTokEnd:p.state.start])
+	}
+	if prev == ttElse || prev == ttSemi || prev == ttEOF || prev == ttParenR {
+		return true
+	}
+	if prev == ttBraceL {
+		return parent == ctBraceStatement
+	}
+	if prev == ttVar || prev == ttConst || prev == ttName {
+		return false
+	}
+	if prev == ttRelational {
+		// class C<T> { ... }
+		return true
+	}
+	return !p.state.exprAllowed
+}
+
+func (p *parser) getTokenFromCode(code rune) {
+	next := p.charAt(p.state.pos + 1)
+
+	// Flow
+	if code == '{' && next == '|' {
+		p.finishOp(ttBraceBarL, 2)
+		return
+	} else if p.state.inType && (code == '>' || code == '<') {
+		p.finishOp(ttRelational, 1)
+		return
+	} else if code == '@' && next == '@' {
+		p.state.isIterator = true
+		p.readWord()
+		return
+	}
+
+	// JSX
+	if !p.state.inPropertyName {
+		ctx := p.curContext()
+		if ctx == ctJSXExpr {
+			p.jsxReadToken()
+			return
+		}
+		if ctx == ctJSXOpenTag || ctx == ctJSXCloseTag {
+			if isIdentifierStart(code) {
+				p.jsxReadWord()
+				return
+			}
+			if code == '>' {
+				p.state.pos++
+				p.finishToken(ttJSXTagEnd, nil)
+				return
+			}
+			if (code == '"' || code == '\'') && ctx == ctJSXOpenTag {
+				p.jsxReadString(code)
+				return
+			}
+		}
+		if code == '<' && p.state.exprAllowed {
+			p.state.pos++
+			p.finishToken(ttJSXTagStart, nil)
+			return
+		}
+	}
+	p.readTokenFromCode(code)
+}
+
+// readTokenFromCode reads a token without the JSX and Flow rules.
+func (p *parser) readTokenFromCode(code rune) {
+	next := p.charAt(p.state.pos + 1)
+	switch code {
+	case '#':
+		p.readTokenNumberSign()
+		return
+	case '.':
+		p.readTokenDot()
+		return
+	case '(':
+		p.state.pos++
+		p.finishToken(ttParenL, nil)
+		return
+	case ')':
+		p.state.pos++
+		p.finishToken(ttParenR, nil)
+		return
+	case ';':
+		p.state.pos++
+		p.finishToken(ttSemi, nil)
+		return
+	case ',':
+		p.state.pos++
+		p.finishToken(ttComma, nil)
+		return
+	case '[':
+		p.state.pos++
+		p.finishToken(ttBracketL, nil)
+		return
+	case ']':
+		p.state.pos++
+		p.finishToken(ttBracketR, nil)
+		return
+	case '{':
+		p.state.pos++
+		p.finishToken(ttBraceL, nil)
+		return
+	case '}':
+		p.state.pos++
+		p.finishToken(ttBraceR, nil)
+		return
+	case ':':
+		if next == ':' {
+			p.finishOp(ttDoubleColon, 2)
+		} else {
+			p.state.pos++
+			p.finishToken(ttColon, nil)
+		}
+		return
+	case '?':
+		p.readTokenQuestion()
+		return
+	case '@':
+		p.state.pos++
+		p.finishToken(ttAt, nil)
+		return
+	case '`':
+		p.state.pos++
+		p.finishToken(ttBackQuote, nil)
+		return
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		if code == '0' {
+			switch next {
+			case 'x', 'X':
+				p.readRadixNumber(16)
+				return
+			case 'o', 'O':
+				p.readRadixNumber(8)
+				return
+			case 'b', 'B':
+				p.readRadixNumber(2)
+				return
+			}
+		}
+		p.readNumber(false)
+		return
+	case '"', '\'':
+		p.readString(code)
+		return
+	case '/':
+		p.readTokenSlash()
+		return
+	case '%', '*':
+		p.readTokenMultModulo(code)
+		return
+	case '|', '&':
+		p.readTokenPipeAmp(code)
+		return
+	case '^':
+		p.readTokenCaret()
+		return
+	case '+', '-':
+		p.readTokenPlusMin(code)
+		return
+	case '<', '>':
+		p.readTokenLtGt(code)
+		return
+	case '=', '!':
+		p.readTokenEqExcl(code)
+		return
+	case '~':
+		p.finishOp(ttTilde, 1)
+		return
+	case '\\':
+		p.readWord()
+		return
+	default:
+		if isIdentifierStart(code) {
+			p.readWord()
+			return
+		}
+	}
+	p.raise(p.state.pos, fmt.Sprintf("Unexpected character '%s'", string(code)))
+}
+
+func (p *parser) finishOp(t *tokenType, size int) {
+	str := decode(p.input[p.state.pos : p.state.pos+size])
+	p.state.pos += size
+	p.finishToken(t, str)
+}
+
+func (p *parser) readTokenInterpreter() bool {
+	if p.state.pos != 0 || len(p.input) < 2 {
+		return false
+	}
+	start := p.state.pos
+	p.state.pos++
+	ch := p.charAt(p.state.pos)
+	if ch != '!' {
+		return false
+	}
+	for !isNewLine(ch) {
+		p.state.pos++
+		if p.state.pos >= len(p.input) {
+			break
+		}
+		ch = p.charAt(p.state.pos)
+	}
+	p.finishToken(ttInterpreterDirective, decode(p.input[start+2:p.state.pos]))
+	return true
+}
+
+func (p *parser) readTokenNumberSign() {
+	if p.state.pos == 0 && p.readTokenInterpreter() {
+		return
+	}
+	if isDigit(p.charAt(p.state.pos + 1)) {
+		p.raise(p.state.pos, "Unexpected digit after hash token")
+	}
+	if p.state.classLevel > 0 {
+		p.state.pos++
+		p.finishToken(ttHash, nil)
+		return
+	}
+	p.raise(p.state.pos, "Unexpected character '#'")
+}
+
+func (p *parser) readTokenDot() {
+	next := p.charAt(p.state.pos + 1)
+	if isDigit(next) {
+		p.readNumber(true)
+		return
+	}
+	if next == '.' && p.charAt(p.state.pos+2) == '.' {
+		p.state.pos += 3
+		p.finishToken(ttEllipsis, nil)
+	} else {
+		p.state.pos++
+		p.finishToken(ttDot, nil)
+	}
+}
+
+func (p *parser) readTokenSlash() {
+	if p.state.exprAllowed && !p.state.inType {
+		p.state.pos++
+		p.readRegexp()
+		return
+	}
+	if p.charAt(p.state.pos+1) == '=' {
+		p.finishOp(ttAssign, 2)
+	} else {
+		p.finishOp(ttSlash, 1)
+	}
+}
+
+func (p *parser) readTokenMultModulo(code rune) {
+	t := ttModulo
+	if code == '*' {
+		t = ttStar
+	}
+	width := 1
+	next := p.charAt(p.state.pos + 1)
+	exprAllowed := p.state.exprAllowed
+	if code == '*' && next == '*' {
+		width++
+		next = p.charAt(p.state.pos + 2)
+		t = ttExponent
+	}
+	if next == '=' && !exprAllowed {
+		width++
+		t = ttAssign
+	}
+	p.finishOp(t, width)
+}
+
+func (p *parser) readTokenPipeAmp(code rune) {
+	next := p.charAt(p.state.pos + 1)
+	if next == code {
+		if p.charAt(p.state.pos+2) == '=' {
+			p.finishOp(ttAssign, 3)
+		} else if code == '|' {
+			p.finishOp(ttLogicalOR, 2)
+		} else {
+			p.finishOp(ttLogicalAND, 2)
+		}
+		return
+	}
+	if code == '|' {
+		if next == '>' {
+			p.finishOp(ttPipeline, 2)
+			return
+		} else if next == '}' {
+			p.finishOp(ttBraceBarR, 2)
+			return
+		}
+	}
+	if next == '=' {
+		p.finishOp(ttAssign, 2)
+		return
+	}
+	if code == '|' {
+		p.finishOp(ttBitwiseOR, 1)
+	} else {
+		p.finishOp(ttBitwiseAND, 1)
+	}
+}
+
+func (p *parser) readTokenCaret() {
+	if p.charAt(p.state.pos+1) == '=' {
+		p.finishOp(ttAssign, 2)
+	} else {
+		p.finishOp(ttBitwiseXOR, 1)
+	}
+}
+
+func (p *parser) readTokenPlusMin(code rune) {
+	next := p.charAt(p.state.pos + 1)
+	if next == code {
+		if next == '-' && !p.inModule && p.charAt(p.state.pos+2) == '>' &&
+			(p.state.lastTokEnd == 0 || hasLineBreak(p.input[p.state.lastTokEnd:p.state.pos])) {
+			// a "-->" line comment
+			p.skipLineComment(3)
+			p.skipSpace()
+			p.nextToken()
+			return
+		}
+		p.finishOp(ttIncDec, 2)
+		return
+	}
+	if next == '=' {
+		p.finishOp(ttAssign, 2)
+	} else {
+		p.finishOp(ttPlusMin, 1)
+	}
+}
+
+func (p *parser) readTokenLtGt(code rune) {
+	next := p.charAt(p.state.pos + 1)
+	size := 1
+	if next == code {
+		size = 2
+		if code == '>' && p.charAt(p.state.pos+2) == '>' {
+			size = 3
+		}
+		if p.charAt(p.state.pos+size) == '=' {
+			p.finishOp(ttAssign, size+1)
+			return
+		}
+		p.finishOp(ttBitShift, size)
+		return
+	}
+	if next == '!' && code == '<' && !p.inModule && p.charAt(p.state.pos+2) == '-' && p.charAt(p.state.pos+3) == '-' {
+		// a "<!--" line comment
+		p.skipLineComment(4)
+		p.skipSpace()
+		p.nextToken()
+		return
+	}
+	if next == '=' {
+		size = 2
+	}
+	p.finishOp(ttRelational, size)
+}
+
+func (p *parser) readTokenEqExcl(code rune) {
+	next := p.charAt(p.state.pos + 1)
+	if next == '=' {
+		if p.charAt(p.state.pos+2) == '=' {
+			p.finishOp(ttEquality, 3)
+		} else {
+			p.finishOp(ttEquality, 2)
+		}
+		return
+	}
+	if code == '=' && next == '>' {
+		p.state.pos += 2
+		p.finishToken(ttArrow, nil)
+		return
+	}
+	if code == '=' {
+		p.finishOp(ttEq, 1)
+	} else {
+		p.finishOp(ttBang, 1)
+	}
+}
+
+func (p *parser) readTokenQuestion() {
+	next := p.charAt(p.state.pos + 1)
+	next2 := p.charAt(p.state.pos + 2)
+	if next == '?' && !p.state.inType {
+		if next2 == '=' {
+			p.finishOp(ttAssign, 3)
+		} else {
+			p.finishOp(ttNullishCoalescing, 2)
+		}
+	} else if next == '.' && !isDigit(next2) {
+		// "?." not followed by a number
+		p.state.pos += 2
+		p.finishToken(ttQuestionDot, nil)
+	} else {
+		p.state.pos++
+		p.finishToken(ttQuestion, nil)
+	}
+}
+
+// regexpValue is the value of a regular expression token.
+type regexpValue struct {
+	pattern string
+	flags   string
+}
+
+func (p *parser) readRegexp() {
+	start := p.state.pos
+	escaped, inClass := false, false
+	for {
+		if p.state.pos >= len(p.input) {
+			p.raise(start, "Unterminated regular expression")
+		}
+		ch := rune(p.input[p.state.pos])
+		if isNewLine(ch) {
+			p.raise(start, "Unterminated regular expression")
+		}
+		if escaped {
+			escaped = false
+		} else {
+			if ch == '[' {
+				inClass = true
+			} else if ch == ']' && inClass {
+				inClass = false
+			} else if ch == '/' && !inClass {
+				break
+			}
+			escaped = ch == '\\'
+		}
+		p.state.pos++
+	}
+	content := decode(p.input[start:p.state.pos])
+	p.state.pos++
+
+	var mods []rune
+	for p.state.pos < len(p.input) {
+		ch := codePointAt(p.input, p.state.pos)
+		if strings.ContainsRune("gmsiyu", ch) {
+			for _, m := range mods {
+				if m == ch {
+					p.raise(p.state.pos+1, "Duplicate regular expression flag")
+				}
+			}
+		} else if isIdentifierChar(ch) || ch == '\\' {
+			p.raise(p.state.pos+1, "Invalid regular expression flag")
+		} else {
+			break
+		}
+		p.state.pos++
+		mods = append(mods, ch)
+	}
+	p.finishToken(ttRegexp, regexpValue{pattern: content, flags: string(mods)})
+}
+
+var (
+	forbiddenSeparatorSiblingsDec = []rune{'.', 'B', 'E', 'O', '_', 'b', 'e', 'o'}
+	forbiddenSeparatorSiblingsHex = []rune{'.', 'X', '_', 'x'}
+)
+
+func containsRune(l []rune, c rune) bool {
+	for _, r := range l {
+		if r == c {
+			return true
+		}
+	}
+	return false
+}
+
+func digitVal(c rune) int {
+	switch {
+	case c >= 'a':
+		return int(c-'a') + 10
+	case c >= 'A':
+		return int(c-'A') + 10
+	case isDigit(c):
+		return int(c - '0')
+	}
+	return math.MaxInt32
+}
+
+// readInt reads an integer in a given radix. If n is positive, exactly n
+// digits are read. It returns false if no digits were read.
+func (p *parser) readInt(radix, n int) (float64, bool) {
+	start := p.state.pos
+	forbidden := forbiddenSeparatorSiblingsDec
+	if radix == 16 {
+		forbidden = forbiddenSeparatorSiblingsHex
+	}
+	total := 0.0
+	for i := 0; n <= 0 || i < n; i++ {
+		code := p.charAt(p.state.pos)
+		if code == '_' {
+			prev := p.charAt(p.state.pos - 1)
+			next := p.charAt(p.state.pos + 1)
+			if next < 0 || digitVal(next) >= radix || (radix != 16 && next > '9') {
+				p.raise(p.state.pos, "Invalid or unexpected token")
+			}
+			if containsRune(forbidden, prev) || containsRune(forbidden, next) {
+				p.raise(p.state.pos, "Invalid or unexpected token")
+			}
+			p.state.pos++
+			continue
+		}
+		val := math.MaxInt32
+		if code >= 0 {
+			val = digitVal(code)
+		}
+		if val >= radix {
+			break
+		}
+		p.state.pos++
+		total = total*float64(radix) + float64(val)
+	}
+	if p.state.pos == start || (n > 0 && p.state.pos-start != n) {
+		return 0, false
+	}
+	return total, true
+}
+
+func (p *parser) readRadixNumber(radix int) {
+	start := p.state.pos
+	isBigInt := false
+	p.state.pos += 2 // 0x
+	val, ok := p.readInt(radix, 0)
+	if !ok {
+		p.raise(p.state.start+2, "Expected number in radix "+strconv.Itoa(radix))
+	}
+	if p.charAt(p.state.pos) == 'n' {
+		p.state.pos++
+		isBigInt = true
+	}
+	if isIdentifierStart(codePointAt(p.input, p.state.pos)) {
+		p.raise(p.state.pos, "Identifier directly after number")
+	}
+	if isBigInt {
+		str := strings.NewReplacer("_", "", "n", "").Replace(decode(p.input[start:p.state.pos]))
+		p.finishToken(ttBigint, str)
+		return
+	}
+	p.finishToken(ttNum, val)
+}
+
+func (p *parser) readNumber(startsWithDot bool) {
+	start := p.state.pos
+	isFloat, isBigInt := false, false
+	if !startsWithDot {
+		if _, ok := p.readInt(10, 0); !ok {
+			p.raise(start, "Invalid number")
+		}
+	}
+	octal := p.state.pos-start >= 2 && p.input[start] == '0'
+	if octal {
+		if p.state.strict {
+			p.raise(start, "Legacy octal literals are not allowed in strict mode")
+		}
+		for _, c := range p.input[start:p.state.pos] {
+			if c == '8' || c == '9' {
+				octal = false
+			}
+		}
+	}
+	next := p.charAt(p.state.pos)
+	if next == '.' && !octal {
+		p.state.pos++
+		p.readInt(10, 0)
+		isFloat = true
+		next = p.charAt(p.state.pos)
+	}
+	if (next == 'E' || next == 'e') && !octal {
+		p.state.pos++
+		next = p.charAt(p.state.pos)
+		if next == '+' || next == '-' {
+			p.state.pos++
+		}
+		if _, ok := p.readInt(10, 0); !ok {
+			p.raise(start, "Invalid number")
+		}
+		isFloat = true
+		next = p.charAt(p.state.pos)
+	}
+	if next == 'n' {
+		if isFloat || octal {
+			p.raise(start, "Invalid BigIntLiteral")
+		}
+		p.state.pos++
+		isBigInt = true
+	}
+	if isIdentifierStart(codePointAt(p.input, p.state.pos)) {
+		p.raise(p.state.pos, "Identifier directly after number")
+	}
+	str := strings.NewReplacer("_", "", "n", "").Replace(decode(p.input[start:p.state.pos]))
+	if isBigInt {
+		p.finishToken(ttBigint, str)
+		return
+	}
+	var val float64
+	if octal {
+		v, _ := strconv.ParseUint(str, 8, 64)
+		val = float64(v)
+	} else {
+		// out of range values are returned as infinities
+		val, _ = strconv.ParseFloat(str, 64)
+	}
+	p.finishToken(ttNum, val)
+}
+
+// readCodePoint reads a code point of an escape sequence \u{XXXX} or \uXXXX.
+// It returns -1 if the escape is invalid and throwOnInvalid is not set.
+func (p *parser) readCodePoint(throwOnInvalid bool) rune {
+	ch := p.charAt(p.state.pos)
+	var code rune
+	if ch == '{' {
+		p.state.pos++
+		codePos := p.state.pos
+		end := indexOf(p.input, []uint16{'}'}, p.state.pos)
+		code = p.readHexChar(end-p.state.pos, throwOnInvalid)
+		p.state.pos++
+		if code < 0 {
+			// point to the backslash instead of the "u"
+			p.state.invalidTemplateEscapePosition--
+		} else if code > 0x10ffff {
+			if throwOnInvalid {
+				p.raise(codePos, "Code point out of bounds")
+			}
+			p.state.invalidTemplateEscapePosition = codePos - 2
+			return -1
+		}
+	} else {
+		code = p.readHexChar(4, throwOnInvalid)
+	}
+	return code
+}
+
+func (p *parser) readString(quote rune) {
+	var out []uint16
+	p.state.pos++
+	chunkStart := p.state.pos
+	for {
+		if p.state.pos >= len(p.input) {
+			p.raise(p.state.start, "Unterminated string constant")
+		}
+		ch := rune(p.input[p.state.pos])
+		if ch == quote {
+			break
+		}
+		if ch == '\\' {
+			out = append(out, p.input[chunkStart:p.state.pos]...)
+			esc, _ := p.readEscapedChar(false)
+			out = append(out, esc...)
+			chunkStart = p.state.pos
+		} else if ch == 0x2028 || ch == 0x2029 {
+			p.state.pos++
+			p.state.curLine++
+		} else if isNewLine(ch) {
+			p.raise(p.state.start, "Unterminated string constant")
+		} else {
+			p.state.pos++
+		}
+	}
+	out = append(out, p.input[chunkStart:p.state.pos]...)
+	p.state.pos++
+	p.finishToken(ttString, decode(out))
+}
+
+// readTmplToken reads a template string chunk. The value is nil if the chunk
+// contains an invalid escape.
+func (p *parser) readTmplToken() {
+	var out []uint16
+	chunkStart := p.state.pos
+	containsInvalid := false
+	for {
+		if p.state.pos >= len(p.input) {
+			p.raise(p.state.start, "Unterminated template")
+		}
+		ch := rune(p.input[p.state.pos])
+		if ch == '`' || (ch == '$' && p.charAt(p.state.pos+1) == '{') {
+			if p.state.pos == p.state.start && p.match(ttTemplate) {
+				if ch == '$' {
+					p.state.pos += 2
+					p.finishToken(ttDollarBraceL, nil)
+				} else {
+					p.state.pos++
+					p.finishToken(ttBackQuote, nil)
+				}
+				return
+			}
+			out = append(out, p.input[chunkStart:p.state.pos]...)
+			if containsInvalid {
+				p.finishToken(ttTemplate, nil)
+			} else {
+				p.finishToken(ttTemplate, decode(out))
+			}
+			return
+		}
+		if ch == '\\' {
+			out = append(out, p.input[chunkStart:p.state.pos]...)
+			esc, ok := p.readEscapedChar(true)
+			if !ok {
+				containsInvalid = true
+			} else {
+				out = append(out, esc...)
+			}
+			chunkStart = p.state.pos
+		} else if isNewLine(ch) {
+			out = append(out, p.input[chunkStart:p.state.pos]...)
+			p.state.pos++
+			switch ch {
+			case '\r':
+				if p.charAt(p.state.pos) == '\n' {
+					p.state.pos++
+				}
+				out = append(out, '\n')
+			case '\n':
+				out = append(out, '\n')
+			default:
+				out = append(out, uint16(ch))
+			}
+			p.state.curLine++
+			p.state.lineStart = p.state.pos
+			chunkStart = p.state.pos
+		} else {
+			p.state.pos++
+		}
+	}
+}
+
+// readEscapedChar reads an escape sequence. It returns false if the escape is
+// invalid in a template.
+func (p *parser) readEscapedChar(inTemplate bool) ([]uint16, bool) {
+	throwOnInvalid := !inTemplate
+	p.state.pos++
+	ch := p.charAt(p.state.pos)
+	p.state.pos++
+	switch ch {
+	case 'n':
+		return []uint16{'\n'}, true
+	case 'r':
+		return []uint16{'\r'}, true
+	case 'x':
+		code := p.readHexChar(2, throwOnInvalid)
+		if code < 0 {
+			return nil, false
+		}
+		return []uint16{uint16(code)}, true
+	case 'u':
+		code := p.readCodePoint(throwOnInvalid)
+		if code < 0 {
+			return nil, false
+		}
+		return appendRune(nil, code), true
+	case 't':
+		return []uint16{'\t'}, true
+	case 'b':
+		return []uint16{'\b'}, true
+	case 'v':
+		return []uint16{'\v'}, true
+	case 'f':
+		return []uint16{'\f'}, true
+	case '\r', '\n':
+		if ch == '\r' && p.charAt(p.state.pos) == '\n' {
+			p.state.pos++
+		}
+		p.state.lineStart = p.state.pos
+		p.state.curLine++
+		return nil, true
+	case 0x2028, 0x2029:
+		return nil, true
+	}
+	if ch >= '0' && ch <= '7' {
+		codePos := p.state.pos - 1
+		end := codePos
+		for end < len(p.input) && end < codePos+3 && p.input[end] >= '0' && p.input[end] <= '7' {
+			end++
+		}
+		octalStr := decode(p.input[codePos:end])
+		octal, _ := strconv.ParseUint(octalStr, 8, 32)
+		if octal > 255 {
+			octalStr = octalStr[:len(octalStr)-1]
+			octal, _ = strconv.ParseUint(octalStr, 8, 32)
+		}
+		p.state.pos += len(octalStr) - 1
+		next := p.charAt(p.state.pos)
+		if octalStr != "0" || next == '8' || next == '9' {
+			if inTemplate {
+				p.state.invalidTemplateEscapePosition = codePos
+				return nil, false
+			} else if p.state.strict {
+				p.raise(codePos, "Octal literal in strict mode")
+			} else if !p.state.containsOctal {
+				// strict mode may be enabled by a directive later
+				p.state.containsOctal = true
+				p.state.octalPosition = codePos
+			}
+		}
+		return []uint16{uint16(octal)}, true
+	}
+	if ch < 0 {
+		return nil, true
+	}
+	return []uint16{uint16(ch)}, true
+}
+
+// readHexChar reads n hexadecimal digits. It returns -1 if the digits are
+// invalid and throwOnInvalid is not set.
+func (p *parser) readHexChar(n int, throwOnInvalid bool) rune {
+	codePos := p.state.pos
+	if n <= 0 {
+		// an empty or unterminated \u{} escape
+		n = -1
+	}
+	v, ok := p.readIntLen(16, n)
+	if !ok {
+		if throwOnInvalid {
+			p.raise(codePos, "Bad character escape sequence")
+		}
+		p.state.pos = codePos - 1
+		p.state.invalidTemplateEscapePosition = codePos - 1
+		return -1
+	}
+	if v > math.MaxInt32 {
+		v = math.MaxInt32
+	}
+	return rune(v)
+}
+
+// readIntLen is readInt that fails for n < 0.
+func (p *parser) readIntLen(radix, n int) (float64, bool) {
+	if n < 0 {
+		return 0, false
+	}
+	return p.readInt(radix, n)
+}
+
+// readWord1 reads an identifier, processing unicode escapes.
+func (p *parser) readWord1() string {
+	var word []uint16
+	p.state.containsEsc = false
+	start := p.state.pos
+	chunkStart := p.state.pos
+	for p.state.pos < len(p.input) {
+		ch := codePointAt(p.input, p.state.pos)
+		if isIdentifierChar(ch) {
+			p.state.pos += runeLen(ch)
+		} else if p.state.isIterator && ch == '@' {
+			p.state.pos++
+		} else if ch == '\\' {
+			p.state.containsEsc = true
+			word = append(word, p.input[chunkStart:p.state.pos]...)
+			escStart := p.state.pos
+			check := isIdentifierChar
+			if p.state.pos == start {
+				check = isIdentifierStart
+			}
+			p.state.pos++
+			if p.charAt(p.state.pos) != 'u' {
+				p.raise(p.state.pos, "Expecting Unicode escape sequence \\uXXXX")
+			}
+			p.state.pos++
+			esc := p.readCodePoint(true)
+			if !check(esc) {
+				p.raise(escStart, "Invalid Unicode escape")
+			}
+			word = appendRune(word, esc)
+			chunkStart = p.state.pos
+		} else {
+			break
+		}
+	}
+	word = append(word, p.input[chunkStart:p.state.pos]...)
+	return decode(word)
+}
+
+func isIteratorName(word string) bool {
+	return word == "@@iterator" || word == "@@asyncIterator"
+}
+
+// readWord reads an identifier or a keyword.
+func (p *parser) readWord() {
+	word := p.readWord1()
+	t := keywords[word]
+	if t == nil {
+		t = ttName
+	}
+	// @@iterator and @@asyncIterator are allowed as identifiers in types only
+	if p.state.isIterator && (!isIteratorName(word) || !p.state.inType) {
+		p.raise(p.state.pos, "Invalid identifier "+word)
+	}
+	p.finishToken(t, word)
+}
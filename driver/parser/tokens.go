@@ -0,0 +1,289 @@
+package parser
+
+// tokenType describes a kind of tokens, like the token types of Babel.
+type tokenType struct {
+	label   string
+	keyword string
+
+	beforeExpr       bool
+	startsExpr       bool
+	rightAssociative bool
+	isLoop           bool
+	isAssign         bool
+	prefix           bool
+	postfix          bool
+	binop            int // -1 for non-binary operators
+
+	// updateContext is called when the token is read. The default is to
+	// allow an expression after the token if it has beforeExpr set.
+	updateContext func(p *parser, prev *tokenType)
+}
+
+type tokenOpts struct {
+	keyword          string
+	beforeExpr       bool
+	startsExpr       bool
+	rightAssociative bool
+	isLoop           bool
+	isAssign         bool
+	prefix           bool
+	postfix          bool
+	binop            int
+}
+
+func newToken(label string, o tokenOpts) *tokenType {
+	if o.binop == 0 {
+		o.binop = -1
+	}
+	return &tokenType{
+		label:            label,
+		keyword:          o.keyword,
+		beforeExpr:       o.beforeExpr,
+		startsExpr:       o.startsExpr,
+		rightAssociative: o.rightAssociative,
+		isLoop:           o.isLoop,
+		isAssign:         o.isAssign,
+		prefix:           o.prefix,
+		postfix:          o.postfix,
+		binop:            o.binop,
+	}
+}
+
+// binop creates a binary operator token. Precedences are shifted by one,
+// since zero is reserved for the default.
+func binop(label string, prec int) *tokenType {
+	t := newToken(label, tokenOpts{beforeExpr: true})
+	t.binop = prec
+	return t
+}
+
+var (
+	ttNum    = newToken("num", tokenOpts{startsExpr: true})
+	ttBigint = newToken("bigint", tokenOpts{startsExpr: true})
+	ttRegexp = newToken("regexp", tokenOpts{startsExpr: true})
+	ttString = newToken("string", tokenOpts{startsExpr: true})
+	ttName   = newToken("name", tokenOpts{startsExpr: true})
+	ttEOF    = newToken("eof", tokenOpts{})
+
+	ttBracketL     = newToken("[", tokenOpts{beforeExpr: true, startsExpr: true})
+	ttBracketR     = newToken("]", tokenOpts{})
+	ttBraceL       = newToken("{", tokenOpts{beforeExpr: true, startsExpr: true})
+	ttBraceBarL    = newToken("{|", tokenOpts{beforeExpr: true, startsExpr: true})
+	ttBraceR       = newToken("}", tokenOpts{})
+	ttBraceBarR    = newToken("|}", tokenOpts{})
+	ttParenL       = newToken("(", tokenOpts{beforeExpr: true, startsExpr: true})
+	ttParenR       = newToken(")", tokenOpts{})
+	ttComma        = newToken(",", tokenOpts{beforeExpr: true})
+	ttSemi         = newToken(";", tokenOpts{beforeExpr: true})
+	ttColon        = newToken(":", tokenOpts{beforeExpr: true})
+	ttDoubleColon  = newToken("::", tokenOpts{beforeExpr: true})
+	ttDot          = newToken(".", tokenOpts{})
+	ttQuestion     = newToken("?", tokenOpts{beforeExpr: true})
+	ttQuestionDot  = newToken("?.", tokenOpts{})
+	ttArrow        = newToken("=>", tokenOpts{beforeExpr: true})
+	ttTemplate     = newToken("template", tokenOpts{})
+	ttEllipsis     = newToken("...", tokenOpts{beforeExpr: true})
+	ttBackQuote    = newToken("`", tokenOpts{startsExpr: true})
+	ttDollarBraceL = newToken("${", tokenOpts{beforeExpr: true, startsExpr: true})
+	ttAt           = newToken("@", tokenOpts{})
+	ttHash         = newToken("#", tokenOpts{startsExpr: true})
+
+	ttInterpreterDirective = newToken("#!...", tokenOpts{})
+
+	ttEq                = newToken("=", tokenOpts{beforeExpr: true, isAssign: true})
+	ttAssign            = newToken("_=", tokenOpts{beforeExpr: true, isAssign: true})
+	ttIncDec            = newToken("++/--", tokenOpts{prefix: true, postfix: true, startsExpr: true})
+	ttBang              = newToken("!", tokenOpts{beforeExpr: true, prefix: true, startsExpr: true})
+	ttTilde             = newToken("~", tokenOpts{beforeExpr: true, prefix: true, startsExpr: true})
+	ttPipeline          = binop("|>", 0)
+	ttNullishCoalescing = binop("??", 1)
+	ttLogicalOR         = binop("||", 1)
+	ttLogicalAND        = binop("&&", 2)
+	ttBitwiseOR         = binop("|", 3)
+	ttBitwiseXOR        = binop("^", 4)
+	ttBitwiseAND        = binop("&", 5)
+	ttEquality          = binop("==/!=", 6)
+	ttRelational        = binop("</>", 7)
+	ttBitShift          = binop("<</>>", 8)
+	ttPlusMin           = newToken("+/-", tokenOpts{beforeExpr: true, binop: 9, prefix: true, startsExpr: true})
+	ttModulo            = binop("%", 10)
+	ttStar              = binop("*", 10)
+	ttSlash             = binop("/", 10)
+	ttExponent          = newToken("**", tokenOpts{beforeExpr: true, binop: 11, rightAssociative: true})
+
+	// JSX tokens
+	ttJSXName     = newToken("jsxName", tokenOpts{})
+	ttJSXText     = newToken("jsxText", tokenOpts{beforeExpr: true})
+	ttJSXTagStart = newToken("jsxTagStart", tokenOpts{startsExpr: true})
+	ttJSXTagEnd   = newToken("jsxTagEnd", tokenOpts{})
+)
+
+// keywords maps keywords to their token types.
+var keywords = map[string]*tokenType{}
+
+func kw(name string, o tokenOpts) *tokenType {
+	o.keyword = name
+	t := newToken(name, o)
+	keywords[name] = t
+	return t
+}
+
+var (
+	ttBreak      = kw("break", tokenOpts{})
+	ttCase       = kw("case", tokenOpts{beforeExpr: true})
+	ttCatch      = kw("catch", tokenOpts{})
+	ttContinue   = kw("continue", tokenOpts{})
+	ttDebugger   = kw("debugger", tokenOpts{})
+	ttDefault    = kw("default", tokenOpts{beforeExpr: true})
+	ttDo         = kw("do", tokenOpts{isLoop: true, beforeExpr: true})
+	ttElse       = kw("else", tokenOpts{beforeExpr: true})
+	ttFinally    = kw("finally", tokenOpts{})
+	ttFor        = kw("for", tokenOpts{isLoop: true})
+	ttFunction   = kw("function", tokenOpts{startsExpr: true})
+	ttIf         = kw("if", tokenOpts{})
+	ttReturn     = kw("return", tokenOpts{beforeExpr: true})
+	ttSwitch     = kw("switch", tokenOpts{})
+	ttThrow      = kw("throw", tokenOpts{beforeExpr: true, prefix: true, startsExpr: true})
+	ttTry        = kw("try", tokenOpts{})
+	ttVar        = kw("var", tokenOpts{})
+	ttConst      = kw("const", tokenOpts{})
+	ttWhile      = kw("while", tokenOpts{isLoop: true})
+	ttWith       = kw("with", tokenOpts{})
+	ttNew        = kw("new", tokenOpts{beforeExpr: true, startsExpr: true})
+	ttThis       = kw("this", tokenOpts{startsExpr: true})
+	ttSuper      = kw("super", tokenOpts{startsExpr: true})
+	ttClass      = kw("class", tokenOpts{startsExpr: true})
+	ttExtends    = kw("extends", tokenOpts{beforeExpr: true})
+	ttExport     = kw("export", tokenOpts{})
+	ttImport     = kw("import", tokenOpts{startsExpr: true})
+	ttNull       = kw("null", tokenOpts{startsExpr: true})
+	ttTrue       = kw("true", tokenOpts{startsExpr: true})
+	ttFalse      = kw("false", tokenOpts{startsExpr: true})
+	ttIn         = kw("in", tokenOpts{beforeExpr: true, binop: 7})
+	ttInstanceof = kw("instanceof", tokenOpts{beforeExpr: true, binop: 7})
+	ttTypeof     = kw("typeof", tokenOpts{beforeExpr: true, prefix: true, startsExpr: true})
+	ttVoid       = kw("void", tokenOpts{beforeExpr: true, prefix: true, startsExpr: true})
+	ttDelete     = kw("delete", tokenOpts{beforeExpr: true, prefix: true, startsExpr: true})
+)
+
+// tokContext is a tokenizer context, used to distinguish regular expressions
+// from divisions and to read templates and JSX.
+type tokContext struct {
+	token         string
+	isExpr        bool
+	preserveSpace bool
+	override      func(p *parser)
+}
+
+var (
+	ctBraceStatement     = &tokContext{token: "{"}
+	ctBraceExpression    = &tokContext{token: "{", isExpr: true}
+	ctTemplateQuasi      = &tokContext{token: "${"}
+	ctParenStatement     = &tokContext{token: "("}
+	ctParenExpression    = &tokContext{token: "(", isExpr: true}
+	ctTemplate           = &tokContext{token: "`", isExpr: true, preserveSpace: true}
+	ctFunctionExpression = &tokContext{token: "function", isExpr: true}
+	ctFunctionStatement  = &tokContext{token: "function"}
+
+	ctJSXOpenTag  = &tokContext{token: "<tag"}
+	ctJSXCloseTag = &tokContext{token: "</tag"}
+	ctJSXExpr     = &tokContext{token: "<tag>...</tag>", isExpr: true, preserveSpace: true}
+)
+
+func init() {
+	ctTemplate.override = func(p *parser) { p.readTmplToken() }
+
+	closeContext := func(p *parser, prev *tokenType) {
+		if len(p.state.context) == 1 {
+			p.state.exprAllowed = true
+			return
+		}
+		out := p.popContext()
+		if out == ctBraceStatement && p.curContext().token == "function" {
+			out = p.popContext()
+		}
+		p.state.exprAllowed = !out.isExpr
+	}
+	ttParenR.updateContext = closeContext
+	ttBraceR.updateContext = closeContext
+
+	ttName.updateContext = func(p *parser, prev *tokenType) {
+		allowed := false
+		if prev != ttDot {
+			v, _ := p.state.value.(string)
+			if (v == "of" && !p.state.exprAllowed) || (v == "yield" && p.scope.inGenerator()) {
+				allowed = true
+			}
+		}
+		p.state.exprAllowed = allowed
+		if p.state.isIterator {
+			p.state.isIterator = false
+		}
+	}
+
+	ttBraceL.updateContext = func(p *parser, prev *tokenType) {
+		if p.braceIsBlock(prev) {
+			p.pushContext(ctBraceStatement)
+		} else {
+			p.pushContext(ctBraceExpression)
+		}
+		p.state.exprAllowed = true
+	}
+
+	ttDollarBraceL.updateContext = func(p *parser, prev *tokenType) {
+		p.pushContext(ctTemplateQuasi)
+		p.state.exprAllowed = true
+	}
+
+	ttParenL.updateContext = func(p *parser, prev *tokenType) {
+		if prev == ttIf || prev == ttFor || prev == ttWith || prev == ttWhile {
+			p.pushContext(ctParenStatement)
+		} else {
+			p.pushContext(ctParenExpression)
+		}
+		p.state.exprAllowed = true
+	}
+
+	// the expression state is not changed by ++ and --
+	ttIncDec.updateContext = func(p *parser, prev *tokenType) {}
+
+	fnContext := func(p *parser, prev *tokenType) {
+		if prev == ttDot || prev == ttQuestionDot {
+			// a property name, the context is not changed
+		} else if prev.beforeExpr && prev != ttSemi && prev != ttElse &&
+			!(prev == ttReturn && hasLineBreak(p.input[p.state.lastTokEnd:p.state.start])) &&
+			!((prev == ttColon || prev == ttBraceL) && p.curContext() == ctBraceStatement) {
+			p.pushContext(ctFunctionExpression)
+		} else {
+			p.pushContext(ctFunctionStatement)
+		}
+		p.state.exprAllowed = false
+	}
+	ttFunction.updateContext = fnContext
+	ttClass.updateContext = fnContext
+
+	ttBackQuote.updateContext = func(p *parser, prev *tokenType) {
+		if p.curContext() == ctTemplate {
+			p.popContext()
+		} else {
+			p.pushContext(ctTemplate)
+		}
+		p.state.exprAllowed = false
+	}
+
+	ttJSXTagStart.updateContext = func(p *parser, prev *tokenType) {
+		p.pushContext(ctJSXExpr)
+		p.pushContext(ctJSXOpenTag)
+		p.state.exprAllowed = false
+	}
+
+	ttJSXTagEnd.updateContext = func(p *parser, prev *tokenType) {
+		out := p.popContext()
+		if (out == ctJSXOpenTag && prev == ttSlash) || out == ctJSXCloseTag {
+			p.popContext()
+			p.state.exprAllowed = p.curContext() == ctJSXExpr
+		} else {
+			p.state.exprAllowed = true
+		}
+	}
+}
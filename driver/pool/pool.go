@@ -0,0 +1,391 @@
+// Package pool implements a native driver that distributes parse requests
+// over a number of native parser processes.
+//
+// Requests are queued and served in the order of arrival by the first idle
+// worker, thus a slow request never delays requests that could be served by
+// other workers. Each worker tracks its health: a native process that keeps
+// failing is replaced by a new one.
+//
+// Request deadlines are enforced by the pool: a native process that does not
+// respond in time might be stuck, thus it is killed and replaced. A canceled
+// request fails immediately, but its worker waits for the reply of the native
+// process before serving the next request.
+package pool
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	serrors "gopkg.in/src-d/go-errors.v1"
+)
+
+var (
+	// ErrTimeout is returned when a request is not served before its deadline.
+	// It is always wrapped into driver.ErrDriverFailure, since the request
+	// never reached the parser or the parser was killed.
+	ErrTimeout = serrors.NewKind("parse timed out")
+	// ErrClosed is returned when calling Parse on a closed pool.
+	ErrClosed = errors.New("pool is closed")
+	// ErrQueueFull is returned when too many requests are waiting for a worker.
+	ErrQueueFull = errors.New("parse queue is full")
+	// ErrNoWorkers is returned when none of the native processes is running.
+	ErrNoWorkers = errors.New("no healthy native workers")
+)
+
+const (
+	// DefaultQueuePerWorker is the default queue size per worker.
+	DefaultQueuePerWorker = 16
+	// DefaultMaxFailures is the default number of consecutive failures after
+	// which a native process is replaced.
+	DefaultMaxFailures = 3
+
+	restartBackoff    = 100 * time.Millisecond
+	maxRestartBackoff = 10 * time.Second
+)
+
+// Config of a pool. Zero values are replaced with defaults.
+type Config struct {
+	// Size is the number of native processes. Defaults to the number of CPUs.
+	Size int
+	// QueueSize is the maximal number of requests waiting for a worker.
+	// If the queue is full, Parse fails with ErrQueueFull.
+	// Defaults to DefaultQueuePerWorker requests per worker.
+	QueueSize int
+	// MaxFailures is the number of consecutive driver failures after which
+	// a native process is replaced. Defaults to DefaultMaxFailures.
+	MaxFailures int
+	// Timeout is the deadline for requests without one. No deadline is set if zero.
+	Timeout time.Duration
+}
+
+// WorkerStats is a snapshot of a worker state.
+type WorkerStats struct {
+	ID       int
+	Healthy  bool
+	Busy     bool
+	Requests uint64
+	Failures uint64
+	Timeouts uint64
+	Restarts int
+	// LastError is the last driver failure.
+	LastError string
+}
+
+// Pool is a native driver backed by multiple native processes.
+type Pool struct {
+	conf      Config
+	newDriver func() driver.Native
+
+	queue   chan *request
+	done    chan struct{}
+	wg      sync.WaitGroup
+	workers []*worker
+
+	mu      sync.RWMutex
+	started bool
+	closed  bool
+}
+
+var _ driver.Native = (*Pool)(nil)
+
+// New creates a pool that runs native processes created by newDriver.
+func New(conf Config, newDriver func() driver.Native) *Pool {
+	if conf.Size <= 0 {
+		conf.Size = runtime.NumCPU()
+	}
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = DefaultQueuePerWorker * conf.Size
+	}
+	if conf.MaxFailures <= 0 {
+		conf.MaxFailures = DefaultMaxFailures
+	}
+	return &Pool{conf: conf, newDriver: newDriver}
+}
+
+type request struct {
+	ctx  context.Context
+	src  string
+	resp chan response
+}
+
+type response struct {
+	ast nodes.Node
+	err error
+}
+
+// Start runs all native processes. It fails if any of them cannot be started.
+func (p *Pool) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.started {
+		return errors.New("pool is already started")
+	}
+	workers := make([]*worker, 0, p.conf.Size)
+	for i := 0; i < p.conf.Size; i++ {
+		d := p.newDriver()
+		if err := d.Start(); err != nil {
+			for _, w := range workers {
+				w.d.Close()
+			}
+			return err
+		}
+		workers = append(workers, &worker{p: p, d: d, stats: WorkerStats{ID: i, Healthy: true}})
+	}
+	p.workers = workers
+	p.queue = make(chan *request, p.conf.QueueSize)
+	p.done = make(chan struct{})
+	p.started = true
+	for _, w := range workers {
+		p.wg.Add(1)
+		go w.run()
+	}
+	return nil
+}
+
+// Parse queues the request and waits for one of the workers to parse it.
+func (p *Pool) Parse(ctx context.Context, src string) (nodes.Node, error) {
+	if _, ok := ctx.Deadline(); !ok && p.conf.Timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, p.conf.Timeout)
+		defer cancel()
+	}
+	req := &request{ctx: ctx, src: src, resp: make(chan response, 1)}
+	if err := p.enqueue(req); err != nil {
+		return nil, driver.ErrDriverFailure.Wrap(err)
+	}
+	select {
+	case r := <-req.resp:
+		return r.ast, r.err
+	case <-ctx.Done():
+		// the worker will skip the request or drop the response
+		return nil, contextError(ctx)
+	}
+}
+
+// contextError returns an error for a done request context. A canceled
+// request returns the context error as is.
+func contextError(ctx context.Context) error {
+	err := ctx.Err()
+	if err != context.DeadlineExceeded {
+		return err
+	}
+	return driver.ErrDriverFailure.Wrap(ErrTimeout.Wrap(err))
+}
+
+func (p *Pool) enqueue(req *request) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.started || p.closed {
+		return ErrClosed
+	}
+	if p.healthy() == 0 {
+		return ErrNoWorkers
+	}
+	select {
+	case p.queue <- req:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (p *Pool) healthy() int {
+	n := 0
+	for _, w := range p.workers {
+		w.mu.Lock()
+		if w.stats.Healthy {
+			n++
+		}
+		w.mu.Unlock()
+	}
+	return n
+}
+
+// Stats returns a snapshot of all workers.
+func (p *Pool) Stats() []WorkerStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]WorkerStats, 0, len(p.workers))
+	for _, w := range p.workers {
+		w.mu.Lock()
+		out = append(out, w.stats)
+		w.mu.Unlock()
+	}
+	return out
+}
+
+// Close stops all native processes. Queued requests fail with ErrClosed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if !p.started || p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	for len(p.queue) != 0 {
+		req := <-p.queue
+		req.resp <- response{err: driver.ErrDriverFailure.Wrap(ErrClosed)}
+	}
+	var last error
+	for _, w := range p.workers {
+		if w.d == nil {
+			continue
+		}
+		if err := w.d.Close(); err != nil {
+			last = err
+		}
+	}
+	return last
+}
+
+// worker serves requests with a single native process.
+type worker struct {
+	p *Pool
+	d driver.Native // nil if the process cannot be restarted
+
+	mu       sync.Mutex
+	stats    WorkerStats
+	failures int // consecutive
+}
+
+func (w *worker) run() {
+	defer w.p.wg.Done()
+	for {
+		if w.d == nil && !w.restart() {
+			return
+		}
+		select {
+		case <-w.p.done:
+			return
+		case req := <-w.p.queue:
+			if req.ctx.Err() != nil {
+				req.resp <- response{err: contextError(req.ctx)}
+				continue
+			}
+			w.setBusy(true)
+			r, stuck := w.parse(req)
+			req.resp <- r
+			if w.done(r.err, stuck) {
+				w.replace()
+			}
+		}
+	}
+}
+
+// parse runs the request on the native process. It returns true if the
+// process did not respond before the request deadline.
+func (w *worker) parse(req *request) (response, bool) {
+	resp := make(chan response, 1)
+	d := w.d
+	go func() {
+		ast, err := d.Parse(req.ctx, req.src)
+		resp <- response{ast: ast, err: err}
+	}()
+	select {
+	case r := <-resp:
+		if r.err != nil && req.ctx.Err() == context.DeadlineExceeded {
+			// the native driver gave up on the deadline
+			return response{err: contextError(req.ctx)}, true
+		}
+		return r, false
+	case <-req.ctx.Done():
+	}
+	if req.ctx.Err() == context.DeadlineExceeded {
+		// the response is dropped when the process is killed
+		return response{err: contextError(req.ctx)}, true
+	}
+	// The request was canceled, but the process is still busy with it. The
+	// reply is drained to keep the process in sync; only a reply that misses
+	// the request deadline means that the process is stuck.
+	var timeout <-chan time.Time
+	if dl, ok := req.ctx.Deadline(); ok {
+		t := time.NewTimer(time.Until(dl))
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case <-resp:
+		return response{err: req.ctx.Err()}, false
+	case <-timeout:
+		return response{err: req.ctx.Err()}, true
+	}
+}
+
+// replace stops the native process. A new one is started before the next request.
+func (w *worker) replace() {
+	// the process might be stuck, thus it may take a while to kill it
+	go w.d.Close()
+	w.d = nil
+}
+
+func (w *worker) setBusy(busy bool) {
+	w.mu.Lock()
+	w.stats.Busy = busy
+	w.mu.Unlock()
+}
+
+// done records the result of a request. It returns true if the process must be replaced.
+func (w *worker) done(err error, stuck bool) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stats.Busy = false
+	w.stats.Requests++
+	if stuck {
+		w.stats.Timeouts++
+		w.stats.LastError = ErrTimeout.New().Error()
+		w.failures = 0
+		return true
+	}
+	if err == nil || !driver.ErrDriverFailure.Is(err) {
+		w.failures = 0
+		return false
+	}
+	w.stats.Failures++
+	w.stats.LastError = err.Error()
+	w.failures++
+	if w.failures < w.p.conf.MaxFailures {
+		return false
+	}
+	w.failures = 0
+	return true
+}
+
+// restart starts a new native process, retrying with a backoff until it
+// succeeds. It returns false if the pool was closed.
+func (w *worker) restart() bool {
+	backoff := restartBackoff
+	for {
+		d := w.p.newDriver()
+		err := d.Start()
+		w.mu.Lock()
+		if err == nil {
+			w.d = d
+			w.stats.Healthy = true
+			w.stats.Restarts++
+		} else {
+			w.stats.Healthy = false
+			w.stats.LastError = err.Error()
+		}
+		w.mu.Unlock()
+		if err == nil {
+			return true
+		}
+		select {
+		case <-w.p.done:
+			return false
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxRestartBackoff {
+			backoff = maxRestartBackoff
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+// fakeDriver returns the source as an AST. A few special sources control
+// its behavior.
+type fakeDriver struct {
+	started *int32
+	closed  *int32
+	block   chan struct{} // requests with "block" source wait on it
+	order   chan string   // if set, receives all sources in order of parsing
+	hang    chan struct{} // closed when the process is killed
+}
+
+func (d *fakeDriver) Start() error {
+	atomic.AddInt32(d.started, 1)
+	return nil
+}
+
+func (d *fakeDriver) Close() error {
+	atomic.AddInt32(d.closed, 1)
+	close(d.hang)
+	return nil
+}
+
+func (d *fakeDriver) Parse(ctx context.Context, src string) (nodes.Node, error) {
+	if d.order != nil {
+		d.order <- src
+	}
+	switch src {
+	case "block":
+		<-d.block
+	case "hang":
+		// ignores the context, like a stuck native process
+		<-d.hang
+		return nil, driver.ErrDriverFailure.Wrap(errors.New("killed"))
+	case "crash":
+		return nil, driver.ErrDriverFailure.Wrap(errors.New("crashed"))
+	case "syntax":
+		return nil, errors.New("syntax error")
+	}
+	return nodes.String(src), nil
+}
+
+type fakeFactory struct {
+	started int32
+	closed  int32
+	block   chan struct{}
+	order   chan string
+	fail    int32 // number of Start calls to fail
+}
+
+func (f *fakeFactory) New() driver.Native {
+	if atomic.AddInt32(&f.fail, -1) >= 0 {
+		return failingDriver{}
+	}
+	return &fakeDriver{
+		started: &f.started, closed: &f.closed,
+		block: f.block, order: f.order, hang: make(chan struct{}),
+	}
+}
+
+type failingDriver struct{ driver.Native }
+
+func (failingDriver) Start() error { return errors.New("cannot start") }
+
+func newPool(t *testing.T, conf Config, f *fakeFactory) *Pool {
+	p := New(conf, f.New)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPoolParse(t *testing.T) {
+	f := &fakeFactory{}
+	p := newPool(t, Config{Size: 3}, f)
+	defer p.Close()
+
+	if n := atomic.LoadInt32(&f.started); n != 3 {
+		t.Fatalf("expected 3 processes, got %d", n)
+	}
+	ctx := context.Background()
+	ast, err := p.Parse(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	} else if ast != nodes.String("a") {
+		t.Fatalf("unexpected AST: %v", ast)
+	}
+	if _, err = p.Parse(ctx, "syntax"); err == nil || driver.ErrDriverFailure.Is(err) {
+		t.Fatalf("expected syntax error, got %v", err)
+	}
+}
+
+func TestPoolConcurrent(t *testing.T) {
+	f := &fakeFactory{block: make(chan struct{})}
+	p := newPool(t, Config{Size: 4}, f)
+	defer p.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Parse(context.Background(), "block"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	// all workers must be busy at the same time
+	deadline := time.Now().Add(5 * time.Second)
+	for busy(p) != 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 4 busy workers, got %d", busy(p))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(f.block)
+	wg.Wait()
+	var total uint64
+	for _, s := range p.Stats() {
+		total += s.Requests
+	}
+	if total != 4 {
+		t.Fatalf("expected 4 requests, got %d", total)
+	}
+}
+
+func busy(p *Pool) int {
+	n := 0
+	for _, s := range p.Stats() {
+		if s.Busy {
+			n++
+		}
+	}
+	return n
+}
+
+func TestPoolOrder(t *testing.T) {
+	f := &fakeFactory{block: make(chan struct{}), order: make(chan string, 10)}
+	p := newPool(t, Config{Size: 1, QueueSize: 3}, f)
+	defer p.Close()
+
+	ctx := context.Background()
+	errc := make(chan error, 5)
+	parse := func(src string) {
+		_, err := p.Parse(ctx, src)
+		errc <- err
+	}
+	go parse("block")
+	if src := <-f.order; src != "block" {
+		t.Fatalf("unexpected request: %q", src)
+	}
+	// the worker is busy, so requests are queued
+	for i, src := range []string{"1", "2", "3"} {
+		go parse(src)
+		for len(p.queue) != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if _, err := p.Parse(ctx, "4"); err == nil || !driver.ErrDriverFailure.Is(err) {
+		t.Fatalf("expected queue full error, got %v", err)
+	}
+	close(f.block)
+	for _, exp := range []string{"1", "2", "3"} {
+		if src := <-f.order; src != exp {
+			t.Fatalf("unexpected order: %q, expected %q", src, exp)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPoolRestart(t *testing.T) {
+	f := &fakeFactory{}
+	p := newPool(t, Config{Size: 1, MaxFailures: 2}, f)
+	defer p.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := p.Parse(ctx, "crash"); !driver.ErrDriverFailure.Is(err) {
+			t.Fatalf("expected driver failure, got %v", err)
+		}
+	}
+	if _, err := p.Parse(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	st := p.Stats()[0]
+	if st.Restarts != 1 || st.Failures != 2 || st.Requests != 3 || !st.Healthy {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+	if n := atomic.LoadInt32(&f.started); n != 2 {
+		t.Fatalf("expected 2 processes, got %d", n)
+	}
+}
+
+func TestPoolUnhealthy(t *testing.T) {
+	f := &fakeFactory{}
+	p := newPool(t, Config{Size: 1, MaxFailures: 1}, f)
+	defer p.Close()
+
+	// the next process fails to start
+	atomic.StoreInt32(&f.fail, 1)
+	ctx := context.Background()
+	if _, err := p.Parse(ctx, "crash"); !driver.ErrDriverFailure.Is(err) {
+		t.Fatalf("expected driver failure, got %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Stats()[0].Restarts == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("worker was not restarted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := p.Parse(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolStartError(t *testing.T) {
+	f := &fakeFactory{fail: 1}
+	p := New(Config{Size: 2}, f.New)
+	if err := p.Start(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := p.Parse(context.Background(), "a"); !driver.ErrDriverFailure.Is(err) {
+		t.Fatalf("expected driver failure, got %v", err)
+	}
+}
+
+func TestPoolClosed(t *testing.T) {
+	f := &fakeFactory{}
+	p := newPool(t, Config{Size: 1}, f)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Parse(context.Background(), "a"); !driver.ErrDriverFailure.Is(err) {
+		t.Fatalf("expected driver failure, got %v", err)
+	}
+}
+
+func TestPoolTimeout(t *testing.T) {
+	f := &fakeFactory{}
+	p := newPool(t, Config{Size: 1, Timeout: 50 * time.Millisecond}, f)
+	defer p.Close()
+
+	ctx := context.Background()
+	_, err := p.Parse(ctx, "hang")
+	if !ErrTimeout.Is(err) || !driver.ErrDriverFailure.Is(err) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	// the stuck process is replaced
+	if _, err = p.Parse(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	st := p.Stats()[0]
+	if st.Timeouts != 1 || st.Restarts != 1 || st.Failures != 0 || !st.Healthy {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+	if n := atomic.LoadInt32(&f.started); n != 2 {
+		t.Fatalf("expected 2 processes, got %d", n)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&f.closed) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("stuck process was not killed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolCancel(t *testing.T) {
+	f := &fakeFactory{block: make(chan struct{}), order: make(chan string, 10)}
+	p := newPool(t, Config{Size: 1}, f)
+	defer p.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-f.order
+		cancel()
+	}()
+	if _, err := p.Parse(ctx, "block"); err != context.Canceled {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+	// the worker waits for the reply of the canceled request
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(f.block)
+	}()
+	if _, err := p.Parse(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	if src := <-f.order; src != "a" {
+		t.Fatalf("unexpected request: %q", src)
+	}
+	st := p.Stats()[0]
+	if st.Timeouts != 0 || st.Restarts != 0 || st.Failures != 0 || !st.Healthy {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+	if n := atomic.LoadInt32(&f.closed); n != 0 {
+		t.Fatalf("expected no killed processes, got %d", n)
+	}
+}
+
+func TestPoolCancelStuck(t *testing.T) {
+	f := &fakeFactory{}
+	p := newPool(t, Config{Size: 1, Timeout: 50 * time.Millisecond}, f)
+	defer p.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := p.Parse(ctx, "hang"); err != context.Canceled {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+	// the process misses the deadline of the canceled request and is replaced
+	if _, err := p.Parse(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	if st := p.Stats()[0]; st.Timeouts != 1 || st.Restarts != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestPoolDeadline(t *testing.T) {
+	f := &fakeFactory{}
+	p := newPool(t, Config{Size: 1, Timeout: time.Hour}, f)
+	defer p.Close()
+
+	// the request deadline takes precedence over the pool timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Parse(ctx, "hang"); !ErrTimeout.Is(err) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+}
@@ -0,0 +1,519 @@
+package printer
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/scope"
+)
+
+// Operator precedence, from the loosest to the tightest binding.
+const (
+	precSeq = iota + 1
+	precAssign
+	precCond
+	precPipeline
+	precOr
+	precAnd
+	precBitOr
+	precBitXor
+	precBitAnd
+	precEq
+	precRel
+	precShift
+	precAdd
+	precMul
+	precExp
+	precUnary
+	precPostfix
+	precCall
+	precMember
+	precPrimary
+)
+
+var binaryPrec = map[string]int{
+	"|>": precPipeline,
+	"??": precOr, "||": precOr,
+	"&&": precAnd,
+	"|":  precBitOr,
+	"^":  precBitXor,
+	"&":  precBitAnd,
+	"==": precEq, "!=": precEq, "===": precEq, "!==": precEq,
+	"<": precRel, ">": precRel, "<=": precRel, ">=": precRel, "instanceof": precRel, "in": precRel,
+	"<<": precShift, ">>": precShift, ">>>": precShift,
+	"+": precAdd, "-": precAdd,
+	"*": precMul, "/": precMul, "%": precMul,
+	"**": precExp,
+}
+
+// precOf returns the precedence of an expression node.
+func precOf(n nodes.Object) int {
+	switch typeOf(n) {
+	case "SequenceExpression":
+		return precSeq
+	case "AssignmentExpression", "ArrowFunctionExpression", "YieldExpression":
+		return precAssign
+	case "ConditionalExpression":
+		return precCond
+	case "BinaryExpression", "LogicalExpression":
+		if p, ok := binaryPrec[operatorOf(n)]; ok {
+			return p
+		}
+		return precOr
+	case "UnaryExpression", "AwaitExpression":
+		return precUnary
+	case "UpdateExpression":
+		if isTrue(n, "prefix") {
+			return precUnary
+		}
+		return precPostfix
+	case "CallExpression", "OptionalCallExpression", "NewExpression":
+		return precCall
+	case "MemberExpression", "OptionalMemberExpression", "TaggedTemplateExpression", "BindExpression":
+		return precMember
+	}
+	return precPrimary
+}
+
+func isOptionalChain(n nodes.Object) bool {
+	switch typeOf(n) {
+	case "OptionalMemberExpression", "OptionalCallExpression":
+		return true
+	}
+	return false
+}
+
+// hasCall checks if the expression is a call, or a member of a call result.
+func hasCall(n nodes.Object) bool {
+	switch typeOf(n) {
+	case "CallExpression", "OptionalCallExpression":
+		return true
+	case "MemberExpression", "OptionalMemberExpression":
+		return hasCall(field(n, "object"))
+	case "TaggedTemplateExpression":
+		return hasCall(field(n, "tag"))
+	}
+	return false
+}
+
+// expr writes an expression, wrapping it in parentheses if the precedence of
+// the expression is lower than min.
+func (p *printer) expr(n nodes.Object, min int) {
+	if n == nil {
+		return
+	}
+	paren := precOf(n) < min
+	if p.noIn && typeOf(n) == "BinaryExpression" && operatorOf(n) == "in" {
+		paren = true
+	}
+	p.comments(list(n, "leadingComments"), commentBefore)
+	if paren {
+		noIn := p.noIn
+		p.noIn = false
+		p.write("(")
+		p.exprInner(n)
+		p.write(")")
+		p.noIn = noIn
+	} else {
+		p.exprInner(n)
+	}
+	p.comments(list(n, "trailingComments"), commentAfter)
+}
+
+// exprs writes a comma-separated list of expressions.
+func (p *printer) exprs(arr nodes.Array) {
+	for i, e := range arr {
+		if i != 0 {
+			p.write(", ")
+		}
+		p.expr(asObject(e), precAssign)
+	}
+}
+
+func (p *printer) exprInner(n nodes.Object) {
+	switch typ := typeOf(n); typ {
+	case "Identifier":
+		p.write(scope.NameOf(n))
+		if isTrue(n, "optional") {
+			p.write("?")
+		}
+		p.flowType(field(n, "typeAnnotation"))
+	case "PrivateName":
+		p.write("#")
+		p.expr(field(n, "id"), precPrimary)
+	case "ThisExpression":
+		p.write("this")
+	case "Super":
+		p.write("super")
+	case "Import":
+		p.write("import")
+	case "NullLiteral":
+		p.write("null")
+	case "BooleanLiteral":
+		if v, _ := valueOf(n).(nodes.Bool); v {
+			p.write("true")
+		} else {
+			p.write("false")
+		}
+	case "NumericLiteral", "BigIntLiteral", "DecimalLiteral":
+		if raw, ok := rawOf(n); ok {
+			p.write(raw)
+			break
+		}
+		p.write(formatNumber(valueOf(n)))
+		if typ == "BigIntLiteral" {
+			p.write("n")
+		} else if typ == "DecimalLiteral" {
+			p.write("m")
+		}
+	case "StringLiteral":
+		p.stringLiteral(n)
+	case "RegExpLiteral":
+		if raw, ok := rawOf(n); ok {
+			p.write(raw)
+			break
+		}
+		p.write("/" + strField(n, "pattern") + "/" + strField(n, "flags"))
+	case "TemplateLiteral":
+		p.template(n)
+	case "TaggedTemplateExpression":
+		tag := field(n, "tag")
+		p.callee(n, tag, precMember)
+		p.flowType(field(n, "typeParameters"))
+		p.template(field(n, "quasi"))
+	case "ArrayExpression", "ArrayPattern":
+		p.write("[")
+		elems := list(n, "elements")
+		for i, e := range elems {
+			if i != 0 {
+				p.write(",")
+				if e != nil {
+					p.write(" ")
+				}
+			}
+			if e == nil {
+				if i == len(elems)-1 {
+					// trailing hole requires an additional comma
+					p.write(",")
+				}
+				continue
+			}
+			p.expr(asObject(e), precAssign)
+		}
+		p.write("]")
+		if typ == "ArrayPattern" {
+			p.flowType(field(n, "typeAnnotation"))
+		}
+	case "ObjectExpression", "ObjectPattern":
+		p.object(n)
+		if typ == "ObjectPattern" {
+			p.flowType(field(n, "typeAnnotation"))
+		}
+	case "ObjectProperty":
+		p.property(n)
+	case "ObjectMethod":
+		p.method(n)
+	case "SpreadElement", "RestElement":
+		p.write("...")
+		p.expr(field(n, "argument"), precAssign)
+		p.flowType(field(n, "typeAnnotation"))
+	case "AssignmentPattern":
+		p.expr(field(n, "left"), precCall)
+		p.write(" = ")
+		p.expr(field(n, "right"), precAssign)
+	case "FunctionExpression":
+		p.function(n)
+	case "ArrowFunctionExpression":
+		p.arrow(n)
+	case "ClassExpression":
+		p.class(n)
+	case "SequenceExpression":
+		for i, e := range list(n, "expressions") {
+			if i != 0 {
+				p.write(", ")
+			}
+			p.expr(asObject(e), precAssign)
+		}
+	case "AssignmentExpression":
+		p.expr(field(n, "left"), precCall)
+		p.write(" " + operatorOf(n) + " ")
+		p.expr(field(n, "right"), precAssign)
+	case "ConditionalExpression":
+		p.expr(field(n, "test"), precCond+1)
+		p.write(" ? ")
+		p.expr(field(n, "consequent"), precAssign)
+		p.write(" : ")
+		p.expr(field(n, "alternate"), precAssign)
+	case "BinaryExpression", "LogicalExpression":
+		p.binary(n)
+	case "UnaryExpression":
+		op := operatorOf(n)
+		p.write(op)
+		arg := field(n, "argument")
+		s := p.capture(func() { p.expr(arg, precUnary) })
+		if isIdentPart(op[len(op)-1]) ||
+			(op == "+" || op == "-") && strings.HasPrefix(s, op) {
+			p.write(" ")
+		}
+		p.raw(s)
+	case "UpdateExpression":
+		op := operatorOf(n)
+		if isTrue(n, "prefix") {
+			p.write(op)
+			p.expr(field(n, "argument"), precUnary)
+		} else {
+			p.expr(field(n, "argument"), precPostfix)
+			p.write(op)
+		}
+	case "AwaitExpression":
+		p.write("await")
+		if arg := field(n, "argument"); arg != nil {
+			p.write(" ")
+			p.expr(arg, precUnary)
+		}
+	case "YieldExpression":
+		p.write("yield")
+		if isTrue(n, "delegate") {
+			p.write("*")
+		}
+		if arg := field(n, "argument"); arg != nil {
+			p.write(" ")
+			p.expr(arg, precAssign)
+		}
+	case "MemberExpression", "OptionalMemberExpression":
+		obj := field(n, "object")
+		if typeOf(obj) == "NumericLiteral" {
+			// 1.toString() is a syntax error
+			s := p.capture(func() { p.expr(obj, precPrimary) })
+			if strings.Trim(s, "0123456789") == "" {
+				s = "(" + s + ")"
+			}
+			p.raw(s)
+		} else {
+			p.callee(n, obj, precCall)
+		}
+		if isTrue(n, "optional") {
+			p.write("?.")
+		}
+		if isTrue(n, "computed") {
+			p.write("[")
+			p.expr(field(n, "property"), precSeq)
+			p.write("]")
+		} else {
+			if !isTrue(n, "optional") {
+				p.write(".")
+			}
+			p.expr(field(n, "property"), precPrimary)
+		}
+	case "CallExpression", "OptionalCallExpression":
+		p.callee(n, field(n, "callee"), precCall)
+		if isTrue(n, "optional") {
+			p.write("?.")
+		}
+		p.flowType(field(n, "typeArguments"))
+		p.write("(")
+		p.exprs(list(n, "arguments"))
+		p.write(")")
+	case "NewExpression":
+		p.write("new ")
+		callee := field(n, "callee")
+		if hasCall(callee) {
+			p.write("(")
+			p.expr(callee, precSeq)
+			p.write(")")
+		} else {
+			p.callee(n, callee, precMember)
+		}
+		p.flowType(field(n, "typeArguments"))
+		p.write("(")
+		p.exprs(list(n, "arguments"))
+		p.write(")")
+	case "MetaProperty":
+		p.expr(field(n, "meta"), precPrimary)
+		p.write(".")
+		p.expr(field(n, "property"), precPrimary)
+	case "BindExpression":
+		if obj := field(n, "object"); obj != nil {
+			p.callee(n, obj, precMember)
+		}
+		p.write("::")
+		p.callee(n, field(n, "callee"), precMember)
+	case "DoExpression":
+		p.write("do ")
+		p.block(field(n, "body"))
+	case "ParenthesizedExpression":
+		p.write("(")
+		p.expr(field(n, "expression"), precSeq)
+		p.write(")")
+	case "TypeCastExpression":
+		p.write("(")
+		p.expr(field(n, "expression"), precAssign)
+		p.flowType(field(n, "typeAnnotation"))
+		p.write(")")
+	default:
+		if !p.jsx(n) {
+			p.unsupported(n)
+		}
+	}
+}
+
+// callee writes an object of a member expression or a callee of a call.
+// Optional chains are wrapped in parentheses when used by a regular member
+// or call expression, since it changes the meaning of the chain.
+func (p *printer) callee(outer, n nodes.Object, min int) {
+	if isOptionalChain(n) && !isOptionalChain(outer) {
+		min = precPrimary
+	}
+	p.expr(n, min)
+}
+
+func (p *printer) binary(n nodes.Object) {
+	op := operatorOf(n)
+	prec := precOf(n)
+	left, right := field(n, "left"), field(n, "right")
+	lmin, rmin := prec, prec+1
+	if op == "**" {
+		// right-associative; unary operators are not allowed on the left
+		lmin, rmin = precPostfix, prec
+	}
+	p.expr(left, p.logicalMin(op, left, lmin))
+	p.write(" " + op + " ")
+	p.expr(right, p.logicalMin(op, right, rmin))
+}
+
+// logicalMin returns the minimal precedence for an operand of a logical
+// operator. The ?? operator cannot be mixed with || and && without parentheses.
+func (p *printer) logicalMin(op string, arg nodes.Object, min int) int {
+	if typeOf(arg) != "LogicalExpression" {
+		return min
+	}
+	aop := operatorOf(arg)
+	if (op == "??") != (aop == "??") && (op == "??" || op == "||" || op == "&&") {
+		return precPrimary
+	}
+	return min
+}
+
+func (p *printer) stringLiteral(n nodes.Object) {
+	if raw, ok := rawOf(n); ok {
+		p.write(raw)
+		return
+	}
+	p.write(quote(strField(n, "value"), '"'))
+}
+
+var errNoTemplateValue = errors.New("printer: template element has no value")
+
+func (p *printer) template(n nodes.Object) {
+	p.write("`")
+	quasis, exprs := list(n, "quasis"), list(n, "expressions")
+	for i, q := range quasis {
+		q := asObject(q)
+		v := field(q, "value")
+		if v == nil {
+			// dropped from Annotated AST
+			p.fail(errNoTemplateValue)
+		}
+		p.buf.WriteString(strField(v, "raw"))
+		if i < len(exprs) {
+			p.write("${")
+			p.expr(asObject(exprs[i]), precSeq)
+			p.write("}")
+		}
+	}
+	p.write("`")
+}
+
+func (p *printer) arrow(n nodes.Object) {
+	if isTrue(n, "async") {
+		p.write("async ")
+	}
+	p.signature(n)
+	p.write(" => ")
+	body := field(n, "body")
+	if typeOf(body) == "BlockStatement" {
+		p.block(body)
+		return
+	}
+	s := p.capture(func() { p.expr(body, precAssign) })
+	if strings.HasPrefix(s, "{") {
+		s = "(" + s + ")"
+	}
+	p.raw(s)
+}
+
+// object writes an object literal or an object pattern.
+func (p *printer) object(n nodes.Object) {
+	props := list(n, "properties")
+	if len(props) == 0 {
+		p.write("{")
+		if p.hasComments(list(n, "innerComments")) {
+			p.newline()
+			p.indent++
+			p.comments(list(n, "innerComments"), commentOwnLine)
+			p.indent--
+		}
+		p.write("}")
+		return
+	}
+	multiline := false
+	for _, pr := range props {
+		if typeOf(asObject(pr)) == "ObjectMethod" {
+			multiline = true
+			break
+		}
+	}
+	if !multiline {
+		s := p.measure(func() { p.exprs(props) })
+		if fitsLine(s) {
+			p.write("{")
+			p.exprs(props)
+			p.write("}")
+			return
+		}
+	}
+	p.write("{")
+	p.newline()
+	p.indent++
+	for i, pr := range props {
+		p.expr(asObject(pr), precAssign)
+		if i != len(props)-1 {
+			p.write(",")
+		}
+		if !p.lineStart {
+			p.newline()
+		}
+	}
+	p.indent--
+	p.write("}")
+}
+
+// maxInline is the maximal length of object literals and object types
+// that are written on a single line.
+const maxInline = 60
+
+func fitsLine(s string) bool {
+	return len(s) <= maxInline && !strings.Contains(s, "\n")
+}
+
+func (p *printer) property(n nodes.Object) {
+	p.decorators(n)
+	val := field(n, "value")
+	if isTrue(n, "shorthand") && !isTrue(n, "computed") {
+		local := val
+		if typeOf(local) == "AssignmentPattern" {
+			local = field(local, "left")
+		}
+		key := field(n, "key")
+		if typeOf(local) == "Identifier" && typeOf(key) == "Identifier" &&
+			scope.NameOf(local) == scope.NameOf(key) {
+			p.expr(val, precAssign)
+			return
+		}
+	}
+	p.key(n)
+	p.write(": ")
+	p.expr(val, precAssign)
+}
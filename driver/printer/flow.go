@@ -0,0 +1,491 @@
+package printer
+
+import (
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+var flowTypes = map[string]bool{
+	"TypeParameterDeclaration":   true,
+	"TypeParameterInstantiation": true,
+	"TypeParameter":              true,
+	"ObjectTypeProperty":         true,
+	"ObjectTypeSpreadProperty":   true,
+	"ObjectTypeIndexer":          true,
+	"ObjectTypeCallProperty":     true,
+	"ObjectTypeInternalSlot":     true,
+	"FunctionTypeParam":          true,
+	"QualifiedTypeIdentifier":    true,
+	"InterfaceExtends":           true,
+	"ClassImplements":            true,
+	"Variance":                   true,
+	"InferredPredicate":          true,
+	"DeclaredPredicate":          true,
+	"IndexedAccessType":          true,
+	"OptionalIndexedAccessType":  true,
+}
+
+func isFlowType(n nodes.Object) bool {
+	typ := typeOf(n)
+	return strings.HasSuffix(typ, "TypeAnnotation") || flowTypes[typ]
+}
+
+var flowKeywords = map[string]string{
+	"AnyTypeAnnotation":         "any",
+	"MixedTypeAnnotation":       "mixed",
+	"EmptyTypeAnnotation":       "empty",
+	"NumberTypeAnnotation":      "number",
+	"StringTypeAnnotation":      "string",
+	"BooleanTypeAnnotation":     "boolean",
+	"SymbolTypeAnnotation":      "symbol",
+	"BigIntTypeAnnotation":      "bigint",
+	"VoidTypeAnnotation":        "void",
+	"NullLiteralTypeAnnotation": "null",
+	"ExistsTypeAnnotation":      "*",
+	"ThisTypeAnnotation":        "this",
+}
+
+// Precedence of Flow types, from the loosest to the tightest binding.
+const (
+	typePrecFunc = iota + 1
+	typePrecUnion
+	typePrecIntersection
+	typePrecPrefix
+	typePrecArray
+	typePrecPrimary
+)
+
+func typePrecOf(n nodes.Object) int {
+	switch typeOf(n) {
+	case "FunctionTypeAnnotation":
+		return typePrecFunc
+	case "UnionTypeAnnotation":
+		return typePrecUnion
+	case "IntersectionTypeAnnotation":
+		return typePrecIntersection
+	case "NullableTypeAnnotation", "TypeofTypeAnnotation":
+		return typePrecPrefix
+	case "ArrayTypeAnnotation", "IndexedAccessType", "OptionalIndexedAccessType":
+		return typePrecArray
+	}
+	return typePrecPrimary
+}
+
+// flowType writes a Flow type node. Nil nodes are ignored.
+func (p *printer) flowType(n nodes.Object) {
+	p.typeMin(n, typePrecFunc)
+}
+
+// typeMin writes a Flow type, wrapping it in parentheses if the precedence of
+// the type is lower than min.
+func (p *printer) typeMin(n nodes.Object, min int) {
+	if n == nil {
+		return
+	}
+	if typePrecOf(n) < min {
+		p.write("(")
+		p.typeInner(n)
+		p.write(")")
+		return
+	}
+	p.typeInner(n)
+}
+
+func (p *printer) types(arr nodes.Array, sep string, min int) {
+	for i, t := range arr {
+		if i != 0 {
+			p.write(sep)
+		}
+		p.typeMin(asObject(t), min)
+	}
+}
+
+// typeList writes a prefix and a comma-separated list of types, if any.
+func (p *printer) typeList(prefix string, arr nodes.Array) {
+	if len(arr) == 0 {
+		return
+	}
+	p.write(prefix)
+	p.types(arr, ", ", typePrecFunc)
+}
+
+func (p *printer) typeInner(n nodes.Object) {
+	typ := typeOf(n)
+	if kw, ok := flowKeywords[typ]; ok {
+		p.write(kw)
+		return
+	}
+	switch typ {
+	case "TypeAnnotation":
+		p.write(": ")
+		p.flowType(field(n, "typeAnnotation"))
+	case "TypeParameterDeclaration", "TypeParameterInstantiation":
+		p.write("<")
+		p.types(list(n, "params"), ", ", typePrecFunc)
+		p.write(">")
+	case "TypeParameter":
+		p.flowType(field(n, "variance"))
+		p.write(strField(n, "name"))
+		p.flowType(field(n, "bound"))
+		if d := field(n, "default"); d != nil {
+			p.write(" = ")
+			p.flowType(d)
+		}
+	case "Variance":
+		switch strField(n, "kind") {
+		case "plus":
+			p.write("+")
+		case "minus":
+			p.write("-")
+		}
+	case "StringLiteralTypeAnnotation":
+		p.stringLiteral(n)
+	case "NumberLiteralTypeAnnotation", "BigIntLiteralTypeAnnotation":
+		if raw, ok := rawOf(n); ok {
+			p.write(raw)
+		} else {
+			p.write(formatNumber(valueOf(n)))
+		}
+	case "BooleanLiteralTypeAnnotation":
+		if v, _ := valueOf(n).(nodes.Bool); v {
+			p.write("true")
+		} else {
+			p.write("false")
+		}
+	case "NullableTypeAnnotation":
+		p.write("?")
+		p.typeMin(field(n, "typeAnnotation"), typePrecPrefix)
+	case "TypeofTypeAnnotation":
+		p.write("typeof ")
+		p.typeMin(field(n, "argument"), typePrecPrimary)
+	case "ArrayTypeAnnotation":
+		p.typeMin(field(n, "elementType"), typePrecArray)
+		p.write("[]")
+	case "IndexedAccessType", "OptionalIndexedAccessType":
+		p.typeMin(field(n, "objectType"), typePrecArray)
+		if isTrue(n, "optional") {
+			p.write("?.")
+		}
+		p.write("[")
+		p.flowType(field(n, "indexType"))
+		p.write("]")
+	case "UnionTypeAnnotation":
+		p.types(list(n, "types"), " | ", typePrecIntersection)
+	case "IntersectionTypeAnnotation":
+		p.types(list(n, "types"), " & ", typePrecPrefix)
+	case "TupleTypeAnnotation":
+		p.write("[")
+		p.types(list(n, "types"), ", ", typePrecFunc)
+		p.write("]")
+	case "GenericTypeAnnotation", "InterfaceExtends", "ClassImplements":
+		p.typeName(field(n, "id"))
+		p.flowType(field(n, "typeParameters"))
+	case "QualifiedTypeIdentifier":
+		p.typeName(n)
+	case "Identifier":
+		// keywords used as types, like "delete", are parsed as identifiers
+		p.write(strField(n, "name"))
+	case "FunctionTypeAnnotation":
+		p.funcType(n, true)
+	case "FunctionTypeParam":
+		if name := field(n, "name"); name != nil {
+			p.expr(name, precPrimary)
+			if isTrue(n, "optional") {
+				p.write("?")
+			}
+			p.write(": ")
+		}
+		p.flowType(field(n, "typeAnnotation"))
+	case "ObjectTypeAnnotation":
+		p.objectType(n)
+	case "ObjectTypeProperty":
+		if isTrue(n, "proto") {
+			p.write("proto ")
+		}
+		if isTrue(n, "static") {
+			p.write("static ")
+		}
+		p.flowType(field(n, "variance"))
+		switch kind := strField(n, "kind"); kind {
+		case "get", "set":
+			p.write(kind + " ")
+		}
+		p.expr(field(n, "key"), precPrimary)
+		if isTrue(n, "method") {
+			p.funcType(field(n, "value"), false)
+			return
+		}
+		if isTrue(n, "optional") {
+			p.write("?")
+		}
+		p.write(": ")
+		p.flowType(field(n, "value"))
+	case "ObjectTypeSpreadProperty":
+		p.write("...")
+		p.flowType(field(n, "argument"))
+	case "ObjectTypeIndexer":
+		if isTrue(n, "static") {
+			p.write("static ")
+		}
+		p.flowType(field(n, "variance"))
+		p.write("[")
+		if id := field(n, "id"); id != nil {
+			p.expr(id, precPrimary)
+			p.write(": ")
+		}
+		p.flowType(field(n, "key"))
+		p.write("]: ")
+		p.flowType(field(n, "value"))
+	case "ObjectTypeCallProperty":
+		if isTrue(n, "static") {
+			p.write("static ")
+		}
+		p.funcType(field(n, "value"), false)
+	case "ObjectTypeInternalSlot":
+		if isTrue(n, "static") {
+			p.write("static ")
+		}
+		p.write("[[")
+		p.expr(field(n, "id"), precPrimary)
+		p.write("]]")
+		if isTrue(n, "optional") {
+			p.write("?")
+		}
+		if isTrue(n, "method") {
+			p.funcType(field(n, "value"), false)
+			return
+		}
+		p.write(": ")
+		p.flowType(field(n, "value"))
+	case "InterfaceTypeAnnotation":
+		p.write("interface")
+		p.typeList(" extends ", list(n, "extends"))
+		p.write(" ")
+		p.flowType(field(n, "body"))
+	case "InferredPredicate":
+		p.write("%checks")
+	case "DeclaredPredicate":
+		p.write("%checks(")
+		p.expr(field(n, "value"), precSeq)
+		p.write(")")
+	default:
+		p.unsupported(n)
+	}
+}
+
+// typeName writes an identifier or a qualified name of a type.
+func (p *printer) typeName(n nodes.Object) {
+	if typeOf(n) == "QualifiedTypeIdentifier" {
+		p.typeName(field(n, "qualification"))
+		p.write(".")
+		p.expr(field(n, "id"), precPrimary)
+		return
+	}
+	p.expr(n, precPrimary)
+}
+
+// funcType writes a function type. Arrow function types are used in type
+// expressions, while the method form is used in object types and declarations.
+func (p *printer) funcType(n nodes.Object, arrow bool) {
+	p.flowType(field(n, "typeParameters"))
+	p.write("(")
+	params := list(n, "params")
+	p.types(params, ", ", typePrecFunc)
+	if rest := field(n, "rest"); rest != nil {
+		if len(params) != 0 {
+			p.write(", ")
+		}
+		p.write("...")
+		p.flowType(rest)
+	}
+	p.write(")")
+	if arrow {
+		p.write(" => ")
+	} else {
+		p.write(": ")
+	}
+	p.flowType(field(n, "returnType"))
+}
+
+func (p *printer) objectType(n nodes.Object) {
+	open, close := "{", "}"
+	if isTrue(n, "exact") {
+		open, close = "{|", "|}"
+	}
+	var members []nodes.Object
+	for _, k := range []string{"properties", "indexers", "callProperties", "internalSlots"} {
+		for _, m := range list(n, k) {
+			members = append(members, asObject(m))
+		}
+	}
+	inexact := isTrue(n, "inexact")
+	if len(members) == 0 {
+		p.write(open)
+		if inexact {
+			p.write("...")
+		}
+		p.write(close)
+		return
+	}
+	writeMember := func(m nodes.Object) {
+		p.comments(list(m, "leadingComments"), commentBefore)
+		p.flowType(m)
+		p.comments(list(m, "trailingComments"), commentAfter)
+	}
+	line := p.measure(func() {
+		for i, m := range members {
+			if i != 0 {
+				p.write(", ")
+			}
+			writeMember(m)
+		}
+	})
+	if fitsLine(line) {
+		p.write(open)
+		for i, m := range members {
+			if i != 0 {
+				p.write(", ")
+			}
+			writeMember(m)
+		}
+		if inexact {
+			p.write(", ...")
+		}
+		p.write(close)
+		return
+	}
+	p.write(open)
+	p.newline()
+	p.indent++
+	for _, m := range members {
+		writeMember(m)
+		p.write(",")
+		if !p.lineStart {
+			p.newline()
+		}
+	}
+	if inexact {
+		p.write("...")
+		p.newline()
+	}
+	p.indent--
+	p.write(close)
+}
+
+// flowDeclaration writes a Flow declaration statement.
+// It returns false if the node is not a Flow declaration.
+func (p *printer) flowDeclaration(n nodes.Object) bool {
+	switch typeOf(n) {
+	case "TypeAlias":
+		p.typeAlias(n)
+	case "DeclareTypeAlias":
+		p.write("declare ")
+		p.typeAlias(n)
+	case "OpaqueType", "DeclareOpaqueType":
+		if typeOf(n) == "DeclareOpaqueType" {
+			p.write("declare ")
+		}
+		p.write("opaque type ")
+		p.expr(field(n, "id"), precPrimary)
+		p.flowType(field(n, "typeParameters"))
+		if st := field(n, "supertype"); st != nil {
+			p.write(": ")
+			p.flowType(st)
+		}
+		if it := field(n, "impltype"); it != nil {
+			p.write(" = ")
+			p.flowType(it)
+		}
+		p.write(";")
+	case "InterfaceDeclaration":
+		p.write("interface ")
+		p.interfaceDecl(n)
+	case "DeclareInterface":
+		p.write("declare interface ")
+		p.interfaceDecl(n)
+	case "DeclareClass":
+		p.write("declare class ")
+		p.interfaceDecl(n)
+	case "DeclareFunction":
+		p.write("declare function ")
+		id := field(n, "id")
+		p.write(strField(id, "name"))
+		if ta := field(id, "typeAnnotation"); ta != nil {
+			p.funcType(field(ta, "typeAnnotation"), false)
+		}
+		if pred := field(n, "predicate"); pred != nil {
+			p.write(" ")
+			p.flowType(pred)
+		}
+		p.write(";")
+	case "DeclareVariable":
+		p.write("declare var ")
+		p.expr(field(n, "id"), precPrimary)
+		p.write(";")
+	case "DeclareModule":
+		p.write("declare module ")
+		p.expr(field(n, "id"), precPrimary)
+		p.write(" ")
+		p.block(field(n, "body"))
+	case "DeclareModuleExports":
+		p.write("declare module.exports")
+		p.flowType(field(n, "typeAnnotation"))
+		p.write(";")
+	case "DeclareExportDeclaration":
+		p.write("declare export ")
+		if isTrue(n, "default") {
+			p.write("default ")
+		}
+		if decl := field(n, "declaration"); decl != nil {
+			if isFlowType(decl) {
+				p.flowType(decl)
+				p.write(";")
+			} else {
+				p.statement(decl)
+			}
+			return true
+		}
+		p.write("{")
+		for i, s := range list(n, "specifiers") {
+			if i != 0 {
+				p.write(", ")
+			}
+			s := asObject(s)
+			p.alias(field(s, "local"), field(s, "exported"))
+		}
+		p.write("}")
+		if src := field(n, "source"); src != nil {
+			p.write(" from ")
+			p.expr(src, precPrimary)
+		}
+		p.write(";")
+	case "DeclareExportAllDeclaration":
+		p.write("declare export * from ")
+		p.expr(field(n, "source"), precPrimary)
+		p.write(";")
+	default:
+		return false
+	}
+	return true
+}
+
+func (p *printer) typeAlias(n nodes.Object) {
+	p.write("type ")
+	p.expr(field(n, "id"), precPrimary)
+	p.flowType(field(n, "typeParameters"))
+	p.write(" = ")
+	p.flowType(field(n, "right"))
+	p.write(";")
+}
+
+// interfaceDecl writes an interface or a class declaration, starting from the name.
+func (p *printer) interfaceDecl(n nodes.Object) {
+	p.expr(field(n, "id"), precPrimary)
+	p.flowType(field(n, "typeParameters"))
+	p.typeList(" extends ", list(n, "extends"))
+	p.typeList(" mixins ", list(n, "mixins"))
+	p.typeList(" implements ", list(n, "implements"))
+	p.write(" ")
+	p.flowType(field(n, "body"))
+}
@@ -0,0 +1,96 @@
+package printer
+
+import (
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/scope"
+)
+
+// jsx writes a JSX node. It returns false if the node is not a JSX node.
+//
+// Whitespace is significant in JSX children, thus the printer never indents
+// or breaks lines in them and writes JSX text verbatim.
+func (p *printer) jsx(n nodes.Object) bool {
+	switch typeOf(n) {
+	case "JSXElement":
+		p.jsxOpening(field(n, "openingElement"))
+		if c := field(n, "closingElement"); c != nil {
+			p.jsxChildren(list(n, "children"))
+			p.write("</")
+			p.expr(field(c, "name"), precPrimary)
+			p.write(">")
+		}
+	case "JSXFragment":
+		p.write("<>")
+		p.jsxChildren(list(n, "children"))
+		p.write("</>")
+	case "JSXOpeningElement":
+		p.jsxOpening(n)
+	case "JSXClosingElement":
+		p.write("</")
+		p.expr(field(n, "name"), precPrimary)
+		p.write(">")
+	case "JSXOpeningFragment":
+		p.write("<>")
+	case "JSXClosingFragment":
+		p.write("</>")
+	case "JSXIdentifier":
+		p.write(scope.NameOf(n))
+	case "JSXMemberExpression":
+		p.expr(field(n, "object"), precPrimary)
+		p.write(".")
+		p.expr(field(n, "property"), precPrimary)
+	case "JSXNamespacedName":
+		p.expr(field(n, "namespace"), precPrimary)
+		p.write(":")
+		p.expr(field(n, "name"), precPrimary)
+	case "JSXAttribute":
+		p.expr(field(n, "name"), precPrimary)
+		if v := field(n, "value"); v != nil {
+			p.write("=")
+			p.expr(v, precPrimary)
+		}
+	case "JSXSpreadAttribute", "JSXSpreadChild":
+		p.write("{...")
+		p.expr(field(n, "argument"), precAssign)
+		p.expr(field(n, "expression"), precAssign)
+		p.write("}")
+	case "JSXExpressionContainer":
+		p.write("{")
+		p.expr(field(n, "expression"), precAssign)
+		p.write("}")
+	case "JSXEmptyExpression":
+		p.comments(list(n, "innerComments"), commentBefore)
+	case "JSXText":
+		text, ok := rawOf(n)
+		if !ok {
+			text = strField(n, "value")
+		}
+		p.write("")
+		p.buf.WriteString(text)
+	default:
+		return false
+	}
+	return true
+}
+
+func (p *printer) jsxOpening(n nodes.Object) {
+	p.write("<")
+	p.expr(field(n, "name"), precPrimary)
+	p.flowType(field(n, "typeParameters"))
+	for _, a := range list(n, "attributes") {
+		p.write(" ")
+		p.expr(asObject(a), precPrimary)
+	}
+	if isTrue(n, "selfClosing") {
+		p.write(" />")
+	} else {
+		p.write(">")
+	}
+}
+
+func (p *printer) jsxChildren(arr nodes.Array) {
+	for _, c := range arr {
+		p.expr(asObject(c), precPrimary)
+	}
+}
@@ -0,0 +1,449 @@
+// Package printer generates JavaScript source code from a native AST.
+//
+// The printer accepts the Babel AST as returned by the native parser, as well
+// as the Preprocessed AST (which is what is returned after reversing the
+// Native annotations). It supports JSX and Flow syntax. Annotated AST can be
+// printed as well, unless it contains template literals, since annotations
+// drop their text.
+//
+// The output is formatted by the printer itself: the original whitespace is
+// not preserved, but comments are.
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/scope"
+)
+
+// UnsupportedError is returned when the printer meets an unknown node.
+type UnsupportedError struct {
+	Type string
+}
+
+func (e *UnsupportedError) Error() string {
+	if e.Type == "" {
+		return "printer: node has no type"
+	}
+	return "printer: unsupported node type: " + e.Type
+}
+
+// Config is the printer configuration.
+type Config struct {
+	// Indent is the string used for a single indentation level.
+	Indent string
+	// NoComments disables printing of comments.
+	NoComments bool
+}
+
+// DefaultConfig is the configuration used by Print and Fprint.
+var DefaultConfig = Config{Indent: "  "}
+
+// Print generates the source code for a native AST node.
+func Print(n nodes.Node) (string, error) {
+	return DefaultConfig.Print(n)
+}
+
+// Fprint writes the source code for a native AST node to w.
+func Fprint(w io.Writer, n nodes.Node) error {
+	return DefaultConfig.Fprint(w, n)
+}
+
+// Print generates the source code for a native AST node.
+func (c Config) Print(n nodes.Node) (string, error) {
+	p := &printer{
+		conf:    c,
+		printed: make(map[string]bool),
+	}
+	if err := p.root(n); err != nil {
+		return "", err
+	}
+	return p.buf.String(), nil
+}
+
+// Fprint writes the source code for a native AST node to w.
+func (c Config) Fprint(w io.Writer, n nodes.Node) error {
+	s, err := c.Print(n)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, s)
+	return err
+}
+
+// abort is used to stop printing on the first error.
+type abort struct {
+	err error
+}
+
+type printer struct {
+	conf Config
+	buf  bytes.Buffer
+
+	indent    int
+	lineStart bool
+	// noIn is set when printing the init of a for statement,
+	// where a top-level "in" operator must be parenthesized.
+	noIn bool
+	// printed records comments that were already written.
+	printed map[string]bool
+}
+
+func (p *printer) root(n nodes.Node) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			a, ok := r.(abort)
+			if !ok {
+				panic(r)
+			}
+			err = a.err
+		}
+	}()
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return &UnsupportedError{}
+	}
+	switch typeOf(obj) {
+	case "File":
+		p.node(field(obj, "program"))
+		// write comments that are not attached to any node
+		p.comments(list(obj, "comments"), commentOwnLine)
+	case "Program":
+		p.node(obj)
+	default:
+		if isStatement(obj) {
+			p.statement(obj)
+		} else {
+			p.expr(obj, precSeq)
+		}
+	}
+	if !p.lineStart && p.buf.Len() != 0 {
+		p.newline()
+	}
+	return nil
+}
+
+func (p *printer) fail(err error) {
+	panic(abort{err: err})
+}
+
+func (p *printer) unsupported(n nodes.Object) {
+	p.fail(&UnsupportedError{Type: typeOf(n)})
+}
+
+func (p *printer) write(s string) {
+	if s == "" {
+		return
+	}
+	if p.lineStart {
+		for i := 0; i < p.indent; i++ {
+			p.buf.WriteString(p.conf.Indent)
+		}
+		p.lineStart = false
+	}
+	p.buf.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.buf.WriteByte('\n')
+	p.lineStart = true
+}
+
+// capture runs fn and returns everything it printed instead of writing it.
+func (p *printer) capture(fn func()) string {
+	old, oldStart := p.buf, p.lineStart
+	p.buf = bytes.Buffer{}
+	p.lineStart = false
+	fn()
+	s := p.buf.String()
+	p.buf, p.lineStart = old, oldStart
+	return s
+}
+
+// measure is like capture, but it also discards the state of printed comments.
+func (p *printer) measure(fn func()) string {
+	printed := make(map[string]bool, len(p.printed))
+	for k, v := range p.printed {
+		printed[k] = v
+	}
+	s := p.capture(fn)
+	p.printed = printed
+	return s
+}
+
+// raw writes a captured string.
+func (p *printer) raw(s string) {
+	if s == "" {
+		return
+	}
+	p.write("")
+	if p.lineStart {
+		p.write(s)
+		return
+	}
+	p.buf.WriteString(s)
+	p.lineStart = strings.HasSuffix(s, "\n")
+}
+
+// Node accessors. They work for both the native AST and the Preprocessed UAST.
+
+func typeOf(n nodes.Node) string {
+	return scope.TypeOf(n)
+}
+
+func field(obj nodes.Object, k string) nodes.Object {
+	v, _ := obj[k].(nodes.Object)
+	return v
+}
+
+func list(obj nodes.Object, k string) nodes.Array {
+	v, _ := obj[k].(nodes.Array)
+	return v
+}
+
+func isTrue(obj nodes.Object, k string) bool {
+	v, _ := obj[k].(nodes.Bool)
+	return bool(v)
+}
+
+func strField(obj nodes.Object, k string) string {
+	v, _ := obj[k].(nodes.String)
+	return string(v)
+}
+
+func asObject(n nodes.Node) nodes.Object {
+	v, _ := n.(nodes.Object)
+	return v
+}
+
+// operatorOf returns an operator of an expression node. In Annotated AST
+// operators are stored in uast:Operator nodes.
+func operatorOf(n nodes.Object) string {
+	switch op := n["operator"].(type) {
+	case nodes.String:
+		return string(op)
+	case nodes.Object:
+		return strField(op, uast.KeyToken)
+	}
+	return ""
+}
+
+// rawOf returns the raw source of a literal node, if it was preserved.
+func rawOf(n nodes.Object) (string, bool) {
+	if extra := field(n, "extra"); extra != nil {
+		if s, ok := extra["raw"].(nodes.String); ok {
+			return string(s), true
+		}
+	}
+	if s, ok := n["raw"].(nodes.String); ok {
+		return string(s), true
+	}
+	if s, ok := n[uast.KeyToken].(nodes.String); ok {
+		return string(s), true
+	}
+	return "", false
+}
+
+// valueOf returns the value of a literal node. In Annotated AST the value
+// is moved to the token field.
+func valueOf(n nodes.Object) nodes.Node {
+	if v, ok := n["value"]; ok {
+		return v
+	}
+	return n[uast.KeyToken]
+}
+
+func formatNumber(v nodes.Node) string {
+	switch v := v.(type) {
+	case nodes.Int:
+		return strconv.FormatInt(int64(v), 10)
+	case nodes.Uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case nodes.Float:
+		return strconv.FormatFloat(float64(v), 'g', -1, 64)
+	case nodes.String:
+		return string(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// quote returns a JavaScript string literal for s.
+func quote(s string, q byte) string {
+	var b strings.Builder
+	b.WriteByte(q)
+	for _, r := range s {
+		switch r {
+		case rune(q), '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\u2028':
+			b.WriteString(`\u2028`)
+		case '\u2029':
+			b.WriteString(`\u2029`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\x%02x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte(q)
+	return b.String()
+}
+
+// Comments
+
+// commentKey returns a key that identifies a comment in the file.
+func commentKey(c nodes.Object) string {
+	if v, ok := c["start"].(nodes.Value); ok && v != nil {
+		return formatNumber(v)
+	}
+	if ps := uast.PositionsOf(c); ps != nil {
+		if s := ps.Start(); s != nil {
+			return strconv.FormatUint(uint64(s.Offset), 10)
+		}
+	}
+	return typeOf(c) + ":" + commentText(c)
+}
+
+func commentText(c nodes.Object) string {
+	if s, ok := c["value"].(nodes.String); ok {
+		return string(s)
+	}
+	return strField(c, uast.KeyToken)
+}
+
+// Placement of comments relative to the code.
+const (
+	// commentOwnLine puts every comment on a separate line.
+	commentOwnLine = iota
+	// commentBefore writes comments before the code on the same line.
+	commentBefore
+	// commentAfter writes comments after the code on the same line.
+	commentAfter
+)
+
+// comments writes a list of comments that were not written yet.
+// Line comments are always followed by a line break.
+func (p *printer) comments(arr nodes.Array, place int) {
+	if p.conf.NoComments {
+		return
+	}
+	for _, c := range arr {
+		c := asObject(c)
+		if c == nil {
+			continue
+		}
+		key := commentKey(c)
+		if p.printed[key] {
+			continue
+		}
+		p.printed[key] = true
+		line := typeOf(c) == "CommentLine"
+		if place == commentAfter && !p.lineStart {
+			p.write(" ")
+		}
+		if line {
+			p.write("//" + commentText(c))
+		} else {
+			p.write("/*" + commentText(c) + "*/")
+		}
+		switch {
+		case line || place == commentOwnLine:
+			p.newline()
+		case place == commentBefore:
+			p.write(" ")
+		}
+	}
+}
+
+// lineOf returns a line number of the start or the end of the node, or zero
+// if the node has no positional information.
+func lineOf(n nodes.Object, end bool) int {
+	k := "start"
+	if end {
+		k = "end"
+	}
+	if loc := field(n, "loc"); loc != nil {
+		if v, ok := field(loc, k)["line"].(nodes.Value); ok && v != nil {
+			l, _ := strconv.Atoi(formatNumber(v))
+			return l
+		}
+	}
+	if ps := uast.PositionsOf(n); ps != nil {
+		pos := ps.Start()
+		if end {
+			pos = ps.End()
+		}
+		if pos != nil {
+			return int(pos.Line)
+		}
+	}
+	return 0
+}
+
+// trailing writes trailing comments of a statement-like node. Comments that
+// start on the same line as the node ends are kept on that line.
+func (p *printer) trailing(n nodes.Object) {
+	arr := list(n, "trailingComments")
+	if len(arr) == 0 || p.conf.NoComments {
+		return
+	}
+	end := lineOf(n, true)
+	var same, next nodes.Array
+	for _, c := range arr {
+		if l := lineOf(asObject(c), false); end != 0 && l != 0 && l > end {
+			next = append(next, c)
+		} else {
+			same = append(same, c)
+		}
+	}
+	p.comments(same, commentAfter)
+	if p.hasComments(next) {
+		if !p.lineStart {
+			p.newline()
+		}
+		p.comments(next, commentOwnLine)
+	}
+}
+
+func (p *printer) hasComments(arr nodes.Array) bool {
+	if p.conf.NoComments {
+		return false
+	}
+	for _, c := range arr {
+		if c := asObject(c); c != nil && !p.printed[commentKey(c)] {
+			return true
+		}
+	}
+	return false
+}
+
+// node prints any node, dispatching on its type.
+func (p *printer) node(n nodes.Object) {
+	if n == nil {
+		return
+	}
+	switch {
+	case isStatement(n):
+		p.statement(n)
+	case isFlowType(n):
+		p.flowType(n)
+	default:
+		p.expr(n, precSeq)
+	}
+}
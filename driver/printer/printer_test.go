@@ -0,0 +1,231 @@
+package printer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/driver/native"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/transformer"
+
+	"github.com/bblfsh/javascript-driver/driver/internal/fixturetest"
+	"github.com/bblfsh/javascript-driver/driver/normalizer"
+	"github.com/bblfsh/javascript-driver/driver/parser"
+)
+
+const nativeBin = "../../build/bin/native"
+
+func nativeFixtures(t testing.TB) []string {
+	return fixturetest.Glob(t, "*.js.native", filepath.Join("encoding", "*.js.native"), filepath.Join("escaped", "*.js.native"))
+}
+
+// stripped are the fields that are not preserved by the printer. The source
+// type is guessed by the parser: scripts with HTML-like comments are printed
+// with line comments, and may be parsed as modules.
+var stripped = map[string]bool{
+	"start": true, "end": true, "loc": true, "range": true, "extra": true,
+	"comments": true, "leadingComments": true, "trailingComments": true, "innerComments": true,
+	"sourceType": true,
+}
+
+// strip removes positions, formatting details and comments from the AST.
+func strip(n nodes.Node) nodes.Node {
+	switch n := n.(type) {
+	case nodes.Object:
+		out := make(nodes.Object, len(n))
+		for k, v := range n {
+			if stripped[k] {
+				continue
+			}
+			out[k] = strip(v)
+		}
+		return out
+	case nodes.Array:
+		out := make(nodes.Array, len(n))
+		for i, v := range n {
+			out[i] = strip(v)
+		}
+		return out
+	}
+	return n
+}
+
+func TestPrintFixtures(t *testing.T) {
+	for _, path := range nativeFixtures(t) {
+		name := strings.TrimSuffix(filepath.Base(path), ".native")
+		t.Run(name, func(t *testing.T) {
+			ast := fixturetest.Load(t, path)
+			out, err := Print(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(out) == "" && len(ast.(nodes.Object)) != 0 {
+				src, _ := ioutil.ReadFile(strings.TrimSuffix(path, ".native"))
+				if strings.TrimSpace(string(src)) != "" {
+					t.Fatal("empty output")
+				}
+			}
+			// must work without the raw values as well
+			if _, err = (Config{Indent: "\t", NoComments: true}).Print(strip(ast)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestPrintAnnotated checks that the same code is generated from the
+// Preprocessed and the Annotated AST.
+func TestPrintAnnotated(t *testing.T) {
+	annotate := transformer.Mappings(normalizer.Annotations...)
+	for _, path := range nativeFixtures(t) {
+		name := strings.TrimSuffix(filepath.Base(path), ".native")
+		t.Run(name, func(t *testing.T) {
+			ast := fixturetest.Load(t, path)
+			var err error
+			for _, tr := range normalizer.Preprocess {
+				ast, err = tr.Do(ast)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			exp, err := Print(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ann, err := annotate.Do(ast.Clone())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Print(ann)
+			if err == errNoTemplateValue {
+				t.Skip("annotated AST has no template values")
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got != exp {
+				t.Fatalf("unexpected output:\n%s\nexpected:\n%s", got, exp)
+			}
+		})
+	}
+}
+
+// TestRoundTrip parses the generated code with the in-process parser and
+// checks that the AST is the same as the original one.
+func TestRoundTrip(t *testing.T) {
+	roundTrip(t, parser.NewDriver(0))
+}
+
+// TestRoundTripNative is the same as TestRoundTrip, but it uses the native
+// driver, thus it requires the native driver to be built.
+func TestRoundTripNative(t *testing.T) {
+	if _, err := os.Stat(nativeBin); err != nil {
+		t.Skip("native driver is not built")
+	}
+	d := native.NewDriverAt(nativeBin, native.UTF8)
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	roundTrip(t, d)
+}
+
+func roundTrip(t *testing.T, d driver.Native) {
+	ctx := context.Background()
+	for _, path := range nativeFixtures(t) {
+		name := strings.TrimSuffix(filepath.Base(path), ".native")
+		t.Run(name, func(t *testing.T) {
+			ast := fixturetest.Load(t, path)
+			out, err := Print(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast2, err := d.Parse(ctx, out)
+			if err != nil {
+				t.Fatalf("cannot parse generated code: %v\n%s", err, out)
+			}
+			if !nodes.Equal(strip(ast), strip(ast2)) {
+				t.Fatalf("AST changed after the round trip:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestPrintExpression(t *testing.T) {
+	id := func(name string) nodes.Object {
+		return nodes.Object{"type": nodes.String("Identifier"), "name": nodes.String(name)}
+	}
+	bin := func(op string, l, r nodes.Object) nodes.Object {
+		return nodes.Object{
+			"type": nodes.String("BinaryExpression"), "operator": nodes.String(op),
+			"left": l, "right": r,
+		}
+	}
+	cases := []struct {
+		name string
+		ast  nodes.Object
+		exp  string
+	}{
+		{"left assoc", bin("-", bin("-", id("a"), id("b")), id("c")), "a - b - c"},
+		{"right operand", bin("-", id("a"), bin("-", id("b"), id("c"))), "a - (b - c)"},
+		{"precedence", bin("*", bin("+", id("a"), id("b")), id("c")), "(a + b) * c"},
+		{"exponent", bin("**", bin("**", id("a"), id("b")), id("c")), "(a ** b) ** c"},
+		{"object statement", nodes.Object{
+			"type": nodes.String("ExpressionStatement"),
+			"expression": nodes.Object{
+				"type":       nodes.String("ObjectExpression"),
+				"properties": nodes.Array{},
+			},
+		}, "({});"},
+		{"optional chain", nodes.Object{
+			"type": nodes.String("MemberExpression"),
+			"object": nodes.Object{
+				"type":     nodes.String("OptionalMemberExpression"),
+				"object":   id("a"),
+				"property": id("b"),
+				"optional": nodes.Bool(true),
+			},
+			"property": id("c"),
+		}, "(a?.b).c"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := Print(c.ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(out); got != c.exp {
+				t.Fatalf("unexpected output: %q, expected: %q", got, c.exp)
+			}
+		})
+	}
+}
+
+func TestPrintSource(t *testing.T) {
+	cases := []struct {
+		name, src, exp string
+	}{
+		{"keyword type", "x;let x: delete", "x;\nlet x: delete;"},
+		{"keyword types", "type T = typeof x | void | this", "type T = typeof x | void | this;"},
+	}
+	d := parser.NewDriver(0)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ast, err := d.Parse(context.Background(), c.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := Print(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(out); got != c.exp {
+				t.Fatalf("unexpected output: %q, expected: %q", got, c.exp)
+			}
+		})
+	}
+}
@@ -0,0 +1,659 @@
+package printer
+
+import (
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/scope"
+)
+
+func isStatement(n nodes.Object) bool {
+	typ := typeOf(n)
+	switch typ {
+	case "Program", "Directive", "TypeAlias", "OpaqueType":
+		return true
+	case "ExportSpecifier", "ExportDefaultSpecifier", "ExportNamespaceSpecifier":
+		return false
+	}
+	return strings.HasSuffix(typ, "Statement") ||
+		strings.HasSuffix(typ, "Declaration") ||
+		strings.HasPrefix(typ, "Declare")
+}
+
+// statements writes a list of statements, each on a separate line.
+func (p *printer) statements(arr nodes.Array) {
+	for _, s := range arr {
+		s := asObject(s)
+		if s == nil {
+			continue
+		}
+		p.statement(s)
+		if !p.lineStart {
+			p.newline()
+		}
+	}
+}
+
+// statement writes a single statement with its comments.
+func (p *printer) statement(n nodes.Object) {
+	p.comments(list(n, "leadingComments"), commentOwnLine)
+	p.statementInner(n)
+	p.trailing(n)
+}
+
+func (p *printer) statementInner(n nodes.Object) {
+	switch typeOf(n) {
+	case "Program":
+		if in := field(n, "interpreter"); in != nil {
+			p.write("#!" + strField(in, "value"))
+			p.newline()
+		}
+		p.statements(list(n, "directives"))
+		p.statements(list(n, "body"))
+		p.comments(list(n, "innerComments"), commentOwnLine)
+	case "Directive":
+		lit := field(n, "value")
+		if raw, ok := rawOf(lit); ok {
+			p.write(raw)
+		} else {
+			p.write(quote(strField(lit, "value"), '"'))
+		}
+		p.write(";")
+	case "BlockStatement":
+		p.block(n)
+	case "EmptyStatement":
+		p.write(";")
+	case "DebuggerStatement":
+		p.write("debugger;")
+	case "ExpressionStatement":
+		p.expressionStatement(field(n, "expression"))
+	case "VariableDeclaration":
+		p.variables(n)
+		p.write(";")
+	case "FunctionDeclaration":
+		p.function(n)
+	case "ClassDeclaration":
+		p.class(n)
+	case "ReturnStatement":
+		p.write("return")
+		p.restricted(field(n, "argument"))
+		p.write(";")
+	case "ThrowStatement":
+		p.write("throw")
+		p.restricted(field(n, "argument"))
+		p.write(";")
+	case "BreakStatement", "ContinueStatement":
+		if typeOf(n) == "BreakStatement" {
+			p.write("break")
+		} else {
+			p.write("continue")
+		}
+		if l := field(n, "label"); l != nil {
+			p.write(" ")
+			p.expr(l, precPrimary)
+		}
+		p.write(";")
+	case "LabeledStatement":
+		p.expr(field(n, "label"), precPrimary)
+		p.write(": ")
+		p.statement(field(n, "body"))
+	case "IfStatement":
+		p.write("if (")
+		p.expr(field(n, "test"), precSeq)
+		p.write(")")
+		cons := field(n, "consequent")
+		p.body(cons)
+		if alt := field(n, "alternate"); alt != nil {
+			if typeOf(cons) == "BlockStatement" && !p.lineStart {
+				p.write(" ")
+			} else if !p.lineStart {
+				p.newline()
+			}
+			p.write("else")
+			if typeOf(alt) == "IfStatement" {
+				p.write(" ")
+				p.statement(alt)
+			} else {
+				p.body(alt)
+			}
+		}
+	case "WithStatement":
+		p.write("with (")
+		p.expr(field(n, "object"), precSeq)
+		p.write(")")
+		p.body(field(n, "body"))
+	case "WhileStatement":
+		p.write("while (")
+		p.expr(field(n, "test"), precSeq)
+		p.write(")")
+		p.body(field(n, "body"))
+	case "DoWhileStatement":
+		p.write("do")
+		body := field(n, "body")
+		p.body(body)
+		if typeOf(body) == "BlockStatement" && !p.lineStart {
+			p.write(" ")
+		} else if !p.lineStart {
+			p.newline()
+		}
+		p.write("while (")
+		p.expr(field(n, "test"), precSeq)
+		p.write(");")
+	case "ForStatement":
+		p.write("for (")
+		if init := field(n, "init"); init != nil {
+			p.noIn = true
+			if typeOf(init) == "VariableDeclaration" {
+				p.variables(init)
+			} else {
+				p.expr(init, precSeq)
+			}
+			p.noIn = false
+		}
+		p.write(";")
+		if test := field(n, "test"); test != nil {
+			p.write(" ")
+			p.expr(test, precSeq)
+		}
+		p.write(";")
+		if upd := field(n, "update"); upd != nil {
+			p.write(" ")
+			p.expr(upd, precSeq)
+		}
+		p.write(")")
+		p.body(field(n, "body"))
+	case "ForInStatement", "ForOfStatement":
+		p.write("for ")
+		if isTrue(n, "await") {
+			p.write("await ")
+		}
+		p.write("(")
+		left := field(n, "left")
+		if typeOf(left) == "VariableDeclaration" {
+			p.variables(left)
+		} else {
+			p.expr(left, precCall)
+		}
+		if typeOf(n) == "ForInStatement" {
+			p.write(" in ")
+			p.expr(field(n, "right"), precSeq)
+		} else {
+			p.write(" of ")
+			p.expr(field(n, "right"), precAssign)
+		}
+		p.write(")")
+		p.body(field(n, "body"))
+	case "SwitchStatement":
+		p.write("switch (")
+		p.expr(field(n, "discriminant"), precSeq)
+		p.write(") {")
+		p.newline()
+		for _, c := range list(n, "cases") {
+			c := asObject(c)
+			p.comments(list(c, "leadingComments"), commentOwnLine)
+			if test := field(c, "test"); test != nil {
+				p.write("case ")
+				p.expr(test, precSeq)
+				p.write(":")
+			} else {
+				p.write("default:")
+			}
+			p.comments(list(c, "trailingComments"), commentAfter)
+			if !p.lineStart {
+				p.newline()
+			}
+			p.indent++
+			p.statements(list(c, "consequent"))
+			p.indent--
+		}
+		p.write("}")
+	case "TryStatement":
+		p.write("try ")
+		p.block(field(n, "block"))
+		if h := field(n, "handler"); h != nil {
+			p.comments(list(h, "leadingComments"), commentAfter)
+			p.write(" catch")
+			if param := field(h, "param"); param != nil {
+				p.write(" (")
+				p.expr(param, precAssign)
+				p.write(")")
+			}
+			p.write(" ")
+			p.block(field(h, "body"))
+		}
+		if f := field(n, "finalizer"); f != nil {
+			p.write(" finally ")
+			p.block(f)
+		}
+	case "ImportDeclaration":
+		p.importDecl(n)
+	case "ExportNamedDeclaration":
+		p.exportNamed(n)
+	case "ExportDefaultDeclaration":
+		p.write("export default ")
+		decl := field(n, "declaration")
+		switch typeOf(decl) {
+		case "FunctionDeclaration", "ClassDeclaration":
+			p.statement(decl)
+		default:
+			s := p.capture(func() { p.expr(decl, precAssign) })
+			if startsWithKeyword(s, "function") || startsWithKeyword(s, "class") ||
+				strings.HasPrefix(s, "async function") {
+				s = "(" + s + ")"
+			}
+			p.raw(s)
+			p.write(";")
+		}
+	case "ExportAllDeclaration":
+		p.write("export ")
+		if strField(n, "exportKind") == "type" {
+			p.write("type ")
+		}
+		p.write("* from ")
+		p.expr(field(n, "source"), precPrimary)
+		p.write(";")
+	default:
+		if !p.flowDeclaration(n) {
+			p.unsupported(n)
+		}
+	}
+}
+
+// block writes a block statement.
+func (p *printer) block(n nodes.Object) {
+	p.braces(n, func() {
+		p.statements(list(n, "directives"))
+		p.statements(list(n, "body"))
+	})
+}
+
+// classBody writes a body of a class.
+func (p *printer) classBody(n nodes.Object) {
+	p.braces(n, func() {
+		p.members(list(n, "body"))
+	})
+}
+
+// braces writes the code in curly braces on separate lines.
+// Empty blocks only contain inner comments, if any.
+func (p *printer) braces(n nodes.Object, fn func()) {
+	p.write("{")
+	if len(list(n, "directives")) == 0 && len(list(n, "body")) == 0 {
+		if p.hasComments(list(n, "innerComments")) {
+			p.newline()
+			p.indent++
+			p.comments(list(n, "innerComments"), commentOwnLine)
+			p.indent--
+		}
+		p.write("}")
+		return
+	}
+	p.newline()
+	p.indent++
+	fn()
+	p.comments(list(n, "innerComments"), commentOwnLine)
+	p.indent--
+	p.write("}")
+}
+
+// body writes a body of a control flow statement.
+func (p *printer) body(n nodes.Object) {
+	if typeOf(n) == "EmptyStatement" {
+		p.statement(n)
+		return
+	}
+	p.write(" ")
+	p.statement(n)
+}
+
+// restricted writes an optional argument of return or throw statements.
+// No line terminator is allowed between the keyword and the argument.
+func (p *printer) restricted(arg nodes.Object) {
+	if arg == nil {
+		return
+	}
+	p.write(" ")
+	s := p.capture(func() { p.expr(arg, precSeq) })
+	if strings.Contains(s, "\n") && hasLeadingLineBreak(s) {
+		s = "(" + s + ")"
+	}
+	p.raw(s)
+}
+
+// hasLeadingLineBreak checks if the code starts with a line comment.
+func hasLeadingLineBreak(s string) bool {
+	s = strings.TrimLeft(s, " ")
+	for strings.HasPrefix(s, "/*") {
+		i := strings.Index(s, "*/")
+		if i < 0 {
+			return false
+		}
+		if strings.Contains(s[:i], "\n") {
+			return true
+		}
+		s = strings.TrimLeft(s[i+2:], " ")
+	}
+	return strings.HasPrefix(s, "//") || strings.HasPrefix(s, "\n")
+}
+
+func isIdentPart(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// startsWithKeyword checks if the code starts with a given keyword.
+func startsWithKeyword(s, kw string) bool {
+	return strings.HasPrefix(s, kw) && (len(s) == len(kw) || !isIdentPart(s[len(kw)]))
+}
+
+func (p *printer) expressionStatement(e nodes.Object) {
+	s := p.capture(func() { p.expr(e, precSeq) })
+	if strings.HasPrefix(s, "{") ||
+		startsWithKeyword(s, "function") || startsWithKeyword(s, "class") ||
+		strings.HasPrefix(s, "async function") || strings.HasPrefix(s, "let [") {
+		s = "(" + s + ")"
+	}
+	p.raw(s)
+	p.write(";")
+}
+
+// variables writes a variable declaration without the trailing semicolon.
+func (p *printer) variables(n nodes.Object) {
+	if isTrue(n, "declare") {
+		p.write("declare ")
+	}
+	p.write(strField(n, "kind"))
+	for i, d := range list(n, "declarations") {
+		d := asObject(d)
+		if i == 0 {
+			p.write(" ")
+		} else {
+			p.write(", ")
+		}
+		p.comments(list(d, "leadingComments"), commentBefore)
+		p.expr(field(d, "id"), precAssign)
+		if init := field(d, "init"); init != nil {
+			p.write(" = ")
+			p.expr(init, precAssign)
+		}
+		p.comments(list(d, "trailingComments"), commentAfter)
+	}
+}
+
+// function writes a function declaration or expression.
+func (p *printer) function(n nodes.Object) {
+	if isTrue(n, "declare") {
+		p.write("declare ")
+	}
+	if isTrue(n, "async") {
+		p.write("async ")
+	}
+	p.write("function")
+	if isTrue(n, "generator") {
+		p.write("*")
+	}
+	if id := field(n, "id"); id != nil {
+		p.write(" ")
+		p.expr(id, precPrimary)
+	}
+	p.signature(n)
+	p.write(" ")
+	p.block(field(n, "body"))
+}
+
+// signature writes type parameters, parameters and a return type of a function.
+func (p *printer) signature(n nodes.Object) {
+	p.flowType(field(n, "typeParameters"))
+	p.params(list(n, "params"))
+	p.returnType(n)
+}
+
+func (p *printer) params(arr nodes.Array) {
+	p.write("(")
+	for i, a := range arr {
+		if i != 0 {
+			p.write(", ")
+		}
+		p.expr(asObject(a), precAssign)
+	}
+	p.write(")")
+}
+
+func (p *printer) returnType(n nodes.Object) {
+	rt, pred := field(n, "returnType"), field(n, "predicate")
+	if rt != nil {
+		p.flowType(rt)
+	}
+	if pred != nil {
+		if rt == nil {
+			p.write(":")
+		}
+		p.write(" ")
+		p.flowType(pred)
+	}
+}
+
+func (p *printer) decorators(n nodes.Object) {
+	for _, d := range list(n, "decorators") {
+		p.write("@")
+		p.expr(field(asObject(d), "expression"), precCall)
+		p.write(" ")
+	}
+}
+
+// class writes a class declaration or expression.
+func (p *printer) class(n nodes.Object) {
+	p.decorators(n)
+	if isTrue(n, "declare") {
+		p.write("declare ")
+	}
+	if isTrue(n, "abstract") {
+		p.write("abstract ")
+	}
+	p.write("class")
+	if id := field(n, "id"); id != nil {
+		p.write(" ")
+		p.expr(id, precPrimary)
+	}
+	p.flowType(field(n, "typeParameters"))
+	if sup := field(n, "superClass"); sup != nil {
+		p.write(" extends ")
+		p.expr(sup, precCall)
+		p.flowType(field(n, "superTypeParameters"))
+	}
+	p.typeList(" implements ", list(n, "implements"))
+	p.write(" ")
+	p.classBody(field(n, "body"))
+}
+
+// members writes class body members, each on a separate line.
+func (p *printer) members(arr nodes.Array) {
+	for _, m := range arr {
+		m := asObject(m)
+		p.comments(list(m, "leadingComments"), commentOwnLine)
+		p.member(m)
+		p.trailing(m)
+		if !p.lineStart {
+			p.newline()
+		}
+	}
+}
+
+func (p *printer) member(n nodes.Object) {
+	p.decorators(n)
+	if isTrue(n, "declare") {
+		p.write("declare ")
+	}
+	if isTrue(n, "static") {
+		p.write("static ")
+	}
+	switch typeOf(n) {
+	case "ClassMethod", "ClassPrivateMethod":
+		p.method(n)
+	case "ClassProperty", "ClassPrivateProperty":
+		p.flowType(field(n, "variance"))
+		p.key(n)
+		if isTrue(n, "optional") {
+			p.write("?")
+		}
+		p.flowType(field(n, "typeAnnotation"))
+		if v := field(n, "value"); v != nil {
+			p.write(" = ")
+			p.expr(v, precAssign)
+		}
+		p.write(";")
+	default:
+		p.unsupported(n)
+	}
+}
+
+// method writes a class or object method, starting from the key.
+func (p *printer) method(n nodes.Object) {
+	switch kind := strField(n, "kind"); kind {
+	case "get", "set":
+		p.write(kind + " ")
+	}
+	if isTrue(n, "async") {
+		p.write("async ")
+	}
+	if isTrue(n, "generator") {
+		p.write("*")
+	}
+	p.key(n)
+	if isTrue(n, "optional") {
+		p.write("?")
+	}
+	p.signature(n)
+	p.write(" ")
+	p.block(field(n, "body"))
+}
+
+// key writes a key of an object property or a class member.
+func (p *printer) key(n nodes.Object) {
+	k := field(n, "key")
+	if isTrue(n, "computed") {
+		p.write("[")
+		p.expr(k, precAssign)
+		p.write("]")
+		return
+	}
+	p.expr(k, precPrimary)
+}
+
+func (p *printer) importDecl(n nodes.Object) {
+	p.write("import ")
+	switch kind := strField(n, "importKind"); kind {
+	case "type", "typeof":
+		p.write(kind + " ")
+	}
+	specs := list(n, "specifiers")
+	if len(specs) != 0 {
+		var named []nodes.Object
+		first := true
+		for _, s := range specs {
+			s := asObject(s)
+			switch typeOf(s) {
+			case "ImportDefaultSpecifier":
+				if !first {
+					p.write(", ")
+				}
+				p.expr(field(s, "local"), precPrimary)
+			case "ImportNamespaceSpecifier":
+				if !first {
+					p.write(", ")
+				}
+				p.write("* as ")
+				p.expr(field(s, "local"), precPrimary)
+			default:
+				named = append(named, s)
+				continue
+			}
+			first = false
+		}
+		if len(named) != 0 {
+			if !first {
+				p.write(", ")
+			}
+			p.write("{")
+			for i, s := range named {
+				if i != 0 {
+					p.write(", ")
+				}
+				switch kind := strField(s, "importKind"); kind {
+				case "type", "typeof":
+					p.write(kind + " ")
+				}
+				p.alias(field(s, "imported"), field(s, "local"))
+			}
+			p.write("}")
+		}
+		p.write(" from ")
+	}
+	p.expr(field(n, "source"), precPrimary)
+	p.write(";")
+}
+
+// alias writes "name" or "name as local" for import and export specifiers.
+func (p *printer) alias(name, local nodes.Object) {
+	p.expr(name, precPrimary)
+	if local == nil {
+		return
+	}
+	if typeOf(name) == typeOf(local) && scope.NameOf(name) == scope.NameOf(local) {
+		return
+	}
+	p.write(" as ")
+	p.expr(local, precPrimary)
+}
+
+func (p *printer) exportNamed(n nodes.Object) {
+	p.write("export ")
+	if decl := field(n, "declaration"); decl != nil {
+		p.statement(decl)
+		return
+	}
+	if strField(n, "exportKind") == "type" {
+		p.write("type ")
+	}
+	var named []nodes.Object
+	first := true
+	for _, s := range list(n, "specifiers") {
+		s := asObject(s)
+		switch typeOf(s) {
+		case "ExportDefaultSpecifier":
+			if !first {
+				p.write(", ")
+			}
+			p.expr(field(s, "exported"), precPrimary)
+		case "ExportNamespaceSpecifier":
+			if !first {
+				p.write(", ")
+			}
+			p.write("* as ")
+			p.expr(field(s, "exported"), precPrimary)
+		default:
+			named = append(named, s)
+			continue
+		}
+		first = false
+	}
+	if len(named) != 0 || first {
+		if !first {
+			p.write(", ")
+		}
+		p.write("{")
+		for i, s := range named {
+			if i != 0 {
+				p.write(", ")
+			}
+			p.alias(field(s, "local"), field(s, "exported"))
+		}
+		p.write("}")
+	}
+	if src := field(n, "source"); src != nil {
+		p.write(" from ")
+		p.expr(src, precPrimary)
+	}
+	p.write(";")
+}
@@ -0,0 +1,109 @@
+package resolve
+
+import (
+	"path/filepath"
+
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/scope"
+)
+
+const (
+	// KeyResolved is the field set by Annotate on import nodes. It contains a
+	// slash-separated path of the resolved file relative to the project root.
+	KeyResolved = "resolved"
+	// KeyBuiltin is the field set by Annotate on imports of Node.js core modules.
+	KeyBuiltin = "builtin"
+)
+
+// Import is a single import found in the file.
+type Import struct {
+	// Node is the import node: ImportDeclaration, ExportNamedDeclaration,
+	// ExportAllDeclaration or uast:Import.
+	Node nodes.Object
+	// Spec is the module specifier, as written in the source.
+	Spec string
+	// Result is the resolved module, or nil if it cannot be resolved.
+	Result *Result
+	// Err is the resolution error, if any.
+	Err error
+}
+
+// importSpec returns a module specifier of an import node, if any.
+func importSpec(n nodes.Object) (string, bool) {
+	switch scope.TypeOf(n) {
+	case "ImportDeclaration", "ExportNamedDeclaration", "ExportAllDeclaration":
+		src, _ := n["source"].(nodes.Object)
+		if src == nil {
+			return "", false
+		}
+		switch scope.TypeOf(src) {
+		case "StringLiteral":
+			v, ok := src["value"].(nodes.String)
+			return string(v), ok
+		case "uast:String":
+			v, ok := src["Value"].(nodes.String)
+			return string(v), ok
+		}
+	case "uast:Import":
+		return importPath(n["Path"])
+	}
+	return "", false
+}
+
+func importPath(n nodes.Node) (string, bool) {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return "", false
+	}
+	switch scope.TypeOf(obj) {
+	case "uast:String":
+		v, ok := obj["Value"].(nodes.String)
+		return string(v), ok
+	case "uast:Alias":
+		return importPath(obj["Node"])
+	}
+	return "", false
+}
+
+// Imports resolves all static imports and re-exports of a file.
+func (r *Resolver) Imports(root nodes.Node, file string) []Import {
+	var out []Import
+	nodes.WalkPreOrder(root, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok {
+			return true
+		}
+		spec, ok := importSpec(obj)
+		if !ok {
+			return true
+		}
+		imp := Import{Node: obj, Spec: spec}
+		imp.Result, imp.Err = r.Resolve(file, spec)
+		out = append(out, imp)
+		return false
+	})
+	return out
+}
+
+// Annotate resolves all imports of a file and sets KeyResolved or KeyBuiltin
+// fields on the import nodes. Unresolved imports are left intact.
+// The tree is modified in place.
+func (r *Resolver) Annotate(root nodes.Node, file string) []Import {
+	imps := r.Imports(root, file)
+	for _, imp := range imps {
+		if imp.Result == nil {
+			continue
+		}
+		if imp.Result.Builtin {
+			imp.Node[KeyBuiltin] = nodes.String(imp.Result.Package)
+			continue
+		}
+		rel, err := filepath.Rel(r.Root, imp.Result.Path)
+		if err != nil {
+			continue
+		}
+		imp.Node[KeyResolved] = nodes.String(filepath.ToSlash(rel))
+	}
+	return imps
+}
@@ -0,0 +1,29 @@
+package resolve
+
+import "strings"
+
+// builtins is a list of Node.js core modules.
+var builtins = map[string]bool{
+	"assert": true, "async_hooks": true, "buffer": true, "child_process": true,
+	"cluster": true, "console": true, "constants": true, "crypto": true,
+	"dgram": true, "dns": true, "domain": true, "events": true, "fs": true,
+	"http": true, "http2": true, "https": true, "inspector": true, "module": true,
+	"net": true, "os": true, "path": true, "perf_hooks": true, "process": true,
+	"punycode": true, "querystring": true, "readline": true, "repl": true,
+	"stream": true, "string_decoder": true, "sys": true, "timers": true,
+	"tls": true, "trace_events": true, "tty": true, "url": true, "util": true,
+	"v8": true, "vm": true, "wasi": true, "worker_threads": true, "zlib": true,
+}
+
+// IsBuiltin checks if a specifier refers to a Node.js core module.
+func IsBuiltin(spec string) bool {
+	if strings.HasPrefix(spec, "node:") {
+		return true
+	}
+	name := spec
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		// fs/promises, path/posix, etc
+		name = name[:i]
+	}
+	return builtins[name]
+}
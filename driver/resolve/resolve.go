@@ -0,0 +1,411 @@
+// Package resolve implements Node.js-style module specifier resolution over
+// a local file tree.
+//
+// It resolves relative paths, index files, file extensions, node_modules
+// packages and the main, module and exports fields of package.json, without
+// running Node.js.
+package resolve
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	// DefaultExtensions is the list of extensions tried for extension-less specifiers.
+	DefaultExtensions = []string{".js", ".jsx", ".mjs", ".cjs", ".json"}
+
+	// DefaultMainFields is the list of package.json fields that point to the
+	// package entry point, in order of preference.
+	DefaultMainFields = []string{"module", "main"}
+
+	// DefaultConditions is the list of conditions for the package.json exports
+	// field, in order of preference.
+	DefaultConditions = []string{"import", "module", "require", "node", "default"}
+)
+
+// NotFoundError is returned when a specifier cannot be resolved.
+type NotFoundError struct {
+	Spec string
+	From string
+}
+
+func (e *NotFoundError) Error() string {
+	return "cannot resolve " + e.Spec + " from " + e.From
+}
+
+// IsNotFound checks if an error is NotFoundError.
+func IsNotFound(err error) bool {
+	_, ok := err.(*NotFoundError)
+	return ok
+}
+
+// InvalidPackageError is returned when package.json of a package cannot be
+// used for resolution, like an exports field that mixes subpaths and conditions.
+type InvalidPackageError struct {
+	// Dir is the package directory.
+	Dir    string
+	Reason string
+}
+
+func (e *InvalidPackageError) Error() string {
+	return "invalid package.json in " + e.Dir + ": " + e.Reason
+}
+
+// Result is a resolved module.
+type Result struct {
+	// Path is an absolute path of the resolved file. It is empty for builtins.
+	Path string
+	// Builtin is set for Node.js core modules like "fs" or "node:path".
+	Builtin bool
+	// Package is the name of the package for specifiers resolved through node_modules.
+	Package string
+}
+
+// Resolver resolves module specifiers for files in a single project.
+//
+// Zero values of optional fields are replaced with defaults.
+type Resolver struct {
+	// Root is the project root. Resolution never looks for node_modules above it,
+	// and absolute specifiers like "/lib/a" are resolved relative to it.
+	Root string
+	// Extensions to try for the specifiers without an extension.
+	Extensions []string
+	// MainFields to try in package.json when a package or a directory is imported.
+	MainFields []string
+	// Conditions for package.json exports, in order of preference.
+	Conditions []string
+
+	pkgs map[string]*packageJSON
+}
+
+// New creates a resolver for a given project root.
+func New(root string) (*Resolver, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{Root: abs}, nil
+}
+
+func (r *Resolver) extensions() []string {
+	if len(r.Extensions) != 0 {
+		return r.Extensions
+	}
+	return DefaultExtensions
+}
+
+func (r *Resolver) mainFields() []string {
+	if len(r.MainFields) != 0 {
+		return r.MainFields
+	}
+	return DefaultMainFields
+}
+
+func (r *Resolver) conditions() []string {
+	if len(r.Conditions) != 0 {
+		return r.Conditions
+	}
+	return DefaultConditions
+}
+
+// Resolve resolves a specifier imported from a given file. The file path can
+// be either absolute or relative to the project root.
+// It returns NotFoundError if the specifier cannot be resolved, and
+// InvalidPackageError if package.json of the imported package is invalid.
+func (r *Resolver) Resolve(from, spec string) (*Result, error) {
+	if !filepath.IsAbs(from) {
+		from = filepath.Join(r.Root, from)
+	}
+	notFound := &NotFoundError{Spec: spec, From: from}
+	if spec == "" {
+		return nil, notFound
+	}
+	// strip query and fragment, as used by bundlers
+	if i := strings.IndexAny(spec, "?#"); i > 0 {
+		spec = spec[:i]
+	}
+	dir := filepath.Dir(from)
+	switch {
+	case spec == "." || spec == ".." || strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../"):
+		p := filepath.Join(dir, filepath.FromSlash(spec))
+		if f, ok := r.loadFileOrDir(p, strings.HasSuffix(spec, "/")); ok {
+			return &Result{Path: f}, nil
+		}
+		return nil, notFound
+	case strings.HasPrefix(spec, "/"):
+		p := filepath.Join(r.Root, filepath.FromSlash(spec))
+		if f, ok := r.loadFileOrDir(p, strings.HasSuffix(spec, "/")); ok {
+			return &Result{Path: f}, nil
+		}
+		return nil, notFound
+	case IsBuiltin(spec):
+		return &Result{Builtin: true, Package: strings.TrimPrefix(spec, "node:")}, nil
+	}
+	name, sub := splitPackage(spec)
+	if name == "" {
+		return nil, notFound
+	}
+	for d := dir; ; d = filepath.Dir(d) {
+		if filepath.Base(d) != "node_modules" {
+			pdir := filepath.Join(d, "node_modules", filepath.FromSlash(name))
+			if isDir(pdir) {
+				f, ok, err := r.loadPackage(pdir, sub)
+				if err != nil {
+					return nil, err
+				} else if ok {
+					return &Result{Path: f, Package: name}, nil
+				}
+			}
+		}
+		if !r.inRoot(d) || d == r.Root || filepath.Dir(d) == d {
+			break
+		}
+	}
+	return nil, notFound
+}
+
+func (r *Resolver) inRoot(p string) bool {
+	rel, err := filepath.Rel(r.Root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// splitPackage splits a bare specifier into a package name and a subpath.
+func splitPackage(spec string) (name, sub string) {
+	parts := strings.SplitN(spec, "/", 3)
+	n := 1
+	if strings.HasPrefix(spec, "@") {
+		if len(parts) < 2 {
+			return "", ""
+		}
+		n = 2
+	}
+	if len(parts) <= n {
+		return spec, ""
+	}
+	name = strings.Join(parts[:n], "/")
+	return name, strings.TrimPrefix(spec, name+"/")
+}
+
+func isFile(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+func isDir(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && fi.IsDir()
+}
+
+func (r *Resolver) loadFileOrDir(p string, dirOnly bool) (string, bool) {
+	if !dirOnly {
+		if f, ok := r.loadFile(p); ok {
+			return f, true
+		}
+	}
+	return r.loadDir(p)
+}
+
+func (r *Resolver) loadFile(p string) (string, bool) {
+	if isFile(p) {
+		return p, true
+	}
+	for _, ext := range r.extensions() {
+		if isFile(p + ext) {
+			return p + ext, true
+		}
+	}
+	return "", false
+}
+
+func (r *Resolver) loadIndex(p string) (string, bool) {
+	for _, ext := range r.extensions() {
+		f := filepath.Join(p, "index"+ext)
+		if isFile(f) {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+func (r *Resolver) loadDir(p string) (string, bool) {
+	if pkg := r.readPackage(p); pkg != nil {
+		for _, field := range r.mainFields() {
+			m := pkg.main(field)
+			if m == "" {
+				continue
+			}
+			mp := filepath.Join(p, filepath.FromSlash(m))
+			if f, ok := r.loadFile(mp); ok {
+				return f, true
+			}
+			if f, ok := r.loadIndex(mp); ok {
+				return f, true
+			}
+		}
+	}
+	return r.loadIndex(p)
+}
+
+// loadPackage resolves a subpath of a package located in a given directory.
+func (r *Resolver) loadPackage(dir, sub string) (string, bool, error) {
+	if pkg := r.readPackage(dir); pkg != nil && pkg.Exports != nil {
+		key := "."
+		if sub != "" {
+			key = "./" + sub
+		}
+		target, ok, err := r.resolveExports(pkg.Exports, key)
+		if err != nil {
+			return "", false, &InvalidPackageError{Dir: dir, Reason: err.Error()}
+		} else if !ok {
+			return "", false, nil
+		}
+		f := filepath.Join(dir, filepath.FromSlash(target))
+		return f, isFile(f), nil
+	}
+	var (
+		f  string
+		ok bool
+	)
+	if sub == "" {
+		f, ok = r.loadDir(dir)
+	} else {
+		f, ok = r.loadFileOrDir(filepath.Join(dir, filepath.FromSlash(sub)), strings.HasSuffix(sub, "/"))
+	}
+	return f, ok, nil
+}
+
+// packageJSON is a subset of package.json used for resolution.
+type packageJSON struct {
+	Name    string                     `json:"name"`
+	Fields  map[string]json.RawMessage `json:"-"`
+	Exports interface{}                `json:"exports"`
+}
+
+func (p *packageJSON) main(field string) string {
+	raw, ok := p.Fields[field]
+	if !ok {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return ""
+	}
+	return s
+}
+
+func (r *Resolver) readPackage(dir string) *packageJSON {
+	if pkg, ok := r.pkgs[dir]; ok {
+		return pkg
+	}
+	if r.pkgs == nil {
+		r.pkgs = make(map[string]*packageJSON)
+	}
+	var pkg *packageJSON
+	data, err := ioutil.ReadFile(filepath.Join(dir, "package.json"))
+	if err == nil {
+		var p packageJSON
+		if json.Unmarshal(data, &p) == nil && json.Unmarshal(data, &p.Fields) == nil {
+			pkg = &p
+		}
+	}
+	r.pkgs[dir] = pkg
+	return pkg
+}
+
+// resolveExports finds a target path for a subpath key (like "." or "./sub")
+// in the exports field of package.json.
+func (r *Resolver) resolveExports(exports interface{}, key string) (string, bool, error) {
+	m, _ := exports.(map[string]interface{})
+	sub, err := isSubpathMap(m)
+	if err != nil {
+		return "", false, err
+	} else if !sub {
+		// the whole value describes the "." subpath
+		if key != "." {
+			return "", false, nil
+		}
+		t, ok := r.resolveTarget(exports, "")
+		return t, ok, nil
+	}
+	if v, ok := m[key]; ok {
+		t, ok := r.resolveTarget(v, "")
+		return t, ok, nil
+	}
+	// subpath patterns; the longest prefix wins, equal lengths are ordered
+	// by the key itself to not depend on the order of the map
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		if i := strings.IndexByte(k, '*'); i >= 0 {
+			pref, suff := k[:i], k[i+1:]
+			if strings.HasPrefix(key, pref) && strings.HasSuffix(key, suff) && len(key) >= len(pref)+len(suff) {
+				t, ok := r.resolveTarget(m[k], key[len(pref):len(key)-len(suff)])
+				return t, ok, nil
+			}
+		} else if strings.HasSuffix(k, "/") && strings.HasPrefix(key, k) {
+			// deprecated folder mappings
+			t, ok := r.resolveTarget(m[k], "")
+			if !ok {
+				return "", false, nil
+			}
+			return t + key[len(k):], true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// isSubpathMap reports if the keys of an exports object are subpaths, like
+// ".", rather than conditions. Node.js rejects objects that mix both.
+func isSubpathMap(m map[string]interface{}) (bool, error) {
+	subpaths := 0
+	for k := range m {
+		if strings.HasPrefix(k, ".") {
+			subpaths++
+		}
+	}
+	if subpaths != 0 && subpaths != len(m) {
+		return false, errors.New("exports mix subpaths and conditions")
+	}
+	return subpaths != 0, nil
+}
+
+// resolveTarget resolves conditions and pattern substitutions of a single exports target.
+func (r *Resolver) resolveTarget(v interface{}, match string) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		if !strings.HasPrefix(v, "./") {
+			return "", false
+		}
+		return path.Clean(strings.Replace(v, "*", match, -1)), true
+	case []interface{}:
+		for _, t := range v {
+			if s, ok := r.resolveTarget(t, match); ok {
+				return s, true
+			}
+		}
+	case map[string]interface{}:
+		for _, c := range r.conditions() {
+			if t, ok := v[c]; ok {
+				if s, ok := r.resolveTarget(t, match); ok {
+					return s, true
+				}
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,190 @@
+package resolve
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/uastyaml"
+)
+
+// writeTree creates files in a temporary directory.
+func writeTree(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "resolve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+var testTree = map[string]string{
+	"src/app.js":                     "",
+	"src/util.jsx":                   "",
+	"src/data.json":                  "{}",
+	"src/lib/index.mjs":              "",
+	"src/dir/package.json":           `{"main": "entry"}`,
+	"src/dir/entry.cjs":              "",
+	"services/environment.js":        "",
+	"node_modules/left/package.json": `{"main": "lib/left.js", "module": "es/left.js"}`,
+	"node_modules/left/lib/left.js":  "",
+	"node_modules/left/es/left.js":   "",
+	"node_modules/left/extra.js":     "",
+	"node_modules/plain/index.js":    "",
+	"node_modules/@scope/pkg/package.json": `{
+		"exports": {
+			".": {"require": "./cjs/index.js", "import": "./esm/index.js"},
+			"./feature": "./src/feature.js",
+			"./utils/*": "./src/utils/*.js"
+		}
+	}`,
+	"node_modules/@scope/pkg/cjs/index.js":      "",
+	"node_modules/@scope/pkg/esm/index.js":      "",
+	"node_modules/@scope/pkg/src/feature.js":    "",
+	"node_modules/@scope/pkg/src/utils/date.js": "",
+	"node_modules/@scope/pkg/private.js":        "",
+	"node_modules/sugar/package.json":           `{"exports": "./sugar.js"}`,
+	"node_modules/sugar/sugar.js":               "",
+	// patterns of the same length match the same subpath
+	"node_modules/ties/package.json": `{
+		"exports": {"./x/*.js": "./first/*.js", "./*/y.js": "./second/*.js"}
+	}`,
+	"node_modules/ties/first/y.js":  "",
+	"node_modules/ties/second/x.js": "",
+	"node_modules/mixed/package.json": `{
+		"exports": {".": "./index.js", "import": "./esm.js"}
+	}`,
+	"node_modules/mixed/index.js": "",
+	"node_modules/mixed/esm.js":   "",
+}
+
+var casesResolve = []struct {
+	from, spec string
+	exp        string // empty for not found
+	builtin    bool
+}{
+	{from: "src/app.js", spec: "./util", exp: "src/util.jsx"},
+	{from: "src/app.js", spec: "./util.jsx", exp: "src/util.jsx"},
+	{from: "src/app.js", spec: "./data", exp: "src/data.json"},
+	{from: "src/app.js", spec: "./lib", exp: "src/lib/index.mjs"},
+	{from: "src/app.js", spec: "./dir", exp: "src/dir/entry.cjs"},
+	{from: "src/app.js", spec: "../services/environment", exp: "services/environment.js"},
+	{from: "src/app.js", spec: "/services/environment", exp: "services/environment.js"},
+	{from: "src/app.js", spec: "./missing"},
+	{from: "src/app.js", spec: "left", exp: "node_modules/left/es/left.js"},
+	{from: "src/app.js", spec: "left/extra", exp: "node_modules/left/extra.js"},
+	{from: "src/app.js", spec: "plain", exp: "node_modules/plain/index.js"},
+	{from: "src/app.js", spec: "@scope/pkg", exp: "node_modules/@scope/pkg/esm/index.js"},
+	{from: "src/app.js", spec: "@scope/pkg/feature", exp: "node_modules/@scope/pkg/src/feature.js"},
+	{from: "src/app.js", spec: "@scope/pkg/utils/date", exp: "node_modules/@scope/pkg/src/utils/date.js"},
+	{from: "src/app.js", spec: "@scope/pkg/private"},
+	{from: "src/app.js", spec: "sugar", exp: "node_modules/sugar/sugar.js"},
+	{from: "src/app.js", spec: "ties/x/y.js", exp: "node_modules/ties/second/x.js"},
+	{from: "src/app.js", spec: "unknown"},
+	{from: "src/app.js", spec: "fs", builtin: true},
+	{from: "src/app.js", spec: "node:path", builtin: true},
+	{from: "src/app.js", spec: "fs/promises", builtin: true},
+}
+
+func TestResolve(t *testing.T) {
+	dir := writeTree(t, testTree)
+	defer os.RemoveAll(dir)
+
+	r, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range casesResolve {
+		res, err := r.Resolve(c.from, c.spec)
+		if c.exp == "" && !c.builtin {
+			if !IsNotFound(err) {
+				t.Errorf("%s: expected not found error, got: %v, %+v", c.spec, err, res)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.spec, err)
+			continue
+		}
+		if res.Builtin != c.builtin {
+			t.Errorf("%s: unexpected builtin flag: %v", c.spec, res.Builtin)
+		}
+		if c.builtin {
+			continue
+		}
+		exp := filepath.Join(dir, filepath.FromSlash(c.exp))
+		if res.Path != exp {
+			t.Errorf("%s: expected %q, got %q", c.spec, exp, res.Path)
+		}
+	}
+}
+
+func TestResolveMixedExports(t *testing.T) {
+	dir := writeTree(t, testTree)
+	defer os.RemoveAll(dir)
+
+	// the keys are checked in the order of the map, which changes between runs
+	for i := 0; i < 20; i++ {
+		r, err := New(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = r.Resolve("src/app.js", "mixed")
+		if _, ok := err.(*InvalidPackageError); !ok {
+			t.Fatalf("expected invalid package error, got: %v", err)
+		}
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"src/app.js":                       "",
+		"services/environment.js":          "",
+		"node_modules/react/index.js":      "",
+		"src/primitives/PickerDropDown.js": "",
+	})
+	defer os.RemoveAll(dir)
+
+	data, err := ioutil.ReadFile("../../fixtures/issue69-70.js.uast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := uastyaml.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pretend the fixture is located two levels deep
+	imps := r.Annotate(root, "src/components/LocationPicker.js")
+	resolved := make(map[string]string)
+	for _, imp := range imps {
+		if v, ok := imp.Node[KeyResolved].(nodes.String); ok {
+			resolved[imp.Spec] = string(v)
+		}
+	}
+	exp := map[string]string{
+		"react":                      "node_modules/react/index.js",
+		"../../services/environment": "services/environment.js",
+	}
+	if len(resolved) != len(exp) {
+		t.Fatalf("unexpected resolved imports: %v", resolved)
+	}
+	for k, v := range exp {
+		if resolved[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, resolved[k])
+		}
+	}
+}
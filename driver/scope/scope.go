@@ -0,0 +1,735 @@
+// Package scope implements lexical scope analysis for JavaScript UAST.
+//
+// It walks a tree produced by this driver in Preprocessed or Annotated mode,
+// builds JavaScript scopes (module, function, function body, block, catch,
+// class and named function expression scopes) and resolves each identifier reference to the
+// declaration it binds to.
+package scope
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+// KeyBinding is the field set by Annotate on declaring and referencing
+// identifiers. It contains the ID of the Binding the identifier refers to.
+const KeyBinding = "binding"
+
+// ErrNoProgram is returned when the tree has no JavaScript File or Program node.
+var ErrNoProgram = errors.New("scope: no Program node found")
+
+// Kind is a kind of the lexical scope.
+type Kind int
+
+const (
+	// Module is the top-level scope of a file.
+	Module = Kind(iota)
+	// Function is a scope of function parameters. Default values of the
+	// parameters are resolved in it, thus they do not see the declarations
+	// of the function body.
+	Function
+	// Block is a scope of let, const and class declarations in a block.
+	Block
+	// Catch is a scope of the catch clause parameter.
+	Catch
+	// Class is a scope of a class body; it binds the name of a class expression.
+	Class
+	// FunctionName is a scope that binds the name of a named function expression.
+	FunctionName
+	// FunctionBody is a scope of the declarations in a function body. It is a
+	// child of the Function scope. A var that redeclares a parameter refers to
+	// the binding of the parameter.
+	FunctionBody
+)
+
+var kindNames = []string{
+	Module:       "module",
+	Function:     "function",
+	Block:        "block",
+	Catch:        "catch",
+	Class:        "class",
+	FunctionName: "function-name",
+	FunctionBody: "function-body",
+}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "unknown"
+}
+
+// DeclKind is a kind of the declaration that introduced a binding.
+type DeclKind int
+
+const (
+	Var = DeclKind(iota)
+	Let
+	Const
+	FunctionDecl
+	ClassDecl
+	Param
+	Import
+	CatchParam
+)
+
+var declKindNames = []string{
+	Var:          "var",
+	Let:          "let",
+	Const:        "const",
+	FunctionDecl: "function",
+	ClassDecl:    "class",
+	Param:        "param",
+	Import:       "import",
+	CatchParam:   "catch",
+}
+
+func (k DeclKind) String() string {
+	if int(k) < len(declKindNames) {
+		return declKindNames[k]
+	}
+	return "unknown"
+}
+
+// Binding is a single declared name.
+type Binding struct {
+	// ID is a unique binding ID in the file, starting from 1.
+	ID   int
+	Name string
+	Kind DeclKind
+	// Scope is the scope the binding is declared in.
+	Scope *Scope
+	// Node is the declaration node, e.g. VariableDeclarator, FunctionDeclaration,
+	// ImportSpecifier or the function that declares a parameter.
+	Node nodes.Object
+	// Decls are the identifiers that declare this binding. There might be more
+	// than one, for example for repeated var declarations.
+	Decls []nodes.Object
+	// Refs are the identifiers referencing this binding.
+	Refs []nodes.Object
+}
+
+// Scope is a single lexical scope.
+type Scope struct {
+	Kind Kind
+	// Node is the node that created the scope.
+	Node     nodes.Object
+	Parent   *Scope
+	Children []*Scope
+	Bindings map[string]*Binding
+}
+
+// Lookup finds a binding visible in this scope by name.
+func (s *Scope) Lookup(name string) *Binding {
+	for ; s != nil; s = s.Parent {
+		if b := s.Bindings[name]; b != nil {
+			return b
+		}
+	}
+	return nil
+}
+
+// Info is the result of a scope analysis.
+type Info struct {
+	// Root is the module scope.
+	Root *Scope
+	// Bindings lists all bindings in declaration order. The binding with ID
+	// N is at index N-1.
+	Bindings []*Binding
+	// Unresolved are the references that do not bind to any declaration in the
+	// file. Usually those are globals.
+	Unresolved []nodes.Object
+
+	idents map[nodes.Comparable]*Binding
+	scopes map[nodes.Comparable]*Scope
+}
+
+// BindingOf returns a binding for a declaring or referencing identifier,
+// or nil if it is unresolved or is not an identifier.
+func (info *Info) BindingOf(ident nodes.Object) *Binding {
+	return info.idents[nodes.UniqueKey(ident)]
+}
+
+// ScopeOf returns a scope created by a given node, or nil if the node does not
+// create a scope.
+func (info *Info) ScopeOf(n nodes.Object) *Scope {
+	return info.scopes[nodes.UniqueKey(n)]
+}
+
+// Analyze builds the scopes for a UAST of a JavaScript file and resolves all
+// identifier references.
+func Analyze(root nodes.Node) (*Info, error) {
+	prog := findProgram(root)
+	if prog == nil {
+		return nil, ErrNoProgram
+	}
+	a := &analyzer{info: &Info{
+		idents: make(map[nodes.Comparable]*Binding),
+		scopes: make(map[nodes.Comparable]*Scope),
+	}}
+	a.program(prog)
+	return a.info, nil
+}
+
+// Annotate runs Analyze and sets the KeyBinding field on every resolved
+// identifier. The tree is modified in place.
+func Annotate(root nodes.Node) (*Info, error) {
+	info, err := Analyze(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range info.Bindings {
+		id := nodes.Int(b.ID)
+		for _, n := range b.Decls {
+			n[KeyBinding] = id
+		}
+		for _, n := range b.Refs {
+			n[KeyBinding] = id
+		}
+	}
+	return info, nil
+}
+
+func findProgram(root nodes.Node) nodes.Object {
+	var prog nodes.Object
+	nodes.WalkPreOrder(root, func(n nodes.Node) bool {
+		if prog != nil {
+			return false
+		}
+		obj, ok := n.(nodes.Object)
+		if !ok {
+			return true
+		}
+		if TypeOf(obj) == "Program" {
+			prog = obj
+			return false
+		}
+		return true
+	})
+	return prog
+}
+
+// TypeOf returns a native type of the node without the driver namespace.
+// It works for both the native AST and the UAST.
+func TypeOf(n nodes.Node) string {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return ""
+	}
+	typ, ok := obj[uast.KeyType].(nodes.String)
+	if !ok {
+		typ, _ = obj["type"].(nodes.String)
+	}
+	s := string(typ)
+	if strings.HasPrefix(s, "uast:") {
+		return s
+	}
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		s = s[i+1:]
+	}
+	return s
+}
+
+// NameOf returns a name of an identifier node.
+func NameOf(n nodes.Node) string {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return ""
+	}
+	for _, k := range []string{"name", uast.KeyToken, "Name"} {
+		if s, ok := obj[k].(nodes.String); ok {
+			return string(s)
+		}
+	}
+	return ""
+}
+
+func isFunction(typ string) bool {
+	switch typ {
+	case "FunctionDeclaration", "FunctionExpression", "ArrowFunctionExpression",
+		"ObjectMethod", "ClassMethod", "ClassPrivateMethod":
+		return true
+	}
+	return false
+}
+
+func field(obj nodes.Object, k string) nodes.Object {
+	v, _ := obj[k].(nodes.Object)
+	return v
+}
+
+func list(obj nodes.Object, k string) nodes.Array {
+	v, _ := obj[k].(nodes.Array)
+	return v
+}
+
+func isTrue(obj nodes.Object, k string) bool {
+	v, _ := obj[k].(nodes.Bool)
+	return bool(v)
+}
+
+func strField(obj nodes.Object, k string) string {
+	v, _ := obj[k].(nodes.String)
+	return string(v)
+}
+
+// skipFields are never walked: they contain positions, comments or Flow types.
+var skipFields = map[string]bool{
+	uast.KeyPos:           true,
+	uast.KeyRoles:         true,
+	"loc":                 true,
+	"comments":            true,
+	"leadingComments":     true,
+	"trailingComments":    true,
+	"innerComments":       true,
+	"typeAnnotation":      true,
+	"returnType":          true,
+	"typeParameters":      true,
+	"superTypeParameters": true,
+	"predicate":           true,
+	"implements":          true,
+}
+
+// skipTypes are declarations that only exist in the type namespace.
+var skipTypes = map[string]bool{
+	"TypeAlias":                true,
+	"OpaqueType":               true,
+	"InterfaceDeclaration":     true,
+	"DeclareClass":             true,
+	"DeclareFunction":          true,
+	"DeclareVariable":          true,
+	"DeclareModule":            true,
+	"DeclareModuleExports":     true,
+	"DeclareTypeAlias":         true,
+	"DeclareOpaqueType":        true,
+	"DeclareInterface":         true,
+	"DeclareExportDeclaration": true,
+}
+
+type analyzer struct {
+	info *Info
+	cur  *Scope
+}
+
+func (a *analyzer) push(kind Kind, n nodes.Object) *Scope {
+	s := &Scope{
+		Kind:     kind,
+		Node:     n,
+		Parent:   a.cur,
+		Bindings: make(map[string]*Binding),
+	}
+	if a.cur != nil {
+		a.cur.Children = append(a.cur.Children, s)
+	} else {
+		a.info.Root = s
+	}
+	if n != nil {
+		if _, ok := a.info.scopes[nodes.UniqueKey(n)]; !ok {
+			a.info.scopes[nodes.UniqueKey(n)] = s
+		}
+	}
+	a.cur = s
+	return s
+}
+
+func (a *analyzer) pop() {
+	a.cur = a.cur.Parent
+}
+
+// declare adds a declaring identifier to a binding in scope s,
+// creating the binding if necessary.
+func (a *analyzer) declare(s *Scope, kind DeclKind, decl, ident nodes.Object) {
+	name := NameOf(ident)
+	if name == "" {
+		return
+	}
+	key := nodes.UniqueKey(ident)
+	if _, ok := a.info.idents[key]; ok {
+		return
+	}
+	b := s.Bindings[name]
+	if b == nil && kind == Var && s.Kind == FunctionBody {
+		if p := s.Parent.Bindings[name]; p != nil && p.Kind == Param {
+			b = p
+		}
+	}
+	if b == nil {
+		b = &Binding{
+			ID:    len(a.info.Bindings) + 1,
+			Name:  name,
+			Kind:  kind,
+			Scope: s,
+			Node:  decl,
+		}
+		s.Bindings[name] = b
+		a.info.Bindings = append(a.info.Bindings, b)
+	}
+	b.Decls = append(b.Decls, ident)
+	a.info.idents[key] = b
+}
+
+// declarePattern declares all identifiers bound by a pattern.
+func (a *analyzer) declarePattern(s *Scope, kind DeclKind, decl, pat nodes.Object) {
+	switch TypeOf(pat) {
+	case "Identifier", "uast:Identifier":
+		a.declare(s, kind, decl, pat)
+	case "ObjectPattern":
+		for _, p := range list(pat, "properties") {
+			p, _ := p.(nodes.Object)
+			switch TypeOf(p) {
+			case "ObjectProperty":
+				a.declarePattern(s, kind, decl, field(p, "value"))
+			case "RestElement":
+				a.declarePattern(s, kind, decl, field(p, "argument"))
+			}
+		}
+	case "ArrayPattern":
+		for _, p := range list(pat, "elements") {
+			p, _ := p.(nodes.Object)
+			a.declarePattern(s, kind, decl, p)
+		}
+	case "RestElement":
+		a.declarePattern(s, kind, decl, field(pat, "argument"))
+	case "AssignmentPattern":
+		a.declarePattern(s, kind, decl, field(pat, "left"))
+	}
+}
+
+// reference resolves an identifier in the current scope.
+func (a *analyzer) reference(ident nodes.Object) {
+	key := nodes.UniqueKey(ident)
+	if _, ok := a.info.idents[key]; ok {
+		return // declaration
+	}
+	b := a.cur.Lookup(NameOf(ident))
+	if b == nil {
+		a.info.Unresolved = append(a.info.Unresolved, ident)
+		return
+	}
+	b.Refs = append(b.Refs, ident)
+	a.info.idents[key] = b
+}
+
+func varKind(decl nodes.Object) DeclKind {
+	switch strField(decl, "kind") {
+	case "let":
+		return Let
+	case "const":
+		return Const
+	}
+	return Var
+}
+
+// hoistVars declares all var declarations and function parameters
+// of a function-level scope, not descending into nested functions.
+func (a *analyzer) hoistVars(s *Scope, n nodes.Node) {
+	switch n := n.(type) {
+	case nodes.Array:
+		for _, v := range n {
+			a.hoistVars(s, v)
+		}
+	case nodes.Object:
+		typ := TypeOf(n)
+		if isFunction(typ) || skipTypes[typ] {
+			return
+		}
+		switch typ {
+		case "ClassDeclaration", "ClassExpression":
+			return
+		case "VariableDeclaration":
+			if varKind(n) == Var {
+				for _, d := range list(n, "declarations") {
+					d, _ := d.(nodes.Object)
+					a.declarePattern(s, Var, d, field(d, "id"))
+				}
+			}
+		}
+		for _, k := range n.Keys() {
+			if skipFields[k] {
+				continue
+			}
+			switch n[k].(type) {
+			case nodes.Object, nodes.Array:
+				a.hoistVars(s, n[k])
+			}
+		}
+	}
+}
+
+// hoistLexical declares let, const, class, function and import declarations
+// that appear directly in a statement list.
+func (a *analyzer) hoistLexical(s *Scope, stmts nodes.Array) {
+	for _, st := range stmts {
+		st, _ := st.(nodes.Object)
+		switch TypeOf(st) {
+		case "ExportNamedDeclaration", "ExportDefaultDeclaration":
+			if d := field(st, "declaration"); d != nil {
+				a.hoistLexical(s, nodes.Array{d})
+			}
+		case "VariableDeclaration":
+			if kind := varKind(st); kind != Var {
+				for _, d := range list(st, "declarations") {
+					d, _ := d.(nodes.Object)
+					a.declarePattern(s, kind, d, field(d, "id"))
+				}
+			}
+		case "FunctionDeclaration":
+			if id := field(st, "id"); id != nil {
+				a.declare(s, FunctionDecl, st, id)
+			}
+		case "ClassDeclaration":
+			if id := field(st, "id"); id != nil {
+				a.declare(s, ClassDecl, st, id)
+			}
+		case "ImportDeclaration":
+			for _, sp := range list(st, "specifiers") {
+				sp, _ := sp.(nodes.Object)
+				if l := field(sp, "local"); l != nil {
+					a.declare(s, Import, sp, l)
+				}
+			}
+		}
+	}
+}
+
+func (a *analyzer) program(prog nodes.Object) {
+	s := a.push(Module, prog)
+	body := list(prog, "body")
+	a.hoistLexical(s, body)
+	a.hoistVars(s, body)
+	a.walk(body)
+	a.pop()
+}
+
+func (a *analyzer) function(fn nodes.Object) {
+	typ := TypeOf(fn)
+	switch typ {
+	case "ObjectMethod", "ClassMethod":
+		if isTrue(fn, "computed") {
+			a.walk(fn["key"])
+		}
+	}
+	named := false
+	if id := field(fn, "id"); id != nil && typ == "FunctionExpression" {
+		named = true
+		s := a.push(FunctionName, nil)
+		a.declare(s, FunctionDecl, fn, id)
+	}
+	s := a.push(Function, fn)
+	params := list(fn, "params")
+	for _, p := range params {
+		p, _ := p.(nodes.Object)
+		a.declarePattern(s, Param, fn, p)
+	}
+	for _, p := range params {
+		a.pattern(p)
+	}
+	body := field(fn, "body")
+	if TypeOf(body) == "BlockStatement" {
+		bs := a.push(FunctionBody, body)
+		stmts := list(body, "body")
+		a.hoistLexical(bs, stmts)
+		a.hoistVars(bs, stmts)
+		a.walk(stmts)
+		a.pop()
+	} else {
+		a.walk(body)
+	}
+	a.pop()
+	if named {
+		a.pop()
+	}
+}
+
+func (a *analyzer) class(cls nodes.Object) {
+	a.walk(cls["superClass"])
+	a.walk(cls["decorators"])
+	s := a.push(Class, cls)
+	if id := field(cls, "id"); id != nil && TypeOf(cls) == "ClassExpression" {
+		a.declare(s, ClassDecl, cls, id)
+	}
+	a.walk(cls["body"])
+	a.pop()
+}
+
+// pattern walks the default values and computed keys of a declaring pattern.
+// Identifiers that are declared by the pattern are skipped.
+func (a *analyzer) pattern(n nodes.Node) {
+	pat, ok := n.(nodes.Object)
+	if !ok {
+		return
+	}
+	switch TypeOf(pat) {
+	case "Identifier", "uast:Identifier":
+		a.reference(pat)
+	case "ObjectPattern":
+		for _, p := range list(pat, "properties") {
+			p, _ := p.(nodes.Object)
+			switch TypeOf(p) {
+			case "ObjectProperty":
+				if isTrue(p, "computed") {
+					a.walk(p["key"])
+				}
+				a.pattern(p["value"])
+			case "RestElement":
+				a.pattern(p["argument"])
+			}
+		}
+	case "ArrayPattern":
+		for _, p := range list(pat, "elements") {
+			a.pattern(p)
+		}
+	case "RestElement":
+		a.pattern(pat["argument"])
+	case "AssignmentPattern":
+		a.pattern(pat["left"])
+		a.walk(pat["right"])
+	default:
+		a.walk(pat)
+	}
+}
+
+func (a *analyzer) block(n nodes.Object, stmts nodes.Array) {
+	s := a.push(Block, n)
+	a.hoistLexical(s, stmts)
+	a.walk(stmts)
+	a.pop()
+}
+
+func (a *analyzer) walk(n nodes.Node) {
+	switch n := n.(type) {
+	case nodes.Array:
+		for _, v := range n {
+			a.walk(v)
+		}
+		return
+	case nodes.Object:
+		a.walkObject(n)
+	}
+}
+
+func (a *analyzer) walkObject(n nodes.Object) {
+	typ := TypeOf(n)
+	if skipTypes[typ] {
+		return
+	}
+	switch typ {
+	case "Identifier", "uast:Identifier":
+		a.reference(n)
+		return
+	case "FunctionDeclaration", "FunctionExpression", "ArrowFunctionExpression",
+		"ObjectMethod", "ClassMethod", "ClassPrivateMethod":
+		a.walk(n["decorators"])
+		a.function(n)
+		return
+	case "ClassDeclaration", "ClassExpression":
+		a.class(n)
+		return
+	case "BlockStatement":
+		a.block(n, list(n, "body"))
+		return
+	case "ForStatement", "ForInStatement", "ForOfStatement":
+		init := field(n, "init")
+		if init == nil {
+			init = field(n, "left")
+		}
+		if TypeOf(init) == "VariableDeclaration" && varKind(init) != Var {
+			a.push(Block, n)
+			a.hoistLexical(a.cur, nodes.Array{init})
+			a.walkFields(n)
+			a.pop()
+			return
+		}
+	case "SwitchStatement":
+		a.walk(n["discriminant"])
+		s := a.push(Block, n)
+		for _, c := range list(n, "cases") {
+			c, _ := c.(nodes.Object)
+			a.hoistLexical(s, list(c, "consequent"))
+		}
+		a.walk(n["cases"])
+		a.pop()
+		return
+	case "CatchClause":
+		s := a.push(Catch, n)
+		if p := field(n, "param"); p != nil {
+			a.declarePattern(s, CatchParam, n, p)
+			a.pattern(p)
+		}
+		a.walk(n["body"])
+		a.pop()
+		return
+	case "VariableDeclaration":
+		for _, d := range list(n, "declarations") {
+			d, _ := d.(nodes.Object)
+			a.pattern(d["id"])
+			a.walk(d["init"])
+		}
+		return
+	case "JSXMemberExpression":
+		// the object is always a reference, regardless of the case
+		if obj := field(n, "object"); TypeOf(obj) == "JSXIdentifier" {
+			a.reference(obj)
+		} else {
+			a.walk(obj)
+		}
+		return
+	case "MemberExpression", "OptionalMemberExpression":
+		a.walk(n["object"])
+		if isTrue(n, "computed") {
+			a.walk(n["property"])
+		}
+		return
+	case "ObjectProperty", "ClassProperty", "ClassPrivateProperty":
+		a.walk(n["decorators"])
+		if isTrue(n, "computed") {
+			a.walk(n["key"])
+		}
+		a.walk(n["value"])
+		return
+	case "LabeledStatement":
+		a.walk(n["body"])
+		return
+	case "BreakStatement", "ContinueStatement", "MetaProperty", "PrivateName",
+		"ImportDeclaration", "ExportAllDeclaration", "JSXNamespacedName",
+		"CommentLine", "CommentBlock", "uast:Comment":
+		return
+	case "ExportNamedDeclaration":
+		a.walk(n["declaration"])
+		if field(n, "source") == nil {
+			for _, sp := range list(n, "specifiers") {
+				sp, _ := sp.(nodes.Object)
+				a.walk(sp["local"])
+			}
+		}
+		return
+	case "JSXIdentifier":
+		// lowercase names are intrinsic elements, not references
+		if name := NameOf(n); name != "" && strings.ToLower(name[:1]) != name[:1] {
+			a.reference(n)
+		}
+		return
+	case "JSXAttribute":
+		a.walk(n["value"])
+		return
+	case "TypeCastExpression":
+		a.walk(n["expression"])
+		return
+	}
+	a.walkFields(n)
+}
+
+func (a *analyzer) walkFields(n nodes.Object) {
+	for _, k := range n.Keys() {
+		if skipFields[k] {
+			continue
+		}
+		switch v := n[k].(type) {
+		case nodes.Object, nodes.Array:
+			a.walk(v)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package scope
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/internal/fixturetest"
+	"github.com/bblfsh/javascript-driver/driver/parser"
+)
+
+// summary describes every binding as "name:kind:scope:decls:refs".
+func summary(info *Info) []string {
+	var out []string
+	for _, b := range info.Bindings {
+		out = append(out, b.Name+":"+b.Kind.String()+":"+b.Scope.Kind.String()+
+			":"+strconv.Itoa(len(b.Decls))+":"+strconv.Itoa(len(b.Refs)))
+	}
+	return out
+}
+
+func unresolved(info *Info) []string {
+	var out []string
+	for _, n := range info.Unresolved {
+		out = append(out, NameOf(n))
+	}
+	sort.Strings(out)
+	return out
+}
+
+var casesAnalyze = []struct {
+	name       string
+	bindings   []string
+	unresolved []string
+}{
+	{
+		name: "bench_fibonacci.js",
+		bindings: []string{
+			"fib:function:module:1:2",
+			"n:param:function:1:4",
+		},
+	},
+	{
+		name: "bench_accumulator_factory.js",
+		bindings: []string{
+			"accumulator:let:module:1:2",
+			"x:let:module:1:2",
+			"sum:param:function:1:1",
+			"n:param:function:1:1",
+		},
+		unresolved: []string{"console", "console"},
+	},
+	{
+		name: "bench_mutual_recursion.js",
+		bindings: []string{
+			"f:function:module:1:2",
+			"m:function:module:1:2",
+			"range:function:module:1:0",
+			"num:param:function:1:3",
+			"num:param:function:1:3",
+			"m:param:function:1:2",
+			"n:param:function:1:1",
+			"x:param:function:1:0",
+			"i:param:function:1:1",
+		},
+		unresolved: []string{"Array", "Array"},
+	},
+	{
+		name: "try-statement.js",
+		bindings: []string{
+			"e:catch:catch:1:0",
+			"e:catch:catch:1:0",
+		},
+	},
+	{
+		name: "u2_import_subsymbol_alias.js",
+		bindings: []string{
+			"name1:import:module:1:0",
+			"name2:import:module:1:0",
+			"name3:import:module:1:0",
+		},
+	},
+	{
+		name: "for-of-statement.js",
+		bindings: []string{
+			"x:let:block:1:0",
+		},
+		unresolved: []string{"y"},
+	},
+	{
+		name: "u2_class_method.js",
+		bindings: []string{
+			"testcls1:function:module:3:2",
+			"testcls2:function:module:3:2",
+			"testcls5:class:module:1:0",
+			"testcls3:var:module:1:0",
+			"testcls4:var:module:1:0",
+		},
+		unresolved: []string{"Object", "Object", "Object", "Object"},
+	},
+}
+
+func TestAnalyze(t *testing.T) {
+	for _, c := range casesAnalyze {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Analyze(fixturetest.LoadUAST(t, c.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summary(info); !fixturetest.Equal(got, c.bindings) {
+				t.Errorf("unexpected bindings:\n%q\nexpected:\n%q", got, c.bindings)
+			}
+			if got := unresolved(info); !fixturetest.Equal(got, c.unresolved) {
+				t.Errorf("unexpected unresolved names:\n%q\nexpected:\n%q", got, c.unresolved)
+			}
+		})
+	}
+}
+
+var casesParams = []struct {
+	src        string
+	bindings   []string
+	unresolved []string
+}{
+	{
+		// defaults do not see the declarations of the body
+		src: "function f(a = x) { var x }",
+		bindings: []string{
+			"f:function:module:1:0",
+			"a:param:function:1:0",
+			"x:var:function-body:1:0",
+		},
+		unresolved: []string{"x"},
+	},
+	{
+		src: "var x; function f(a = x, b = a) { function x() {} x }",
+		bindings: []string{
+			"f:function:module:1:0",
+			"x:var:module:1:1",
+			"a:param:function:1:1",
+			"b:param:function:1:0",
+			"x:function:function-body:1:1",
+		},
+	},
+	{
+		// var redeclares the parameter
+		src: "function f(a) { var a; a }",
+		bindings: []string{
+			"f:function:module:1:0",
+			"a:param:function:2:1",
+		},
+	},
+}
+
+func TestAnalyzeParams(t *testing.T) {
+	d := parser.NewDriver(0)
+	for _, c := range casesParams {
+		c := c
+		t.Run(c.src, func(t *testing.T) {
+			ast, err := d.Parse(context.Background(), c.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := Analyze(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summary(info); !fixturetest.Equal(got, c.bindings) {
+				t.Errorf("unexpected bindings:\n%q\nexpected:\n%q", got, c.bindings)
+			}
+			if got := unresolved(info); !fixturetest.Equal(got, c.unresolved) {
+				t.Errorf("unexpected unresolved names:\n%q\nexpected:\n%q", got, c.unresolved)
+			}
+		})
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	root := fixturetest.LoadUAST(t, "bench_fibonacci.js")
+	info, err := Annotate(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fib := info.Root.Lookup("fib")
+	if fib == nil {
+		t.Fatal("expected fib to be declared in the module scope")
+	}
+	for _, n := range append(fib.Decls, fib.Refs...) {
+		if id, _ := n[KeyBinding].(nodes.Int); int(id) != fib.ID {
+			t.Errorf("expected binding %d, got %v", fib.ID, n[KeyBinding])
+		}
+	}
+	fn := fib.Node
+	s := info.ScopeOf(fn)
+	if s == nil || s.Kind != Function {
+		t.Fatalf("expected a function scope for fib, got %v", s)
+	}
+	if s.Lookup("n") == nil || info.Root.Lookup("n") != nil {
+		t.Error("expected n to be visible only in the function scope")
+	}
+}
+
+func TestAnalyzeAllFixtures(t *testing.T) {
+	for _, path := range fixturetest.Glob(t, "*.js.uast") {
+		name := strings.TrimSuffix(filepath.Base(path), ".uast")
+		t.Run(name, func(t *testing.T) {
+			if _, err := Analyze(fixturetest.LoadUAST(t, name)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
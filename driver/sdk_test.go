@@ -1,22 +1,51 @@
 package main_test
 
 import (
+	"fmt"
+	"path/filepath"
+	"regexp"
 	"testing"
 
 	"github.com/bblfsh/sdk/v3/build"
 )
 
+// customized are managed files that differ from the SDK templates on purpose:
+// the server is started with the driver created by impl.NewDriver, not by
+// server.Run. They must be merged by hand on SDK updates.
+var customized = map[string]bool{
+	"main.go":     true,
+	"sdk_test.go": true,
+}
+
+var changedFile = regexp.MustCompile(`^managed file changed "(.*)"`)
+
 func TestSDKUpToDate(t *testing.T) {
 	printf := func(format string, args ...interface{}) (int, error) {
 		t.Logf(format, args...)
 		return 0, nil
 	}
+	var changed []string
+	warning := func(format string, args ...interface{}) (int, error) {
+		t.Logf(format, args...)
+		if m := changedFile.FindStringSubmatch(fmt.Sprintf(format, args...)); m != nil {
+			changed = append(changed, m[1])
+		}
+		return 0, nil
+	}
 	err := build.UpdateSDK("../", &build.UpdateOptions{
 		DryRun:  true,
 		Debug:   printf,
 		Notice:  printf,
-		Warning: printf,
+		Warning: warning,
 	})
+	if err == build.ErrChangesRequired && len(changed) != 0 {
+		err = nil
+		for _, file := range changed {
+			if filepath.Dir(file) != filepath.Join("..", "driver") || !customized[filepath.Base(file)] {
+				err = build.ErrChangesRequired
+			}
+		}
+	}
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,204 @@
+package sourcemap
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/transformer/positioner"
+)
+
+// KeyOriginal is a field of nodes with the original position.
+const KeyOriginal = "original"
+
+// reURL matches sourceMappingURL comments. Both the current "#" and the
+// deprecated "@" forms are accepted.
+var reURL = regexp.MustCompile(`(?://|/\*)[#@][ \t]*sourceMappingURL=([^\s'"*]+)`)
+
+// FindURL returns the URL of the last sourceMappingURL comment of the source,
+// or an empty string if there is none.
+func FindURL(src string) string {
+	all := reURL.FindAllStringSubmatch(src, -1)
+	if len(all) == 0 {
+		return ""
+	}
+	return all[len(all)-1][1]
+}
+
+// ErrNotInline is returned by ParseInline if the source has no embedded map.
+var ErrNotInline = errors.New("source map is not inline")
+
+// ParseInline decodes a source map embedded into the source as a data URL.
+func ParseInline(src string) (*Map, error) {
+	u := FindURL(src)
+	if !strings.HasPrefix(u, "data:") {
+		return nil, ErrNotInline
+	}
+	i := strings.IndexByte(u, ',')
+	if i < 0 {
+		return nil, errors.New("invalid data url of a source map")
+	}
+	meta, data := u[len("data:"):i], u[i+1:]
+	var (
+		raw []byte
+		err error
+	)
+	if strings.HasSuffix(meta, ";base64") {
+		raw, err = base64.StdEncoding.DecodeString(data)
+	} else {
+		var s string
+		s, err = url.PathUnescape(data)
+		raw = []byte(s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(raw)
+}
+
+// Load finds a source map for a file: inline, referenced by the
+// sourceMappingURL comment, or located next to the file with the ".map"
+// extension. It returns nil if there is no source map.
+func Load(path, src string) (*Map, error) {
+	m, err := ParseInline(src)
+	if err != ErrNotInline {
+		return m, err
+	}
+	name := path + ".map"
+	if u := FindURL(src); u != "" {
+		if strings.Contains(u, "://") {
+			// remote maps are never fetched
+			return nil, nil
+		}
+		if p, err := url.PathUnescape(u); err == nil {
+			u = p
+		}
+		name = filepath.Join(filepath.Dir(path), filepath.FromSlash(u))
+	}
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Attach sets the original position of all nodes that have one. The tree
+// must be in a given mode: native nodes use Babel locations, other modes use
+// UAST positions, which are converted to UTF-16 columns using the source.
+func Attach(ast nodes.Node, src string, mode driver.Mode, m *Map) error {
+	var idx *positioner.Index
+	if mode != driver.ModeNative {
+		idx = positioner.NewIndex([]byte(src), &positioner.IndexOptions{Unicode: true})
+	}
+	var err error
+	nodes.WalkPreOrder(ast, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok || err != nil {
+			return err == nil
+		}
+		var line, col int
+		if mode == driver.ModeNative {
+			line, col, ok = nativeStart(obj)
+		} else {
+			line, col, ok, err = uastStart(idx, obj)
+		}
+		if !ok {
+			return err == nil
+		}
+		if o, ok := m.Lookup(line, col); ok {
+			orig := nodes.Object{
+				"source": nodes.String(o.Source),
+				"line":   nodes.Int(o.Line + 1),
+				"col":    nodes.Int(o.Column + 1),
+			}
+			if o.Name != "" {
+				orig["name"] = nodes.String(o.Name)
+			}
+			obj[KeyOriginal] = orig
+		}
+		return true
+	})
+	return err
+}
+
+// nativeStart returns the zero-based start line and UTF-16 column of a native node.
+func nativeStart(n nodes.Object) (int, int, bool) {
+	loc, _ := n["loc"].(nodes.Object)
+	start, _ := loc["start"].(nodes.Object)
+	line, ok1 := toInt(start["line"])
+	col, ok2 := toInt(start["column"])
+	if !ok1 || !ok2 {
+		return 0, 0, false
+	}
+	return line - 1, col, true
+}
+
+func toInt(n nodes.Node) (int, bool) {
+	switch n := n.(type) {
+	case nodes.Int:
+		return int(n), true
+	case nodes.Uint:
+		return int(n), true
+	case nodes.Float:
+		return int(n), float64(int(n)) == float64(n)
+	}
+	return 0, false
+}
+
+// uastStart returns the zero-based start line and UTF-16 column of a UAST node.
+func uastStart(idx *positioner.Index, n nodes.Object) (int, int, bool, error) {
+	if _, ok := n[uast.KeyPos]; !ok {
+		return 0, 0, false, nil
+	}
+	start := uast.PositionsOf(n).Start()
+	if start == nil || !start.HasOffset() {
+		return 0, 0, false, nil
+	}
+	line, col, err := idx.ToUTF16LineCol(int(start.Offset))
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return line - 1, col - 1, true, nil
+}
+
+// NewDriver wraps a driver to attach original positions to the nodes of
+// sources with an inline source map. Invalid maps are ignored, since the
+// source itself was parsed successfully.
+func NewDriver(d driver.DriverModule) driver.DriverModule {
+	return &mappedDriver{DriverModule: d}
+}
+
+type mappedDriver struct {
+	driver.DriverModule
+}
+
+// Parse implements driver.Driver.
+func (d *mappedDriver) Parse(ctx context.Context, src string, opts *driver.ParseOptions) (nodes.Node, error) {
+	ast, err := d.DriverModule.Parse(ctx, src, opts)
+	if err != nil {
+		return ast, err
+	}
+	m, merr := ParseInline(src)
+	if merr != nil {
+		return ast, nil
+	}
+	mode := driver.ModeDefault
+	if opts != nil && opts.Mode != 0 {
+		mode = opts.Mode
+	}
+	if err = Attach(ast, src, mode, m); err != nil {
+		return nil, driver.ErrTransformFailure.Wrap(err)
+	}
+	return ast, nil
+}
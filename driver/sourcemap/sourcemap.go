@@ -0,0 +1,269 @@
+// Package sourcemap maps positions of generated code to the original sources
+// using source maps (revision 3).
+//
+// Positions of the UAST point into the generated code, thus the original
+// source, line and column of each node are attached to it separately:
+//
+//	original: {source: "src/app.js", line: 12, col: 5, name: "render"}
+//
+// Lines and columns of the original position are one-based; columns count
+// UTF-16 code units, as in source maps.
+package sourcemap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Original is a position in an original source.
+type Original struct {
+	Source string
+	// Line and Column are zero-based, Column is in UTF-16 code units.
+	Line, Column int
+	// Name is an original name of the symbol, if known.
+	Name string
+}
+
+// Map is a decoded source map.
+type Map struct {
+	File    string
+	Sources []string
+	Names   []string
+
+	// lines of generated code, each is sorted by the generated column
+	lines [][]mapping
+
+	// sections of an index map, sorted by offsets; if set, lines are empty
+	sections []section
+}
+
+type mapping struct {
+	col    int
+	source int // -1 if the segment has no source
+	line   int
+	srcCol int
+	name   int // -1 if the segment has no name
+}
+
+type section struct {
+	line, col int
+	m         *Map
+}
+
+// file is a JSON representation of a source map.
+type file struct {
+	Version    int      `json:"version"`
+	File       string   `json:"file"`
+	SourceRoot string   `json:"sourceRoot"`
+	Sources    []string `json:"sources"`
+	Names      []string `json:"names"`
+	Mappings   string   `json:"mappings"`
+	Sections   []struct {
+		Offset struct {
+			Line   int `json:"line"`
+			Column int `json:"column"`
+		} `json:"offset"`
+		URL string `json:"url"`
+		Map *file  `json:"map"`
+	} `json:"sections"`
+}
+
+// Parse decodes a source map. Index maps are supported, as long as all
+// sections are embedded.
+func Parse(data []byte) (*Map, error) {
+	// maps served over HTTP may start with a line preventing XSSI
+	if s := string(data); strings.HasPrefix(s, ")]}'") {
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			data = data[i+1:]
+		} else {
+			data = nil
+		}
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid source map: %v", err)
+	}
+	return newMap(&f)
+}
+
+func newMap(f *file) (*Map, error) {
+	if f.Version != 3 {
+		return nil, fmt.Errorf("unsupported source map version: %d", f.Version)
+	}
+	m := &Map{File: f.File, Names: f.Names}
+	if f.Sections != nil {
+		for _, s := range f.Sections {
+			if s.Map == nil {
+				return nil, errors.New("source map sections with urls are not supported")
+			}
+			sm, err := newMap(s.Map)
+			if err != nil {
+				return nil, err
+			}
+			m.sections = append(m.sections, section{line: s.Offset.Line, col: s.Offset.Column, m: sm})
+		}
+		sort.SliceStable(m.sections, func(i, j int) bool {
+			a, b := m.sections[i], m.sections[j]
+			return a.line < b.line || (a.line == b.line && a.col < b.col)
+		})
+		return m, nil
+	}
+	root := f.SourceRoot
+	if root != "" && !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+	for _, s := range f.Sources {
+		if root != "" && !strings.HasPrefix(s, "/") && !strings.Contains(s, "://") {
+			s = root + s
+		}
+		m.Sources = append(m.Sources, s)
+	}
+	lines, err := decodeMappings(f.Mappings, len(m.Sources), len(m.Names))
+	if err != nil {
+		return nil, err
+	}
+	m.lines = lines
+	return m, nil
+}
+
+// decodeMappings decodes the mappings field. Fields of segments are relative
+// to the previous segment; the generated column is reset on each line.
+func decodeMappings(s string, sources, names int) ([][]mapping, error) {
+	var (
+		lines                         [][]mapping
+		line                          []mapping
+		source, srcLine, srcCol, name int
+	)
+	for len(s) != 0 {
+		switch s[0] {
+		case ';':
+			lines = append(lines, sortLine(line))
+			line = nil
+			s = s[1:]
+			continue
+		case ',':
+			s = s[1:]
+			continue
+		}
+		var (
+			fields [5]int
+			n      int
+		)
+		for n < len(fields) && len(s) != 0 && s[0] != ',' && s[0] != ';' {
+			v, rest, err := decodeVLQ(s)
+			if err != nil {
+				return nil, err
+			}
+			fields[n] = v
+			n++
+			s = rest
+		}
+		if len(s) != 0 && s[0] != ',' && s[0] != ';' {
+			return nil, errors.New("invalid source map: too many fields in a segment")
+		}
+		mp := mapping{source: -1, name: -1}
+		if len(line) != 0 {
+			mp.col = line[len(line)-1].col
+		}
+		mp.col += fields[0]
+		switch n {
+		case 1:
+		case 4, 5:
+			source += fields[1]
+			srcLine += fields[2]
+			srcCol += fields[3]
+			if source < 0 || source >= sources {
+				return nil, fmt.Errorf("invalid source map: source index %d is out of range", source)
+			}
+			mp.source, mp.line, mp.srcCol = source, srcLine, srcCol
+			if n == 5 {
+				name += fields[4]
+				if name < 0 || name >= names {
+					return nil, fmt.Errorf("invalid source map: name index %d is out of range", name)
+				}
+				mp.name = name
+			}
+		default:
+			return nil, fmt.Errorf("invalid source map: segment with %d fields", n)
+		}
+		line = append(line, mp)
+	}
+	return append(lines, sortLine(line)), nil
+}
+
+// sortLine sorts segments by the generated column. Most generators emit them
+// in order, thus the check is cheaper than sorting.
+func sortLine(line []mapping) []mapping {
+	if !sort.SliceIsSorted(line, func(i, j int) bool { return line[i].col < line[j].col }) {
+		sort.SliceStable(line, func(i, j int) bool { return line[i].col < line[j].col })
+	}
+	return line
+}
+
+const vlqChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// decodeVLQ decodes a single base64 VLQ value.
+func decodeVLQ(s string) (int, string, error) {
+	var (
+		v     int
+		shift uint
+	)
+	for i := 0; i < len(s); i++ {
+		d := strings.IndexByte(vlqChars, s[i])
+		if d < 0 {
+			return 0, "", fmt.Errorf("invalid source map: unexpected character %q in mappings", s[i])
+		} else if shift > 30 {
+			return 0, "", errors.New("invalid source map: value is too large")
+		}
+		v |= (d & 31) << shift
+		if d&32 == 0 {
+			if v&1 != 0 {
+				return -(v >> 1), s[i+1:], nil
+			}
+			return v >> 1, s[i+1:], nil
+		}
+		shift += 5
+	}
+	return 0, "", errors.New("invalid source map: unterminated value in mappings")
+}
+
+// Lookup returns the original position of a zero-based line and column of the
+// generated code. The column is in UTF-16 code units. It returns false if the
+// position is not mapped.
+//
+// As in browsers, the closest segment to the left on the same line is used.
+func (m *Map) Lookup(line, col int) (Original, bool) {
+	if m.sections != nil {
+		i := sort.Search(len(m.sections), func(i int) bool {
+			s := m.sections[i]
+			return s.line > line || (s.line == line && s.col > col)
+		})
+		if i == 0 {
+			return Original{}, false
+		}
+		s := m.sections[i-1]
+		if line == s.line {
+			col -= s.col
+		}
+		return s.m.Lookup(line-s.line, col)
+	}
+	if line < 0 || line >= len(m.lines) {
+		return Original{}, false
+	}
+	segs := m.lines[line]
+	i := sort.Search(len(segs), func(i int) bool {
+		return segs[i].col > col
+	})
+	if i == 0 || segs[i-1].source < 0 {
+		return Original{}, false
+	}
+	mp := segs[i-1]
+	o := Original{Source: m.Sources[mp.source], Line: mp.line, Column: mp.srcCol}
+	if mp.name >= 0 {
+		o.Name = m.Names[mp.name]
+	}
+	return o, true
+}
@@ -0,0 +1,262 @@
+package sourcemap
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/normalizer"
+	"github.com/bblfsh/javascript-driver/driver/parser"
+)
+
+// encodeVLQ is the inverse of decodeVLQ.
+func encodeVLQ(v int) string {
+	if v < 0 {
+		v = -v<<1 | 1
+	} else {
+		v <<= 1
+	}
+	var buf strings.Builder
+	for {
+		d := v & 31
+		v >>= 5
+		if v != 0 {
+			d |= 32
+		}
+		buf.WriteByte(vlqChars[d])
+		if v == 0 {
+			return buf.String()
+		}
+	}
+}
+
+// encodeMappings encodes absolute segments of generated lines.
+func encodeMappings(lines [][][]int) string {
+	var (
+		buf  strings.Builder
+		prev [5]int
+	)
+	for i, line := range lines {
+		if i != 0 {
+			buf.WriteByte(';')
+		}
+		prev[0] = 0
+		for j, seg := range line {
+			if j != 0 {
+				buf.WriteByte(',')
+			}
+			for k, v := range seg {
+				buf.WriteString(encodeVLQ(v - prev[k]))
+				prev[k] = v
+			}
+		}
+	}
+	return buf.String()
+}
+
+// original source of the test map:
+//
+//	function greet(name: string) {
+//	  return "hi " + name;
+//	}
+const testGenerated = `function greet(n){return"hi "+n}`
+
+func testMap() map[string]interface{} {
+	return map[string]interface{}{
+		"version":    3,
+		"file":       "out.js",
+		"sourceRoot": "src",
+		"sources":    []string{"greet.ts"},
+		"names":      []string{"greet", "name"},
+		"mappings": encodeMappings([][][]int{{
+			{0, 0, 0, 0},
+			{9, 0, 0, 9, 0},
+			{15, 0, 0, 15, 1},
+			{18, 0, 1, 2},
+			{24, 0, 1, 9},
+			{30, 0, 1, 17, 1},
+			{31},
+		}}),
+	}
+}
+
+func marshal(t *testing.T, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVLQ(t *testing.T) {
+	for _, v := range []int{0, 1, -1, 15, 16, -16, 1234567, -1234567} {
+		s := encodeVLQ(v)
+		got, rest, err := decodeVLQ(s + ",")
+		if err != nil {
+			t.Fatal(err)
+		} else if got != v || rest != "," {
+			t.Fatalf("%d: decoded %d, rest %q", v, got, rest)
+		}
+	}
+	for _, s := range []string{"g", "!", "gggggggggg"} {
+		if _, _, err := decodeVLQ(s); err == nil {
+			t.Fatalf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	m, err := Parse(marshal(t, testMap()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		col int
+		exp Original
+		ok  bool
+	}{
+		{col: 0, exp: Original{Source: "src/greet.ts"}, ok: true},
+		{col: 11, exp: Original{Source: "src/greet.ts", Column: 9, Name: "greet"}, ok: true},
+		{col: 30, exp: Original{Source: "src/greet.ts", Line: 1, Column: 17, Name: "name"}, ok: true},
+		{col: 31},
+		{col: 40},
+	} {
+		got, ok := m.Lookup(0, c.col)
+		if ok != c.ok || got != c.exp {
+			t.Errorf("column %d: expected %+v, got %+v", c.col, c.exp, got)
+		}
+	}
+	if _, ok := m.Lookup(1, 0); ok {
+		t.Fatal("unexpected mapping of the second line")
+	}
+}
+
+func TestSections(t *testing.T) {
+	data := marshal(t, map[string]interface{}{
+		"version": 3,
+		"sections": []interface{}{
+			map[string]interface{}{"offset": map[string]int{"line": 0, "column": 0}, "map": testMap()},
+			map[string]interface{}{"offset": map[string]int{"line": 2, "column": 4}, "map": testMap()},
+		},
+	})
+	m, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := Original{Source: "src/greet.ts", Column: 9, Name: "greet"}
+	if got, _ := m.Lookup(2, 13); got != exp {
+		t.Fatalf("unexpected position: %+v", got)
+	}
+	if got, ok := m.Lookup(2, 2); ok {
+		t.Fatalf("unexpected position: %+v", got)
+	}
+}
+
+func TestParseInline(t *testing.T) {
+	src := testGenerated + "\n//# sourceMappingURL=data:application/json;charset=utf-8;base64," +
+		base64.StdEncoding.EncodeToString(marshal(t, testMap())) + "\n"
+	m, err := ParseInline(src)
+	if err != nil {
+		t.Fatal(err)
+	} else if m.File != "out.js" {
+		t.Fatalf("unexpected file: %q", m.File)
+	}
+	if _, err = ParseInline(testGenerated); err != ErrNotInline {
+		t.Fatalf("expected no inline map, got %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sourcemap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "out.js.map"), marshal(t, testMap()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Mkdir(filepath.Join(dir, "maps"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "maps", "x.map"), marshal(t, testMap()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name, src string
+		found     bool
+	}{
+		{name: "out.js", src: testGenerated, found: true},
+		{name: "other.js", src: testGenerated},
+		{name: "other.js", src: testGenerated + "\n//# sourceMappingURL=maps/x.map", found: true},
+		{name: "out.js", src: testGenerated + "\n//# sourceMappingURL=https://example.com/x.map"},
+	} {
+		m, err := Load(filepath.Join(dir, c.name), c.src)
+		if err != nil {
+			t.Fatal(err)
+		} else if (m != nil) != c.found {
+			t.Errorf("%s: expected found=%v", c.src, c.found)
+		}
+	}
+}
+
+func TestAttach(t *testing.T) {
+	m, err := Parse(marshal(t, testMap()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	ast, err := parser.NewDriver(0).Parse(ctx, testGenerated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []driver.Mode{driver.ModeNative, driver.ModeSemantic} {
+		ast, err := normalizer.Transforms.Do(ctx, mode, testGenerated, ast.Clone())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = Attach(ast, testGenerated, mode, m); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		nodes.WalkPreOrder(ast, func(n nodes.Node) bool {
+			obj, ok := n.(nodes.Object)
+			if !ok {
+				return true
+			}
+			typ := uast.TypeOf(obj)
+			if s, ok := obj["type"].(nodes.String); ok && typ == "" {
+				typ = string(s)
+			}
+			if typ != "Identifier" && typ != "uast:Identifier" {
+				return true
+			}
+			// synthetic nodes have no position
+			orig, ok := obj[KeyOriginal].(nodes.Object)
+			if !ok {
+				return true
+			}
+			got = append(got, fmt.Sprintf("%v:%v:%v", orig["name"], orig["line"], orig["col"]))
+			return true
+		})
+		// the order of the walk is not defined
+		exp := map[string]bool{"greet:1:10": true, "name:1:16": true, "name:2:18": true}
+		for _, s := range got {
+			if !exp[s] {
+				t.Errorf("%v: unexpected identifier %s", mode, s)
+			}
+		}
+		if len(got) != 3 {
+			t.Errorf("%v: expected 3 identifiers, got %q", mode, got)
+		}
+	}
+}
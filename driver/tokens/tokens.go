@@ -0,0 +1,175 @@
+// Package tokens links the token stream of the parser to the UAST.
+//
+// If EnvTokens is set, the native parser adds a list of Token nodes to each
+// File node, including comments. Each token has a label, which is a keyword,
+// a punctuator or an operator itself, or a class of a token, like "name",
+// "string", "num", "regexp" and "template". Tokens are transformed as other
+// native nodes, thus their positions are the same as positions of the UAST.
+package tokens
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+// EnvTokens enables the token stream, if "1" or "true". It is read by both
+// the native parser and the Go parser.
+const EnvTokens = "JS_DRIVER_TOKENS"
+
+// Key is a field of the nodes with a list of tokens.
+const Key = "tokens"
+
+// Enabled reports if the token stream is enabled by EnvTokens.
+func Enabled() bool {
+	v, _ := strconv.ParseBool(os.Getenv(EnvTokens))
+	return v
+}
+
+// positioned is a node with its offsets.
+type positioned struct {
+	node       nodes.Node
+	start, end uint32
+}
+
+// Link moves tokens of File nodes of a transformed AST to the innermost nodes
+// that contain them. Each node gets a list of tokens that are not contained in
+// any of its children, in the order of the source. Nodes without positions,
+// like synthetic nodes of the semantic UAST, are skipped. Tokens without
+// positions are dropped.
+func Link(ast nodes.Node) {
+	nodes.WalkPreOrder(ast, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok {
+			return true
+		}
+		list, ok := obj[Key].(nodes.Array)
+		if !ok {
+			return true
+		}
+		delete(obj, Key)
+		toks := make([]positioned, 0, len(list))
+		for _, t := range list {
+			if start, end, ok := span(t); ok {
+				toks = append(toks, positioned{node: t, start: start, end: end})
+			}
+		}
+		sort.SliceStable(toks, func(i, j int) bool {
+			return toks[i].start < toks[j].start
+		})
+		assign(obj, toks)
+		return false
+	})
+}
+
+// span returns the offsets of a node, if it has any.
+func span(n nodes.Node) (uint32, uint32, bool) {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return 0, 0, false
+	} else if _, ok = obj[uast.KeyPos]; !ok {
+		return 0, 0, false
+	}
+	pos := uast.PositionsOf(obj)
+	start, end := pos.Start(), pos.End()
+	if start == nil || end == nil || !start.HasOffset() || !end.HasOffset() {
+		return 0, 0, false
+	}
+	return start.Offset, end.Offset, true
+}
+
+// assign distributes sorted tokens contained in a node between the node and
+// its children.
+func assign(n nodes.Object, toks []positioned) {
+	// children are listed in the order of keys and the sort is stable, thus
+	// the owner of tokens of children with the same span is deterministic
+	var kids []positioned
+	for _, k := range n.Keys() {
+		if k != uast.KeyPos && k != Key {
+			kids = children(kids, n[k])
+		}
+	}
+	sort.SliceStable(kids, func(i, j int) bool {
+		if kids[i].start != kids[j].start {
+			return kids[i].start < kids[j].start
+		}
+		return kids[i].end > kids[j].end
+	})
+	// tokens do not overlap, thus the tokens of a child are a contiguous range
+	owner := make([]int, len(toks))
+	for i := range owner {
+		owner[i] = -1
+	}
+	for k, kid := range kids {
+		i := sort.Search(len(toks), func(i int) bool {
+			return toks[i].start >= kid.start
+		})
+		for ; i < len(toks) && toks[i].end <= kid.end; i++ {
+			if owner[i] < 0 {
+				owner[i] = k
+			}
+		}
+	}
+	var (
+		own    nodes.Array
+		groups = make(map[int][]positioned)
+	)
+	for i, t := range toks {
+		if k := owner[i]; k >= 0 {
+			groups[k] = append(groups[k], t)
+		} else {
+			own = append(own, t.node)
+		}
+	}
+	for k, group := range groups {
+		assign(kids[k].node.(nodes.Object), group)
+	}
+	if len(own) != 0 {
+		n[Key] = own
+	}
+}
+
+// children appends the nearest positioned nodes of a subtree.
+func children(list []positioned, n nodes.Node) []positioned {
+	switch n := n.(type) {
+	case nodes.Object:
+		if start, end, ok := span(n); ok {
+			return append(list, positioned{node: n, start: start, end: end})
+		}
+		for _, k := range n.Keys() {
+			if k != uast.KeyPos {
+				list = children(list, n[k])
+			}
+		}
+	case nodes.Array:
+		for _, v := range n {
+			list = children(list, v)
+		}
+	}
+	return list
+}
+
+// NewDriver wraps a driver to link tokens to the nodes, as by Link. The AST
+// is returned as is in the native mode.
+func NewDriver(d driver.DriverModule) driver.DriverModule {
+	return &linkedDriver{DriverModule: d}
+}
+
+type linkedDriver struct {
+	driver.DriverModule
+}
+
+// Parse implements driver.Driver.
+func (d *linkedDriver) Parse(ctx context.Context, src string, opts *driver.ParseOptions) (nodes.Node, error) {
+	ast, err := d.DriverModule.Parse(ctx, src, opts)
+	if err != nil || (opts != nil && opts.Mode == driver.ModeNative) {
+		return ast, err
+	}
+	Link(ast)
+	return ast, nil
+}
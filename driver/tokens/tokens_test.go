@@ -0,0 +1,148 @@
+package tokens
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+
+	"github.com/bblfsh/javascript-driver/driver/normalizer"
+	"github.com/bblfsh/javascript-driver/driver/parser"
+)
+
+// non-ASCII characters check that offsets are converted from UTF-16
+const src = "/* é */ var ü = \"😀\" + f(a, b);\n"
+
+func TestLink(t *testing.T) {
+	ctx := context.Background()
+	d := parser.NewDriver(0).WithTokens()
+	for name, mode := range map[string]driver.Mode{
+		"annotated": driver.ModeAnnotated,
+		"semantic":  driver.ModeSemantic,
+	} {
+		mode := mode
+		t.Run(name, func(t *testing.T) {
+			ast, err := d.Parse(ctx, src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err = normalizer.Transforms.Do(ctx, mode, src, ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			Link(ast)
+
+			var (
+				texts []string
+				names []string
+			)
+			nodes.WalkPreOrder(ast, func(n nodes.Node) bool {
+				obj, ok := n.(nodes.Object)
+				if !ok {
+					return true
+				}
+				list, _ := obj[Key].(nodes.Array)
+				for _, tok := range list {
+					start, end, ok := span(tok)
+					if !ok {
+						t.Fatalf("no positions of a token: %v", tok)
+					}
+					pstart, pend, ok := span(obj)
+					if !ok || start < pstart || end > pend {
+						t.Errorf("token %q is not in its node", src[start:end])
+					}
+					texts = append(texts, src[start:end])
+					label := tok.(nodes.Object)["label"]
+					if label == nodes.String("name") {
+						names = append(names, uast.TypeOf(obj))
+					}
+				}
+				return true
+			})
+			exp := []string{"/* é */", "var", "ü", "=", `"😀"`, "+", "f", "(", "a", ",", "b", ")", ";", ""}
+			if !sameSet(exp, texts) {
+				t.Fatalf("unexpected tokens:\n%q\nvs\n%q", exp, texts)
+			}
+			typ := "Identifier"
+			if mode == driver.ModeSemantic {
+				typ = uast.TypeOf(uast.Identifier{})
+			}
+			for _, name := range names {
+				if name != typ {
+					t.Errorf("name token linked to %q", name)
+				}
+			}
+			if len(names) != 4 {
+				t.Errorf("expected 4 names, got %d", len(names))
+			}
+		})
+	}
+}
+
+// spanned returns a node of a given type spanning the offsets.
+func spanned(typ string, start, end uint32) nodes.Object {
+	return nodes.Object{
+		uast.KeyType: nodes.String(typ),
+		uast.KeyPos: uast.Positions{
+			uast.KeyStart: {Offset: start, Line: 1, Col: start + 1},
+			uast.KeyEnd:   {Offset: end, Line: 1, Col: end + 1},
+		}.ToObject(),
+	}
+}
+
+func TestLinkSameSpan(t *testing.T) {
+	// the map order is random, but the first child by key must own the tokens
+	for i := 0; i < 20; i++ {
+		a, b := spanned("A", 0, 1), spanned("B", 0, 1)
+		file := nodes.Object{
+			"b": b,
+			"a": a,
+			"c": nodes.Array{spanned("C", 0, 1)},
+			Key: nodes.Array{spanned("Token", 0, 1)},
+		}
+		Link(file)
+		if _, ok := a[Key]; !ok {
+			t.Fatalf("tokens are not linked to the first child: %v", file)
+		}
+	}
+}
+
+// sameSet compares lists regardless of the order.
+func sameSet(a, b []string) bool {
+	count := func(l []string) map[string]int {
+		m := make(map[string]int)
+		for _, s := range l {
+			m[s]++
+		}
+		return m
+	}
+	return reflect.DeepEqual(count(a), count(b))
+}
+
+func TestLinkNative(t *testing.T) {
+	ast, err := parser.NewDriver(0).WithTokens().Parse(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := ast.Clone()
+	dm := NewDriver(nativeModule{ast: ast})
+	got, err := dm.Parse(context.Background(), src, &driver.ParseOptions{Mode: driver.ModeNative})
+	if err != nil {
+		t.Fatal(err)
+	} else if !nodes.Equal(exp, got) {
+		t.Fatal("native AST was changed")
+	}
+}
+
+// nativeModule is a driver that returns the same AST.
+type nativeModule struct {
+	driver.DriverModule
+	ast nodes.Node
+}
+
+func (d nativeModule) Parse(ctx context.Context, src string, opts *driver.ParseOptions) (nodes.Node, error) {
+	return d.ast, nil
+}
@@ -0,0 +1,100 @@
+package walk
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ignoreFile is a parsed .gitignore file.
+type ignoreFile struct {
+	dir   string // relative to the root
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	re     *regexp.Regexp
+	negate bool
+	dir    bool // matches only directories
+}
+
+// readIgnoreFile reads a .gitignore file located in dir. It returns nil if the
+// file does not exist.
+func readIgnoreFile(name, dir string) (*ignoreFile, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	out := &ignoreFile{dir: dir}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		r, ok, err := parseIgnoreRule(sc.Text())
+		if err != nil {
+			return nil, err
+		} else if ok {
+			out.rules = append(out.rules, r)
+		}
+	}
+	return out, sc.Err()
+}
+
+// parseIgnoreRule parses a single line of a .gitignore file.
+// See https://git-scm.com/docs/gitignore#_pattern_format.
+func parseIgnoreRule(line string) (ignoreRule, bool, error) {
+	var r ignoreRule
+	line = strings.TrimSuffix(line, "\r")
+	// trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return r, false, nil
+	}
+	if line[0] == '!' {
+		r.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' && len(line) > 1 && (line[1] == '#' || line[1] == '!') {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dir = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return r, false, nil
+	}
+	anchored := strings.Contains(line, "/")
+	re, err := compileGlob(strings.TrimPrefix(line, "/"), anchored)
+	if err != nil {
+		return r, false, err
+	}
+	r.re = re
+	return r, true, nil
+}
+
+// isIgnored checks if a path relative to the root is ignored by any of the
+// files. Files must be ordered from the root to the deepest directory, since
+// the last matching rule wins.
+func isIgnored(files []*ignoreFile, rel string, isDir bool) bool {
+	ignored := false
+	for _, f := range files {
+		p := rel
+		if f.dir != "" {
+			p = strings.TrimPrefix(rel, f.dir+"/")
+		}
+		for _, r := range f.rules {
+			if r.dir && !isDir {
+				continue
+			}
+			if r.re.MatchString(p) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,180 @@
+// Package walk lists source files in a directory tree.
+//
+// Files are selected by include and exclude globs, and files ignored by
+// .gitignore files found in the tree are skipped.
+package walk
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultInclude is the list of globs for files parsed by the driver.
+var DefaultInclude = []string{"*.js", "*.jsx", "*.mjs", "*.cjs", "*.html", "*.htm", "*.vue", "*.svelte"}
+
+// Options control which files are listed.
+//
+// Globs support "*", "?", character classes and "**" for any number of
+// directories. A glob without a slash matches a base name at any depth,
+// otherwise it matches a path relative to the root.
+type Options struct {
+	// Include is a list of globs for files to list. DefaultInclude is used if empty.
+	Include []string
+	// Exclude is a list of globs for files and directories to skip.
+	Exclude []string
+	// NoGitIgnore disables .gitignore files.
+	NoGitIgnore bool
+}
+
+// Walk calls fn for each file in the root directory that matches the options.
+// The path passed to fn is relative to the root and uses forward slashes.
+// Directories are visited in lexical order.
+//
+// The .git directory and symbolic links are always skipped.
+func Walk(root string, opt Options, fn func(path string) error) error {
+	include := opt.Include
+	if len(include) == 0 {
+		include = DefaultInclude
+	}
+	w := &walker{root: root, fn: fn, git: !opt.NoGitIgnore}
+	var err error
+	if w.include, err = compileGlobs(include); err != nil {
+		return err
+	}
+	if w.exclude, err = compileGlobs(opt.Exclude); err != nil {
+		return err
+	}
+	return w.walk("", nil)
+}
+
+type walker struct {
+	root    string
+	fn      func(path string) error
+	git     bool
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+func (w *walker) walk(dir string, ignores []*ignoreFile) error {
+	abs := filepath.Join(w.root, filepath.FromSlash(dir))
+	if w.git {
+		f, err := readIgnoreFile(filepath.Join(abs, ".gitignore"), dir)
+		if err != nil {
+			return err
+		}
+		if f != nil {
+			ignores = append(ignores[:len(ignores):len(ignores)], f)
+		}
+	}
+	list, err := ioutil.ReadDir(abs)
+	if err != nil {
+		return err
+	}
+	for _, fi := range list {
+		name := fi.Name()
+		rel := path.Join(dir, name)
+		isDir := fi.IsDir()
+		switch {
+		case fi.Mode()&os.ModeSymlink != 0:
+			continue
+		case isDir && name == ".git":
+			continue
+		case !isDir && !fi.Mode().IsRegular():
+			continue
+		case matchAny(w.exclude, rel):
+			continue
+		case isIgnored(ignores, rel, isDir):
+			continue
+		}
+		if isDir {
+			err = w.walk(rel, ignores)
+		} else if matchAny(w.include, rel) {
+			err = w.fn(rel)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compileGlobs(globs []string) ([]*regexp.Regexp, error) {
+	out := make([]*regexp.Regexp, 0, len(globs))
+	for _, g := range globs {
+		g = strings.TrimSuffix(strings.TrimPrefix(g, "./"), "/")
+		re, err := compileGlob(strings.TrimPrefix(g, "/"), strings.Contains(g, "/"))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+func matchAny(list []*regexp.Regexp, rel string) bool {
+	for _, re := range list {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlob converts a glob to a regexp that matches slash-separated paths.
+// If the glob is not anchored, it matches a base name at any depth.
+func compileGlob(glob string, anchored bool) (*regexp.Regexp, error) {
+	var buf strings.Builder
+	buf.WriteString("^")
+	if !anchored {
+		buf.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**") {
+				rest := glob[i+2:]
+				switch {
+				case rest == "":
+					buf.WriteString(".*")
+				case rest[0] == '/':
+					buf.WriteString("(?:.*/)?")
+					i++
+				default:
+					buf.WriteString("[^/]*")
+				}
+				i++
+			} else {
+				buf.WriteString("[^/]*")
+			}
+		case '?':
+			buf.WriteString("[^/]")
+		case '[':
+			j := strings.IndexByte(glob[i+1:], ']')
+			if j < 0 {
+				buf.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += j + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				c = glob[i]
+			}
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	buf.WriteString("$")
+	return regexp.Compile(buf.String())
+}
@@ -0,0 +1,156 @@
+package walk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTree creates files in a temporary directory.
+func writeTree(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "walk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+var testTree = map[string]string{
+	".gitignore":               "# build output\n/dist/\n*.min.js\n!keep.min.js\nlogs\n",
+	"index.js":                 "",
+	"README.md":                "",
+	"keep.min.js":              "",
+	"app.min.js":               "",
+	"dist/bundle.js":           "",
+	"src/dist/code.js":         "",
+	"src/view.jsx":             "",
+	"src/lib.mjs":              "",
+	"src/logs/a.js":            "",
+	"src/gen/.gitignore":       "*.js\n!main.js\n",
+	"src/gen/main.js":          "",
+	"src/gen/out.js":           "",
+	"src/test/lib.test.js":     "",
+	"node_modules/left/lib.js": "",
+	".git/hooks/hook.js":       "",
+}
+
+func list(t *testing.T, root string, opt Options) []string {
+	var out []string
+	err := Walk(root, opt, func(path string) error {
+		out = append(out, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestWalk(t *testing.T) {
+	root := writeTree(t, testTree)
+	defer os.RemoveAll(root)
+
+	cases := []struct {
+		name string
+		opt  Options
+		exp  []string
+	}{
+		{
+			name: "default",
+			exp: []string{
+				"index.js",
+				"keep.min.js",
+				"node_modules/left/lib.js",
+				"src/dist/code.js",
+				"src/gen/main.js",
+				"src/lib.mjs",
+				"src/test/lib.test.js",
+				"src/view.jsx",
+			},
+		},
+		{
+			name: "exclude",
+			opt:  Options{Exclude: []string{"node_modules/", "**/*.test.js", "src/dist"}},
+			exp: []string{
+				"index.js",
+				"keep.min.js",
+				"src/gen/main.js",
+				"src/lib.mjs",
+				"src/view.jsx",
+			},
+		},
+		{
+			name: "include",
+			opt:  Options{Include: []string{"src/**/*.js", "*.md"}, Exclude: []string{"node_modules"}},
+			exp: []string{
+				"README.md",
+				"src/dist/code.js",
+				"src/gen/main.js",
+				"src/test/lib.test.js",
+			},
+		},
+		{
+			name: "no gitignore",
+			opt:  Options{NoGitIgnore: true, Exclude: []string{"node_modules", "src"}},
+			exp: []string{
+				"app.min.js",
+				"dist/bundle.js",
+				"index.js",
+				"keep.min.js",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := list(t, root, c.opt)
+			if !reflect.DeepEqual(got, c.exp) {
+				t.Fatalf("unexpected files:\n%q\nexpected:\n%q", got, c.exp)
+			}
+		})
+	}
+}
+
+func TestGlob(t *testing.T) {
+	cases := []struct {
+		glob     string
+		anchored bool
+		path     string
+		exp      bool
+	}{
+		{"*.js", false, "a.js", true},
+		{"*.js", false, "a/b/c.js", true},
+		{"*.js", false, "a.jsx", false},
+		{"a/*.js", true, "a/b.js", true},
+		{"a/*.js", true, "x/a/b.js", false},
+		{"a/*.js", true, "a/b/c.js", false},
+		{"a/**/*.js", true, "a/b.js", true},
+		{"a/**/*.js", true, "a/b/c/d.js", true},
+		{"a/**", true, "a/b/c", true},
+		{"**/b", true, "a/c/b", true},
+		{"?.js", false, "ab.js", false},
+		{"[ab].js", false, "b.js", true},
+		{"[!ab].js", false, "b.js", false},
+		{`\*.js`, false, "*.js", true},
+		{`\*.js`, false, "a.js", false},
+	}
+	for _, c := range cases {
+		re, err := compileGlob(c.glob, c.anchored)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := re.MatchString(c.path); got != c.exp {
+			t.Errorf("%q matching %q: got %v, expected %v", c.glob, c.path, got, c.exp)
+		}
+	}
+}
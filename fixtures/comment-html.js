@@ -0,0 +1,5 @@
+var a = b <!-- HTML-like comment
+--> closing comment
+// line comment
+/* block
+   comment */
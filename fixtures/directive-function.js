@@ -0,0 +1,13 @@
+function f() {
+  "use strict";
+  return 1;
+}
+
+const g = () => {
+  "use asm";
+  'another';
+};
+
+function h() {
+  "not a directive" + 1;
+}
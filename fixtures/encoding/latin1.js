@@ -0,0 +1,5 @@
+// Gre aus Kln, encoded in Latin-1
+var caf = 'cafe';
+function gre() {
+  return .length + 1;
+}
@@ -0,0 +1,3 @@
+﻿// UTF-8 with a byte order mark: ünïcödé
+var s = "ünïcödé";
+var t = 'ok';
@@ -0,0 +1,3 @@
+// Smart quotes and the  sign, encoded in Windows-1252
+var uvre = "oeuvre";
+var prix = 10; // 10 
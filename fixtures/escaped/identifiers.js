@@ -0,0 +1 @@
+function f(\u0061bc: number, d\u{65}f?: string, ghi?) {}
@@ -1 +1,5 @@
-"𝓏"
\ No newline at end of file
+"𝓏"
+var a = "😀"; // 𝒳 astral
+var b = 1;var c = "é"; var d = 2; var e = `x
+y😀`;
+/* block 😀 comment */ var 𝒳 = a;
@@ -2,4 +2,5 @@
 SCRIPT=index.js
 BIN="`readlink -f $0`"
 DIR="`dirname "$BIN"`"
-exec node "$DIR/$SCRIPT"
\ No newline at end of file
+# JS_DRIVER_MAX_HEAP limits the heap of the parser, in megabytes
+exec node ${JS_DRIVER_MAX_HEAP:+--max-old-space-size=$JS_DRIVER_MAX_HEAP} "$DIR/$SCRIPT"
\ No newline at end of file
@@ -1,15 +1,29 @@
-import { guessParsing, GuessParsingError } from './parser';
+import { compactTokens, guessParsing, GuessParsingError } from './parser';
+import { checkSize, checkTree, limitsFromEnv, TooComplexError } from './limits';
 import { error, ok } from './response';
 
-function parse(data) {
+const LIMITS = limitsFromEnv(process.env);
+// the same variable enables the token stream in the Go driver
+const TOKENS = /^(1|t|true)$/i.test(process.env.JS_DRIVER_TOKENS || '');
+
+function parse(data, limits, tokens) {
   try {
     let { content } = JSON.parse(data);
-    let ast = guessParsing(content);
+    checkSize(content, limits);
+    let ast = guessParsing(content, tokens);
+    if (tokens) {
+      ast.tokens = compactTokens(ast.tokens);
+    }
+    // the tree must be checked before it is serialized recursively
+    checkTree(ast, limits);
     return ok(ast);
   } catch (ex) {
     if (ex instanceof GuessParsingError) {
       return error(...ex.allMessages);
     }
+    if (ex instanceof TooComplexError) {
+      return error([`${ex.code}: ${ex.reason}`]);
+    }
     return error([ex.message]);
   }
 }
@@ -18,6 +32,6 @@ function jsonify(data) {
   return `${JSON.stringify(data)}`;
 }
 
-export function handler(input) {
-  return jsonify(parse(input));
+export function handler(input, limits = LIMITS, tokens = TOKENS) {
+  return jsonify(parse(input, limits, tokens));
 }
@@ -0,0 +1,84 @@
+// Limits protect the parser process from inputs that would exhaust its
+// stack or heap. The same environment variables are used by the Go driver.
+// All limits are disabled unless they are set in the environment.
+export const DEFAULT_LIMITS = {
+  maxSize: 0, // bytes of source
+  maxNodes: 0,
+  maxDepth: 0,
+};
+
+// TOO_COMPLEX prefixes the error of a response for inputs that exceed one of
+// the limits. The Go driver maps such errors to limits.ErrTooComplex.
+export const TOO_COMPLEX = 'E_TOO_COMPLEX';
+
+export class TooComplexError extends Error {
+  constructor(reason) {
+    super(`too complex: ${reason}`);
+
+    this.code = TOO_COMPLEX;
+    this.reason = reason;
+  }
+}
+
+function envInt(env, name, def) {
+  let v = env[name];
+  if (v === undefined || v === '') {
+    return def;
+  }
+  let n = parseInt(v, 10);
+  // zero or negative disables the limit
+  return isNaN(n) ? def : n;
+}
+
+export function limitsFromEnv(env) {
+  return {
+    maxSize: envInt(env, 'JS_DRIVER_MAX_SIZE', DEFAULT_LIMITS.maxSize),
+    maxNodes: envInt(env, 'JS_DRIVER_MAX_NODES', DEFAULT_LIMITS.maxNodes),
+    maxDepth: envInt(env, 'JS_DRIVER_MAX_DEPTH', DEFAULT_LIMITS.maxDepth),
+  };
+}
+
+export function checkSize(content, limits) {
+  let size = Buffer.byteLength(content, 'utf8');
+  if (limits.maxSize > 0 && size > limits.maxSize) {
+    throw new TooComplexError(`source size ${size} exceeds ${limits.maxSize} bytes`);
+  }
+}
+
+function isNode(v) {
+  return v !== null && typeof v === 'object' && !Array.isArray(v) && typeof v.type === 'string';
+}
+
+// checkTree counts AST nodes and their nesting depth. Only objects with a type
+// are counted, locations and other properties are not. The walk is iterative,
+// thus it works for trees that are too deep for a recursive walk.
+export function checkTree(ast, limits) {
+  let count = 0;
+  let stack = [[ast, 0]];
+  while (stack.length > 0) {
+    let [v, depth] = stack.pop();
+    if (v === null || typeof v !== 'object') {
+      continue;
+    }
+    if (isNode(v)) {
+      depth++;
+      count++;
+      if (limits.maxNodes > 0 && count > limits.maxNodes) {
+        throw new TooComplexError(`number of nodes exceeds ${limits.maxNodes}`);
+      }
+      if (limits.maxDepth > 0 && depth > limits.maxDepth) {
+        throw new TooComplexError(`nesting depth exceeds ${limits.maxDepth}`);
+      }
+    }
+    for (let k in v) {
+      let c = v[k];
+      if (c !== null && typeof c === 'object') {
+        stack.push([c, depth]);
+      }
+    }
+  }
+}
+
+export function isStackOverflow(ex) {
+  return ex instanceof RangeError && /call stack/i.test(ex.message);
+}
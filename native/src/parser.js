@@ -1,3 +1,5 @@
+import { TooComplexError, isStackOverflow } from './limits';
+
 const babylon = require('@babel/parser');
 
 export const ALL_PLUGINS = [
@@ -34,8 +36,8 @@ export function parseExpression(code, option) {
 }
 
 const GUESSING_ORDER = [
-  [parse, { sourceType: 'module', allowImportExportEverywhere: true, tokens: false }],
-  [parse, { sourceType: 'script', allowReturnOutsideFunction: true, tokens: false }],
+  [parse, { sourceType: 'module', allowImportExportEverywhere: true }],
+  [parse, { sourceType: 'script', allowReturnOutsideFunction: true }],
 ];
 
 export class GuessParsingError extends Error {
@@ -46,15 +48,38 @@ export class GuessParsingError extends Error {
   }
 }
 
-export function guessParsing(code) {
+export function guessParsing(code, tokens = false) {
   let exceptions = [];
   for (let [fn, opts] of GUESSING_ORDER) {
     try {
-      return fn(code, opts);
+      return fn(code, Object.assign({ tokens }, opts));
     } catch (ex) {
+      if (isStackOverflow(ex)) {
+        // other parsing modes would fail the same way
+        throw new TooComplexError('nesting is too deep for the parser');
+      }
       exceptions.push(ex)
     }
   }
   throw new GuessParsingError(exceptions.map((x) => x.message));
 }
 
+function compactLoc(loc) {
+  return {
+    start: { line: loc.start.line, column: loc.start.column },
+    end: { line: loc.end.line, column: loc.end.column },
+  };
+}
+
+// compactTokens converts Babel tokens to plain nodes. Token types are shared
+// objects with parser internals, only their labels are kept. Comments are
+// labeled by their node type.
+export function compactTokens(tokens) {
+  return tokens.map((t) => ({
+    type: 'Token',
+    label: typeof t.type === 'string' ? t.type : t.type.label,
+    start: t.start,
+    end: t.end,
+    loc: compactLoc(t.loc),
+  }));
+}
@@ -30,3 +30,18 @@ test('accepts module syntax', t => {
   t.is(resp.status, "ok");
   t.true("ast" in resp);
 });
+
+test('returns tokens if enabled', t => {
+  let resp = JSON.parse(handler(request("a = 1; // c"), undefined, true));
+
+  t.is(resp.status, "ok");
+  let labels = resp.ast.tokens.map((tok) => tok.label);
+  t.deepEqual(labels, ["name", "=", "num", ";", "CommentLine", "eof"]);
+  t.deepEqual(resp.ast.tokens[1].loc, { start: { line: 1, column: 2 }, end: { line: 1, column: 3 } });
+});
+
+test('returns no tokens by default', t => {
+  let resp = responseFor("a = 1;");
+
+  t.false("tokens" in resp.ast);
+});
@@ -0,0 +1,64 @@
+import test from 'ava';
+import { checkSize, checkTree, handler, limitsFromEnv, DEFAULT_LIMITS, TOO_COMPLEX, TooComplexError } from '../lib';
+
+function nested(depth) {
+  let node = { type: 'NumericLiteral', value: 1 };
+  for (let i = 0; i < depth; i++) {
+    node = { type: 'ArrayExpression', elements: [node], loc: { start: { line: 1, column: i } } };
+  }
+  return node;
+}
+
+test('reads limits from the environment', t => {
+  let limits = limitsFromEnv({ JS_DRIVER_MAX_DEPTH: '10', JS_DRIVER_MAX_NODES: 'x' });
+
+  t.is(limits.maxDepth, 10);
+  t.is(limits.maxNodes, DEFAULT_LIMITS.maxNodes, 'invalid values are ignored');
+  t.is(limits.maxSize, DEFAULT_LIMITS.maxSize);
+});
+
+test('disables the limits by default', t => {
+  let limits = limitsFromEnv({});
+
+  t.notThrows(() => checkSize('a'.repeat(32 * 1024 * 1024), limits));
+  t.notThrows(() => checkTree(nested(100000), limits));
+});
+
+test('rejects large sources', t => {
+  let limits = { maxSize: 4 };
+
+  t.notThrows(() => checkSize('a+b', limits));
+  let err = t.throws(() => checkSize('ä+b;', limits), TooComplexError);
+  t.true(err.message.startsWith('too complex:'));
+  t.is(err.code, TOO_COMPLEX);
+});
+
+test('counts only typed nodes in the nesting depth', t => {
+  let limits = { maxDepth: 11, maxNodes: 0 };
+
+  t.notThrows(() => checkTree(nested(10), limits));
+  t.throws(() => checkTree(nested(11), limits), TooComplexError);
+});
+
+test('checks very deep trees without recursion', t => {
+  t.throws(() => checkTree(nested(1000000), { maxDepth: 5000 }), /nesting depth/);
+  t.notThrows(() => checkTree(nested(1000000), { maxDepth: 0 }));
+});
+
+test('limits the number of nodes', t => {
+  let ast = { type: 'Program', body: [] };
+  for (let i = 0; i < 10; i++) {
+    ast.body.push({ type: 'EmptyStatement' });
+  }
+
+  t.notThrows(() => checkTree(ast, { maxNodes: 11 }));
+  t.throws(() => checkTree(ast, { maxNodes: 10 }), /number of nodes/);
+});
+
+test('returns an error response for complex input', t => {
+  let resp = JSON.parse(handler(JSON.stringify({ content: '[[[1]]]' }), { maxDepth: 3 }));
+
+  t.is(resp.status, 'error');
+  t.is(resp.errors.length, 1);
+  t.is(resp.errors[0], `${TOO_COMPLEX}: nesting depth exceeds 3`);
+});